@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/scraper"
+	"github.com/HeidiZHH/skull/internal/summarizer"
+	"github.com/rs/zerolog"
+)
+
+// runBriefing scrapes and summarizes every URL listed in urlsFile and writes a
+// markdown report to reportPath, or to out when reportPath is empty, reporting
+// progress to progress. Failed URLs are noted in the report without aborting the
+// run. With chunked, each page is streamed through the map-reduce summarizer
+// instead of being scraped whole. Without an API key, pages are summarized with
+// summarizer.Extractive, and chunked is ignored.
+func runBriefing(ctx context.Context, logger zerolog.Logger, out, progress io.Writer, urlsFile, reportPath string, summary summaryOptions, chunked bool) error {
+	urls, err := readURLList(urlsFile)
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("no URLs found in %s", urlsFile)
+	}
+
+	apiKey, baseURL, model, err := llmSettingsFromEnv()
+	if err != nil && !errors.Is(err, errNoAPIKey) {
+		return err
+	}
+	scraperService, err := scraper.NewService(scraper.Config{
+		UserAgent:   "skull-agent/1.0",
+		Timeout:     30 * time.Second,
+		MaxRetries:  3,
+		RateLimit:   1 * time.Second,
+		MaxBodySize: 10 * 1024 * 1024, // 10MB
+	}, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create scraper: %w", err)
+	}
+	// Without an API key the briefing still runs, with extractive summaries
+	var summarizerService *summarizer.Service
+	if apiKey != "" {
+		summarizerService, err = summarizer.NewService(summarizer.Config{
+			Provider:  "openai",
+			APIKey:    apiKey,
+			BaseURL:   baseURL,
+			Model:     model,
+			MaxTokens: 1000,
+		}, logger)
+		if err != nil {
+			return fmt.Errorf("failed to create summarizer: %w", err)
+		}
+	} else {
+		fmt.Fprintf(progress, "🔒 OPENAI_API_KEY is not set; writing extractive summaries instead.\n")
+		if chunked {
+			fmt.Fprintf(progress, "🔒 --chunked needs an LLM; scraping each page whole.\n")
+			chunked = false
+		}
+	}
+
+	var results []*scraper.Result
+	var summaries []*summarizer.Response
+	var summaryErrs []error
+	if chunked {
+		results, summaries, summaryErrs = summarizeChunked(ctx, progress, scraperService, summarizerService, urls, summary)
+	} else {
+		results, summaries, summaryErrs = summarizeScraped(ctx, logger, progress, scraperService, summarizerService, urls, summary)
+	}
+
+	if reportPath != "" {
+		file, err := os.Create(reportPath)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	writeBriefing(out, urls, results, summaries, summaryErrs)
+	if reportPath != "" {
+		fmt.Fprintf(progress, "🧾 Report written to %s\n", reportPath)
+	}
+	return nil
+}
+
+// summarizeScraped scrapes every URL, then summarizes the pages that scraped successfully
+func summarizeScraped(ctx context.Context, logger zerolog.Logger, progress io.Writer, scraperService *scraper.Service, summarizerService *summarizer.Service, urls []string, summary summaryOptions) ([]*scraper.Result, []*summarizer.Response, []error) {
+	fmt.Fprintf(progress, "🌐 Scraping %d URL(s)...\n", len(urls))
+	results, scrapeErr := scraperService.ScrapeMultiple(ctx, urls, "")
+	if scrapeErr != nil {
+		logger.Warn().Err(scrapeErr).Msg("Some URLs failed to scrape")
+	}
+
+	var requests []summarizer.Request
+	var requestIndex []int
+	for i, result := range results {
+		if result != nil && strings.TrimSpace(result.CleanText) != "" {
+			requests = append(requests, summarizer.Request{
+				Content:   result.CleanText,
+				ImageURL:  result.MainImage,
+				Style:     summary.Style,
+				MaxLength: summary.MaxLength,
+			})
+			requestIndex = append(requestIndex, i)
+		}
+	}
+	fmt.Fprintf(progress, "🧠 Summarizing %d page(s)...\n", len(requests))
+	summaries := make([]*summarizer.Response, len(urls))
+	summaryErrs := make([]error, len(urls))
+	if summarizerService == nil {
+		for j, req := range requests {
+			summaries[requestIndex[j]] = extractiveSummary(req)
+		}
+		return results, summaries, summaryErrs
+	}
+	for batchResult := range summarizerService.SummarizeBatchStream(ctx, requests) {
+		i := requestIndex[batchResult.Index]
+		summaries[i], summaryErrs[i] = batchResult.Response, batchResult.Err
+	}
+	return results, summaries, summaryErrs
+}
+
+// extractiveModel is the Response.Model of summaries written without an LLM
+const extractiveModel = "extractive"
+
+// extractiveSummary summarizes req's content with summarizer.Extractive
+func extractiveSummary(req summarizer.Request) *summarizer.Response {
+	summary := summarizer.Extractive(req.Content, req.MaxLength)
+	return &summarizer.Response{
+		Summary:      summary,
+		OriginalSize: len(req.Content),
+		SummarySize:  len(summary),
+		Model:        extractiveModel,
+	}
+}
+
+// summarizeChunked streams each page's text straight into the map-reduce
+// summarizer one URL at a time, so no page is ever held whole. Each page's scrape
+// result has only its URL, title, status, and word count, and fetch failures are
+// reported as summary errors.
+func summarizeChunked(ctx context.Context, progress io.Writer, scraperService *scraper.Service, summarizerService *summarizer.Service, urls []string, summary summaryOptions) ([]*scraper.Result, []*summarizer.Response, []error) {
+	results := make([]*scraper.Result, len(urls))
+	summaries := make([]*summarizer.Response, len(urls))
+	summaryErrs := make([]error, len(urls))
+	for i, url := range urls {
+		fmt.Fprintf(progress, "🧠 Streaming %s into the summarizer (%d/%d)...\n", url, i+1, len(urls))
+		var info scraper.StreamInfo
+		req := summarizer.Request{Style: summary.Style, MaxLength: summary.MaxLength}
+		summaries[i], summaryErrs[i] = summarizerService.SummarizeChunks(ctx, req, scraperService.TextChunks(ctx, url, 0, &info))
+		results[i] = &scraper.Result{URL: url, Title: info.Title, WordCount: info.WordCount}
+		if info.Response != nil {
+			results[i].StatusCode = info.Response.StatusCode
+		}
+	}
+	return results, summaries, summaryErrs
+}
+
+// readURLList reads one URL per line, skipping blank lines and # comments. Bare
+// domains are given an https:// scheme; lines that are not URLs are reported.
+func readURLList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open URL list: %w", err)
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		url, err := scraper.NormalizeURL(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		urls = append(urls, url)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URL list: %w", err)
+	}
+	return urls, nil
+}
+
+// writeBriefing renders the combined markdown report, one section per URL in input order
+func writeBriefing(w io.Writer, urls []string, results []*scraper.Result, summaries []*summarizer.Response, summaryErrs []error) {
+	fmt.Fprintf(w, "# Briefing\n\n_Generated %s from %d URL(s)_\n\n", time.Now().Format("2006-01-02 15:04"), len(urls))
+	for _, summary := range summaries {
+		if summary != nil && summary.Model == extractiveModel {
+			fmt.Fprintf(w, "_Summaries are extractive: set OPENAI_API_KEY for LLM summaries._\n\n")
+			break
+		}
+	}
+	for i, url := range urls {
+		title := url
+		if results[i] != nil && results[i].Title != "" {
+			title = results[i].Title
+		}
+		fmt.Fprintf(w, "## %s\n\n<%s>\n\n", title, url)
+
+		switch {
+		case results[i] == nil:
+			fmt.Fprintf(w, "> ⚠️ Failed to scrape this page.\n\n")
+		case summaryErrs[i] != nil:
+			fmt.Fprintf(w, "> ⚠️ Failed to summarize: %v\n\n", summaryErrs[i])
+		case summaries[i] == nil:
+			fmt.Fprintf(w, "> ⚠️ The page returned no extractable text.\n\n")
+		default:
+			fmt.Fprintf(w, "%s\n\n", summaries[i].Summary)
+		}
+	}
+}
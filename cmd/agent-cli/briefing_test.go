@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// servePages serves each path's text as an HTML article and 404s anything else
+func servePages(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>Page %s</title></head><body><article><p>%s</p></article></body></html>", r.URL.Path, text)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeURLList writes lines to a URL list file and returns its path
+func writeURLList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunBriefing(t *testing.T) {
+	pages := servePages(t, map[string]string{"/one": longText, "/two": longText})
+	llm := newFakeLLM(t, "A summary of the page.")
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", llm.URL+"/v1")
+	t.Setenv("OPENAI_MODEL", "test-model")
+
+	urlsFile := writeURLList(t,
+		"# research list",
+		pages.URL+"/one",
+		"",
+		pages.URL+"/missing",
+		pages.URL+"/two",
+	)
+	reportPath := filepath.Join(t.TempDir(), "report.md")
+
+	if err := runBriefing(context.Background(), zerolog.Nop(), io.Discard, io.Discard, urlsFile, reportPath, defaultSummaryOptions, false); err != nil {
+		t.Fatalf("runBriefing: %v", err)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(data)
+
+	// One section per URL, in input order, with the failure noted in place
+	sections := strings.Split(report, "\n## ")[1:]
+	if len(sections) != 3 {
+		t.Fatalf("report has %d sections, want 3:\n%s", len(sections), report)
+	}
+	wants := []struct{ url, body string }{
+		{pages.URL + "/one", "A summary of the page."},
+		{pages.URL + "/missing", "Failed to scrape"},
+		{pages.URL + "/two", "A summary of the page."},
+	}
+	for i, want := range wants {
+		if !strings.Contains(sections[i], "<"+want.url+">") || !strings.Contains(sections[i], want.body) {
+			t.Errorf("section %d = %q, want %s with %q", i+1, sections[i], want.url, want.body)
+		}
+	}
+}
+
+func TestRunBriefingWritesToWriters(t *testing.T) {
+	pages := servePages(t, map[string]string{"/one": longText})
+	llm := newFakeLLM(t, "A summary of the page.")
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", llm.URL+"/v1")
+	t.Setenv("OPENAI_MODEL", "test-model")
+	urlsFile := writeURLList(t, pages.URL+"/one")
+
+	var report, progress bytes.Buffer
+	stdout := captureStdout(t, func() {
+		if err := runBriefing(context.Background(), zerolog.Nop(), &report, &progress, urlsFile, "", defaultSummaryOptions, false); err != nil {
+			t.Errorf("runBriefing: %v", err)
+		}
+	})
+	if stdout != "" {
+		t.Errorf("runBriefing wrote to stdout:\n%s", stdout)
+	}
+	if !strings.HasPrefix(report.String(), "# Briefing") || !strings.Contains(report.String(), "A summary of the page.") {
+		t.Errorf("report = %q, want the briefing", report.String())
+	}
+	if !strings.Contains(progress.String(), "🌐 Scraping 1 URL(s)...") || strings.Contains(progress.String(), "# Briefing") {
+		t.Errorf("progress = %q, want only the progress lines", progress.String())
+	}
+}
+
+func TestRunBriefingChunked(t *testing.T) {
+	pages := servePages(t, map[string]string{"/one": longText})
+	llm := newFakeLLM(t, "A summary of the page.")
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", llm.URL+"/v1")
+	t.Setenv("OPENAI_MODEL", "test-model")
+	urlsFile := writeURLList(t, pages.URL+"/one", pages.URL+"/missing")
+
+	var report bytes.Buffer
+	if err := runBriefing(context.Background(), zerolog.Nop(), &report, io.Discard, urlsFile, "", defaultSummaryOptions, true); err != nil {
+		t.Fatalf("runBriefing: %v", err)
+	}
+	sections := strings.Split(report.String(), "\n## ")[1:]
+	if len(sections) != 2 {
+		t.Fatalf("report has %d sections, want 2:\n%s", len(sections), report.String())
+	}
+	if !strings.HasPrefix(sections[0], "Page /one\n") || !strings.Contains(sections[0], "A summary of the page.") {
+		t.Errorf("section 1 = %q, want the streamed page under its title", sections[0])
+	}
+	if !strings.Contains(sections[1], "Failed to summarize") || !strings.Contains(sections[1], "404") {
+		t.Errorf("section 2 = %q, want the fetch failure", sections[1])
+	}
+}
+
+func TestRunBriefingWithoutAPIKey(t *testing.T) {
+	pages := servePages(t, map[string]string{"/one": longText})
+	t.Setenv("OPENAI_API_KEY", "")
+	urlsFile := writeURLList(t, pages.URL+"/one")
+
+	for _, chunked := range []bool{false, true} {
+		var report, progress bytes.Buffer
+		if err := runBriefing(context.Background(), zerolog.Nop(), &report, &progress, urlsFile, "", defaultSummaryOptions, chunked); err != nil {
+			t.Fatalf("chunked=%v: runBriefing: %v", chunked, err)
+		}
+		if !strings.Contains(report.String(), "The quick brown fox jumps over the lazy dog") {
+			t.Errorf("chunked=%v: report = %q, want an extractive summary of the page", chunked, report.String())
+		}
+		if !strings.Contains(report.String(), "Summaries are extractive") {
+			t.Errorf("chunked=%v: report = %q, want the extractive note", chunked, report.String())
+		}
+		if !strings.Contains(progress.String(), "OPENAI_API_KEY is not set") {
+			t.Errorf("chunked=%v: progress = %q, want the missing key noted", chunked, progress.String())
+		}
+		if got := strings.Contains(progress.String(), "--chunked needs an LLM"); got != chunked {
+			t.Errorf("chunked=%v: progress = %q, chunked fallback noted = %v", chunked, progress.String(), got)
+		}
+	}
+}
+
+func TestReadURLList(t *testing.T) {
+	path := writeURLList(t, "# comment", "", "  https://example.com/a  ", "example.org/b")
+	urls, err := readURLList(path)
+	if err != nil {
+		t.Fatalf("readURLList: %v", err)
+	}
+	want := []string{"https://example.com/a", "https://example.org/b"}
+	if strings.Join(urls, " ") != strings.Join(want, " ") {
+		t.Errorf("urls = %q, want %q", urls, want)
+	}
+
+	if _, err := readURLList(writeURLList(t, "https://example.com", "not a url")); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("err = %v, want the bad line reported", err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/HeidiZHH/skull/internal/agent"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// llmTools are the MCP tools that spend LLM tokens on the server, so they count
+// against the session budget; the scrape tools do not
+var llmTools = map[string]bool{"summarize": true}
+
+// sessionBudget caps what a CLI session may spend on LLM calls, in tokens or USD.
+// Once it is spent, inputs are served scrape-only and LLM features are refused.
+type sessionBudget struct {
+	maxTokens int
+	maxUSD    float64
+	// pricePerMillion is the blended USD price per million tokens used to cost
+	// the agent's calls, and tool calls that report no cost of their own
+	pricePerMillion float64
+	// carried holds the usage of agents replaced by ":model" or ":provider"
+	carried agent.Usage
+	// toolTokens and toolUSD are spent by LLM-backed tools such as summarize
+	toolTokens int
+	toolUSD    float64
+}
+
+// parseBudget reads a --budget value: a token count such as "50000" or "50k", or a
+// USD amount such as "$0.50" or "0.5usd". An empty value means no budget.
+func parseBudget(value string, pricePerMillion float64) (*sessionBudget, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return nil, nil
+	}
+	if pricePerMillion < 0 {
+		return nil, fmt.Errorf("price per million tokens must not be negative (got %g)", pricePerMillion)
+	}
+	budget := &sessionBudget{pricePerMillion: pricePerMillion}
+
+	if amount, ok := strings.CutPrefix(value, "$"); ok || strings.HasSuffix(value, "usd") {
+		amount = strings.TrimSpace(strings.TrimSuffix(amount, "usd"))
+		usd, err := strconv.ParseFloat(amount, 64)
+		if err != nil || usd <= 0 {
+			return nil, fmt.Errorf("invalid USD budget %q: expected a positive amount such as $0.50", value)
+		}
+		if pricePerMillion == 0 {
+			return nil, fmt.Errorf("a USD budget needs a token price to cost the agent's calls: set --price-per-million")
+		}
+		budget.maxUSD = usd
+		return budget, nil
+	}
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "k"):
+		multiplier, value = 1_000, strings.TrimSuffix(value, "k")
+	case strings.HasSuffix(value, "m"):
+		multiplier, value = 1_000_000, strings.TrimSuffix(value, "m")
+	}
+	tokens, err := strconv.ParseFloat(strings.TrimSuffix(value, "tokens"), 64)
+	if err != nil || tokens*multiplier < 1 {
+		return nil, fmt.Errorf("invalid token budget %q: expected a positive count such as 50000 or 50k, or a USD amount such as $0.50", value)
+	}
+	budget.maxTokens = int(tokens * multiplier)
+	return budget, nil
+}
+
+// spent totals the session's tokens and their estimated USD cost, including the current agent's
+func (b *sessionBudget) spent(current *agent.Agent) (tokens int, usd float64) {
+	agentTokens := b.carried.TotalTokens
+	if current != nil {
+		agentTokens += current.Usage().TotalTokens
+	}
+	return agentTokens + b.toolTokens, float64(agentTokens)*b.pricePerMillion/1_000_000 + b.toolUSD
+}
+
+// exhausted reports whether the session has spent its budget
+func (b *sessionBudget) exhausted(current *agent.Agent) bool {
+	tokens, usd := b.spent(current)
+	if b.maxUSD > 0 {
+		return usd >= b.maxUSD
+	}
+	return tokens >= b.maxTokens
+}
+
+// limit describes the budget itself, e.g. "50000 tokens" or "$0.50"
+func (b *sessionBudget) limit() string {
+	if b.maxUSD > 0 {
+		return fmt.Sprintf("$%.2f", b.maxUSD)
+	}
+	return fmt.Sprintf("%d tokens", b.maxTokens)
+}
+
+// describe reports the spend against the budget, e.g. "51200 of 50000 tokens"
+func (b *sessionBudget) describe(current *agent.Agent) string {
+	tokens, usd := b.spent(current)
+	if b.maxUSD > 0 {
+		return fmt.Sprintf("$%.4f of $%.4f (%d tokens)", usd, b.maxUSD, tokens)
+	}
+	return fmt.Sprintf("%d of %d tokens", tokens, b.maxTokens)
+}
+
+// recordToolUsage counts the tokens and cost an LLM-backed tool reports in its
+// structured result, as the summarize tool does
+func (b *sessionBudget) recordToolUsage(res *mcp.CallToolResult) {
+	if res == nil || res.StructuredContent == nil {
+		return
+	}
+	encoded, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		return
+	}
+	var usage struct {
+		TokensUsed       int     `json:"tokens_used"`
+		EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+	}
+	if json.Unmarshal(encoded, &usage) != nil {
+		return
+	}
+	b.recordSpend(usage.TokensUsed, usage.EstimatedCostUSD)
+}
+
+// recordSpend counts tokens spent outside the agent, costing them at
+// pricePerMillion when no cost is known
+func (b *sessionBudget) recordSpend(tokens int, usd float64) {
+	b.toolTokens += tokens
+	if usd > 0 {
+		b.toolUSD += usd
+	} else {
+		b.toolUSD += float64(tokens) * b.pricePerMillion / 1_000_000
+	}
+}
+
+// overBudget reports whether the session has a budget and has spent it
+func (cli *AgentCLI) overBudget() bool {
+	return cli.budget != nil && cli.budget.exhausted(cli.agent)
+}
+
+// withinBudget reports whether an LLM operation may run, explaining the refusal
+// when the session budget is spent. Without a budget everything may run.
+func (cli *AgentCLI) withinBudget(operation string) bool {
+	if !cli.overBudget() {
+		return true
+	}
+	fmt.Fprintf(cli.out, "💸 %s skipped: the session budget is spent (%s). Scraping still works.\n", operation, cli.budget.describe(cli.agent))
+	return false
+}
+
+// handleOverBudget serves an input once the budget is spent: URLs in it are
+// scraped without the LLM, as in scrape-only mode
+func (cli *AgentCLI) handleOverBudget(ctx context.Context, userInput string, turn *transcriptEntry) error {
+	fmt.Fprintf(cli.out, "💸 The session budget is spent (%s); LLM calls are disabled for the rest of the session.\n", cli.budget.describe(cli.agent))
+	if cli.scraper == nil {
+		scraperService, err := newScrapeOnlyScraper(cli)
+		if err != nil {
+			return fmt.Errorf("failed to create scraper: %w", err)
+		}
+		cli.scraper = scraperService
+	}
+	return cli.handleScrapeOnly(ctx, userInput, turn)
+}
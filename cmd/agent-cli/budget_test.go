@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/scraper"
+	"github.com/rs/zerolog"
+)
+
+func TestBudgetHaltsLLMCalls(t *testing.T) {
+	tools := newFakeTools(t, map[string]string{"https://example.com": longText})
+	llm := newFakeLLM(t, plan("scrape_url", map[string]any{"url": "https://example.com"}, "Summarize the page"), "A summary.")
+	// The first plan alone spends the 100-token budget: the fake LLM reports 150 tokens a call
+	input := "summarize https://example.com\n:recap\nexample.com/news\nexit\n"
+	cli, out := newTestCLI(t, llm, tools, input)
+	budget, err := parseBudget("100", 0)
+	if err != nil {
+		t.Fatalf("parseBudget: %v", err)
+	}
+	cli.budget = budget
+
+	// Over budget, inputs are scraped directly rather than through the agent's tools
+	var requested []string
+	scraperService, err := scraper.NewService(scraper.Config{
+		Timeout: 5 * time.Second,
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requested = append(requested, req.URL.String())
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+				Body:       io.NopCloser(strings.NewReader("<html><head><title>News</title></head><body><p>" + longText + "</p></body></html>")),
+				Request:    req,
+			}, nil
+		}),
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("scraper.NewService: %v", err)
+	}
+	cli.scraper = scraperService
+
+	if err := cli.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	output := out.String()
+	for _, want := range []string{
+		"💸 Session budget: LLM calls stop after 100 tokens.",
+		"💸 Post-processing skipped: the session budget is spent (150 of 100 tokens). Scraping still works.",
+		"💸 Recapping the session skipped: the session budget is spent",
+		"💸 The session budget is spent (150 of 100 tokens); LLM calls are disabled for the rest of the session.",
+		"🧾 Extractive summary (raise --budget for LLM summaries)",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %q:\n%s", want, output)
+		}
+	}
+
+	if requests := llm.Requests(); len(requests) != 1 {
+		t.Errorf("LLM calls = %d, want only the plan made before the budget was spent", len(requests))
+	}
+	if calls := tools.Calls(); len(calls) != 1 || calls[0] != "scrape_url https://example.com" {
+		t.Errorf("tool calls = %q, want the planned scrape", calls)
+	}
+	if len(requested) != 1 || requested[0] != "https://example.com/news" {
+		t.Errorf("scraped %q over budget, want https://example.com/news", requested)
+	}
+}
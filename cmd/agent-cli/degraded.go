@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/scraper"
+	"github.com/HeidiZHH/skull/internal/summarizer"
+)
+
+// errNoAPIKey reports that no OPENAI_API_KEY is set; the CLI then runs in scrape-only mode
+var errNoAPIKey = errors.New("OPENAI_API_KEY environment variable is required")
+
+// urlPattern finds the URLs in scrape-only input
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// newScrapeOnlyScraper creates the scraper used directly when there is no agent
+func newScrapeOnlyScraper(cli *AgentCLI) (*scraper.Service, error) {
+	return scraper.NewService(scraper.Config{
+		UserAgent:   "skull-agent/1.0",
+		Timeout:     30 * time.Second,
+		MaxRetries:  3,
+		RateLimit:   1 * time.Second,
+		MaxBodySize: 10 * 1024 * 1024, // 10MB
+	}, cli.logger)
+}
+
+// requireAgent reports whether an LLM-backed feature can run, explaining how to
+// enable it when the CLI is in scrape-only mode
+func (cli *AgentCLI) requireAgent(feature string) bool {
+	if cli.agent != nil {
+		return true
+	}
+	fmt.Fprintf(cli.out, "🔒 %s needs an LLM: set OPENAI_API_KEY to enable it.\n\n", feature)
+	return false
+}
+
+// handleScrapeOnly serves an input without the agent: it scrapes every URL in the
+// input, or the input itself when it is a bare domain such as "example.com", and
+// prints an extractive summary of each page
+func (cli *AgentCLI) handleScrapeOnly(ctx context.Context, userInput string, turn *transcriptEntry) error {
+	urls := urlPattern.FindAllString(userInput, -1)
+	if len(urls) == 0 {
+		if url, err := scraper.NormalizeURL(userInput); err == nil {
+			urls = []string{url}
+		}
+	}
+	// Over budget there is an agent, just no more spend for it
+	enableLLM := "set OPENAI_API_KEY"
+	if cli.agent != nil {
+		enableLLM = "raise --budget"
+	}
+	if len(urls) == 0 {
+		fmt.Fprintf(cli.out, "🔒 Understanding requests needs an LLM: %s to enable it. Without one, paste a URL to scrape it.\n\n", enableLLM)
+		return nil
+	}
+
+	for _, url := range urls {
+		url = strings.TrimRight(url, ".,;:!?)")
+		fmt.Fprintf(cli.out, "🌐 Scraping %s...\n", url)
+		result, err := cli.scraper.ScrapeURL(ctx, url, "")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			fmt.Fprintf(cli.out, "❌ Scrape failed: %v\n\n", err)
+			continue
+		}
+
+		summary := summarizer.Extractive(result.CleanText, cli.summary.MaxLength)
+		output := fmt.Sprintf("Title: %s\nWords: %d\n\n%s", result.Title, result.WordCount, summary)
+		fmt.Fprintf(cli.out, "✅ %s\n\n🧾 Extractive summary (%s for LLM summaries):\n%s\n\n", url, enableLLM, output)
+		turn.Results = append(turn.Results, output)
+	}
+	return nil
+}
@@ -3,41 +3,109 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/HeidiZHH/skull/internal/agent"
+	"github.com/HeidiZHH/skull/internal/scraper"
+	"github.com/HeidiZHH/skull/internal/summarizer"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/rs/zerolog"
 )
 
 // AgentCLI provides an interactive command-line interface
 type AgentCLI struct {
-	agent  *agent.Agent
-	logger zerolog.Logger
+	// agent is nil in scrape-only mode, when no API key is set
+	agent *agent.Agent
+	// scraper scrapes pages directly in scrape-only mode
+	scraper *scraper.Service
+	// summarizer streams summaries of tool output with --stream; nil in scrape-only mode
+	summarizer *summarizer.Service
+	// agentConfig built the current agent; ":model" and ":provider" rebuild from it
+	agentConfig agent.Config
+	logger      zerolog.Logger
+	input       *bufio.Scanner
+	// out receives everything the CLI prints for the user; logs go to the logger
+	out io.Writer
+	// approveTools asks for confirmation before each tool call is executed
+	approveTools bool
+	// interactive is set when a user is at the prompt to answer questions
+	interactive bool
+	// recorder writes a JSONL transcript of each input when --record is set
+	recorder *json.Encoder
+	// minSummaryWords skips post-processing of tool output shorter than this many words
+	minSummaryWords int
+	// stream shows tool progress and prints post-processed output token by token as it is generated
+	stream bool
+	// summary shapes summaries; changed with --style/--max-length or ":set"
+	summary summaryOptions
+	// call overrides the agent's reply budget per input; changed with ":set"
+	call agent.CallOptions
+	// lastResults holds the full tool results of the last input, for ":full"
+	lastResults []string
+	// budget caps the session's LLM spend when --budget is set; nil means no cap
+	budget *sessionBudget
+
+	// cancelCurrent cancels the input being processed; nil while at the prompt
+	mu            sync.Mutex
+	cancelCurrent context.CancelFunc
 }
 
-// NewAgentCLI creates a new CLI instance
-func NewAgentCLI(logger zerolog.Logger) (*AgentCLI, error) {
-	// Require OPENAI_API_KEY; used for OpenAI-compatible providers (including DeepSeek)
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable is required")
-	}
+// summaryOptions controls the style and length of summaries
+type summaryOptions struct {
+	Style     string
+	MaxLength int
+}
 
-	// Initialize agent
-	baseURL := os.Getenv("OPENAI_BASE_URL")
+// defaultSummaryOptions matches the summarizer's own defaults
+var defaultSummaryOptions = summaryOptions{Style: "concise", MaxLength: 200}
+
+// interruptWindow is how soon a second Ctrl-C must follow the first to exit the CLI
+const interruptWindow = 2 * time.Second
+
+// llmSettingsFromEnv reads the OpenAI-compatible endpoint settings shared by the agent
+// and summarizer. Without an API key it returns errNoAPIKey along with the endpoint
+// and model, which the CLI keeps for switching providers later.
+func llmSettingsFromEnv() (apiKey, baseURL, model string, err error) {
+	baseURL = strings.TrimSpace(os.Getenv("OPENAI_BASE_URL"))
 	if baseURL == "" {
 		// Default to DeepSeek's OpenAI-compatible endpoint if not provided
 		baseURL = "https://api.deepseek.com/v1"
 	}
-	model := getEnvDefault("OPENAI_MODEL", "gpt-3.5-turbo")
+	model = getEnvDefault("OPENAI_MODEL", "gpt-3.5-turbo")
 	if strings.Contains(strings.ToLower(baseURL), "deepseek.com") && os.Getenv("OPENAI_MODEL") == "" {
 		model = "deepseek-chat"
 	}
+
+	// Require OPENAI_API_KEY; used for OpenAI-compatible providers (including DeepSeek)
+	apiKey = os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return "", baseURL, model, errNoAPIKey
+	}
+	return apiKey, baseURL, model, nil
+}
+
+// NewAgentCLI creates a new CLI instance. Tool results longer than
+// condenseToolOutput characters are shown condensed; zero always shows them whole.
+func NewAgentCLI(logger zerolog.Logger, condenseToolOutput int) (*AgentCLI, error) {
+	apiKey, baseURL, model, err := llmSettingsFromEnv()
+	if err != nil && !errors.Is(err, errNoAPIKey) {
+		return nil, err
+	}
+
+	// Initialize agent
 	agentConfig := agent.Config{
 		Provider:    "openai",
 		APIKey:      apiKey,
@@ -46,37 +114,87 @@ func NewAgentCLI(logger zerolog.Logger) (*AgentCLI, error) {
 		MaxTokens:   1000,
 		Temperature: 0.2,
 		MCPServer:   os.Getenv("MCP_SERVER"), // e.g. http://localhost:8080
+
+		CondenseToolOutput: condenseToolOutput,
+	}
+	cli := &AgentCLI{
+		agentConfig: agentConfig,
+		logger:      logger,
+		input:       bufio.NewScanner(os.Stdin),
+		out:         os.Stdout,
+		summary:     defaultSummaryOptions,
 	}
-	agentService := agent.NewAgent(agentConfig, logger)
 
-	return &AgentCLI{
-		agent:  agentService,
-		logger: logger,
-	}, nil
+	// Without a key, start in scrape-only mode rather than refusing to run
+	if apiKey == "" {
+		logger.Warn().Msg("OPENAI_API_KEY is not set; starting in scrape-only mode")
+		if cli.scraper, err = newScrapeOnlyScraper(cli); err != nil {
+			return nil, fmt.Errorf("failed to create scraper: %w", err)
+		}
+		return cli, nil
+	}
+
+	if cli.agent, err = agent.NewAgent(agentConfig, logger); err != nil {
+		return nil, fmt.Errorf("failed to create agent: %w", err)
+	}
+	if cli.summarizer, err = newSummarizer(agentConfig, logger); err != nil {
+		return nil, fmt.Errorf("failed to create summarizer: %w", err)
+	}
+	return cli, nil
+}
+
+// newSummarizer creates a summarizer on the agent's LLM endpoint and model
+func newSummarizer(config agent.Config, logger zerolog.Logger) (*summarizer.Service, error) {
+	return summarizer.NewService(summarizer.Config{
+		Provider:  config.Provider,
+		APIKey:    config.APIKey,
+		BaseURL:   config.BaseURL,
+		Model:     config.Model,
+		MaxTokens: 1000,
+	}, logger)
 }
 
 // Run starts the interactive CLI
 func (cli *AgentCLI) Run(ctx context.Context) error {
-	fmt.Println("🧠 Skull AI Agent - Web Scraping & Summarization Assistant")
-	fmt.Println("=========================================================")
-	fmt.Println()
-	fmt.Println("I can help you with:")
-	fmt.Println("• Scraping content from websites")
-	fmt.Println("• Summarizing text content")
-	fmt.Println("• Getting summaries of web pages")
-	fmt.Println()
-	fmt.Println("Examples:")
-	fmt.Println("• \"Scrape content from https://example.com\"")
-	fmt.Println("• \"Summarize this webpage: https://news.example.com\"")
-	fmt.Println("• \"Get me a summary of the latest news from https://blog.example.com\"")
-	fmt.Println()
-	fmt.Println("Type 'exit' or 'quit' to stop.")
-	fmt.Println()
-
-	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Fprintln(cli.out, "🧠 Skull AI Agent - Web Scraping & Summarization Assistant")
+	fmt.Fprintln(cli.out, "=========================================================")
+	fmt.Fprintln(cli.out)
+	fmt.Fprintln(cli.out, "I can help you with:")
+	fmt.Fprintln(cli.out, "• Scraping content from websites")
+	fmt.Fprintln(cli.out, "• Summarizing text content")
+	fmt.Fprintln(cli.out, "• Getting summaries of web pages")
+	fmt.Fprintln(cli.out)
+	fmt.Fprintln(cli.out, "Examples:")
+	fmt.Fprintln(cli.out, "• \"Scrape content from https://example.com\"")
+	fmt.Fprintln(cli.out, "• \"Summarize this webpage: https://news.example.com\"")
+	fmt.Fprintln(cli.out, "• \"Get me a summary of the latest news from https://blog.example.com\"")
+	fmt.Fprintln(cli.out)
+	fmt.Fprintln(cli.out, "Type ':tool <name>' to see a tool's parameters.")
+	fmt.Fprintln(cli.out, "Type ':set style <style>' or ':set max-length <words>' to shape summaries.")
+	fmt.Fprintln(cli.out, "Type ':set max-tokens <n>' or ':set temperature <t>' to give the agent a different budget.")
+	fmt.Fprintln(cli.out, "Type ':recap' for a summary of this session, or ':full' to see the last tool results uncondensed.")
+	fmt.Fprintln(cli.out, "Type ':model <name>' or ':provider <"+strings.Join(providerNames(), "|")+">' to switch LLMs mid-session.")
+	fmt.Fprintln(cli.out, "Type 'exit' or 'quit' to stop. Ctrl-C cancels the current request; press it twice to exit.")
+	fmt.Fprintln(cli.out)
+	if cli.agent == nil {
+		fmt.Fprintln(cli.out, "⚠️  Scrape-only mode: set OPENAI_API_KEY to enable the agent and LLM summaries.")
+		fmt.Fprintln(cli.out, "   Paste a URL to scrape it and get an extractive summary.")
+		fmt.Fprintln(cli.out)
+	}
+	if cli.budget != nil {
+		fmt.Fprintf(cli.out, "💸 Session budget: LLM calls stop after %s.\n\n", cli.budget.limit())
+	}
+
+	// Ctrl-C cancels the in-flight request instead of killing the CLI
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	go cli.handleInterrupts(sigCh)
+
+	scanner := cli.input
 
 	for {
-		fmt.Print("🤖 You: ")
+		fmt.Fprint(cli.out, "🤖 You: ")
 
 		if !scanner.Scan() {
 			break
@@ -89,13 +207,42 @@ func (cli *AgentCLI) Run(ctx context.Context) error {
 		}
 
 		if strings.ToLower(userInput) == "exit" || strings.ToLower(userInput) == "quit" {
-			fmt.Println("👋 Goodbye!")
+			fmt.Fprintln(cli.out, "👋 Goodbye!")
 			break
 		}
 
-		// Process the user input with the agent
-		if err := cli.processUserInput(ctx, userInput); err != nil {
-			fmt.Printf("❌ Error: %v\n\n", err)
+		if fields := strings.Fields(userInput); fields[0] == ":tool" {
+			cli.explainTool(strings.TrimSpace(strings.TrimPrefix(userInput, ":tool")))
+			continue
+		} else if fields[0] == ":set" {
+			cli.setOption(fields[1:])
+			continue
+		} else if fields[0] == ":full" {
+			cli.showFullResults()
+			continue
+		} else if fields[0] == ":recap" {
+			cli.recap(ctx)
+			continue
+		} else if fields[0] == ":model" {
+			cli.switchModel(fields[1:])
+			continue
+		} else if fields[0] == ":provider" {
+			cli.switchProvider(fields[1:])
+			continue
+		}
+
+		// Process the user input with the agent under a cancellable context
+		inputCtx, cancel := context.WithCancel(ctx)
+		cli.setCancel(cancel)
+		err := cli.processUserInput(inputCtx, userInput)
+		cli.setCancel(nil)
+		cancel()
+		if err != nil {
+			if errors.Is(inputCtx.Err(), context.Canceled) && ctx.Err() == nil {
+				fmt.Fprintf(cli.out, "⛔ Cancelled\n\n")
+			} else {
+				fmt.Fprintf(cli.out, "❌ Error: %v\n\n", err)
+			}
 		}
 	}
 
@@ -106,55 +253,375 @@ func (cli *AgentCLI) Run(ctx context.Context) error {
 	return nil
 }
 
-// processUserInput handles a single user input
+// explainTool prints a tool's description and parameter schema
+func (cli *AgentCLI) explainTool(name string) {
+	if !cli.requireAgent("Listing tools") {
+		return
+	}
+	if name == "" {
+		var names []string
+		for _, tool := range cli.agent.Tools() {
+			names = append(names, tool.Name)
+		}
+		fmt.Fprintf(cli.out, "Usage: :tool <name>  (available: %s)\n\n", strings.Join(names, ", "))
+		return
+	}
+	description, err := cli.agent.DescribeTool(name)
+	if err != nil {
+		fmt.Fprintf(cli.out, "❌ Error: %v\n\n", err)
+		return
+	}
+	fmt.Fprintf(cli.out, "🛠️  %s\n", description)
+}
+
+// setOption handles ":set <option> <value>", printing the current settings when no option is given
+func (cli *AgentCLI) setOption(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(cli.out, "style=%s max-length=%d max-tokens=%s temperature=%s\n\n",
+			cli.summary.Style, cli.summary.MaxLength, cli.callMaxTokens(), cli.callTemperature())
+		return
+	}
+	if len(args) != 2 {
+		fmt.Fprintf(cli.out, "Usage: :set style <%s> | :set max-length <words> | :set max-tokens <n|default> | :set temperature <0-2|default>\n\n", strings.Join(summarizer.Styles, "|"))
+		return
+	}
+
+	switch args[0] {
+	case "style":
+		if err := summarizer.ValidateStyle(args[1]); err != nil {
+			fmt.Fprintf(cli.out, "❌ Error: %v\n\n", err)
+			return
+		}
+		cli.summary.Style = args[1]
+	case "max-length":
+		maxLength, err := strconv.Atoi(args[1])
+		if err != nil || maxLength <= 0 {
+			fmt.Fprintf(cli.out, "❌ Error: max-length must be a positive number of words\n\n")
+			return
+		}
+		cli.summary.MaxLength = maxLength
+	case "max-tokens":
+		call := cli.call
+		call.MaxTokens = 0
+		if args[1] != "default" {
+			maxTokens, err := strconv.Atoi(args[1])
+			if err != nil || maxTokens <= 0 {
+				fmt.Fprintf(cli.out, "❌ Error: max-tokens must be a positive number or \"default\"\n\n")
+				return
+			}
+			call.MaxTokens = maxTokens
+		}
+		cli.call = call
+		fmt.Fprintf(cli.out, "✅ Agent replies will use max-tokens=%s\n\n", cli.callMaxTokens())
+		return
+	case "temperature":
+		call := cli.call
+		call.Temperature = nil
+		if args[1] != "default" {
+			temperature, err := strconv.ParseFloat(args[1], 32)
+			if err != nil {
+				fmt.Fprintf(cli.out, "❌ Error: temperature must be a number or \"default\"\n\n")
+				return
+			}
+			t := float32(temperature)
+			call.Temperature = &t
+		}
+		if err := call.Validate(); err != nil {
+			fmt.Fprintf(cli.out, "❌ Error: %v\n\n", err)
+			return
+		}
+		cli.call = call
+		fmt.Fprintf(cli.out, "✅ Agent replies will use temperature=%s\n\n", cli.callTemperature())
+		return
+	default:
+		fmt.Fprintf(cli.out, "❌ Error: unknown option %q (available: style, max-length, max-tokens, temperature)\n\n", args[0])
+		return
+	}
+	fmt.Fprintf(cli.out, "✅ Summaries will use style=%s max-length=%d\n\n", cli.summary.Style, cli.summary.MaxLength)
+}
+
+// showFullResults prints the full tool results of the last input, which the
+// output may have shown condensed
+func (cli *AgentCLI) showFullResults() {
+	if len(cli.lastResults) == 0 {
+		fmt.Fprintf(cli.out, "No tool results yet.\n\n")
+		return
+	}
+	for i, result := range cli.lastResults {
+		fmt.Fprintf(cli.out, "📄 Result %d/%d:\n%s\n\n", i+1, len(cli.lastResults), result)
+	}
+}
+
+// recap prints a summary of the session so far
+func (cli *AgentCLI) recap(ctx context.Context) {
+	if !cli.requireAgent("Recapping the session") || !cli.withinBudget("Recapping the session") {
+		return
+	}
+	fmt.Fprintf(cli.out, "🤔 Thinking...\n")
+	recap, err := cli.agent.SessionSummary(ctx)
+	if err != nil {
+		fmt.Fprintf(cli.out, "❌ Error: %v\n\n", err)
+		return
+	}
+	if recap == "" {
+		fmt.Fprintf(cli.out, "Nothing to recap yet.\n\n")
+		return
+	}
+	fmt.Fprintf(cli.out, "📋 Session recap:\n%s\n\n", recap)
+}
+
+// callMaxTokens describes the per-input max-tokens override for display
+func (cli *AgentCLI) callMaxTokens() string {
+	if cli.call.MaxTokens == 0 {
+		return "default"
+	}
+	return strconv.Itoa(cli.call.MaxTokens)
+}
+
+// callTemperature describes the per-input temperature override for display
+func (cli *AgentCLI) callTemperature() string {
+	if cli.call.Temperature == nil {
+		return "default"
+	}
+	return strconv.FormatFloat(float64(*cli.call.Temperature), 'g', -1, 32)
+}
+
+// summaryInstruction adds the summarizer's instructions for the chosen summary
+// style and length to a summarizing post-process instruction; other
+// instructions are returned unchanged
+func (cli *AgentCLI) summaryInstruction(instruction string) string {
+	if !isSummaryInstruction(instruction) {
+		return instruction
+	}
+	return fmt.Sprintf("%s. %s", strings.TrimRight(instruction, ". "), summarizer.Instructions(cli.summaryRequest("")))
+}
+
+// summaryRequest is a summarizer request for content in the session's style and length
+func (cli *AgentCLI) summaryRequest(content string) summarizer.Request {
+	return summarizer.Request{Content: content, Style: cli.summary.Style, MaxLength: cli.summary.MaxLength}
+}
+
+// isSummaryInstruction reports whether a post-process instruction asks for a summary
+func isSummaryInstruction(instruction string) bool {
+	return strings.Contains(strings.ToLower(instruction), "summar")
+}
+
+// setCancel records the cancel function of the input currently being processed
+func (cli *AgentCLI) setCancel(cancel context.CancelFunc) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	cli.cancelCurrent = cancel
+}
+
+// handleInterrupts cancels the in-flight request on Ctrl-C and exits when a second
+// Ctrl-C arrives within interruptWindow
+func (cli *AgentCLI) handleInterrupts(sigCh <-chan os.Signal) {
+	var last time.Time
+	for range sigCh {
+		if !last.IsZero() && time.Since(last) < interruptWindow {
+			fmt.Fprintln(cli.out, "\n👋 Goodbye!")
+			os.Exit(130)
+		}
+		last = time.Now()
+
+		cli.mu.Lock()
+		cancel := cli.cancelCurrent
+		cli.mu.Unlock()
+		if cancel != nil {
+			cancel()
+			fmt.Fprintln(cli.out, "\n⛔ Cancelling current request (press Ctrl-C again to exit)")
+		} else {
+			fmt.Fprintln(cli.out, "\n(Press Ctrl-C again to exit)")
+		}
+	}
+}
+
+// transcriptEntry is one input and its outcome in a --record transcript (JSONL)
+type transcriptEntry struct {
+	Time     time.Time       `json:"time"`
+	Input    string          `json:"input"`
+	Response *agent.Response `json:"response,omitempty"`
+	Results  []string        `json:"results,omitempty"`
+	Output   string          `json:"output,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// record appends an entry to the transcript, if recording
+func (cli *AgentCLI) record(entry *transcriptEntry) {
+	if cli.recorder == nil {
+		return
+	}
+	if err := cli.recorder.Encode(entry); err != nil {
+		cli.logger.Warn().Err(err).Msg("Failed to record transcript entry")
+	}
+}
+
+// Replay feeds the inputs of a recorded transcript back through the CLI in order
+func (cli *AgentCLI) Replay(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for n := 1; ; n++ {
+		var entry transcriptEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read transcript entry %d: %w", n, err)
+		}
+		fmt.Fprintf(cli.out, "🔁 Replay %d: %s\n", n, entry.Input)
+		if err := cli.processUserInput(ctx, entry.Input); err != nil {
+			fmt.Fprintf(cli.out, "❌ Error: %v\n\n", err)
+		}
+	}
+}
+
+// sameFile reports whether two paths name the same file, following symlinks when
+// both exist and comparing absolute paths otherwise
+func sameFile(a, b string) bool {
+	if infoA, err := os.Stat(a); err == nil {
+		if infoB, err := os.Stat(b); err == nil {
+			return os.SameFile(infoA, infoB)
+		}
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// processUserInput handles a single user input, recording it when a transcript is open
 func (cli *AgentCLI) processUserInput(ctx context.Context, userInput string) error {
-	fmt.Printf("🤔 Thinking...\n")
+	turn := &transcriptEntry{Time: time.Now(), Input: userInput}
+	err := cli.handleInput(ctx, userInput, turn)
+	cli.lastResults = turn.Results
+	if err != nil {
+		turn.Error = err.Error()
+	}
+	cli.record(turn)
+	return err
+}
+
+// handleInput runs one input through the agent and its tools, filling in turn
+func (cli *AgentCLI) handleInput(ctx context.Context, userInput string, turn *transcriptEntry) error {
+	if cli.agent == nil {
+		return cli.handleScrapeOnly(ctx, userInput, turn)
+	}
+	if cli.overBudget() {
+		return cli.handleOverBudget(ctx, userInput, turn)
+	}
+	fmt.Fprintf(cli.out, "🤔 Thinking...\n")
 
 	// Let the agent analyze the input
-	response, err := cli.agent.ProcessInput(ctx, userInput)
+	response, err := cli.agent.ProcessInput(ctx, userInput, &cli.call)
 	if err != nil {
 		return fmt.Errorf("agent processing failed: %w", err)
 	}
 
+	// Turn ambiguity into a dialog; the agent's memory carries the original request
+	for round := 0; response.NeedsClarification && round < maxClarificationRounds; round++ {
+		answer, ok := cli.askClarification(response)
+		if !ok || !cli.withinBudget("Following up on your answer") {
+			return nil
+		}
+		userInput = userInput + "\n" + answer
+		fmt.Fprintf(cli.out, "🤔 Thinking...\n")
+		response, err = cli.agent.ProcessInput(ctx, answer, &cli.call)
+		if err != nil {
+			return fmt.Errorf("agent processing failed: %w", err)
+		}
+	}
+
+	// Validate the whole plan up front; invalid tool calls are skipped below
+	validationErr := cli.agent.ValidateResponse(response)
+	turn.Response = response
+
 	// Show the agent's understanding
-	fmt.Printf("🧠 Agent: %s\n", response.Message)
+	fmt.Fprintf(cli.out, "🧠 Agent: %s\n", response.Message)
+
+	if response.ToolsUnavailable {
+		fmt.Fprintf(cli.out, "⚠️  Tools unavailable (%s); I can only answer from my own knowledge.\n", cli.agent.ToolsUnavailableReason())
+	}
 
 	if response.Confidence < 0.5 {
-		fmt.Printf("⚠️  Confidence: %.1f%% - I'm not very confident about this interpretation.\n", response.Confidence*100)
+		fmt.Fprintf(cli.out, "⚠️  Confidence: %.1f%% - I'm not very confident about this interpretation.\n", response.Confidence*100)
 	}
 
 	// If no tools should be called, we're done
 	if !response.ShouldCall || len(response.ToolCalls) == 0 {
-		fmt.Printf("💭 %s\n\n", response.Explanation)
+		fmt.Fprintf(cli.out, "💭 %s\n\n", response.Explanation)
 		return nil
 	}
 
+	if validationErr != nil {
+		fmt.Fprintf(cli.out, "⚠️  Some planned tool calls are invalid and will be skipped:\n%v\n", validationErr)
+	}
+
 	// Execute tool calls
-	fmt.Printf("🔧 Executing %d tool(s)...\n", len(response.ToolCalls))
+	fmt.Fprintf(cli.out, "🔧 Executing %d tool(s)...\n", len(response.ToolCalls))
 
-	// Aggregate raw outputs to feed into post-processing
+	// Aggregate raw outputs to feed into post-processing, with the amount of page
+	// content they hold and whether the scraper flagged all of it as low content
 	var aggregated []string
+	words, lowContent := 0, true
 
 	for i, toolCall := range response.ToolCalls {
-		fmt.Printf("\n🛠️  Tool %d/%d: %s\n", i+1, len(response.ToolCalls), toolCall.Name)
-		fmt.Printf("📝 Reasoning: %s\n", toolCall.Reasoning)
+		fmt.Fprintf(cli.out, "\n🛠️  Tool %d/%d: %s\n", i+1, len(response.ToolCalls), toolCall.Name)
+		fmt.Fprintf(cli.out, "📝 Reasoning: %s\n", toolCall.Reasoning)
 
 		// Validate the tool call
 		if err := cli.agent.ValidateToolCall(toolCall); err != nil {
-			fmt.Printf("❌ Validation failed: %v\n", err)
+			fmt.Fprintf(cli.out, "❌ Skipped: invalid tool call\n")
+			continue
+		}
+
+		if llmTools[toolCall.Name] && !cli.withinBudget("The "+toolCall.Name+" tool") {
+			continue
+		}
+
+		if cli.approveTools && !cli.confirmToolCall(toolCall) {
+			fmt.Fprintf(cli.out, "⏭️  Skipped %s\n", toolCall.Name)
 			continue
 		}
 
 		// Execute the tool call
-		result, err := cli.executeToolCall(ctx, toolCall)
+		result, res, err := cli.executeToolCall(ctx, toolCall)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
-			fmt.Printf("❌ Execution failed: %v\n", err)
+			fmt.Fprintf(cli.out, "❌ Execution failed: %v\n", err)
 			continue
 		}
 
-		fmt.Printf("✅ Result: %s\n", result)
+		// Show big results condensed; the full text still feeds post-processing and ":full"
+		shown, condensed := result, false
+		if !cli.overBudget() {
+			shown, condensed, err = cli.agent.CondenseToolResult(ctx, toolCall.Name, userInput, result)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				cli.logger.Warn().Err(err).Msg("Showing the full tool result")
+			}
+		}
+		if condensed {
+			fmt.Fprintf(cli.out, "✅ Result (condensed from %d characters; ':full' shows it all): %s\n", utf8.RuneCountInString(result), shown)
+		} else {
+			fmt.Fprintf(cli.out, "✅ Result: %s\n", result)
+		}
+		turn.Results = append(turn.Results, result)
 		if strings.TrimSpace(result) != "" {
 			aggregated = append(aggregated, result)
+			if n, low, ok := pageWords(res); ok {
+				words += n
+				lowContent = lowContent && low
+			} else {
+				words += len(strings.Fields(result))
+				lowContent = false
+			}
 		}
 	}
 
@@ -162,35 +629,172 @@ func (cli *AgentCLI) processUserInput(ctx context.Context, userInput string) err
 	if response.PostProcess != "" {
 		// Use aggregated tool outputs for post-processing
 		content := strings.TrimSpace(strings.Join(aggregated, "\n\n"))
-		if content != "" {
-			fmt.Printf("\n🧪 Post-processing: %s...\n", response.PostProcess)
-			final, err := cli.agent.PostProcess(ctx, response.PostProcess, userInput, content)
+		if content != "" && (lowContent || words < cli.minSummaryWords) {
+			fmt.Fprintf(cli.out, "\n📄 Skipping %s: the content has only %d words, too little to be worth it. Raw text is shown above.\n\n", response.PostProcess, words)
+		} else if content != "" && cli.withinBudget("Post-processing") {
+			fmt.Fprintf(cli.out, "\n🧪 Post-processing: %s...\n", response.PostProcess)
+			final, err := cli.postProcess(ctx, response.PostProcess, userInput, content)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			if err != nil {
-				fmt.Printf("⚠️  Post-process failed: %v\n\n", err)
+				fmt.Fprintf(cli.out, "⚠️  Post-process failed: %v\n\n", err)
 			} else {
-				fmt.Printf("\n🧾 Final Output:\n%s\n\n", final)
+				turn.Output = final
 			}
 		}
 	}
 
-	fmt.Println()
+	fmt.Fprintln(cli.out)
 	return nil
 }
 
-// executeToolCall executes a specific tool call
-func (cli *AgentCLI) executeToolCall(ctx context.Context, toolCall agent.ToolCall) (string, error) {
-	// Route all tool calls to the agent's reusable MCP session
-	res, err := cli.agent.CallToolRemote(ctx, toolCall.Name, toolCall.Arguments)
+// postProcess runs the agent's post-processing step and prints the final output,
+// streaming it as it is generated when --stream is set. Streamed summaries come
+// from the summarizer in the session's style.
+func (cli *AgentCLI) postProcess(ctx context.Context, instruction, userInput, content string) (string, error) {
+	if cli.stream && cli.summarizer != nil && isSummaryInstruction(instruction) {
+		return cli.streamSummary(ctx, content)
+	}
+	instruction = cli.summaryInstruction(instruction)
+	if !cli.stream {
+		final, err := cli.agent.PostProcess(ctx, instruction, userInput, content)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(cli.out, "\n🧾 Final Output:\n%s\n\n", final)
+		return final, nil
+	}
+
+	fmt.Fprintf(cli.out, "\n🧾 Final Output:\n")
+	final, err := cli.agent.PostProcessStream(ctx, instruction, userInput, content, func(token string) {
+		fmt.Fprint(cli.out, token)
+	})
+	fmt.Fprintf(cli.out, "\n\n")
+	return final, err
+}
+
+// streamSummary summarizes content in the session's style, printing the summary as it is generated
+func (cli *AgentCLI) streamSummary(ctx context.Context, content string) (string, error) {
+	fmt.Fprintf(cli.out, "\n🧾 Final Output:\n")
+	resp, err := cli.summarizer.SummarizeStream(ctx, cli.summaryRequest(content), func(token string) {
+		fmt.Fprint(cli.out, token)
+	})
+	fmt.Fprintf(cli.out, "\n\n")
 	if err != nil {
 		return "", err
 	}
+	if cli.budget != nil {
+		cli.budget.recordSpend(resp.TokensUsed, resp.EstimatedCostUSD)
+	}
+	return resp.Summary, nil
+}
+
+// maxClarificationRounds bounds how many clarifying questions are asked for one input
+const maxClarificationRounds = 3
+
+// askClarification shows the agent's clarifying question and reads the user's answer.
+// It returns false when no answer is available, e.g. in single-run mode.
+func (cli *AgentCLI) askClarification(response *agent.Response) (string, bool) {
+	question := strings.TrimSpace(response.ClarifyingQuestion)
+	if question == "" {
+		question = response.Message
+	}
+	fmt.Fprintf(cli.out, "❔ Agent: %s\n", question)
+	if !cli.interactive {
+		return "", false
+	}
+	fmt.Fprint(cli.out, "🤖 You: ")
+	if !cli.input.Scan() {
+		return "", false
+	}
+	answer := strings.TrimSpace(cli.input.Text())
+	return answer, answer != ""
+}
+
+// confirmToolCall shows a planned tool call and asks the user whether to run it
+func (cli *AgentCLI) confirmToolCall(toolCall agent.ToolCall) bool {
+	args, err := json.MarshalIndent(toolCall.Arguments, "   ", "  ")
+	if err != nil {
+		args = []byte(fmt.Sprintf("%v", toolCall.Arguments))
+	}
+	fmt.Fprintf(cli.out, "🔐 About to run %s with arguments:\n   %s\n", toolCall.Name, string(args))
+	fmt.Fprint(cli.out, "❓ Execute this tool? [y/N]: ")
+
+	if !cli.input.Scan() {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(cli.input.Text()))
+	return answer == "y" || answer == "yes"
+}
+
+// executeToolCall executes a specific tool call
+func (cli *AgentCLI) executeToolCall(ctx context.Context, toolCall agent.ToolCall) (string, *mcp.CallToolResult, error) {
+	// Route all tool calls to the agent's reusable MCP session, showing progress
+	// (such as each URL of scrape_urls finishing) as it comes in when streaming
+	var res *mcp.CallToolResult
+	var err error
+	if cli.stream {
+		res, err = cli.agent.CallToolRemoteWithProgress(ctx, toolCall.Name, toolCall.Arguments, cli.printProgress)
+	} else {
+		res, err = cli.agent.CallToolRemote(ctx, toolCall.Name, toolCall.Arguments)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if cli.budget != nil && llmTools[toolCall.Name] {
+		cli.budget.recordToolUsage(res)
+	}
 	var msgParts []string
 	for _, c := range res.Content {
 		if tc, ok := c.(*mcp.TextContent); ok {
 			msgParts = append(msgParts, tc.Text)
 		}
 	}
-	return strings.Join(msgParts, "\n\n"), nil
+	return strings.Join(msgParts, "\n\n"), res, nil
+}
+
+// printProgress shows a tool's progress notification. The scrape tools report each
+// finished URL as a JSON item; other messages are shown as they are.
+func (cli *AgentCLI) printProgress(progress agent.ToolProgress) {
+	step := fmt.Sprintf("%g", progress.Progress)
+	if progress.Total > 0 {
+		step += fmt.Sprintf("/%g", progress.Total)
+	}
+	var item struct {
+		URL   string `json:"url"`
+		Title string `json:"title"`
+		Error string `json:"error"`
+	}
+	switch {
+	case json.Unmarshal([]byte(progress.Message), &item) != nil || item.URL == "":
+		fmt.Fprintf(cli.out, "   ⏳ %s %s\n", step, progress.Message)
+	case item.Error != "":
+		fmt.Fprintf(cli.out, "   ⚠️  %s %s failed: %s\n", step, item.URL, item.Error)
+	default:
+		fmt.Fprintf(cli.out, "   ⏳ %s %s: %s\n", step, item.URL, item.Title)
+	}
+}
+
+// pageWords reads the scraper's content-quality signal, the word_count and
+// low_content fields scrape_url reports in its structured result. ok is false
+// for results without them.
+func pageWords(res *mcp.CallToolResult) (words int, lowContent, ok bool) {
+	if res == nil || res.StructuredContent == nil {
+		return 0, false, false
+	}
+	encoded, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		return 0, false, false
+	}
+	var page struct {
+		WordCount  *int `json:"word_count"`
+		LowContent bool `json:"low_content"`
+	}
+	if json.Unmarshal(encoded, &page) != nil || page.WordCount == nil {
+		return 0, false, false
+	}
+	return *page.WordCount, page.LowContent, true
 }
 
 // executeRemoteTool removed; we rely on the agent's CallToolRemote
@@ -211,16 +815,74 @@ func main() {
 
 	// Optional single-run input flag for non-interactive testing
 	input := flag.String("input", "", "Process a single input then exit (non-interactive mode)")
+	interactiveApprove := flag.Bool("interactive-approve", false, "Ask for confirmation before executing each tool call")
+	recordPath := flag.String("record", "", "Append each input and its outcome to this JSONL transcript")
+	replayPath := flag.String("replay", "", "Re-run the inputs of a recorded transcript then exit")
+	minSummaryWords := flag.Int("min-summary-words", 50, "Skip summarizing tool output with fewer words than this")
+	stream := flag.Bool("stream", false, "Show tool progress, then stream summaries and other post-processed output as they are generated")
+	style := flag.String("style", defaultSummaryOptions.Style, "Summary style: "+strings.Join(summarizer.Styles, ", "))
+	maxLength := flag.Int("max-length", defaultSummaryOptions.MaxLength, "Approximate maximum summary length in words")
+	urlsFile := flag.String("urls-file", "", "Scrape and summarize every URL in this file (one per line, # for comments) then exit")
+	reportPath := flag.String("report", "", "Write the --urls-file markdown report here instead of stdout")
+	condenseOver := flag.Int("condense-over", 2000, "Show tool results longer than this many characters as a short summary (0 shows them whole; ':full' prints the last results in full)")
+	chunked := flag.Bool("chunked", false, "Stream each --urls-file page to the summarizer in chunks, bounding memory on very large pages")
+	budgetFlag := flag.String("budget", "", "Stop LLM calls once the session spends this many tokens (e.g. 50000 or 50k) or USD (e.g. $0.50); scraping still works")
+	pricePerMillion := flag.Float64("price-per-million", 0, "Blended USD price per million tokens, used to cost LLM calls against a USD --budget")
 	flag.Parse()
 
+	if err := summarizer.ValidateStyle(*style); err != nil {
+		log.Fatalf("Invalid --style: %v", err)
+	}
+	if *maxLength <= 0 {
+		log.Fatalf("Invalid --max-length: must be a positive number of words")
+	}
+	summary := summaryOptions{Style: *style, MaxLength: *maxLength}
+	budget, err := parseBudget(*budgetFlag, *pricePerMillion)
+	if err != nil {
+		log.Fatalf("Invalid --budget: %v", err)
+	}
+
+	// Batch briefing mode talks to the scraper and summarizer directly, without the agent
+	if *urlsFile != "" {
+		if err := runBriefing(context.Background(), logger, os.Stdout, os.Stderr, *urlsFile, *reportPath, summary, *chunked); err != nil {
+			log.Fatalf("Briefing failed: %v", err)
+		}
+		return
+	}
+
 	// Create CLI
-	cli, err := NewAgentCLI(logger)
+	cli, err := NewAgentCLI(logger, *condenseOver)
 	if err != nil {
 		log.Fatalf("Failed to create CLI: %v", err)
 	}
+	// Single-run mode has nobody to answer prompts, so tool calls are auto-approved
+	cli.approveTools = *interactiveApprove && *input == "" && *replayPath == ""
+	cli.interactive = *input == "" && *replayPath == ""
+	cli.minSummaryWords = *minSummaryWords
+	cli.stream = *stream
+	cli.summary = summary
+	cli.budget = budget
+
+	if *recordPath != "" && *replayPath != "" && sameFile(*recordPath, *replayPath) {
+		log.Fatalf("--record and --replay name the same file; replaying a transcript while appending to it never ends")
+	}
+	if *recordPath != "" {
+		file, err := os.OpenFile(*recordPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatalf("Failed to open transcript: %v", err)
+		}
+		defer file.Close()
+		cli.recorder = json.NewEncoder(file)
+	}
 
 	// Run the interactive CLI
 	ctx := context.Background()
+	if *replayPath != "" {
+		if err := cli.Replay(ctx, *replayPath); err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
 	if *input != "" {
 		// Non-interactive single-run
 		if err := cli.processUserInput(ctx, *input); err != nil {
@@ -0,0 +1,715 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/agent"
+	"github.com/HeidiZHH/skull/internal/scraper"
+	"github.com/HeidiZHH/skull/internal/summarizer"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeLLM is an OpenAI-compatible chat completions endpoint that records each
+// request and answers the calls in order with replies, repeating the last one.
+// Streaming requests get the reply split into word chunks.
+type fakeLLM struct {
+	*httptest.Server
+	replies []string
+	// before, when set, runs ahead of each reply with the zero-based call number
+	before func(call int, r *http.Request)
+
+	mu       sync.Mutex
+	requests []openai.ChatCompletionRequest
+}
+
+func newFakeLLM(t *testing.T, replies ...string) *fakeLLM {
+	t.Helper()
+	f := &fakeLLM{replies: replies}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		call := len(f.requests)
+		content := f.replies[min(call, len(f.replies)-1)]
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+		if f.before != nil {
+			f.before(call, r)
+		}
+
+		if req.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, word := range strings.SplitAfter(content, " ") {
+				chunk, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+					Model:   "test-model",
+					Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{Content: word}}},
+				})
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: "test-model",
+			Choices: []openai.ChatCompletionChoice{{
+				Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+				FinishReason: openai.FinishReasonStop,
+			}},
+			Usage: openai.Usage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150},
+		})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Requests returns the chat completion requests received so far
+func (f *fakeLLM) Requests() []openai.ChatCompletionRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), f.requests...)
+}
+
+// fakeTools is an MCP server over SSE offering scrape_url, which serves the text
+// of pages, scrape_urls, which reports each URL as progress, and summarize, which
+// reports 500 tokens used
+type fakeTools struct {
+	*httptest.Server
+	pages map[string]string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+type fakeScrapeArgs struct {
+	URL string `json:"url"`
+}
+
+type fakeScrapeURLsArgs struct {
+	URLs []string `json:"urls"`
+}
+
+type fakeSummarizeArgs struct {
+	Content   string `json:"content"`
+	Style     string `json:"style,omitempty"`
+	MaxLength int    `json:"max_length,omitempty"`
+}
+
+func newFakeTools(t *testing.T, pages map[string]string) *fakeTools {
+	t.Helper()
+	f := &fakeTools{pages: pages}
+	server := mcp.NewServer(&mcp.Implementation{Name: "fake-tools", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "scrape_url", Description: "Scrape a URL"},
+		func(ctx context.Context, req *mcp.CallToolRequest, args fakeScrapeArgs) (*mcp.CallToolResult, any, error) {
+			f.record("scrape_url " + args.URL)
+			text, ok := f.pages[args.URL]
+			if !ok {
+				return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "Error scraping URL: HTTP 404"}}, IsError: true}, nil, nil
+			}
+			words := len(strings.Fields(text))
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: "Successfully scraped " + args.URL},
+					&mcp.TextContent{Text: "RAW_CONTENT:\n" + text},
+				},
+			}, map[string]any{
+				"url":         args.URL,
+				"content":     text,
+				"word_count":  words,
+				"low_content": words < 50,
+			}, nil
+		})
+	mcp.AddTool(server, &mcp.Tool{Name: "scrape_urls", Description: "Scrape several URLs"},
+		func(ctx context.Context, req *mcp.CallToolRequest, args fakeScrapeURLsArgs) (*mcp.CallToolResult, any, error) {
+			var texts []string
+			for i, url := range args.URLs {
+				f.record("scrape_urls " + url)
+				time.Sleep(10 * time.Millisecond) // each URL takes a moment, as a real scrape would
+				item := map[string]any{"index": i, "url": url, "title": "Page " + url}
+				if text, ok := f.pages[url]; ok {
+					item["content"] = text
+					texts = append(texts, text)
+				} else {
+					item = map[string]any{"index": i, "url": url, "error": "HTTP 404"}
+				}
+				if token := req.Params.GetProgressToken(); token != nil {
+					line, _ := json.Marshal(item)
+					req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+						ProgressToken: token,
+						Progress:      float64(i + 1),
+						Total:         float64(len(args.URLs)),
+						Message:       string(line),
+					})
+				}
+			}
+			return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: strings.Join(texts, "\n\n")}}}, nil, nil
+		})
+	mcp.AddTool(server, &mcp.Tool{Name: "summarize", Description: "Summarize text"},
+		func(ctx context.Context, req *mcp.CallToolRequest, args fakeSummarizeArgs) (*mcp.CallToolResult, any, error) {
+			f.record(fmt.Sprintf("summarize style=%s max_length=%d", args.Style, args.MaxLength))
+			return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "A summary."}}},
+				map[string]any{"summary": "A summary.", "tokens_used": 500}, nil
+		})
+	f.Server = httptest.NewServer(mcp.NewSSEHandler(func(*http.Request) *mcp.Server { return server }))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeTools) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+// Calls returns the tool calls made so far, e.g. "scrape_url https://example.com"
+func (f *fakeTools) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// newTestCLI creates a CLI whose agent uses llm and tools, reading the user's
+// answers from input and printing to the returned buffer. A nil tools leaves
+// the agent without an MCP server.
+func newTestCLI(t *testing.T, llm *fakeLLM, tools *fakeTools, input string) (*AgentCLI, *bytes.Buffer) {
+	t.Helper()
+	config := agent.Config{
+		Provider: "openai",
+		APIKey:   "test-key",
+		BaseURL:  llm.URL + "/v1",
+		Model:    "test-model",
+	}
+	if tools != nil {
+		config.MCPServer = tools.URL
+	}
+	agentService, err := agent.NewAgent(config, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewAgent: %v", err)
+	}
+	t.Cleanup(func() { agentService.Close() })
+
+	summarizerService, err := newSummarizer(config, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("newSummarizer: %v", err)
+	}
+
+	var out bytes.Buffer
+	cli := &AgentCLI{
+		agent:       agentService,
+		summarizer:  summarizerService,
+		agentConfig: config,
+		logger:      zerolog.Nop(),
+		input:       bufio.NewScanner(strings.NewReader(input)),
+		out:         &out,
+		summary:     defaultSummaryOptions,
+		interactive: true,
+	}
+	return cli, &out
+}
+
+// plan is an agent reply that calls the named tool with args, post-processing with postProcess
+func plan(tool string, args map[string]any, postProcess string) string {
+	reply, _ := json.Marshal(map[string]any{
+		"message":      "Working on it",
+		"tool_calls":   []map[string]any{{"name": tool, "arguments": args, "reasoning": "needed"}},
+		"should_call":  true,
+		"confidence":   0.9,
+		"explanation":  "The request names a page",
+		"post_process": postProcess,
+	})
+	return string(reply)
+}
+
+// longText is page content well above the CLI's minimum summary length
+var longText = strings.Repeat("The quick brown fox jumps over the lazy dog near the river bank. ", 12)
+
+func TestToolApprovalDeclined(t *testing.T) {
+	tools := newFakeTools(t, map[string]string{"https://example.com": longText})
+	llm := newFakeLLM(t, plan("scrape_url", map[string]any{"url": "https://example.com"}, ""))
+	cli, out := newTestCLI(t, llm, tools, "no\n")
+	cli.approveTools = true
+
+	if err := cli.processUserInput(context.Background(), "scrape https://example.com"); err != nil {
+		t.Fatalf("processUserInput: %v", err)
+	}
+	if calls := tools.Calls(); len(calls) != 0 {
+		t.Errorf("tool calls = %q, want none after declining", calls)
+	}
+	if !strings.Contains(out.String(), "Execute this tool? [y/N]") || !strings.Contains(out.String(), "Skipped scrape_url") {
+		t.Errorf("output does not show the prompt and the skip:\n%s", out)
+	}
+}
+
+func TestToolApprovalAccepted(t *testing.T) {
+	tools := newFakeTools(t, map[string]string{"https://example.com": longText})
+	llm := newFakeLLM(t, plan("scrape_url", map[string]any{"url": "https://example.com"}, ""))
+	cli, _ := newTestCLI(t, llm, tools, "y\n")
+	cli.approveTools = true
+
+	if err := cli.processUserInput(context.Background(), "scrape https://example.com"); err != nil {
+		t.Fatalf("processUserInput: %v", err)
+	}
+	if calls := tools.Calls(); len(calls) != 1 || calls[0] != "scrape_url https://example.com" {
+		t.Errorf("tool calls = %q, want the approved scrape", calls)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the CLI's interrupt goroutine to share
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestInterruptCancelsCurrentInput(t *testing.T) {
+	started := make(chan struct{})
+	llm := newFakeLLM(t, plan("scrape_url", map[string]any{"url": "https://example.com"}, ""))
+	llm.before = func(call int, r *http.Request) {
+		if call == 0 {
+			close(started)
+			<-r.Context().Done() // hang until the CLI gives up on the request
+		}
+	}
+	cli, _ := newTestCLI(t, llm, nil, "scrape https://example.com\nexit\n")
+	out := &syncBuffer{}
+	cli.out = out
+
+	sigCh := make(chan os.Signal, 1)
+	go cli.handleInterrupts(sigCh)
+	t.Cleanup(func() { close(sigCh) })
+	go func() {
+		<-started
+		sigCh <- os.Interrupt
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- cli.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after the interrupt")
+	}
+
+	output := out.String()
+	for _, want := range []string{"Cancelling current request", "⛔ Cancelled", "👋 Goodbye!"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+	// The loop prompted again after the cancel and read "exit"
+	if n := strings.Count(output, "🤖 You: "); n != 2 {
+		t.Errorf("prompted %d times, want 2", n)
+	}
+}
+
+func TestClarifyingQuestion(t *testing.T) {
+	tools := newFakeTools(t, map[string]string{"https://example.com/news": longText})
+	llm := newFakeLLM(t,
+		`{"message": "Which page?", "needs_clarification": true, "clarifying_question": "Which page should I summarize?", "should_call": true, "tool_calls": [{"name": "scrape_url", "arguments": {"url": "https://example.com"}}]}`,
+		plan("scrape_url", map[string]any{"url": "https://example.com/news"}, ""),
+	)
+	cli, out := newTestCLI(t, llm, tools, "the news page on example.com\n")
+
+	if err := cli.processUserInput(context.Background(), "summarize that"); err != nil {
+		t.Fatalf("processUserInput: %v", err)
+	}
+	if !strings.Contains(out.String(), "❔ Agent: Which page should I summarize?") {
+		t.Errorf("output does not ask the question:\n%s", out)
+	}
+	// Only the plan made after the answer runs
+	if calls := tools.Calls(); len(calls) != 1 || calls[0] != "scrape_url https://example.com/news" {
+		t.Errorf("tool calls = %q, want only the scrape for the answer", calls)
+	}
+	if prompt := llm.Requests()[1].Messages; !strings.Contains(prompt[len(prompt)-1].Content, "the news page on example.com") {
+		t.Errorf("follow-up does not carry the answer: %+v", prompt[len(prompt)-1])
+	}
+}
+
+// captureStdout redirects os.Stdout while fn runs and returns what was written to it
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	captured := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		captured <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-captured
+}
+
+func TestOutputGoesToInjectedWriter(t *testing.T) {
+	llm := newFakeLLM(t, `{"message": "Hello there.", "should_call": false, "confidence": 0.9}`)
+	cli, out := newTestCLI(t, llm, nil, "hello\n:set\n:set colour red\nexit\n")
+
+	stdout := captureStdout(t, func() {
+		if err := cli.Run(context.Background()); err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+	if stdout != "" {
+		t.Errorf("the CLI wrote to stdout instead of its writer:\n%s", stdout)
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "banner", want: "🧠 Skull AI Agent"},
+		{name: "agent reply", want: "Hello there."},
+		{name: "settings", want: "style=concise max-length=200 max-tokens=default temperature=default"},
+		{name: "error", want: `❌ Error: unknown option "colour"`},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%s: output does not contain %q:\n%s", tt.name, tt.want, out)
+		}
+	}
+}
+
+func TestExplainToolCommand(t *testing.T) {
+	llm := newFakeLLM(t, "{}")
+	cli, out := newTestCLI(t, llm, newFakeTools(t, nil), ":tool scrape_url\n:tool nope\n:tool\nexit\n")
+
+	if err := cli.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	output := out.String()
+	for _, want := range []string{
+		"🛠️  scrape_url\n  Scrape a URL\n  Parameters:\n    - url (string, required)",
+		"❌ Error: unknown tool: nope",
+		"Usage: :tool <name>  (available: ",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+	if len(llm.Requests()) != 0 {
+		t.Error(":tool reached the LLM")
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	tools := newFakeTools(t, map[string]string{"https://example.com": longText})
+	scrape := plan("scrape_url", map[string]any{"url": "https://example.com"}, "")
+	transcript := filepath.Join(t.TempDir(), "session.jsonl")
+	inputs := []string{"scrape https://example.com", "what can you do?"}
+
+	// Record a short session
+	llm := newFakeLLM(t, scrape, `{"message": "I scrape and summarize pages.", "should_call": false, "explanation": "No tool needed"}`)
+	recorder, _ := newTestCLI(t, llm, tools, "")
+	file, err := os.Create(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.recorder = json.NewEncoder(file)
+	for _, input := range inputs {
+		if err := recorder.processUserInput(context.Background(), input); err != nil {
+			t.Fatalf("processUserInput(%q): %v", input, err)
+		}
+	}
+	file.Close()
+
+	data, err := os.ReadFile(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != len(inputs) {
+		t.Fatalf("transcript has %d entries, want %d", len(lines), len(inputs))
+	}
+	var first transcriptEntry
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("entry 1: %v", err)
+	}
+	if first.Input != inputs[0] || first.Response == nil || len(first.Results) != 1 || !strings.Contains(first.Results[0], "RAW_CONTENT") {
+		t.Errorf("entry 1 = %+v, want the input, plan, and scrape result", first)
+	}
+
+	// Replay it against fresh services
+	replayLLM := newFakeLLM(t, scrape, `{"message": "Still scraping pages.", "should_call": false}`)
+	replayer, out := newTestCLI(t, replayLLM, tools, "")
+	if err := replayer.Replay(context.Background(), transcript); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	requests := replayLLM.Requests()
+	if len(requests) != len(inputs) {
+		t.Fatalf("replay made %d agent calls, want %d", len(requests), len(inputs))
+	}
+	for i, input := range inputs {
+		if !strings.Contains(out.String(), fmt.Sprintf("🔁 Replay %d: %s", i+1, input)) {
+			t.Errorf("output does not announce replay of %q:\n%s", input, out)
+		}
+		if prompt := requests[i].Messages[len(requests[i].Messages)-1].Content; !strings.Contains(prompt, input) {
+			t.Errorf("replayed request %d does not carry %q", i+1, input)
+		}
+	}
+	if calls := tools.Calls(); len(calls) != 2 {
+		t.Errorf("tool calls = %q, want the scrape once while recording and once on replay", calls)
+	}
+}
+
+func TestSameFile(t *testing.T) {
+	dir := t.TempDir()
+	transcript := filepath.Join(dir, "session.jsonl")
+	if err := os.WriteFile(transcript, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.jsonl")
+	if err := os.Symlink(transcript, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{transcript, transcript, true},
+		{transcript, filepath.Join(dir, ".", "session.jsonl"), true},
+		{transcript, link, true},
+		{transcript, filepath.Join(dir, "other.jsonl"), false},
+		{filepath.Join(dir, "new.jsonl"), filepath.Join(dir, "sub", "..", "new.jsonl"), true},
+	}
+	for _, tt := range tests {
+		if got := sameFile(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameFile(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPostProcessSkipsLowContent(t *testing.T) {
+	banner := "We use cookies to improve your experience. Accept all cookies or manage your preferences."
+	tests := []struct {
+		name     string
+		page     string
+		minWords int
+		wantSkip bool
+	}{
+		{name: "tiny page", page: banner, minWords: 50, wantSkip: true},
+		// The scraper's low_content flag wins even under a lower CLI threshold
+		{name: "flagged by the scraper", page: banner, minWords: 5, wantSkip: true},
+		{name: "article", page: longText, minWords: 50, wantSkip: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools := newFakeTools(t, map[string]string{"https://example.com": tt.page})
+			llm := newFakeLLM(t, plan("scrape_url", map[string]any{"url": "https://example.com"}, "Summarize the page"), "A summary of the article.")
+			cli, out := newTestCLI(t, llm, tools, "")
+			cli.minSummaryWords = tt.minWords
+
+			if err := cli.processUserInput(context.Background(), "summarize https://example.com"); err != nil {
+				t.Fatalf("processUserInput: %v", err)
+			}
+			skipped := strings.Contains(out.String(), "📄 Skipping")
+			if skipped != tt.wantSkip {
+				t.Errorf("skipped = %v, want %v; output:\n%s", skipped, tt.wantSkip, out)
+			}
+			wantCalls := 2
+			if tt.wantSkip {
+				wantCalls = 1
+			}
+			if got := len(llm.Requests()); got != wantCalls {
+				t.Errorf("LLM calls = %d, want %d", got, wantCalls)
+			}
+		})
+	}
+}
+
+func TestStreamScrapeAndSummarize(t *testing.T) {
+	tools := newFakeTools(t, map[string]string{"https://a.example": longText, "https://b.example": longText})
+	urls := []any{"https://a.example", "https://missing.example", "https://b.example"}
+	llm := newFakeLLM(t, plan("scrape_urls", map[string]any{"urls": urls}, "Summarize the pages"), "Both pages describe the same widget.")
+	cli, _ := newTestCLI(t, llm, tools, "")
+	out := &syncBuffer{}
+	cli.out = out
+	cli.stream = true
+	cli.summary = summaryOptions{Style: "detailed", MaxLength: 120}
+
+	if err := cli.processUserInput(context.Background(), "summarize these pages"); err != nil {
+		t.Fatalf("processUserInput: %v", err)
+	}
+	output := out.String()
+
+	// Scrape progress arrives first, one line per URL, then the streamed summary
+	wants := []string{
+		"⏳ 1/3 https://a.example: Page https://a.example",
+		"⚠️  2/3 https://missing.example failed: HTTP 404",
+		"⏳ 3/3 https://b.example: Page https://b.example",
+		"🧾 Final Output:\nBoth pages describe the same widget.",
+	}
+	last := -1
+	for _, want := range wants {
+		i := strings.Index(output, want)
+		if i < 0 || i < last {
+			t.Fatalf("output is missing %q after the previous step:\n%s", want, output)
+		}
+		last = i
+	}
+
+	requests := llm.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d completions, want the plan and the summary", len(requests))
+	}
+	summary := requests[1]
+	if !summary.Stream {
+		t.Error("the summary was not streamed")
+	}
+	if prompt := summary.Messages[len(summary.Messages)-1].Content; !strings.Contains(prompt, "120 words") || !strings.Contains(prompt, longText[:40]) {
+		t.Errorf("summary prompt does not carry the session style and scraped text:\n%s", prompt)
+	}
+}
+
+func TestSummaryStyleReachesSummarizer(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			tools := newFakeTools(t, map[string]string{"https://example.com": longText})
+			llm := newFakeLLM(t, plan("scrape_url", map[string]any{"url": "https://example.com"}, "Summarize the page"), "- The fox jumps.")
+			input := ":set style bullet_points\n:set max-length 80\nsummarize https://example.com\nexit\n"
+			cli, out := newTestCLI(t, llm, tools, input)
+			cli.stream = stream
+
+			if err := cli.Run(context.Background()); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if !strings.Contains(out.String(), "✅ Summaries will use style=bullet_points max-length=80") {
+				t.Errorf("output does not confirm the new settings:\n%s", out)
+			}
+
+			requests := llm.Requests()
+			if len(requests) != 2 {
+				t.Fatalf("got %d completions, want the plan and the summary", len(requests))
+			}
+			want := summarizer.Instructions(summarizer.Request{Style: "bullet_points", MaxLength: 80})
+			summary := requests[1]
+			if prompt := summary.Messages[len(summary.Messages)-1].Content; !strings.Contains(prompt, want) {
+				t.Errorf("summary prompt does not carry the summarizer's instructions %q:\n%s", want, prompt)
+			}
+			if summary.Stream != stream {
+				t.Errorf("summary streamed = %v, want %v", summary.Stream, stream)
+			}
+		})
+	}
+}
+
+func TestLargeToolResultCondensed(t *testing.T) {
+	page := strings.Repeat(longText, 4)
+	tools := newFakeTools(t, map[string]string{"https://example.com": page})
+	llm := newFakeLLM(t, plan("scrape_url", map[string]any{"url": "https://example.com"}, ""), "A page about a fox by a river.")
+	cli, out := newTestCLI(t, llm, tools, "")
+	config := cli.agentConfig
+	config.CondenseToolOutput = 500
+	condensing, err := agent.NewAgent(config, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewAgent: %v", err)
+	}
+	t.Cleanup(func() { condensing.Close() })
+	cli.agent = condensing
+
+	if err := cli.processUserInput(context.Background(), "scrape https://example.com"); err != nil {
+		t.Fatalf("processUserInput: %v", err)
+	}
+	if !strings.Contains(out.String(), "✅ Result (condensed from ") || !strings.Contains(out.String(), "A page about a fox by a river.") {
+		t.Errorf("output does not show the condensed result:\n%s", out)
+	}
+	if strings.Contains(out.String(), page) {
+		t.Errorf("output shows the full result:\n%s", out)
+	}
+	if requests := llm.Requests(); len(requests) != 2 {
+		t.Errorf("LLM calls = %d, want the plan and the condensing", len(requests))
+	}
+
+	// The full result is kept for ":full"
+	out.Reset()
+	cli.showFullResults()
+	if !strings.Contains(out.String(), page) {
+		t.Errorf(":full output does not show the full result:\n%s", out)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestScrapeOnlyNormalizesInput(t *testing.T) {
+	var requested []string
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.String())
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			Body:       io.NopCloser(strings.NewReader("<html><head><title>News</title></head><body><p>" + longText + "</p></body></html>")),
+			Request:    req,
+		}, nil
+	})
+	scraperService, err := scraper.NewService(scraper.Config{Transport: transport, Timeout: 5 * time.Second}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("scraper.NewService: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		wantURL    string
+		wantOutput string
+	}{
+		{name: "bare domain", input: "example.com/news", wantURL: "https://example.com/news", wantOutput: "🌐 Scraping https://example.com/news..."},
+		{name: "trailing spaces", input: "example.com/news   ", wantURL: "https://example.com/news", wantOutput: "🌐 Scraping https://example.com/news..."},
+		{name: "explicit http", input: "http://example.com/news", wantURL: "http://example.com/news", wantOutput: "🌐 Scraping http://example.com/news..."},
+		{name: "not a URL", input: "what is new today", wantOutput: "paste a URL to scrape it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested = nil
+			var out bytes.Buffer
+			cli := &AgentCLI{scraper: scraperService, logger: zerolog.Nop(), out: &out, summary: defaultSummaryOptions}
+			if err := cli.processUserInput(context.Background(), tt.input); err != nil {
+				t.Fatalf("processUserInput: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOutput)
+			}
+			if tt.wantURL == "" && len(requested) != 0 || tt.wantURL != "" && (len(requested) != 1 || requested[0] != tt.wantURL) {
+				t.Errorf("requested %q, want %q", requested, tt.wantURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/HeidiZHH/skull/internal/agent"
+)
+
+// providerPreset is an OpenAI-compatible endpoint ":provider" can switch to
+type providerPreset struct {
+	baseURL string
+	model   string
+	// keyEnv names the provider's own API key variable; OPENAI_API_KEY is used when it is unset
+	keyEnv string
+}
+
+// providerPresets are the providers known to ":provider"
+var providerPresets = map[string]providerPreset{
+	"openai":   {baseURL: "https://api.openai.com/v1", model: "gpt-3.5-turbo", keyEnv: "OPENAI_API_KEY"},
+	"deepseek": {baseURL: "https://api.deepseek.com/v1", model: "deepseek-chat", keyEnv: "DEEPSEEK_API_KEY"},
+}
+
+// providerNames returns the known providers in sorted order
+func providerNames() []string {
+	names := make([]string, 0, len(providerPresets))
+	for name := range providerPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// switchModel handles ":model <name>", printing the active settings when no name is given
+func (cli *AgentCLI) switchModel(args []string) {
+	if len(args) == 0 {
+		cli.printActiveModel()
+		return
+	}
+	if len(args) != 1 {
+		fmt.Fprintf(cli.out, "Usage: :model <name>\n\n")
+		return
+	}
+
+	if !cli.requireAgent("Switching models") {
+		return
+	}
+
+	config := cli.agentConfig
+	config.Model = args[0]
+	cli.rebuildAgent(config)
+}
+
+// switchProvider handles ":provider <name>", moving to the provider's endpoint,
+// default model, and API key
+func (cli *AgentCLI) switchProvider(args []string) {
+	if len(args) == 0 {
+		cli.printActiveModel()
+		return
+	}
+	preset, ok := providerPresets[strings.ToLower(args[0])]
+	if len(args) != 1 || !ok {
+		fmt.Fprintf(cli.out, "Usage: :provider <%s>\n\n", strings.Join(providerNames(), "|"))
+		return
+	}
+
+	apiKey := os.Getenv(preset.keyEnv)
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if apiKey == "" {
+		fmt.Fprintf(cli.out, "❌ Error: set %s or OPENAI_API_KEY to use %s\n\n", preset.keyEnv, args[0])
+		return
+	}
+
+	config := cli.agentConfig
+	config.Provider = strings.ToLower(args[0])
+	config.BaseURL = preset.baseURL
+	config.Model = preset.model
+	config.APIKey = apiKey
+	cli.rebuildAgent(config)
+}
+
+// rebuildAgent replaces the agent and summarizer with ones built from config,
+// carrying over the conversation memory. The current pair is kept on failure.
+func (cli *AgentCLI) rebuildAgent(config agent.Config) {
+	next, err := agent.NewAgent(config, cli.logger)
+	if err != nil {
+		fmt.Fprintf(cli.out, "❌ Error: %v\n\n", err)
+		return
+	}
+	nextSummarizer, err := newSummarizer(config, cli.logger)
+	if err != nil {
+		next.Close()
+		fmt.Fprintf(cli.out, "❌ Error: %v\n\n", err)
+		return
+	}
+	// Leaving scrape-only mode there is no previous agent
+	if cli.agent != nil {
+		next.SetHistory(cli.agent.History())
+		if cli.budget != nil {
+			// The new agent starts counting from zero; keep the spend so far
+			used := cli.agent.Usage()
+			cli.budget.carried.Calls += used.Calls
+			cli.budget.carried.PromptTokens += used.PromptTokens
+			cli.budget.carried.CompletionTokens += used.CompletionTokens
+			cli.budget.carried.TotalTokens += used.TotalTokens
+		}
+		if err := cli.agent.Close(); err != nil {
+			cli.logger.Debug().Err(err).Msg("Failed to close the previous agent's MCP session")
+		}
+	}
+	cli.agent = next
+	cli.summarizer = nextSummarizer
+	cli.agentConfig = config
+	fmt.Fprintf(cli.out, "✅ Switched LLM. ")
+	cli.printActiveModel()
+}
+
+// printActiveModel reports the provider, model, and endpoint in use
+func (cli *AgentCLI) printActiveModel() {
+	fmt.Fprintf(cli.out, "provider=%s model=%s base_url=%s\n\n", cli.agentConfig.Provider, cli.agentConfig.Model, cli.agentConfig.BaseURL)
+}
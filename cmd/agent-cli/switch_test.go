@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSwitchModel(t *testing.T) {
+	tools := newFakeTools(t, map[string]string{"https://example.com": longText})
+	llm := newFakeLLM(t,
+		`{"message": "Hello there.", "should_call": false, "confidence": 0.9}`,
+		plan("scrape_url", map[string]any{"url": "https://example.com"}, "Summarize the page"),
+		"The fox jumps.",
+	)
+	input := "hello\n:model other-model\nsummarize https://example.com\nexit\n"
+	cli, out := newTestCLI(t, llm, tools, input)
+	// The rebuilt agent holds its own MCP session
+	t.Cleanup(func() { cli.agent.Close() })
+	cli.stream = true
+	before := cli.summarizer
+
+	if err := cli.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(out.String(), "✅ Switched LLM. provider=openai model=other-model") {
+		t.Errorf("output does not report the new model:\n%s", out)
+	}
+	if cli.agentConfig.Model != "other-model" {
+		t.Errorf("active model = %q, want other-model", cli.agentConfig.Model)
+	}
+	if cli.summarizer == before {
+		t.Error("the summarizer was not rebuilt")
+	}
+
+	requests := llm.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("got %d completions, want the greeting, the plan and the summary", len(requests))
+	}
+	for i, want := range []string{"test-model", "other-model", "other-model"} {
+		if requests[i].Model != want {
+			t.Errorf("completion %d used model %q, want %q", i, requests[i].Model, want)
+		}
+	}
+	// The rebuilt agent still remembers the first exchange
+	remembered := false
+	for _, message := range requests[1].Messages {
+		remembered = remembered || message.Content == "hello" || strings.Contains(message.Content, "Hello there.")
+	}
+	if !remembered {
+		t.Errorf("the plan after the switch lost the conversation memory: %+v", requests[1].Messages)
+	}
+}
+
+func TestSwitchProvider(t *testing.T) {
+	llm := newFakeLLM(t, "unused")
+
+	t.Run("known provider", func(t *testing.T) {
+		t.Setenv("DEEPSEEK_API_KEY", "deepseek-key")
+		cli, out := newTestCLI(t, llm, nil, "")
+		before := cli.summarizer
+
+		cli.switchProvider([]string{"DeepSeek"})
+		if cli.agentConfig.Provider != "deepseek" || cli.agentConfig.Model != "deepseek-chat" || cli.agentConfig.APIKey != "deepseek-key" {
+			t.Errorf("config = %+v, want the deepseek preset and key", cli.agentConfig)
+		}
+		if cli.summarizer == before {
+			t.Error("the summarizer was not rebuilt")
+		}
+		if !strings.Contains(out.String(), "base_url=https://api.deepseek.com/v1") {
+			t.Errorf("output does not report the new endpoint:\n%s", out)
+		}
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		cli, out := newTestCLI(t, llm, nil, "")
+		cli.switchProvider([]string{"nowhere"})
+		if cli.agentConfig.Model != "test-model" || !strings.Contains(out.String(), "Usage: :provider <deepseek|openai>") {
+			t.Errorf("model = %q, output:\n%s\nwant the usage and no switch", cli.agentConfig.Model, out)
+		}
+	})
+}
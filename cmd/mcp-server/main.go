@@ -7,9 +7,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/HeidiZHH/skull/internal/scraper"
+	"github.com/HeidiZHH/skull/internal/summarizer"
+	"github.com/HeidiZHH/skull/internal/textutil"
 	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/rs/zerolog"
@@ -17,9 +20,29 @@ import (
 
 // MCPServer wraps the official MCP server with our business logic
 type MCPServer struct {
-	logger         zerolog.Logger
-	mcpServer      *mcp.Server
+	logger    zerolog.Logger
+	mcpServer *mcp.Server
+	// pool holds the HTTP connections the scraper and summarizer share across
+	// concurrent tool calls; handlers reference the upstream they use
+	pool           *upstreamPool
 	scraperService *scraper.Service
+	// minContentWords is the word count below which content is flagged as not worth summarizing
+	minContentWords int
+	// previewLength is how many characters of scraped text the scrape_url preview shows
+	previewLength int
+	// breaker fails scrapes fast for hosts that keep failing
+	breaker *scraper.CircuitBreaker
+	// summarizerService backs the summarize tool; nil when no API key is configured
+	summarizerService *summarizer.Service
+	// strictArgs validates raw tool arguments against each tool's InputSchema
+	strictArgs bool
+	// defaultToolTimeout bounds every tool call unless toolTimeouts names the
+	// tool; zero means no limit
+	defaultToolTimeout time.Duration
+	toolTimeouts       map[string]time.Duration
+	// prettyJSON indents the JSON that tool results embed in their text, matching
+	// the prettyEvents rewrite of the HTTP event stream
+	prettyJSON bool
 }
 
 // NewMCPServer creates a new MCP server instance using the official SDK
@@ -33,6 +56,8 @@ func NewMCPServer(logger zerolog.Logger) (*MCPServer, error) {
 	serverOpts := &mcp.ServerOptions{}
 	mcpServer := mcp.NewServer(impl, serverOpts)
 
+	pool := newUpstreamPool(defaultUpstreamIdleTimeout)
+
 	// Initialize scraper service
 	scraperConfig := scraper.Config{
 		UserAgent:   "skull-agent/1.0",
@@ -40,13 +65,26 @@ func NewMCPServer(logger zerolog.Logger) (*MCPServer, error) {
 		MaxRetries:  3,
 		RateLimit:   1 * time.Second,
 		MaxBodySize: 10 * 1024 * 1024, // 10MB
+		// Flag "Page Not Found" pages served with a 200 so clients skip summarizing them
+		DetectSoftErrors: true,
+		Transport:        pool.Transport(upstreamWeb),
+	}
+	scraperService, err := scraper.NewService(scraperConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scraper: %w", err)
+	}
+
+	summarizerService, err := summarizerFromEnv(logger, pool.Transport(upstreamLLM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create summarizer: %w", err)
 	}
-	scraperService := scraper.NewService(scraperConfig, logger)
 
 	server := &MCPServer{
-		logger:         logger,
-		mcpServer:      mcpServer,
-		scraperService: scraperService,
+		logger:            logger,
+		mcpServer:         mcpServer,
+		pool:              pool,
+		scraperService:    scraperService,
+		summarizerService: summarizerService,
 	}
 
 	// Register our tools with the MCP server
@@ -63,9 +101,20 @@ type ScrapeURLParams struct {
 	Selector string `json:"selector,omitempty"`
 }
 
-type SummarizeParams struct {
-	Content   string `json:"content"`
-	MaxLength int    `json:"max_length,omitempty"`
+type ScrapeURLsParams struct {
+	URLs     []string `json:"urls"`
+	Selector string   `json:"selector,omitempty"`
+}
+
+// ScrapeURLsItem is one per-URL line of the scrape_urls NDJSON stream
+type ScrapeURLsItem struct {
+	Index      int    `json:"index"`
+	URL        string `json:"url"`
+	Title      string `json:"title,omitempty"`
+	Content    string `json:"content,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ErrorCode  string `json:"error_code,omitempty"`
 }
 
 type ScrapeAndSummarizeParams struct {
@@ -74,8 +123,6 @@ type ScrapeAndSummarizeParams struct {
 	Selector  string `json:"selector,omitempty"`
 }
 
-// Removed summarization-oriented tools. The agent/caller will perform summarization.
-
 // registerTools registers all our tools with the MCP server
 func (s *MCPServer) registerTools() error {
 	// Register scrape_url tool
@@ -97,11 +144,49 @@ func (s *MCPServer) registerTools() error {
 			Required: []string{"url"},
 		},
 	}
-	mcp.AddTool(s.mcpServer, scrapeURLTool, s.handleScrapeURL)
+	if err := addTool(s, scrapeURLTool, s.handleScrapeURL); err != nil {
+		return err
+	}
+
+	// Register scrape_urls batch tool
+	scrapeURLsTool := &mcp.Tool{
+		Name:        "scrape_urls",
+		Description: "Scrape several URLs concurrently. Each per-URL result is streamed as an NDJSON progress notification as it completes; the final result aggregates all of them",
+		InputSchema: &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"urls": {
+					Type:        "array",
+					Description: "The URLs to scrape",
+					Items:       &jsonschema.Schema{Type: "string"},
+				},
+				"selector": {
+					Type:        "string",
+					Description: "Optional CSS selector to extract specific content from each page",
+				},
+			},
+			Required: []string{"urls"},
+		},
+	}
+	if err := addTool(s, scrapeURLsTool, s.handleScrapeURLs); err != nil {
+		return err
+	}
+
+	// Register the summarize tool when an LLM is configured
+	if s.summarizerService != nil {
+		if err := addTool(s, summarizeTool(), s.handleSummarize); err != nil {
+			return err
+		}
+	} else {
+		s.logger.Warn().Msg("OPENAI_API_KEY is not set; the summarize tool is disabled")
+	}
 
 	return nil
 }
 
+// minExtractableTextLength is the shortest cleaned text treated as real page content
+const minExtractableTextLength = 20
+
 // Tool handler implementations
 func (s *MCPServer) handleScrapeURL(
 	ctx context.Context,
@@ -112,10 +197,12 @@ func (s *MCPServer) handleScrapeURL(
 		Str("url", args.URL).
 		Str("selector", args.Selector).
 		Msg("Scraping URL")
+	defer s.pool.acquire(upstreamWeb)()
 
-	// Use the actual scraper service
-	result, err := s.scraperService.ScrapeURL(ctx, args.URL, args.Selector)
+	// Use the actual scraper service, guarded by the host's circuit breaker
+	result, err := s.scrape(ctx, args.URL, args.Selector)
 	if err != nil {
+		code, status := scraper.ErrorCode(err)
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				&mcp.TextContent{
@@ -123,7 +210,12 @@ func (s *MCPServer) handleScrapeURL(
 				},
 			},
 			IsError: true,
-		}, nil, nil
+		}, map[string]interface{}{
+			"url":         args.URL,
+			"error":       err.Error(),
+			"error_code":  code,
+			"status_code": status,
+		}, nil
 	}
 
 	responseData := map[string]interface{}{
@@ -134,13 +226,44 @@ func (s *MCPServer) handleScrapeURL(
 		"images_count": len(result.Images),
 		"status_code":  result.StatusCode,
 		"content_type": result.ContentType,
+		"kind":         result.Kind,
+		"no_content":   false,
+		"word_count":   result.WordCount,
+		"low_content":  result.IsLowContent(s.minContentWords),
+		"soft_error":   result.SoftError,
+		"truncated":    result.Truncated,
+	}
+
+	// Pages rendered client-side often scrape successfully but yield no text;
+	// tell the caller why instead of handing empty content downstream
+	if len(strings.TrimSpace(result.CleanText)) < minExtractableTextLength {
+		s.logger.Warn().Str("url", result.URL).Int("content_length", len(result.CleanText)).Msg("Page returned no extractable text")
+		responseData["no_content"] = true
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: fmt.Sprintf("Scraped %s but the page returned no extractable text; it may require JavaScript rendering.\n\nTitle: %s",
+						result.URL, result.Title),
+				},
+			},
+		}, responseData, nil
+	}
+
+	summary := fmt.Sprintf("Successfully scraped %s\n\nTitle: %s\n\nContent Preview:\n%s",
+		result.URL, result.Title, textutil.TruncateRunes(result.CleanText, s.previewLength))
+	if result.SoftError {
+		summary += "\n\nNote: the page looks like an error page (e.g. \"Page Not Found\") despite a success status; it is not worth summarizing."
+	} else if result.IsLowContent(s.minContentWords) {
+		summary += fmt.Sprintf("\n\nNote: the page has only %d words of text (likely a banner or stub); it is not worth summarizing.", result.WordCount)
+	}
+	if result.Truncated {
+		summary += "\n\nNote: the page was larger than the size limit and was cut off; its content may be incomplete."
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{
-				Text: fmt.Sprintf("Successfully scraped %s\n\nTitle: %s\n\nContent Preview:\n%s",
-					result.URL, result.Title, result.CleanText[:min(len(result.CleanText), 500)]+"..."),
+				Text: summary,
 			},
 			// Include raw content for clients that want to post-process (e.g., summarization)
 			&mcp.TextContent{
@@ -150,10 +273,130 @@ func (s *MCPServer) handleScrapeURL(
 	}, responseData, nil
 }
 
+// scrape scrapes a URL unless its host's circuit breaker is open, recording the outcome
+func (s *MCPServer) scrape(ctx context.Context, url, selector string) (*scraper.Result, error) {
+	if err := s.breaker.Allow(url); err != nil {
+		s.logger.Warn().Err(err).Str("url", url).Msg("Skipping scrape: circuit breaker open")
+		return nil, err
+	}
+	result, err := s.scraperService.ScrapeURL(ctx, url, selector)
+	s.breaker.Record(url, err)
+	return result, err
+}
+
+// handleScrapeURLs scrapes a batch of URLs, streaming each result to the client as
+// a progress notification whose message is one NDJSON line
+func (s *MCPServer) handleScrapeURLs(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	args ScrapeURLsParams,
+) (*mcp.CallToolResult, any, error) {
+	s.logger.Info().Int("urls", len(args.URLs)).Str("selector", args.Selector).Msg("Scraping URLs")
+
+	if len(args.URLs) == 0 {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: "Error scraping URLs: no URLs given"},
+			},
+			IsError: true,
+		}, nil, nil
+	}
+
+	defer s.pool.acquire(upstreamWeb)()
+
+	var progressToken any
+	if req.Params != nil {
+		progressToken = req.Params.GetProgressToken()
+	}
+
+	// Hosts with an open circuit breaker fail fast without being scraped
+	items := make([]ScrapeURLsItem, len(args.URLs))
+	outcomes := make(chan scraper.ScrapeOutcome, len(args.URLs))
+	var allowed []string
+	var allowedIndex []int
+	for i, url := range args.URLs {
+		if err := s.breaker.Allow(url); err != nil {
+			outcomes <- scraper.ScrapeOutcome{Index: i, URL: url, Err: err}
+			continue
+		}
+		allowed = append(allowed, url)
+		allowedIndex = append(allowedIndex, i)
+	}
+	go func() {
+		defer close(outcomes)
+		if len(allowed) == 0 {
+			return
+		}
+		for outcome := range s.scraperService.ScrapeStream(ctx, allowed, args.Selector) {
+			s.breaker.Record(outcome.URL, outcome.Err)
+			outcome.Index = allowedIndex[outcome.Index]
+			outcomes <- outcome
+		}
+	}()
+
+	completed := 0
+	for outcome := range outcomes {
+		item := ScrapeURLsItem{Index: outcome.Index, URL: outcome.URL}
+		if outcome.Err != nil {
+			item.Error = outcome.Err.Error()
+			item.ErrorCode, item.StatusCode = scraper.ErrorCode(outcome.Err)
+		} else {
+			item.Title = outcome.Result.Title
+			item.Content = outcome.Result.CleanText
+			item.StatusCode = outcome.Result.StatusCode
+		}
+		items[outcome.Index] = item
+		completed++
+
+		if progressToken != nil {
+			line, err := s.encodeJSON(item)
+			if err == nil {
+				err = req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+					ProgressToken: progressToken,
+					Progress:      float64(completed),
+					Total:         float64(len(args.URLs)),
+					Message:       string(line),
+				})
+			}
+			if err != nil {
+				s.logger.Warn().Err(err).Str("url", outcome.URL).Msg("Failed to send scrape progress")
+			}
+		}
+	}
+
+	// The final result repeats every item as NDJSON, in input order (with
+	// prettyJSON, each item spans several lines)
+	var ndjson strings.Builder
+	failed := 0
+	for _, item := range items {
+		if item.Error != "" {
+			failed++
+		}
+		line, err := s.encodeJSON(item)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode the result for %s: %w", item.URL, err)
+		}
+		ndjson.Write(line)
+		ndjson.WriteByte('\n')
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: fmt.Sprintf("Scraped %d of %d URLs", len(items)-failed, len(items)),
+			},
+			&mcp.TextContent{
+				Text: ndjson.String(),
+			},
+		},
+		IsError: failed == len(items),
+	}, map[string]interface{}{"results": items, "failed": failed}, nil
+}
+
 // Start starts the MCP server using stdio transport (most common)
 func (s *MCPServer) Start(ctx context.Context) error {
 	s.logger.Info().Msg("Starting MCP server with OpenAI integration")
-	s.logger.Info().Msg("Available tools: scrape_url")
+	s.logger.Info().Msg("Available tools: scrape_url, scrape_urls")
 
 	// Use stdio transport - this is the standard for MCP servers
 	transport := &mcp.StdioTransport{}
@@ -166,20 +409,27 @@ func (s *MCPServer) Start(ctx context.Context) error {
 	return nil
 }
 
-// Helper function for min
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 // Main function
 func main() {
 	// Add flag for HTTP transport
 	httpAddr := flag.String("http", "", "Serve MCP server over HTTP at the given address (e.g. :8080)")
+	minContentWords := flag.Int("min-content-words", 50, "Flag scraped pages with fewer words than this as not worth summarizing")
+	previewLength := flag.Int("preview-length", 500, "Characters of scraped text shown in the scrape_url preview (0 shows all)")
+	breakerThreshold := flag.Int("breaker-threshold", 5, "Consecutive upstream failures before a host is temporarily skipped (0 disables)")
+	breakerCooldown := flag.Duration("breaker-cooldown", 30*time.Second, "How long a failing host is skipped before a probe request is let through")
+	prettyJSON := flag.Bool("pretty-json", false, "Pretty-print JSON for debugging: the messages on HTTP event streams, structured content included, and the JSON embedded in tool result text (stdio framing is always compact)")
+	maxSessions := flag.Int("max-sessions", 100, "Maximum concurrent SSE sessions over HTTP; further connections get 503 (0 means unlimited)")
+	strictArgs := flag.Bool("strict-args", true, "Validate tool arguments against each tool's input schema, rejecting unknown fields and type mismatches before the handler runs")
+	toolTimeout := flag.Duration("tool-timeout", 2*time.Minute, "Maximum duration of a tool call before it is cancelled and reported as timed out (0 disables)")
+	upstreamIdleTimeout := flag.Duration("upstream-idle-timeout", defaultUpstreamIdleTimeout, "Close pooled upstream connections once no tool call has used them for this long")
+	toolTimeoutOverrides := flag.String("tool-timeouts", "", "Per-tool timeouts overriding -tool-timeout, as tool=duration pairs (e.g. scrape_urls=5m,summarize=3m)")
 	flag.Parse()
 
+	toolTimeouts, err := parseToolTimeouts(*toolTimeoutOverrides)
+	if err != nil {
+		log.Fatalf("Invalid -tool-timeouts: %v", err)
+	}
+
 	// Create logger
 	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
 
@@ -188,18 +438,38 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create MCP server: %v", err)
 	}
+	server.minContentWords = *minContentWords
+	server.previewLength = *previewLength
+	server.strictArgs = *strictArgs
+	server.breaker = scraper.NewCircuitBreaker(*breakerThreshold, *breakerCooldown)
+	server.defaultToolTimeout = *toolTimeout
+	server.toolTimeouts = toolTimeouts
+	server.prettyJSON = *prettyJSON
+	server.pool.idleTimeout = *upstreamIdleTimeout
 
 	ctx := context.Background()
+	go server.pool.run(ctx)
 	if *httpAddr != "" {
 		logger.Info().Str("http", *httpAddr).Msg("Starting MCP server with HTTP transport")
-		h := mcp.NewSSEHandler(func(r *http.Request) *mcp.Server { return server.mcpServer })
-		if err := http.ListenAndServe(*httpAddr, h); err != nil {
+		var handler http.Handler = mcp.NewSSEHandler(func(r *http.Request) *mcp.Server { return server.mcpServer })
+		if *prettyJSON {
+			handler = prettyEvents{next: handler}
+		}
+		sessions := newSessionLimiter(handler, *maxSessions, logger)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", sessions.healthHandler)
+		mux.HandleFunc("/metrics", server.pool.metricsHandler)
+		mux.Handle("/", sessions)
+		if err := http.ListenAndServe(*httpAddr, mux); err != nil {
 			log.Fatalf("HTTP server failed: %v", err)
 		}
 		return
 	}
 
 	logger.Info().Msg("Starting MCP server with stdio transport")
+	if *prettyJSON {
+		logger.Warn().Msg("-pretty-json only indents JSON in tool result text over stdio; the messages themselves stay compact")
+	}
 	transport := &mcp.StdioTransport{}
 	if err := server.mcpServer.Run(ctx, transport); err != nil {
 		log.Fatalf("Server failed: %v", err)
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/scraper"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/rs/zerolog"
+)
+
+// newTestServer creates an MCPServer without the summarize tool, whose scraper
+// fails fast: no retries and no rate limit
+func newTestServer(t *testing.T) *MCPServer {
+	t.Helper()
+	t.Setenv("OPENAI_API_KEY", "")
+	server, err := NewMCPServer(zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewMCPServer: %v", err)
+	}
+	server.scraperService = newTestScraper(t, scraper.Config{})
+	server.strictArgs = true
+	return server
+}
+
+// newTestScraper creates a scraper for test servers on loopback addresses
+func newTestScraper(t *testing.T, config scraper.Config) *scraper.Service {
+	t.Helper()
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	service, err := scraper.NewService(config, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("scraper.NewService: %v", err)
+	}
+	return service
+}
+
+// connect opens an in-memory client session to the server
+func connect(t *testing.T, server *MCPServer) *mcp.ClientSession {
+	t.Helper()
+	return connectWithOptions(t, server, nil)
+}
+
+// connectWithOptions opens an in-memory client session with the given client options
+func connectWithOptions(t *testing.T, server *MCPServer, opts *mcp.ClientOptions) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.mcpServer.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, opts)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+// callTool calls a tool, failing the test on protocol errors
+func callTool(t *testing.T, session *mcp.ClientSession, name string, args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("CallTool(%s): %v", name, err)
+	}
+	return result
+}
+
+// resultText joins the text content of a tool result
+func resultText(result *mcp.CallToolResult) string {
+	var text string
+	for _, content := range result.Content {
+		if c, ok := content.(*mcp.TextContent); ok {
+			text += c.Text
+		}
+	}
+	return text
+}
+
+// serveHTML serves page as text/html from a test server
+func serveHTML(t *testing.T, page string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// structured returns the structured content of a tool result as decoded by the client
+func structured(t *testing.T, result *mcp.CallToolResult) map[string]any {
+	t.Helper()
+	data, ok := result.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("structured content is %T, want an object", result.StructuredContent)
+	}
+	return data
+}
+
+func TestScrapeURLEmptyPage(t *testing.T) {
+	page := serveHTML(t, `<html><head><title>App</title><script src="app.js"></script></head><body><div id="root"></div></body></html>`)
+	session := connect(t, newTestServer(t))
+
+	result := callTool(t, session, "scrape_url", map[string]any{"url": page.URL})
+	if result.IsError {
+		t.Fatalf("empty page reported as an error: %s", resultText(result))
+	}
+	text := resultText(result)
+	if !strings.Contains(text, "no extractable text; it may require JavaScript rendering") {
+		t.Errorf("result does not explain the empty page:\n%s", text)
+	}
+	if strings.Contains(text, "RAW_CONTENT") {
+		t.Errorf("result hands empty content downstream:\n%s", text)
+	}
+	if data := structured(t, result); data["no_content"] != true {
+		t.Errorf("no_content = %v, want true", data["no_content"])
+	}
+}
+
+func TestScrapeURLsReportsProgress(t *testing.T) {
+	page := serveHTML(t, `<html><head><title>Page</title></head><body><p>Batch content.</p></body></html>`)
+	progress := make(chan *mcp.ProgressNotificationParams, 10)
+	session := connectWithOptions(t, newTestServer(t), &mcp.ClientOptions{
+		ProgressNotificationHandler: func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+			progress <- req.Params
+		},
+	})
+
+	urls := []string{page.URL + "/a", page.URL + "/b", "ftp://example.com/c"}
+	// SetProgressToken only writes into an existing Meta map
+	params := &mcp.CallToolParams{Name: "scrape_urls", Arguments: map[string]any{"urls": urls}, Meta: mcp.Meta{}}
+	params.SetProgressToken("batch-1")
+	result, err := session.CallTool(context.Background(), params)
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for range urls {
+		select {
+		case p := <-progress:
+			if p.ProgressToken != "batch-1" || p.Total != float64(len(urls)) {
+				t.Errorf("progress token %v total %v, want batch-1 and %d", p.ProgressToken, p.Total, len(urls))
+			}
+			var item ScrapeURLsItem
+			if err := json.Unmarshal([]byte(p.Message), &item); err != nil {
+				t.Fatalf("progress message %q is not an item: %v", p.Message, err)
+			}
+			seen[item.URL] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d progress notifications, want %d", len(seen), len(urls))
+		}
+	}
+	for _, url := range urls {
+		if !seen[url] {
+			t.Errorf("no progress notification for %s", url)
+		}
+	}
+
+	// The final result still aggregates every item in input order
+	text := resultText(result)
+	if !strings.Contains(text, "Scraped 2 of 3 URLs") {
+		t.Errorf("aggregate result does not count the batch:\n%s", text)
+	}
+	lines := strings.Split(strings.TrimSpace(text[strings.Index(text, "{"):]), "\n")
+	if len(lines) != len(urls) {
+		t.Fatalf("got %d NDJSON lines, want %d", len(lines), len(urls))
+	}
+	for i, line := range lines {
+		var item ScrapeURLsItem
+		if err := json.Unmarshal([]byte(line), &item); err != nil || item.Index != i || item.URL != urls[i] {
+			t.Errorf("line %d = %s, want item %d for %s", i, line, i, urls[i])
+		}
+	}
+}
+
+func TestScrapeURLFailureDetails(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	session := connect(t, newTestServer(t))
+
+	result := callTool(t, session, "scrape_url", map[string]any{"url": server.URL + "/missing"})
+	if !result.IsError {
+		t.Fatalf("404 not reported as an error: %s", resultText(result))
+	}
+	if !strings.HasPrefix(resultText(result), "Error scraping URL:") {
+		t.Errorf("human text = %q", resultText(result))
+	}
+	data := structured(t, result)
+	if data["status_code"] != float64(http.StatusNotFound) || data["error_code"] != scraper.ErrorCodeHTTPStatus {
+		t.Errorf("status_code = %v, error_code = %v; want 404 and %s", data["status_code"], data["error_code"], scraper.ErrorCodeHTTPStatus)
+	}
+}
+
+func TestScrapeURLTimeoutDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	mcpServer := newTestServer(t)
+	mcpServer.scraperService = newTestScraper(t, scraper.Config{Timeout: 100 * time.Millisecond})
+	session := connect(t, mcpServer)
+
+	result := callTool(t, session, "scrape_url", map[string]any{"url": server.URL})
+	data := structured(t, result)
+	if !result.IsError || data["error_code"] != scraper.ErrorCodeTimeout || data["status_code"] != float64(0) {
+		t.Errorf("error_code = %v, status_code = %v; want %s without a status", data["error_code"], data["status_code"], scraper.ErrorCodeTimeout)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Upstreams shared by the tool handlers
+const (
+	upstreamWeb = "web" // sites fetched by the scrape tools
+	upstreamLLM = "llm" // the summarize tool's LLM provider
+)
+
+// defaultUpstreamIdleTimeout is how long an upstream goes unused before its idle connections are closed
+const defaultUpstreamIdleTimeout = 90 * time.Second
+
+// maxIdleConnsPerUpstreamHost is how many idle connections each upstream keeps per
+// host, enough for concurrent tool calls to one host to reuse them (the net/http
+// default keeps 2)
+const maxIdleConnsPerUpstreamHost = 16
+
+// upstreamPool shares one pooled HTTP transport per upstream across concurrent
+// tool calls. Handlers hold a reference to an upstream while they use it; once
+// an upstream has gone unreferenced for idleTimeout, its idle connections are
+// closed rather than kept open for traffic that has stopped.
+type upstreamPool struct {
+	idleTimeout time.Duration
+
+	mu        sync.Mutex
+	upstreams map[string]*upstream
+}
+
+// upstream is one pooled transport with its references and usage counters
+type upstream struct {
+	transport *http.Transport
+	refs      int
+	idleSince time.Time // when refs last dropped to zero; zero once cleaned up
+	acquires  int64
+	cleanups  int64
+	requests  atomic.Int64
+	dials     atomic.Int64
+	reused    atomic.Int64
+}
+
+// upstreamStats reports an upstream's use, as served on the metrics endpoint
+type upstreamStats struct {
+	// Active is the number of tool calls holding a reference
+	Active   int   `json:"active"`
+	Acquires int64 `json:"acquires"`
+	Requests int64 `json:"requests"`
+	// Connections counts connections dialed; Reused counts requests sent on an existing one
+	Connections  int64 `json:"connections"`
+	Reused       int64 `json:"reused"`
+	IdleCleanups int64 `json:"idle_cleanups"`
+}
+
+// newUpstreamPool creates a pool that closes an upstream's idle connections
+// after idleTimeout without references; zero means defaultUpstreamIdleTimeout
+func newUpstreamPool(idleTimeout time.Duration) *upstreamPool {
+	if idleTimeout <= 0 {
+		idleTimeout = defaultUpstreamIdleTimeout
+	}
+	return &upstreamPool{idleTimeout: idleTimeout, upstreams: make(map[string]*upstream)}
+}
+
+// get returns the named upstream, creating it on first use. Callers hold p.mu.
+func (p *upstreamPool) get(name string) *upstream {
+	u, ok := p.upstreams[name]
+	if !ok {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = maxIdleConnsPerUpstreamHost
+		u = &upstream{transport: transport}
+		p.upstreams[name] = u
+	}
+	return u
+}
+
+// Transport returns the named upstream's shared transport, for a client's lifetime
+func (p *upstreamPool) Transport(name string) http.RoundTripper {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &pooledTransport{upstream: p.get(name)}
+}
+
+// acquire takes a reference to the named upstream for one tool call, returning
+// the function that releases it. Releasing more than once has no effect.
+func (p *upstreamPool) acquire(name string) (release func()) {
+	p.mu.Lock()
+	u := p.get(name)
+	u.refs++
+	u.acquires++
+	p.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			u.refs--
+			if u.refs == 0 {
+				u.idleSince = time.Now()
+			}
+		})
+	}
+}
+
+// closeIdle closes the idle connections of every upstream that has gone
+// unreferenced for idleTimeout as of now, returning how many were cleaned up
+func (p *upstreamPool) closeIdle(now time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cleaned := 0
+	for _, u := range p.upstreams {
+		if u.refs > 0 || u.idleSince.IsZero() || now.Sub(u.idleSince) < p.idleTimeout {
+			continue
+		}
+		u.transport.CloseIdleConnections()
+		u.idleSince = time.Time{}
+		u.cleanups++
+		cleaned++
+	}
+	return cleaned
+}
+
+// run closes idle upstreams' connections until ctx ends
+func (p *upstreamPool) run(ctx context.Context) {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.closeIdle(now)
+		}
+	}
+}
+
+// Stats returns the use of each upstream by name
+func (p *upstreamPool) Stats() map[string]upstreamStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]upstreamStats, len(p.upstreams))
+	for name, u := range p.upstreams {
+		stats[name] = upstreamStats{
+			Active:       u.refs,
+			Acquires:     u.acquires,
+			Requests:     u.requests.Load(),
+			Connections:  u.dials.Load(),
+			Reused:       u.reused.Load(),
+			IdleCleanups: u.cleanups,
+		}
+	}
+	return stats
+}
+
+// metricsHandler reports the upstream pool's stats as JSON
+func (p *upstreamPool) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"upstreams": p.Stats(),
+	})
+}
+
+// pooledTransport sends requests over an upstream's shared transport, counting
+// whether each one dialed a new connection or reused an idle one
+type pooledTransport struct {
+	upstream *upstream
+}
+
+func (t *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.upstream.requests.Add(1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.upstream.reused.Add(1)
+			} else {
+				t.upstream.dials.Add(1)
+			}
+		},
+	}
+	return t.upstream.transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newFakeLLM serves OpenAI-compatible chat completions that take delay to
+// answer, counting the connections clients open to it
+func newFakeLLM(t *testing.T, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: "test-model",
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "A summary."}},
+			},
+			Usage: openai.Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13},
+		})
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, &connections
+}
+
+func TestUpstreamPoolReusesConnections(t *testing.T) {
+	llm, connections := newFakeLLM(t, 20*time.Millisecond)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", llm.URL+"/v1")
+	t.Setenv("OPENAI_MODEL", "test-model")
+	server, err := NewMCPServer(zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewMCPServer: %v", err)
+	}
+	session := connect(t, server)
+
+	// Several waves of concurrent calls: later waves find the first wave's
+	// connections idle in the pool instead of dialing new ones
+	const waves, concurrent = 3, 4
+	for wave := 0; wave < waves; wave++ {
+		var wg sync.WaitGroup
+		for i := 0; i < concurrent; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result := callTool(t, session, "summarize", map[string]any{"content": "The quick brown fox jumps over the lazy dog."})
+				if result.IsError {
+					t.Errorf("summarize failed: %s", resultText(result))
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	if got := connections.Load(); got > concurrent {
+		t.Errorf("LLM saw %d connections for %d calls, want at most %d", got, waves*concurrent, concurrent)
+	}
+	stats := server.pool.Stats()[upstreamLLM]
+	if stats.Requests != waves*concurrent || stats.Acquires != waves*concurrent {
+		t.Errorf("stats = %+v, want %d requests and acquires", stats, waves*concurrent)
+	}
+	if stats.Connections > concurrent || stats.Reused < (waves-1)*concurrent {
+		t.Errorf("stats = %+v, want at most %d connections and the rest reused", stats, concurrent)
+	}
+	if stats.Active != 0 {
+		t.Errorf("stats.Active = %d after every call returned, want 0", stats.Active)
+	}
+}
+
+func TestUpstreamPoolReferenceCounting(t *testing.T) {
+	pool := newUpstreamPool(time.Minute)
+	first := pool.acquire(upstreamLLM)
+	second := pool.acquire(upstreamLLM)
+	later := time.Now().Add(time.Hour)
+
+	if got := pool.Stats()[upstreamLLM].Active; got != 2 {
+		t.Fatalf("Active = %d, want 2", got)
+	}
+	first()
+	first() // releasing twice has no effect
+	if got := pool.Stats()[upstreamLLM].Active; got != 1 {
+		t.Fatalf("Active = %d after one release, want 1", got)
+	}
+	if cleaned := pool.closeIdle(later); cleaned != 0 {
+		t.Errorf("closeIdle cleaned %d upstreams still in use", cleaned)
+	}
+
+	second()
+	if cleaned := pool.closeIdle(time.Now()); cleaned != 0 {
+		t.Errorf("closeIdle cleaned %d upstreams before the idle timeout", cleaned)
+	}
+	if cleaned := pool.closeIdle(later); cleaned != 1 {
+		t.Errorf("closeIdle cleaned %d upstreams, want the idle one", cleaned)
+	}
+	if cleaned := pool.closeIdle(later); cleaned != 0 {
+		t.Errorf("closeIdle cleaned %d upstreams again without new use", cleaned)
+	}
+	if stats := pool.Stats()[upstreamLLM]; stats.Active != 0 || stats.Acquires != 2 || stats.IdleCleanups != 1 {
+		t.Errorf("stats = %+v, want 0 active, 2 acquires, 1 idle cleanup", stats)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	pool := newUpstreamPool(0)
+	release := pool.acquire(upstreamWeb)
+	defer release()
+
+	recorder := httptest.NewRecorder()
+	pool.metricsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	var body struct {
+		Upstreams map[string]upstreamStats `json:"upstreams"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatalf("metrics body: %v", err)
+	}
+	if stats, ok := body.Upstreams[upstreamWeb]; !ok || stats.Active != 1 || stats.Acquires != 1 {
+		t.Errorf("metrics = %+v, want the web upstream with one active call", body.Upstreams)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// prettyEvents re-indents the JSON-RPC messages on SSE streams, for reading raw
+// frames while debugging. The SDK always encodes messages compactly, structured
+// content included, so this rewrites each event's data as indented JSON spread
+// over several data lines, which SSE clients join back together. Only the HTTP
+// transport can carry it: the stdio transport frames messages by newlines. JSON
+// embedded in result text is a string to the protocol, so MCPServer.encodeJSON
+// indents it separately.
+type prettyEvents struct {
+	next http.Handler
+}
+
+// ServeHTTP pretty-prints the event stream of GET requests; POSTs pass through
+func (p prettyEvents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		p.next.ServeHTTP(w, r)
+		return
+	}
+	p.next.ServeHTTP(&prettyEventWriter{ResponseWriter: w}, r)
+}
+
+// prettyEventWriter buffers the stream up to each event's blank-line terminator
+// and rewrites the event before passing it on
+type prettyEventWriter struct {
+	http.ResponseWriter
+	pending []byte
+}
+
+// Write passes on every complete event in the stream so far, holding back a partial one
+func (w *prettyEventWriter) Write(data []byte) (int, error) {
+	w.pending = append(w.pending, data...)
+	for {
+		end := bytes.Index(w.pending, []byte("\n\n"))
+		if end < 0 {
+			return len(data), nil
+		}
+		event := prettyEvent(w.pending[:end])
+		w.pending = w.pending[end+2:]
+		if _, err := w.ResponseWriter.Write(append(event, '\n', '\n')); err != nil {
+			return len(data), err
+		}
+	}
+}
+
+// Flush implements http.Flusher, which the SDK requires to stream events
+func (w *prettyEventWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// prettyEvent indents the JSON data lines of one SSE event, leaving other fields
+// and non-JSON data (such as the endpoint event's URL) as they are
+func prettyEvent(event []byte) []byte {
+	var out bytes.Buffer
+	for i, line := range bytes.Split(event, []byte("\n")) {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		data, ok := bytes.CutPrefix(line, []byte("data: "))
+		var indented bytes.Buffer
+		if !ok || !json.Valid(data) || json.Indent(&indented, data, "", "  ") != nil {
+			out.Write(line)
+			continue
+		}
+		for j, dataLine := range bytes.Split(indented.Bytes(), []byte("\n")) {
+			if j > 0 {
+				out.WriteByte('\n')
+			}
+			out.WriteString("data: ")
+			out.Write(dataLine)
+		}
+	}
+	return out.Bytes()
+}
+
+// encodeJSON encodes v for embedding in tool result text or progress messages,
+// indented when prettyJSON is set
+func (s *MCPServer) encodeJSON(v any) ([]byte, error) {
+	if s.prettyJSON {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
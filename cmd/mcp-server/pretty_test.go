@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// teeWriter copies everything written to an SSE stream into a buffer
+type teeWriter struct {
+	http.ResponseWriter
+	mu  *sync.Mutex
+	buf *bytes.Buffer
+}
+
+func (w teeWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	w.buf.Write(data)
+	w.mu.Unlock()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w teeWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// rawStream serves server over SSE, through prettyEvents when pretty is set, and
+// calls scrape_url on page with an SDK client, returning the raw event stream
+func rawStream(t *testing.T, server *MCPServer, pretty bool, page string) (*mcp.CallToolResult, string) {
+	t.Helper()
+	var handler http.Handler = mcp.NewSSEHandler(func(r *http.Request) *mcp.Server { return server.mcpServer })
+	if pretty {
+		handler = prettyEvents{next: handler}
+	}
+	var mu sync.Mutex
+	var raw bytes.Buffer
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(teeWriter{ResponseWriter: w, mu: &mu, buf: &raw}, r)
+	}))
+	t.Cleanup(httpServer.Close)
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(context.Background(), mcp.NewSSEClientTransport(httpServer.URL, nil), nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	result := callTool(t, session, "scrape_url", map[string]any{"url": page})
+	session.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	return result, raw.String()
+}
+
+// indentedStructured matches the structured content of a result indented onto its own data line
+var indentedStructured = regexp.MustCompile(`\ndata: +"structuredContent": \{\n`)
+
+func TestPrettyEventStream(t *testing.T) {
+	page := serveHTML(t, `<html><head><title>Pretty</title></head><body><p>Enough words on this page to count as extractable text.</p></body></html>`)
+
+	for _, pretty := range []bool{false, true} {
+		result, raw := rawStream(t, newTestServer(t), pretty, page.URL)
+		if result.IsError || structured(t, result)["title"] != "Pretty" {
+			t.Fatalf("pretty=%v: the client could not read the result: %s", pretty, resultText(result))
+		}
+		indented := indentedStructured.MatchString(raw)
+		if indented != pretty {
+			t.Errorf("pretty=%v: structured content indented = %v in the stream:\n%s", pretty, indented, raw)
+		}
+		if !strings.Contains(raw, "event: endpoint\ndata: /?sessionid=") {
+			t.Errorf("pretty=%v: the endpoint event was not passed through as is:\n%s", pretty, raw)
+		}
+	}
+}
+
+func TestPrettyEmbeddedJSON(t *testing.T) {
+	page := serveHTML(t, `<html><head><title>Page</title></head><body><p>Batch content.</p></body></html>`)
+	urls := []string{page.URL + "/a", page.URL + "/b"}
+
+	for _, pretty := range []bool{false, true} {
+		progress := make(chan string, len(urls))
+		server := newTestServer(t)
+		server.prettyJSON = pretty
+		session := connectWithOptions(t, server, &mcp.ClientOptions{
+			ProgressNotificationHandler: func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+				progress <- req.Params.Message
+			},
+		})
+		params := &mcp.CallToolParams{Name: "scrape_urls", Arguments: map[string]any{"urls": urls}, Meta: mcp.Meta{}}
+		params.SetProgressToken("batch")
+		result, err := session.CallTool(context.Background(), params)
+		if err != nil {
+			t.Fatalf("CallTool: %v", err)
+		}
+
+		for range urls {
+			select {
+			case message := <-progress:
+				if got := strings.Contains(message, "\n  \"index\": "); got != pretty {
+					t.Errorf("pretty=%v: progress message indented = %v: %s", pretty, got, message)
+				}
+				var item ScrapeURLsItem
+				if err := json.Unmarshal([]byte(message), &item); err != nil {
+					t.Errorf("pretty=%v: progress message is not an item: %v", pretty, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("pretty=%v: missing progress notifications", pretty)
+			}
+		}
+
+		// The aggregate still decodes as a stream of items, one line each only when compact
+		text := result.Content[1].(*mcp.TextContent).Text
+		decoder := json.NewDecoder(strings.NewReader(text))
+		for i := range urls {
+			var item ScrapeURLsItem
+			if err := decoder.Decode(&item); err != nil || item.Index != i {
+				t.Errorf("pretty=%v: item %d = %+v, %v", pretty, i, item, err)
+			}
+		}
+		if lines := strings.Count(text, "\n"); (lines == len(urls)) == pretty {
+			t.Errorf("pretty=%v: aggregate spans %d lines for %d items:\n%s", pretty, lines, len(urls), text)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// sessionRetryAfter is the Retry-After hint sent when every session slot is taken
+const sessionRetryAfter = 5 * time.Second
+
+// sessionLimiter caps concurrent SSE sessions. Each GET opens a session that lasts
+// as long as the request, so GETs beyond the limit are rejected with 503; POSTs
+// deliver messages to existing sessions and always pass through.
+type sessionLimiter struct {
+	next   http.Handler
+	max    int64 // zero or less means unlimited
+	active atomic.Int64
+	logger zerolog.Logger
+}
+
+// newSessionLimiter wraps an SSE handler, allowing at most max concurrent sessions
+func newSessionLimiter(next http.Handler, max int, logger zerolog.Logger) *sessionLimiter {
+	return &sessionLimiter{next: next, max: int64(max), logger: logger}
+}
+
+// ServeHTTP opens a session if a slot is free, holding it until the stream ends
+func (l *sessionLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		l.next.ServeHTTP(w, r)
+		return
+	}
+
+	if !l.acquire() {
+		l.logger.Warn().Int64("max_sessions", l.max).Str("remote", r.RemoteAddr).Msg("Rejecting SSE session: limit reached")
+		w.Header().Set("Retry-After", strconv.Itoa(int(sessionRetryAfter.Seconds())))
+		http.Error(w, "too many concurrent sessions", http.StatusServiceUnavailable)
+		return
+	}
+	defer l.active.Add(-1)
+	l.next.ServeHTTP(w, r)
+}
+
+// acquire takes a session slot, reporting false when none is free
+func (l *sessionLimiter) acquire() bool {
+	for {
+		active := l.active.Load()
+		if l.max > 0 && active >= l.max {
+			return false
+		}
+		if l.active.CompareAndSwap(active, active+1) {
+			return true
+		}
+	}
+}
+
+// Active returns the number of open sessions
+func (l *sessionLimiter) Active() int {
+	return int(l.active.Load())
+}
+
+// healthHandler reports liveness and session usage as JSON
+func (l *sessionLimiter) healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "ok",
+		"active_sessions": l.Active(),
+		"max_sessions":    l.max,
+	})
+}
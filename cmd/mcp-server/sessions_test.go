@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// holdingSSE stands in for the SSE handler: GETs stream until the client goes
+// away, POSTs are answered at once
+func holdingSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	<-r.Context().Done()
+}
+
+// openSession starts a GET against url, returning its status and the function
+// that closes it
+func openSession(t *testing.T, url string) (*http.Response, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatalf("GET: %v", err)
+	}
+	return resp, func() {
+		cancel()
+		resp.Body.Close()
+	}
+}
+
+// waitForActive waits until the limiter counts want open sessions
+func waitForActive(t *testing.T, limiter *sessionLimiter, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for limiter.Active() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Active() = %d, want %d", limiter.Active(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSessionLimiter(t *testing.T) {
+	const max = 2
+	limiter := newSessionLimiter(http.HandlerFunc(holdingSSE), max, zerolog.Nop())
+	server := httptest.NewServer(limiter)
+	t.Cleanup(server.Close)
+
+	var closers []context.CancelFunc
+	for i := 0; i < max; i++ {
+		resp, closeSession := openSession(t, server.URL)
+		closers = append(closers, closeSession)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("session %d status = %d, want 200", i, resp.StatusCode)
+		}
+	}
+	t.Cleanup(func() {
+		for _, closeSession := range closers {
+			closeSession()
+		}
+	})
+	waitForActive(t, limiter, max)
+
+	resp, closeRejected := openSession(t, server.URL)
+	closeRejected()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "5" {
+		t.Errorf("session over the limit = %d with Retry-After %q, want 503 with 5", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	// Messages to existing sessions are not sessions themselves
+	post, err := http.Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusAccepted {
+		t.Errorf("POST at the limit = %d, want it passed through", post.StatusCode)
+	}
+
+	recorder := httptest.NewRecorder()
+	limiter.healthHandler(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var health struct {
+		Status         string `json:"status"`
+		ActiveSessions int    `json:"active_sessions"`
+		MaxSessions    int    `json:"max_sessions"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&health); err != nil {
+		t.Fatalf("health body: %v", err)
+	}
+	if health.Status != "ok" || health.ActiveSessions != max || health.MaxSessions != max {
+		t.Errorf("health = %+v, want ok with %d of %d sessions", health, max, max)
+	}
+
+	// A closed session frees its slot
+	closers[0]()
+	waitForActive(t, limiter, max-1)
+	resp, closeSession := openSession(t, server.URL)
+	closers = append(closers, closeSession)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("session after one closed = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestSessionLimiterUnlimited(t *testing.T) {
+	limiter := newSessionLimiter(http.HandlerFunc(holdingSSE), 0, zerolog.Nop())
+	server := httptest.NewServer(limiter)
+	t.Cleanup(server.Close)
+
+	for i := 0; i < 5; i++ {
+		resp, closeSession := openSession(t, server.URL)
+		t.Cleanup(closeSession)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("session %d status = %d, want 200 without a limit", i, resp.StatusCode)
+		}
+	}
+}
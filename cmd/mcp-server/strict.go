@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// addTool registers a typed tool like mcp.AddTool, but first checks the raw
+// arguments of each call against the tool's InputSchema when strictArgs is set.
+// The SDK only validates after decoding into the Go struct, where a wrong type or
+// unknown field surfaces as an opaque protocol error; strict checking reports the
+// violation as an IsError result the client can act on, before the handler runs.
+// Every call is bounded by the tool's timeout.
+func addTool[In, Out any](s *MCPServer, t *mcp.Tool, h mcp.ToolHandlerFor[In, Out]) error {
+	tool, handler := mcp.ToolFor(t, h)
+	resolved, err := tool.InputSchema.Resolve(nil)
+	if err != nil {
+		return fmt.Errorf("tool %s: invalid input schema: %w", tool.Name, err)
+	}
+
+	s.mcpServer.AddTool(tool, s.withTimeout(tool.Name, func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.strictArgs {
+			if err := validateArguments(resolved, req.Params.Arguments); err != nil {
+				s.logger.Warn().Err(err).Str("tool", tool.Name).Msg("Rejected tool call with invalid arguments")
+				return &mcp.CallToolResult{
+					Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid arguments for %s: %v", tool.Name, err)}},
+					IsError: true,
+				}, nil
+			}
+		}
+		return handler(ctx, req)
+	}))
+	return nil
+}
+
+// validateArguments checks a call's raw JSON arguments against resolved. Missing
+// arguments are treated as an empty object, and properties the schema does not
+// declare are violations unless it sets additionalProperties.
+func validateArguments(resolved *jsonschema.Resolved, arguments any) error {
+	raw, ok := arguments.(json.RawMessage)
+	if !ok {
+		return fmt.Errorf("arguments are not JSON")
+	}
+	var args any = map[string]any{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return fmt.Errorf("arguments are not valid JSON: %w", err)
+		}
+	}
+	if object, ok := args.(map[string]any); ok && resolved.Schema().AdditionalProperties == nil {
+		var unknown []string
+		for name := range object {
+			if _, ok := resolved.Schema().Properties[name]; !ok {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return fmt.Errorf("unknown properties %q (expected: %s)", unknown, strings.Join(slices.Sorted(maps.Keys(resolved.Schema().Properties)), ", "))
+		}
+	}
+	return resolved.Validate(args)
+}
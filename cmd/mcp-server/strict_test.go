@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestStrictArgumentsRejectSchemaViolations(t *testing.T) {
+	var hits atomic.Int32
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Page</title></head><body><p>Some text.</p></body></html>`))
+	}))
+	t.Cleanup(page.Close)
+	session := connect(t, newTestServer(t))
+
+	tests := []struct {
+		name string
+		tool string
+		args map[string]any
+		want string
+	}{
+		{name: "wrong type", tool: "scrape_url", args: map[string]any{"url": 42}, want: "url"},
+		{name: "unknown property", tool: "scrape_url", args: map[string]any{"url": page.URL, "depth": 2}, want: `unknown properties ["depth"]`},
+		{name: "missing required", tool: "scrape_url", args: map[string]any{"selector": "p"}, want: "url"},
+		{name: "wrong item type", tool: "scrape_urls", args: map[string]any{"urls": []any{page.URL, 7}}, want: "urls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := callTool(t, session, tt.tool, tt.args)
+			text := resultText(result)
+			if !result.IsError || !strings.HasPrefix(text, "Invalid arguments for "+tt.tool+": ") || !strings.Contains(text, tt.want) {
+				t.Errorf("result = %q (IsError %v), want an invalid arguments error naming %s", text, result.IsError, tt.want)
+			}
+		})
+	}
+	if got := hits.Load(); got != 0 {
+		t.Errorf("the page was fetched %d times, want the handlers never run", got)
+	}
+
+	if result := callTool(t, session, "scrape_url", map[string]any{"url": page.URL, "selector": "p"}); result.IsError {
+		t.Errorf("valid arguments rejected: %s", resultText(result))
+	}
+}
+
+func TestStrictArgumentsOff(t *testing.T) {
+	page := serveHTML(t, `<html><head><title>Page</title></head><body><p>Some text.</p></body></html>`)
+	server := newTestServer(t)
+	server.strictArgs = false
+	session := connect(t, server)
+
+	// Without strict checking an unknown property only surfaces as a protocol error
+	_, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "scrape_url", Arguments: map[string]any{"url": page.URL, "depth": 2}})
+	if err == nil || !strings.Contains(err.Error(), `unknown field "depth"`) {
+		t.Errorf("CallTool error = %v, want the SDK's decoding error", err)
+	}
+}
+
+func TestValidateArguments(t *testing.T) {
+	schema := &jsonschema.Schema{
+		Type:     "object",
+		Required: []string{"url"},
+		Properties: map[string]*jsonschema.Schema{
+			"url":      {Type: "string"},
+			"selector": {Type: "string"},
+		},
+	}
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		arguments any
+		want      string
+	}{
+		{name: "valid", arguments: json.RawMessage(`{"url": "https://example.com"}`)},
+		{name: "not JSON", arguments: map[string]any{"url": "https://example.com"}, want: "arguments are not JSON"},
+		{name: "invalid JSON", arguments: json.RawMessage(`{"url": `), want: "arguments are not valid JSON"},
+		{name: "missing arguments", arguments: json.RawMessage(nil), want: "url"},
+		{name: "unknown properties sorted", arguments: json.RawMessage(`{"url": "x", "b": 1, "a": 2}`), want: `unknown properties ["a" "b"] (expected: selector, url)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateArguments(resolved, tt.arguments)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("validateArguments: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validateArguments error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/HeidiZHH/skull/internal/pagetype"
+	"github.com/HeidiZHH/skull/internal/summarizer"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/rs/zerolog"
+)
+
+// Bounds on summarize tool inputs
+const (
+	maxSummaryLength   = 5000 // words
+	maxBulletCount     = 50
+	maxReadingLevel    = 18
+	maxRequiredSection = 20
+)
+
+// socialPlatforms are the summarizer's "social" style targets
+var socialPlatforms = []string{"short", "twitter", "linkedin"}
+
+// summarizerFromEnv creates the summarize tool's backend from the same
+// OPENAI_API_KEY, OPENAI_BASE_URL, and OPENAI_MODEL variables the agent CLI reads,
+// sending its requests over transport. Without an API key it returns nil, and the
+// tool is not offered.
+func summarizerFromEnv(logger zerolog.Logger, transport http.RoundTripper) (*summarizer.Service, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, nil
+	}
+	model := os.Getenv("OPENAI_MODEL")
+	if model == "" {
+		model = "gpt-3.5-turbo"
+	}
+	return summarizer.NewService(summarizer.Config{
+		Provider:  "openai",
+		APIKey:    apiKey,
+		BaseURL:   strings.TrimSpace(os.Getenv("OPENAI_BASE_URL")),
+		Model:     model,
+		MaxTokens: 1000,
+		Transport: transport,
+	}, logger)
+}
+
+// summarizeTool describes the summarize tool, whose arguments are a summarizer.Request
+func summarizeTool() *mcp.Tool {
+	return &mcp.Tool{
+		Name:        "summarize",
+		Description: "Summarize text with the full set of summarizer options (style, length, language, output format, and more), returning the structured summary",
+		InputSchema: &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"content": {
+					Type:        "string",
+					Description: "The text to summarize",
+				},
+				"max_length": {
+					Type:        "integer",
+					Description: "Target summary length in words (default 200)",
+					Minimum:     jsonschema.Ptr(0.0),
+					Maximum:     jsonschema.Ptr(float64(maxSummaryLength)),
+				},
+				"style": {
+					Type:        "string",
+					Description: "Summary style (default concise)",
+					Enum:        stringEnum(summarizer.Styles),
+				},
+				"language": {
+					Type:        "string",
+					Description: "Language to write the summary in (e.g. French); defaults to the model's choice",
+				},
+				"format": {
+					Type:        "string",
+					Description: "Output format; json returns bullets for bullet_points and sections for required_sections",
+					Enum:        stringEnum([]string{"text", "json"}),
+				},
+				"bullet_count": {
+					Type:        "integer",
+					Description: "Exact number of bullets for the bullet_points style",
+					Minimum:     jsonschema.Ptr(0.0),
+					Maximum:     jsonschema.Ptr(float64(maxBulletCount)),
+				},
+				"focus": {
+					Type:        "string",
+					Description: "Aspect the summary should emphasize (e.g. pricing and availability)",
+				},
+				"reading_level": {
+					Type:        "integer",
+					Description: "US school grade the summary should be readable at (0 for no constraint)",
+					Minimum:     jsonschema.Ptr(0.0),
+					Maximum:     jsonschema.Ptr(float64(maxReadingLevel)),
+				},
+				"platform": {
+					Type:        "string",
+					Description: "Target of the social style (default short)",
+					Enum:        stringEnum(socialPlatforms),
+				},
+				"required_sections": {
+					Type:        "array",
+					Description: "Section names the summary must consist of, in order",
+					Items:       &jsonschema.Schema{Type: "string"},
+					MaxItems:    jsonschema.Ptr(maxRequiredSection),
+				},
+				"page_type": {
+					Type:        "string",
+					Description: "Kind of page the content came from, for type-specific summaries",
+					Enum:        stringEnum(pagetype.All),
+				},
+				"bilingual_target": {
+					Type:        "string",
+					Description: "Also translate the summary into this language, keeping one in the source's language",
+				},
+				"preserve_numbers": {
+					Type:        "boolean",
+					Description: "Keep the source's figures exact and flag numbers not found in the source",
+				},
+				"verify_faithfulness": {
+					Type:        "boolean",
+					Description: "Check the summary against the source for unsupported claims",
+				},
+				"two_pass": {
+					Type:        "boolean",
+					Description: "Draft, critique, and revise the summary",
+				},
+				"score_coverage": {
+					Type:        "boolean",
+					Description: "Estimate how much of the source the summary covers",
+				},
+			},
+			Required: []string{"content"},
+		},
+	}
+}
+
+// stringEnum converts strings to the values of a JSON schema enum
+func stringEnum(values []string) []any {
+	enum := make([]any, len(values))
+	for i, value := range values {
+		enum[i] = value
+	}
+	return enum
+}
+
+// validateSummarizeArgs checks the enum and range inputs the summarizer would otherwise
+// reject late or silently ignore
+func validateSummarizeArgs(args summarizer.Request) error {
+	if strings.TrimSpace(args.Content) == "" {
+		return fmt.Errorf("content is required")
+	}
+	if args.Style != "" {
+		if err := summarizer.ValidateStyle(args.Style); err != nil {
+			return err
+		}
+	}
+	if args.Format != "" && args.Format != "text" && args.Format != "json" {
+		return fmt.Errorf("unsupported format %q (supported: text, json)", args.Format)
+	}
+	if args.Platform != "" && !contains(socialPlatforms, args.Platform) {
+		return fmt.Errorf("unsupported platform %q (supported: %s)", args.Platform, strings.Join(socialPlatforms, ", "))
+	}
+	if args.PageType != "" && !pagetype.Valid(args.PageType) {
+		return fmt.Errorf("unsupported page_type %q (supported: %s)", args.PageType, strings.Join(pagetype.All, ", "))
+	}
+	switch {
+	case args.MaxLength < 0 || args.MaxLength > maxSummaryLength:
+		return fmt.Errorf("max_length must be between 0 and %d (got %d)", maxSummaryLength, args.MaxLength)
+	case args.BulletCount < 0 || args.BulletCount > maxBulletCount:
+		return fmt.Errorf("bullet_count must be between 0 and %d (got %d)", maxBulletCount, args.BulletCount)
+	case args.ReadingLevel < 0 || args.ReadingLevel > maxReadingLevel:
+		return fmt.Errorf("reading_level must be between 0 and %d (got %d)", maxReadingLevel, args.ReadingLevel)
+	case len(args.RequiredSections) > maxRequiredSection:
+		return fmt.Errorf("required_sections must have at most %d entries (got %d)", maxRequiredSection, len(args.RequiredSections))
+	}
+	return nil
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// handleSummarize summarizes the given text, returning the summarizer's Response as
+// structured content. Like every tool registered through addTool, it runs under
+// withTimeout, so a hung completion is cancelled after the tool's timeout.
+func (s *MCPServer) handleSummarize(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	args summarizer.Request,
+) (*mcp.CallToolResult, any, error) {
+	s.logger.Info().
+		Int("content_length", len(args.Content)).
+		Str("style", args.Style).
+		Str("format", args.Format).
+		Msg("Summarizing content")
+	defer s.pool.acquire(upstreamLLM)()
+
+	if err := validateSummarizeArgs(args); err != nil {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Invalid summarize arguments: %v", err)},
+			},
+			IsError: true,
+		}, nil, nil
+	}
+
+	response, err := s.summarizerService.Summarize(ctx, args)
+	if err != nil {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Error summarizing content: %v", err)},
+			},
+			IsError: true,
+		}, nil, nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: response.Summary},
+		},
+	}, response, nil
+}
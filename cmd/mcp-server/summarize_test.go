@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/summarizer"
+	"github.com/rs/zerolog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// completions is a fake LLM answering each chat completion with respond, called
+// with the request's last message, and recording those messages
+type completions struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+// Prompts returns the last message of each request received so far
+func (c *completions) Prompts() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.prompts...)
+}
+
+// newSummarizeServer creates an MCPServer whose summarize tool talks to a fake
+// LLM answering with respond
+func newSummarizeServer(t *testing.T, respond func(prompt string) string) (*MCPServer, *completions) {
+	t.Helper()
+	fake := &completions{}
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		prompt := req.Messages[len(req.Messages)-1].Content
+		fake.mu.Lock()
+		fake.prompts = append(fake.prompts, prompt)
+		fake.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: "test-model",
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: respond(prompt)}, FinishReason: openai.FinishReasonStop},
+			},
+			Usage: openai.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+		})
+	}))
+	t.Cleanup(llm.Close)
+
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", llm.URL+"/v1")
+	t.Setenv("OPENAI_MODEL", "test-model")
+	server, err := NewMCPServer(zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewMCPServer: %v", err)
+	}
+	server.strictArgs = true
+	return server, fake
+}
+
+const summarizeSource = "The city council approved a budget of 4.2 million dollars on Tuesday. Spending on parks rises by 12 percent, and the library will open on Sundays. A vote on road repairs is planned for March."
+
+func TestSummarizeTool(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       map[string]any
+		reply      string
+		wantPrompt string
+		want       map[string]any
+	}{
+		{
+			name:       "concise text",
+			args:       map[string]any{"style": "concise", "max_length": 30},
+			reply:      "The council passed a 4.2 million dollar budget.",
+			wantPrompt: "30 words",
+			want:       map[string]any{"summary": "The council passed a 4.2 million dollar budget."},
+		},
+		{
+			name:       "json bullets",
+			args:       map[string]any{"style": "bullet_points", "format": "json", "bullet_count": 2},
+			reply:      `{"bullets": ["Budget of 4.2 million dollars approved", "Parks spending up 12 percent"]}`,
+			wantPrompt: `{"bullets": [`,
+			want:       map[string]any{"bullets": []any{"Budget of 4.2 million dollars approved", "Parks spending up 12 percent"}},
+		},
+		{
+			name:       "tldr plus in French",
+			args:       map[string]any{"style": "tldr_plus", "language": "French"},
+			reply:      `{"tldr": "Budget adopté.", "summary": "Le conseil a adopté un budget de 4,2 millions de dollars."}`,
+			wantPrompt: "French",
+			want:       map[string]any{"tldr": "Budget adopté.", "summary": "Le conseil a adopté un budget de 4,2 millions de dollars."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, llm := newSummarizeServer(t, func(string) string { return tt.reply })
+			session := connect(t, server)
+
+			tt.args["content"] = summarizeSource
+			result := callTool(t, session, "summarize", tt.args)
+			if result.IsError {
+				t.Fatalf("summarize failed: %s", resultText(result))
+			}
+			data := structured(t, result)
+			for key, want := range tt.want {
+				got, _ := json.Marshal(data[key])
+				wantJSON, _ := json.Marshal(want)
+				if string(got) != string(wantJSON) {
+					t.Errorf("structured %s = %s, want %s", key, got, wantJSON)
+				}
+			}
+			if data["model"] != "test-model" || data["tokens_used"] != 120.0 {
+				t.Errorf("structured content = %v, want the model and token usage", data)
+			}
+			if prompts := llm.Prompts(); len(prompts) == 0 || !strings.Contains(prompts[0], tt.wantPrompt) {
+				t.Errorf("prompts = %q, want the first to contain %q", prompts, tt.wantPrompt)
+			}
+		})
+	}
+}
+
+func TestSummarizeToolRejectsInvalidArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args summarizer.Request
+		want string
+	}{
+		{name: "blank content", args: summarizer.Request{Content: "  "}, want: "content is required"},
+		{name: "unknown style", args: summarizer.Request{Content: summarizeSource, Style: "poem"}, want: "poem"},
+		{name: "unknown format", args: summarizer.Request{Content: summarizeSource, Format: "xml"}, want: `unsupported format "xml"`},
+		{name: "max_length too large", args: summarizer.Request{Content: summarizeSource, MaxLength: maxSummaryLength + 1}, want: "max_length must be between 0 and 5000"},
+		{name: "negative bullet_count", args: summarizer.Request{Content: summarizeSource, BulletCount: -1}, want: "bullet_count must be between 0 and 50"},
+		{name: "unknown platform", args: summarizer.Request{Content: summarizeSource, Style: "social", Platform: "myspace"}, want: `unsupported platform "myspace"`},
+	}
+	server, llm := newSummarizeServer(t, func(string) string { return "unused" })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The handler's own checks, for clients that bypass the schema
+			result, _, err := server.handleSummarize(context.Background(), nil, tt.args)
+			if err != nil {
+				t.Fatalf("handleSummarize: %v", err)
+			}
+			if !result.IsError || !strings.Contains(resultText(result), tt.want) {
+				t.Errorf("result = %q (IsError %v), want an error containing %q", resultText(result), result.IsError, tt.want)
+			}
+		})
+	}
+
+	// Over the protocol, the tool's schema turns away enum violations first
+	result := callTool(t, connect(t, server), "summarize", map[string]any{"content": summarizeSource, "style": "poem"})
+	if !result.IsError || !strings.Contains(resultText(result), "Invalid arguments for summarize") {
+		t.Errorf("result = %q (IsError %v), want the style rejected by the schema", resultText(result), result.IsError)
+	}
+	if prompts := llm.Prompts(); len(prompts) != 0 {
+		t.Errorf("the LLM was called %d times for invalid arguments", len(prompts))
+	}
+}
+
+func TestSummarizeToolTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the completion is cancelled, which the server only notices
+		// once the request body has been read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+	defer slow.Close()
+
+	server, _ := newSummarizeServer(t, func(string) string { return "unused" })
+	t.Setenv("OPENAI_BASE_URL", slow.URL+"/v1")
+	server.summarizerService, _ = summarizerFromEnv(zerolog.Nop(), nil)
+	server.toolTimeouts = map[string]time.Duration{"summarize": 100 * time.Millisecond}
+	session := connect(t, server)
+
+	start := time.Now()
+	result := callTool(t, session, "summarize", map[string]any{"content": summarizeSource})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("call took %s, want about the 100ms timeout", elapsed)
+	}
+	if !result.IsError || !strings.Contains(resultText(result), "summarize timed out after 100ms") {
+		t.Errorf("result = %q (IsError %v), want a timeout error", resultText(result), result.IsError)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(3 * time.Second):
+		t.Error("the LLM request was not cancelled")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// toolTimeout returns the limit on one call of the named tool: its entry in
+// toolTimeouts, else defaultToolTimeout. Zero means no limit.
+func (s *MCPServer) toolTimeout(name string) time.Duration {
+	if timeout, ok := s.toolTimeouts[name]; ok {
+		return timeout
+	}
+	return s.defaultToolTimeout
+}
+
+// withTimeout bounds a tool handler by the tool's timeout. When the deadline
+// passes, the handler's context is cancelled, which aborts its scrape or LLM
+// call, and the client gets a timeout IsError result without waiting for the
+// handler to wind down.
+func (s *MCPServer) withTimeout(name string, handler mcp.ToolHandler) mcp.ToolHandler {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		timeout := s.toolTimeout(name)
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		type outcome struct {
+			result *mcp.CallToolResult
+			err    error
+		}
+		done := make(chan outcome, 1)
+		go func() {
+			result, err := handler(ctx, req)
+			done <- outcome{result: result, err: err}
+		}()
+
+		select {
+		case o := <-done:
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return o.result, o.err
+			}
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// The client went away; there is nobody left to report to
+				return nil, ctx.Err()
+			}
+		}
+
+		s.logger.Warn().Str("tool", name).Dur("timeout", timeout).Msg("Tool call timed out")
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Tool %s timed out after %s", name, timeout)},
+			},
+			IsError: true,
+		}, nil
+	}
+}
+
+// parseToolTimeouts reads the -tool-timeouts flag, a comma-separated list of
+// tool=duration pairs such as "scrape_urls=5m,summarize=2m"
+func parseToolTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, duration, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid tool timeout %q: expected tool=duration", pair)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout for tool %s: %q is not a non-negative duration", name, duration)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToolTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the scrape is cancelled
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+	defer slow.Close()
+
+	server := newTestServer(t)
+	server.defaultToolTimeout = time.Minute
+	server.toolTimeouts = map[string]time.Duration{"scrape_url": 100 * time.Millisecond}
+	session := connect(t, server)
+
+	start := time.Now()
+	result := callTool(t, session, "scrape_url", map[string]any{"url": slow.URL})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("call took %s, want about the 100ms timeout", elapsed)
+	}
+	if !result.IsError {
+		t.Fatalf("IsError = false, want a timeout error")
+	}
+	if text := resultText(result); !strings.Contains(text, "timed out after 100ms") {
+		t.Errorf("result text = %q, want a timeout message", text)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(3 * time.Second):
+		t.Error("the upstream request was not cancelled")
+	}
+}
+
+func TestToolTimeoutDisabled(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Slow</title></head><body><p>A page that takes a moment to load but has plenty of words in it.</p></body></html>"))
+	}))
+	defer page.Close()
+
+	server := newTestServer(t)
+	server.defaultToolTimeout = 10 * time.Millisecond
+	server.toolTimeouts = map[string]time.Duration{"scrape_url": 0}
+	session := connect(t, server)
+
+	result := callTool(t, session, "scrape_url", map[string]any{"url": page.URL})
+	if result.IsError {
+		t.Fatalf("IsError = true with the tool's timeout disabled: %s", resultText(result))
+	}
+}
+
+func TestParseToolTimeouts(t *testing.T) {
+	timeouts, err := parseToolTimeouts(" scrape_urls=5m, summarize=90s,")
+	if err != nil {
+		t.Fatalf("parseToolTimeouts: %v", err)
+	}
+	if timeouts["scrape_urls"] != 5*time.Minute || timeouts["summarize"] != 90*time.Second || len(timeouts) != 2 {
+		t.Errorf("timeouts = %v", timeouts)
+	}
+	for _, invalid := range []string{"scrape_url", "=5s", "scrape_url=soon", "scrape_url=-1s"} {
+		if _, err := parseToolTimeouts(invalid); err == nil {
+			t.Errorf("parseToolTimeouts(%q) succeeded, want an error", invalid)
+		}
+	}
+}
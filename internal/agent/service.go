@@ -3,9 +3,22 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/HeidiZHH/skull/internal/audit"
+	"github.com/HeidiZHH/skull/internal/llm"
+	"github.com/HeidiZHH/skull/internal/retry"
 	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -21,8 +34,55 @@ type Agent struct {
 	tools      []ToolDefinition
 	mcpClient  *mcp.Client
 	mcpSession *mcp.ClientSession
+	sessionMu  sync.Mutex
+	// toolsMu guards tools, toolsErr, and toolsRefreshed
+	toolsMu sync.RWMutex
+	// toolsErr explains why no tools are loaded, if so
+	toolsErr       error
+	toolsRefreshed time.Time
+	// toolArgDefaults is the valid part of Config.ToolArgDefaults, decoded as JSON
+	// values; it is set by NewAgent and read-only afterwards
+	toolArgDefaults map[string]map[string]any
+	// history holds recent ProcessInput exchanges so follow-ups such as answers
+	// to clarifying questions keep their context; guarded by historyMu
+	historyMu sync.Mutex
+	history   []openai.ChatCompletionMessage
+	audit     *audit.Log
+	// usage totals the tokens of every completion; guarded by usageMu
+	usageMu sync.Mutex
+	usage   Usage
+	// progress maps the progress tokens of running tool calls to their routes;
+	// guarded by progressMu
+	progressMu   sync.Mutex
+	progress     map[string]*progressCall
+	progressNext int
 }
 
+// ToolProgress is a progress notification from a running tool call, such as one
+// per URL from scrape_urls. Total is zero when unknown.
+type ToolProgress struct {
+	Message  string
+	Progress float64
+	Total    float64
+}
+
+// Usage totals the tokens an Agent's chat completions have consumed
+type Usage struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	// Estimated is set when some counts were approximated from text length because
+	// the provider did not report usage, as for streamed replies
+	Estimated bool `json:"estimated"`
+}
+
+// maxHistoryMessages caps the conversation memory (a user and assistant message per exchange)
+const maxHistoryMessages = 10
+
+// ErrNoTools is returned by ProcessInput when Config.RequireTools is set and no tools could be loaded
+var ErrNoTools = errors.New("no tools available")
+
 // Config represents agent configuration
 type Config struct {
 	Provider    string // "openai", "custom", etc.
@@ -32,6 +92,154 @@ type Config struct {
 	MaxTokens   int
 	Temperature float32
 	MCPServer   string // MCP server endpoint for tool discovery
+	// RequireTools makes ProcessInput fail with ErrNoTools instead of running toolless
+	RequireTools bool
+	// ParseRetries is how many times an unparseable reply is retried, each attempt
+	// following the escalation schedule Temperature + n*TemperatureStep capped at
+	// MaxTemperature. Zero values default to 1 retry, a 0.2 step, and a cap of 1.0
+	// or Temperature, whichever is higher; a negative ParseRetries disables retrying.
+	ParseRetries    int
+	TemperatureStep float32
+	MaxTemperature  float32
+	// AuditLog, when set, records every chat completion as described in package audit
+	AuditLog io.Writer
+	// AuditMaxContent truncates audited message content to this many characters; zero keeps it whole
+	AuditMaxContent int
+	// ToolArgDefaults maps tool names to argument defaults that are merged into the
+	// model's tool calls without overriding arguments the model set. NewAgent copies
+	// them and checks them against the schemas of the tools it loads, dropping
+	// invalid ones; defaults for tools not loaded then are checked with each call.
+	ToolArgDefaults map[string]map[string]any
+	// ToolsTTL re-fetches the MCP tool list before ProcessInput once it is older than this; zero disables
+	ToolsTTL time.Duration
+	// Optional sampling parameters; zero leaves the provider default
+	TopP             float32
+	FrequencyPenalty float32
+	PresencePenalty  float32
+	// Retry governs retries of failed chat completions; the zero value makes a single
+	// attempt. Rate limits and server errors are retried unless Retry.Retryable says
+	// otherwise, after the delay a Retry-After header asks for when there is one.
+	Retry retry.Policy
+	// SchemaVersion is the reply contract the system prompt asks for; zero means
+	// ResponseSchemaVersion. Version 1 is the original contract without clarification
+	// fields, for prompts and models tuned to it.
+	SchemaVersion int
+	// CondenseToolOutput is the length in characters above which CondenseToolResult
+	// shortens a tool result into a brief summary for display; zero disables condensing
+	CondenseToolOutput int
+	// Reflect has ProcessInput double-check a tool plan in a second completion before
+	// returning it: a plan that does not fit the request is revised or kept with lowered
+	// confidence. It is off by default since it costs an extra call per planned request.
+	Reflect bool
+}
+
+// ResponseSchemaVersion is the newest reply contract: version 2 adds
+// needs_clarification, clarifying_question, and schema_version to version 1
+const ResponseSchemaVersion = 2
+
+// Validate checks the configuration for values that cannot work, reporting every problem found
+func (c Config) Validate() error {
+	var problems []error
+	if strings.TrimSpace(c.Model) == "" {
+		problems = append(problems, fmt.Errorf("Model is required"))
+	}
+	if c.MaxTokens < 0 {
+		problems = append(problems, fmt.Errorf("MaxTokens must not be negative (got %d)", c.MaxTokens))
+	}
+	if c.Temperature < 0 || c.Temperature > 2 {
+		problems = append(problems, fmt.Errorf("Temperature must be between 0 and 2 (got %g)", c.Temperature))
+	}
+	if c.TemperatureStep < 0 {
+		problems = append(problems, fmt.Errorf("TemperatureStep must not be negative (got %g)", c.TemperatureStep))
+	}
+	if c.MaxTemperature < 0 || c.MaxTemperature > 2 {
+		problems = append(problems, fmt.Errorf("MaxTemperature must be between 0 and 2 (got %g)", c.MaxTemperature))
+	} else if c.maxTemperature() < c.Temperature {
+		// The default cap follows Temperature, so only an explicit one can fall below it
+		problems = append(problems, fmt.Errorf("MaxTemperature (%g) must not be below Temperature (%g)", c.maxTemperature(), c.Temperature))
+	}
+	if c.TopP < 0 || c.TopP > 1 {
+		problems = append(problems, fmt.Errorf("TopP must be between 0 and 1 (got %g)", c.TopP))
+	}
+	if c.FrequencyPenalty < -2 || c.FrequencyPenalty > 2 {
+		problems = append(problems, fmt.Errorf("FrequencyPenalty must be between -2 and 2 (got %g)", c.FrequencyPenalty))
+	}
+	if c.PresencePenalty < -2 || c.PresencePenalty > 2 {
+		problems = append(problems, fmt.Errorf("PresencePenalty must be between -2 and 2 (got %g)", c.PresencePenalty))
+	}
+	if c.ToolsTTL < 0 {
+		problems = append(problems, fmt.Errorf("ToolsTTL must not be negative (got %s)", c.ToolsTTL))
+	}
+	if c.AuditMaxContent < 0 {
+		problems = append(problems, fmt.Errorf("AuditMaxContent must not be negative (got %d)", c.AuditMaxContent))
+	}
+	for toolName, defaults := range c.ToolArgDefaults {
+		for paramName, value := range defaults {
+			if _, err := json.Marshal(value); err != nil {
+				problems = append(problems, fmt.Errorf("ToolArgDefaults[%s][%s] is not JSON-encodable: %w", toolName, paramName, err))
+			}
+		}
+	}
+	if c.CondenseToolOutput < 0 {
+		problems = append(problems, fmt.Errorf("CondenseToolOutput must not be negative (got %d)", c.CondenseToolOutput))
+	}
+	if err := c.Retry.Validate(); err != nil {
+		problems = append(problems, fmt.Errorf("invalid Retry policy: %w", err))
+	}
+	if _, err := llm.NormalizeBaseURL(c.BaseURL); err != nil {
+		problems = append(problems, fmt.Errorf("BaseURL: %w", err))
+	}
+	if c.SchemaVersion < 0 || c.SchemaVersion > ResponseSchemaVersion {
+		problems = append(problems, fmt.Errorf("SchemaVersion must be between 1 and %d (got %d)", ResponseSchemaVersion, c.SchemaVersion))
+	}
+	if err := errors.Join(problems...); err != nil {
+		return fmt.Errorf("invalid agent config: %w", err)
+	}
+	return nil
+}
+
+// parseRetries returns the number of retries for unparseable replies
+func (c Config) parseRetries() int {
+	if c.ParseRetries < 0 {
+		return 0
+	}
+	if c.ParseRetries == 0 {
+		return 1
+	}
+	return c.ParseRetries
+}
+
+// retryTemperature returns the temperature for the given parse-failure retry attempt
+func (c Config) retryTemperature(attempt int) float32 {
+	step := c.TemperatureStep
+	if step <= 0 {
+		step = 0.2
+	}
+	return min(c.Temperature+float32(attempt)*step, c.maxTemperature())
+}
+
+// maxTemperature returns MaxTemperature, defaulting to 1.0 or Temperature when
+// that is higher, so retries never run colder than the first attempt
+func (c Config) maxTemperature() float32 {
+	if c.MaxTemperature <= 0 {
+		return max(1.0, c.Temperature)
+	}
+	return c.MaxTemperature
+}
+
+// schemaVersion returns the reply contract to request, defaulting to the newest
+func (c Config) schemaVersion() int {
+	if c.SchemaVersion == 0 {
+		return ResponseSchemaVersion
+	}
+	return c.SchemaVersion
+}
+
+// applySampling copies the optional sampling parameters onto a chat completion request
+func (c Config) applySampling(req *openai.ChatCompletionRequest) {
+	req.TopP = c.TopP
+	req.FrequencyPenalty = c.FrequencyPenalty
+	req.PresencePenalty = c.PresencePenalty
 }
 
 // ToolDefinition represents a tool that the agent can call
@@ -56,16 +264,183 @@ type Response struct {
 	Confidence  float64    `json:"confidence"`
 	Explanation string     `json:"explanation"`
 	PostProcess string     `json:"post_process,omitempty"`
+	// NeedsClarification is set by the model when the request is too ambiguous to act on
+	NeedsClarification bool   `json:"needs_clarification,omitempty"`
+	ClarifyingQuestion string `json:"clarifying_question,omitempty"`
+	// SchemaVersion is the reply contract the model followed; replies without one are version 1
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// ToolsUnavailable is set by the agent (not the model) when it is running without tools
+	ToolsUnavailable bool `json:"-"`
+	// Reflection is set by the agent (not the model) to the issues the Config.Reflect
+	// pass found with the original plan; empty when the plan was confirmed or not checked
+	Reflection string `json:"-"`
+}
+
+// defaultConfidence is assumed when a reply does not state its confidence
+const defaultConfidence = 0.5
+
+// UnmarshalJSON decodes a reply leniently so the contract can evolve across models
+// and prompt versions: unknown keys are ignored, missing keys take defaults, a lone
+// tool call object is accepted in place of a list, and booleans and numbers sent as
+// strings (e.g. "confidence": "0.8") are converted.
+func (r *Response) UnmarshalJSON(data []byte) error {
+	type plain Response
+	var raw struct {
+		plain
+		ToolCalls          json.RawMessage `json:"tool_calls"`
+		ShouldCall         json.RawMessage `json:"should_call"`
+		Confidence         json.RawMessage `json:"confidence"`
+		NeedsClarification json.RawMessage `json:"needs_clarification"`
+		SchemaVersion      json.RawMessage `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = Response(raw.plain)
+
+	var err error
+	if r.ToolCalls, err = lenientToolCalls(raw.ToolCalls); err != nil {
+		return fmt.Errorf("tool_calls: %w", err)
+	}
+	shouldCall, ok, err := lenientBool(raw.ShouldCall)
+	if err != nil {
+		return fmt.Errorf("should_call: %w", err)
+	}
+	r.ShouldCall = shouldCall
+	if !ok {
+		r.ShouldCall = len(r.ToolCalls) > 0
+	}
+	confidence, ok, err := lenientNumber(raw.Confidence)
+	if err != nil {
+		return fmt.Errorf("confidence: %w", err)
+	}
+	r.Confidence = confidence
+	if !ok {
+		r.Confidence = defaultConfidence
+	}
+	if r.NeedsClarification, _, err = lenientBool(raw.NeedsClarification); err != nil {
+		return fmt.Errorf("needs_clarification: %w", err)
+	}
+	version, ok, err := lenientNumber(raw.SchemaVersion)
+	if err != nil {
+		return fmt.Errorf("schema_version: %w", err)
+	}
+	r.SchemaVersion = int(version)
+	if !ok || r.SchemaVersion < 1 {
+		r.SchemaVersion = 1
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a tool call, accepting arguments sent as a JSON-encoded string
+func (c *ToolCall) UnmarshalJSON(data []byte) error {
+	type plain ToolCall
+	var raw struct {
+		plain
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = ToolCall(raw.plain)
+
+	args := raw.Arguments
+	var encoded string
+	if json.Unmarshal(args, &encoded) == nil {
+		args = json.RawMessage(encoded)
+		if strings.TrimSpace(encoded) == "" {
+			args = nil
+		}
+	}
+	if isJSONNull(args) {
+		return nil
+	}
+	if err := json.Unmarshal(args, &c.Arguments); err != nil {
+		return fmt.Errorf("arguments of %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+// lenientToolCalls decodes a list of tool calls, a single tool call, or nothing
+func lenientToolCalls(data json.RawMessage) ([]ToolCall, error) {
+	if isJSONNull(data) {
+		return nil, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		var call ToolCall
+		if err := json.Unmarshal(data, &call); err != nil {
+			return nil, err
+		}
+		return []ToolCall{call}, nil
+	}
+	var calls []ToolCall
+	if err := json.Unmarshal(data, &calls); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+// lenientBool decodes a boolean or a string holding one, reporting whether a value was present
+func lenientBool(data json.RawMessage) (bool, bool, error) {
+	if isJSONNull(data) {
+		return false, false, nil
+	}
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		return value, true, nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return false, false, fmt.Errorf("expected a boolean, got %s", data)
+	}
+	value, err := strconv.ParseBool(strings.TrimSpace(text))
+	if err != nil {
+		return false, false, fmt.Errorf("expected a boolean, got %q", text)
+	}
+	return value, true, nil
+}
+
+// lenientNumber decodes a number or a string holding one, reporting whether a value was present
+func lenientNumber(data json.RawMessage) (float64, bool, error) {
+	if isJSONNull(data) {
+		return 0, false, nil
+	}
+	var value float64
+	if err := json.Unmarshal(data, &value); err == nil {
+		return value, true, nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return 0, false, fmt.Errorf("expected a number, got %s", data)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("expected a number, got %q", text)
+	}
+	return value, true, nil
+}
+
+// isJSONNull reports whether a raw value is absent or null
+func isJSONNull(data json.RawMessage) bool {
+	trimmed := strings.TrimSpace(string(data))
+	return trimmed == "" || trimmed == "null"
 }
 
 // NewAgent creates a new agent instance
-func NewAgent(config Config, logger zerolog.Logger) *Agent {
+func NewAgent(config Config, logger zerolog.Logger) (*Agent, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	// Validate has already rejected malformed URLs
+	config.BaseURL, _ = llm.NormalizeBaseURL(config.BaseURL)
 	clientConfig := openai.DefaultConfig(config.APIKey)
 
 	// Support custom OpenAI-compatible endpoints
 	if config.BaseURL != "" {
 		clientConfig.BaseURL = config.BaseURL
 	}
+	// Let retries wait as long as a rate-limited provider asks
+	clientConfig.HTTPClient = &http.Client{Transport: retry.Transport(nil)}
 
 	client := openai.NewClientWithConfig(clientConfig)
 
@@ -75,37 +450,182 @@ func NewAgent(config Config, logger zerolog.Logger) *Agent {
 		logger: logger.With().Str("component", "agent").Logger(),
 		tools:  []ToolDefinition{},
 	}
+	if config.AuditLog != nil {
+		agent.logger.Warn().Msg("LLM audit logging is enabled; the audit log contains prompts and user input")
+		agent.audit = audit.New(config.AuditLog, config.AuditMaxContent, config.APIKey)
+	}
 
 	// Initialize MCP client if configured
 	if config.MCPServer != "" {
-		agent.mcpClient = mcp.NewClient(&mcp.Implementation{Name: "skull-agent-client"}, &mcp.ClientOptions{})
-		if err := agent.fetchToolsFromMCP(); err != nil {
+		agent.mcpClient = mcp.NewClient(&mcp.Implementation{Name: "skull-agent-client"}, &mcp.ClientOptions{
+			ProgressNotificationHandler: agent.dispatchProgress,
+		})
+		if err := agent.RefreshTools(context.Background()); err != nil {
 			agent.logger.Warn().Err(err).Msg("Failed to fetch tools from MCP server; continuing with no tools")
-			agent.tools = []ToolDefinition{}
 		}
 	} else {
 		agent.logger.Warn().Msg("MCP_SERVER is not configured; agent will operate with no tools")
-		agent.tools = []ToolDefinition{}
+		agent.toolsErr = fmt.Errorf("MCP server not configured")
 	}
+	agent.toolArgDefaults = agent.validToolArgDefaults(agent.Tools())
 
-	return agent
+	return agent, nil
 }
 
 // Tools returns the currently known tool definitions.
-func (a *Agent) Tools() []ToolDefinition { return a.tools }
+func (a *Agent) Tools() []ToolDefinition {
+	a.toolsMu.RLock()
+	defer a.toolsMu.RUnlock()
+	return a.tools
+}
+
+// ToolsRefreshedAt returns when the tool list was last fetched successfully, or the zero time
+func (a *Agent) ToolsRefreshedAt() time.Time {
+	a.toolsMu.RLock()
+	defer a.toolsMu.RUnlock()
+	return a.toolsRefreshed
+}
+
+// RefreshTools re-fetches the tool list from the MCP server. On failure the
+// previously known tools are kept. It is safe for concurrent use.
+func (a *Agent) RefreshTools(ctx context.Context) error {
+	tools, err := a.fetchToolsFromMCP(ctx)
+	a.toolsMu.Lock()
+	defer a.toolsMu.Unlock()
+	if err != nil {
+		if len(a.tools) == 0 {
+			a.toolsErr = err
+		}
+		return err
+	}
+	a.tools = tools
+	a.toolsErr = nil
+	a.toolsRefreshed = time.Now()
+	a.logger.Info().Int("tool_count", len(tools)).Msg("Fetched tools from MCP server")
+	return nil
+}
+
+// validToolArgDefaults returns copies of the configured tool argument defaults
+// that match the tools' schemas, logging and dropping the rest. With no tools
+// loaded every default is kept, to be checked by ValidateToolCall when used.
+func (a *Agent) validToolArgDefaults(tools []ToolDefinition) map[string]map[string]any {
+	valid := make(map[string]map[string]any)
+	for toolName, defaults := range a.config.ToolArgDefaults {
+		var tool *ToolDefinition
+		for i := range tools {
+			if tools[i].Name == toolName {
+				tool = &tools[i]
+				break
+			}
+		}
+		if tool == nil && len(tools) > 0 {
+			a.logger.Warn().Str("tool", toolName).Msg("Ignoring argument defaults for unknown tool")
+			continue
+		}
+
+		for paramName, value := range defaults {
+			// Round-trip through JSON so defaults such as Go ints look like model-provided
+			// values, and so that later changes to the caller's maps do not reach the agent.
+			// Config.Validate has already rejected unencodable values.
+			value, _ = normalizeJSONValue(value)
+			if tool != nil && tool.Parameters != nil {
+				paramDef, exists := tool.Parameters.Properties[paramName]
+				if !exists {
+					a.logger.Warn().Str("tool", toolName).Str("parameter", paramName).Msg("Ignoring default for unknown parameter")
+					continue
+				}
+				err := a.validateParameterType(paramName, value, paramDef.Type)
+				if err == nil {
+					err = a.validateParameterEnum(paramName, value, paramDef.Enum)
+				}
+				if err != nil {
+					a.logger.Warn().Err(err).Str("tool", toolName).Msg("Ignoring invalid argument default")
+					continue
+				}
+			}
+			if valid[toolName] == nil {
+				valid[toolName] = make(map[string]any)
+			}
+			valid[toolName][paramName] = value
+		}
+	}
+	return valid
+}
+
+// normalizeJSONValue converts a Go value into its encoding/json decoded form
+func normalizeJSONValue(value any) (any, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// applyToolArgDefaults fills in argument defaults the model left out of its tool
+// calls. Each call gets its own copy of map and slice defaults.
+func (a *Agent) applyToolArgDefaults(toolCalls []ToolCall) {
+	for i := range toolCalls {
+		defaults := a.toolArgDefaults[toolCalls[i].Name]
+		for paramName, value := range defaults {
+			if _, set := toolCalls[i].Arguments[paramName]; set {
+				continue
+			}
+			if toolCalls[i].Arguments == nil {
+				toolCalls[i].Arguments = make(map[string]interface{})
+			}
+			toolCalls[i].Arguments[paramName] = cloneJSONValue(value)
+		}
+	}
+}
+
+// cloneJSONValue deep-copies a decoded JSON value
+func cloneJSONValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		cloned := make(map[string]any, len(v))
+		for key, item := range v {
+			cloned[key] = cloneJSONValue(item)
+		}
+		return cloned
+	case []any:
+		cloned := make([]any, len(v))
+		for i, item := range v {
+			cloned[i] = cloneJSONValue(item)
+		}
+		return cloned
+	default:
+		return value
+	}
+}
+
+// refreshStaleTools refreshes the tool list when Config.ToolsTTL has elapsed, logging failures
+func (a *Agent) refreshStaleTools(ctx context.Context) {
+	if a.config.ToolsTTL <= 0 || a.mcpClient == nil || time.Since(a.ToolsRefreshedAt()) < a.config.ToolsTTL {
+		return
+	}
+	if err := a.RefreshTools(ctx); err != nil {
+		a.logger.Warn().Err(err).Msg("Failed to refresh tools from MCP server; keeping previous tools")
+	}
+}
 
 // fetchToolsFromMCP fetches tool definitions from the MCP server endpoint
-func (a *Agent) fetchToolsFromMCP() error {
+func (a *Agent) fetchToolsFromMCP(ctx context.Context) ([]ToolDefinition, error) {
 	// Ensure we have a reusable MCP session
-	session, err := a.ensureMCPSession(context.Background())
+	session, err := a.ensureMCPSession(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	result, err := session.ListTools(context.Background(), &mcp.ListToolsParams{})
+	result, err := session.ListTools(ctx, &mcp.ListToolsParams{})
 	if err != nil {
-		return fmt.Errorf("failed to list tools from MCP server: %w", err)
+		// Drop the session so the next attempt reconnects
+		a.resetMCPSession(session)
+		return nil, fmt.Errorf("failed to list tools from MCP server: %w", err)
 	}
-	var tools []ToolDefinition
+	tools := []ToolDefinition{}
 	for _, t := range result.Tools {
 		tools = append(tools, ToolDefinition{
 			Name:        t.Name,
@@ -113,13 +633,35 @@ func (a *Agent) fetchToolsFromMCP() error {
 			Parameters:  t.InputSchema,
 		})
 	}
-	a.tools = tools
-	a.logger.Info().Int("tool_count", len(a.tools)).Msg("Fetched tools from MCP server")
-	return nil
+	return tools, nil
+}
+
+// resetMCPSession closes and forgets session if it is still the current one
+func (a *Agent) resetMCPSession(session *mcp.ClientSession) {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+	if a.mcpSession == session {
+		a.mcpSession.Close()
+		a.mcpSession = nil
+	}
+}
+
+// Close ends the agent's MCP session, if one is open
+func (a *Agent) Close() error {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+	if a.mcpSession == nil {
+		return nil
+	}
+	err := a.mcpSession.Close()
+	a.mcpSession = nil
+	return err
 }
 
 // ensureMCPSession creates or reuses a persistent MCP session
 func (a *Agent) ensureMCPSession(ctx context.Context) (*mcp.ClientSession, error) {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
 	if a.mcpSession != nil {
 		return a.mcpSession, nil
 	}
@@ -145,6 +687,97 @@ func (a *Agent) CallToolRemote(ctx context.Context, name string, args map[string
 	return session.CallTool(ctx, params)
 }
 
+// progressGrace bounds how long CallToolRemoteWithProgress waits, after the tool
+// returns, for progress notifications still in flight
+const progressGrace = 200 * time.Millisecond
+
+// progressCall routes the progress notifications of one running tool call
+type progressCall struct {
+	onProgress func(ToolProgress)
+	// finished is closed by the notification whose Progress reaches Total
+	finished chan struct{}
+
+	mu     sync.Mutex
+	seen   bool
+	final  bool
+	closed bool
+}
+
+// deliver passes a notification on unless the call has already returned
+func (c *progressCall) deliver(progress ToolProgress) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.onProgress(progress)
+	c.seen = true
+	if !c.final && progress.Total > 0 && progress.Progress >= progress.Total {
+		c.final = true
+		close(c.finished)
+	}
+}
+
+// CallToolRemoteWithProgress is CallToolRemote with the tool's progress
+// notifications delivered to onProgress while the call runs. Notifications are
+// handled apart from the result and can trail it, so once a tool has reported
+// progress the call waits briefly for the rest.
+func (a *Agent) CallToolRemoteWithProgress(ctx context.Context, name string, args map[string]any, onProgress func(ToolProgress)) (*mcp.CallToolResult, error) {
+	session, err := a.ensureMCPSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &progressCall{onProgress: onProgress, finished: make(chan struct{})}
+	a.progressMu.Lock()
+	if a.progress == nil {
+		a.progress = make(map[string]*progressCall)
+	}
+	a.progressNext++
+	token := fmt.Sprintf("%s-%d", name, a.progressNext)
+	a.progress[token] = call
+	a.progressMu.Unlock()
+	defer func() {
+		a.progressMu.Lock()
+		delete(a.progress, token)
+		a.progressMu.Unlock()
+		call.mu.Lock()
+		call.closed = true
+		call.mu.Unlock()
+	}()
+
+	// SetProgressToken only fills in an existing Meta
+	params := &mcp.CallToolParams{Name: name, Arguments: args, Meta: mcp.Meta{}}
+	params.SetProgressToken(token)
+	result, err := session.CallTool(ctx, params)
+
+	call.mu.Lock()
+	seen := call.seen
+	call.mu.Unlock()
+	if err == nil && seen {
+		select {
+		case <-call.finished:
+		case <-time.After(progressGrace):
+		case <-ctx.Done():
+		}
+	}
+	return result, err
+}
+
+// dispatchProgress hands a progress notification to the tool call it belongs to
+func (a *Agent) dispatchProgress(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+	token, ok := req.Params.ProgressToken.(string)
+	if !ok {
+		return
+	}
+	a.progressMu.Lock()
+	call := a.progress[token]
+	a.progressMu.Unlock()
+	if call != nil {
+		call.deliver(ToolProgress{Message: req.Params.Message, Progress: req.Params.Progress, Total: req.Params.Total})
+	}
+}
+
 // PostProcess applies a generalized instruction (e.g., "Summarize", "Recommend", "Exclude", "Transform")
 // to the provided content using the agent's LLM. It returns the transformed text.
 func (a *Agent) PostProcess(ctx context.Context, instruction string, userRequest string, content string) (string, error) {
@@ -153,6 +786,190 @@ func (a *Agent) PostProcess(ctx context.Context, instruction string, userRequest
 		return content, nil
 	}
 
+	resp, err := a.createChatCompletion(ctx, a.postProcessRequest(instruction, userRequest, content))
+	if err != nil {
+		return "", fmt.Errorf("post-process failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("post-process returned no choices")
+	}
+	out := strings.TrimSpace(resp.Choices[0].Message.Content)
+	return out, nil
+}
+
+// condenseInstruction asks PostProcess for a display summary of a tool's output
+const condenseInstruction = "Condense this output of the %s tool into a short summary for the user: say what it contains and give its key points in at most five sentences. Describe the output; do not carry out the request yourself"
+
+// CondenseToolResult shortens a tool result longer than Config.CondenseToolOutput
+// characters into a brief human-facing summary via PostProcess, reporting whether it
+// did. Shorter results, and every result when condensing is disabled, are returned
+// unchanged. The summary is only for display: callers should keep the full result
+// for post-processing and anything else that needs the data.
+func (a *Agent) CondenseToolResult(ctx context.Context, toolName, userRequest, result string) (string, bool, error) {
+	if a.config.CondenseToolOutput <= 0 || utf8.RuneCountInString(result) <= a.config.CondenseToolOutput {
+		return result, false, nil
+	}
+	condensed, err := a.PostProcess(ctx, fmt.Sprintf(condenseInstruction, toolName), userRequest, result)
+	if err != nil {
+		return result, false, fmt.Errorf("failed to condense %s output: %w", toolName, err)
+	}
+	if condensed == "" {
+		return result, false, nil
+	}
+	return condensed, true, nil
+}
+
+// PostProcessStream is PostProcess with the output delivered to onToken as it is
+// generated. It falls back to a buffered PostProcess when the provider cannot stream.
+func (a *Agent) PostProcessStream(ctx context.Context, instruction string, userRequest string, content string, onToken func(string)) (string, error) {
+	instruction = strings.TrimSpace(instruction)
+	if instruction == "" || content == "" {
+		onToken(content)
+		return content, nil
+	}
+
+	req := a.postProcessRequest(instruction, userRequest, content)
+	req.Stream = true
+	start := time.Now()
+	stream, err := a.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		a.recordAudit(start, req, nil, err)
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("post-process failed: %w", err)
+		}
+		a.logger.Warn().Err(err).Msg("Streaming unavailable; falling back to buffered post-process")
+		out, err := a.PostProcess(ctx, instruction, userRequest, content)
+		if err != nil {
+			return "", err
+		}
+		onToken(out)
+		return out, nil
+	}
+	defer stream.Close()
+
+	var out strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			a.recordAudit(start, req, nil, err)
+			return "", fmt.Errorf("post-process stream failed: %w", err)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			out.WriteString(delta)
+			onToken(delta)
+		}
+	}
+
+	// Audit the streamed reply as if it had arrived in one piece
+	streamed := openai.ChatCompletionResponse{
+		Model: req.Model,
+		Choices: []openai.ChatCompletionChoice{
+			{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: out.String()}},
+		},
+	}
+	a.recordAudit(start, req, &streamed, nil)
+	a.recordUsage(req, streamed)
+	return strings.TrimSpace(out.String()), nil
+}
+
+// createChatCompletion runs a chat completion, retried per Config.Retry and
+// waiting out any Retry-After the provider sends, recording each attempt in the
+// audit log if one is configured
+func (a *Agent) createChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	policy := a.config.Retry
+	if policy.Retryable == nil {
+		policy.Retryable = isRetryableAPIError
+	}
+	var resp openai.ChatCompletionResponse
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		ctx, hint := retry.WithHint(ctx)
+		start := time.Now()
+		var err error
+		resp, err = a.client.CreateChatCompletion(ctx, req)
+		var audited *openai.ChatCompletionResponse
+		if err == nil {
+			audited = &resp
+		}
+		a.recordAudit(start, req, audited, err)
+		return hint.Wrap(err)
+	})
+	if err == nil {
+		a.recordUsage(req, resp)
+	}
+	return resp, err
+}
+
+// Usage returns the tokens consumed by the agent's chat completions so far
+func (a *Agent) Usage() Usage {
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	return a.usage
+}
+
+// recordUsage adds a completion to the usage totals, estimating its token counts
+// from the text when the provider reports none
+func (a *Agent) recordUsage(req openai.ChatCompletionRequest, resp openai.ChatCompletionResponse) {
+	usage := resp.Usage
+	estimated := usage.PromptTokens == 0 && usage.CompletionTokens == 0 && usage.TotalTokens == 0
+	if estimated {
+		promptChars := 0
+		for _, message := range req.Messages {
+			promptChars += len(message.Content)
+		}
+		completionChars := 0
+		for _, choice := range resp.Choices {
+			completionChars += len(choice.Message.Content)
+		}
+		usage.PromptTokens = estimateTokens(promptChars)
+		usage.CompletionTokens = estimateTokens(completionChars)
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	a.usage.Calls++
+	a.usage.PromptTokens += usage.PromptTokens
+	a.usage.CompletionTokens += usage.CompletionTokens
+	a.usage.TotalTokens += usage.TotalTokens
+	a.usage.Estimated = a.usage.Estimated || estimated
+}
+
+// estimateTokens approximates a token count from a character count
+func estimateTokens(chars int) int {
+	return (chars + 3) / 4
+}
+
+// isRetryableAPIError reports whether err is a provider error with a transient status
+// such as a rate limit or server error
+func isRetryableAPIError(err error) bool {
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		return retry.IsRetryableStatus(apiErr.HTTPStatusCode)
+	case errors.As(err, &requestErr):
+		return retry.IsRetryableStatus(requestErr.HTTPStatusCode)
+	}
+	return false
+}
+
+// recordAudit writes a completion to the audit log, logging rather than returning write failures
+func (a *Agent) recordAudit(start time.Time, req openai.ChatCompletionRequest, resp *openai.ChatCompletionResponse, err error) {
+	if auditErr := a.audit.Record("agent", start, req, resp, err); auditErr != nil {
+		a.logger.Warn().Err(auditErr).Msg("Failed to write audit log")
+	}
+}
+
+// postProcessRequest builds the completion request shared by PostProcess and PostProcessStream
+func (a *Agent) postProcessRequest(instruction string, userRequest string, content string) openai.ChatCompletionRequest {
 	// Build prompts for controlled, single-output transformation
 	system := "You are an expert post-processing assistant. You perform a single action described by an imperative verb (e.g., Summarize, Recommend, Exclude, Transform) on the given content. Return only the final result with no preamble. Keep it faithful, concise, and helpful."
 	user := fmt.Sprintf("Instruction: %s\n\nUser Request: %s\n\nContent to process:\n%s", instruction, userRequest, content)
@@ -166,16 +983,8 @@ func (a *Agent) PostProcess(ctx context.Context, instruction string, userRequest
 		MaxTokens:   minNonZero(a.config.MaxTokens, 700),
 		Temperature: 0.3,
 	}
-
-	resp, err := a.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return "", fmt.Errorf("post-process failed: %w", err)
-	}
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("post-process returned no choices")
-	}
-	out := strings.TrimSpace(resp.Choices[0].Message.Content)
-	return out, nil
+	a.config.applySampling(&req)
+	return req
 }
 
 // minNonZero returns b if a==0 or min(a,b) otherwise
@@ -192,10 +1001,65 @@ func minNonZero(a, b int) int {
 // registerDefaultTools registers the web scraping and summarization tools
 // default tool registration removed to enforce MCP-only tools
 
-// ProcessInput analyzes user input and determines what tools to call
-func (a *Agent) ProcessInput(ctx context.Context, userInput string) (*Response, error) {
+// CallOptions overrides the configured reply budget for a single ProcessInput call,
+// e.g. a larger MaxTokens for a complex multi-tool plan. Zero values keep the config.
+type CallOptions struct {
+	MaxTokens int
+	// Temperature is a pointer so that overriding to 0 is distinguishable from no override
+	Temperature *float32
+}
+
+// Validate checks the overrides for values that cannot work, reporting every problem found
+func (o CallOptions) Validate() error {
+	var problems []error
+	if o.MaxTokens < 0 {
+		problems = append(problems, fmt.Errorf("MaxTokens must not be negative (got %d)", o.MaxTokens))
+	}
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		problems = append(problems, fmt.Errorf("Temperature must be between 0 and 2 (got %g)", *o.Temperature))
+	}
+	if err := errors.Join(problems...); err != nil {
+		return fmt.Errorf("invalid call options: %w", err)
+	}
+	return nil
+}
+
+// apply returns the config with the overrides applied
+func (o *CallOptions) apply(config Config) Config {
+	if o == nil {
+		return config
+	}
+	if o.MaxTokens > 0 {
+		config.MaxTokens = o.MaxTokens
+	}
+	if o.Temperature != nil {
+		config.Temperature = *o.Temperature
+		// Parse retries escalate from the override, so keep the cap above it
+		if config.maxTemperature() < config.Temperature {
+			config.MaxTemperature = config.Temperature
+		}
+	}
+	return config
+}
+
+// ProcessInput analyzes user input and determines what tools to call. opts, when
+// non-nil, overrides MaxTokens and Temperature for this call only.
+func (a *Agent) ProcessInput(ctx context.Context, userInput string, opts *CallOptions) (*Response, error) {
 	a.logger.Info().Str("input", userInput).Msg("Processing user input")
 
+	if opts != nil {
+		if err := opts.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	config := opts.apply(a.config)
+
+	a.refreshStaleTools(ctx)
+	tools := a.Tools()
+	if len(tools) == 0 && a.config.RequireTools {
+		return nil, a.noToolsError()
+	}
+
 	// Create system prompt that teaches the agent about tools
 	systemPrompt := a.buildSystemPrompt()
 
@@ -220,17 +1084,19 @@ Instructions:
 				Role:    openai.ChatMessageRoleSystem,
 				Content: systemPrompt,
 			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: userPrompt,
-			},
 		},
-		MaxTokens:   a.config.MaxTokens,
-		Temperature: a.config.Temperature,
+		MaxTokens:   config.MaxTokens,
+		Temperature: config.Temperature,
 	}
+	chatReq.Messages = append(chatReq.Messages, a.History()...)
+	chatReq.Messages = append(chatReq.Messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: userPrompt,
+	})
+	config.applySampling(&chatReq)
 
 	// Call the LLM
-	resp, err := a.client.CreateChatCompletion(ctx, chatReq)
+	resp, err := a.createChatCompletion(ctx, chatReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat completion: %w", err)
 	}
@@ -242,18 +1108,72 @@ Instructions:
 	content := resp.Choices[0].Message.Content
 	content = strings.TrimSpace(content)
 
-	// Parse the JSON response
+	// A MaxTokens cap that is too low cuts the JSON off mid-object; retry once with more room
+	if isTruncated(resp.Choices[0].FinishReason, content) {
+		chatReq.MaxTokens = raisedTokenLimit(chatReq.MaxTokens)
+		a.logger.Warn().
+			Int("max_tokens", config.MaxTokens).
+			Int("retry_max_tokens", chatReq.MaxTokens).
+			Msg("Agent response hit the token limit; retrying with a raised cap")
+
+		retryResp, err := a.createChatCompletion(ctx, chatReq)
+		if err != nil {
+			a.logger.Warn().Err(err).Msg("Retry after token limit failed")
+		} else if len(retryResp.Choices) > 0 {
+			content = strings.TrimSpace(retryResp.Choices[0].Message.Content)
+		}
+	}
+
+	// Parse the JSON response, retrying hotter so the model breaks out of a failing pattern
 	var response Response
-	if err := json.Unmarshal([]byte(content), &response); err != nil {
+	parseErr := json.Unmarshal([]byte(content), &response)
+	for attempt := 1; parseErr != nil && attempt <= config.parseRetries(); attempt++ {
+		chatReq.Temperature = config.retryTemperature(attempt)
+		a.logger.Warn().
+			Err(parseErr).
+			Int("attempt", attempt).
+			Float32("temperature", chatReq.Temperature).
+			Msg("Agent response was not valid JSON; retrying at a higher temperature")
+
+		retryResp, err := a.createChatCompletion(ctx, chatReq)
+		if err != nil {
+			a.logger.Warn().Err(err).Msg("Retry after parse failure failed")
+			break
+		}
+		if len(retryResp.Choices) == 0 {
+			continue
+		}
+		content = strings.TrimSpace(retryResp.Choices[0].Message.Content)
+		response = Response{}
+		parseErr = json.Unmarshal([]byte(content), &response)
+	}
+	if err := parseErr; err != nil {
 		// If JSON parsing fails, create a fallback response
 		a.logger.Warn().Err(err).Str("content", content).Msg("Failed to parse agent response as JSON")
 		return &Response{
-			Message:     "I understand your request, but I had trouble determining the best approach. Could you please rephrase your request?",
-			ShouldCall:  false,
-			Confidence:  0.1,
-			Explanation: "Failed to parse agent decision",
+			Message:          "I understand your request, but I had trouble determining the best approach. Could you please rephrase your request?",
+			ShouldCall:       false,
+			Confidence:       0.1,
+			Explanation:      "Failed to parse agent decision",
+			ToolsUnavailable: len(tools) == 0,
 		}, nil
 	}
+	response.ToolsUnavailable = len(tools) == 0
+	a.remember(userPrompt, content)
+	a.applyToolArgDefaults(response.ToolCalls)
+
+	if response.SchemaVersion > ResponseSchemaVersion {
+		a.logger.Debug().Int("schema_version", response.SchemaVersion).Msg("Agent reply uses a newer schema; ignoring unknown fields")
+	}
+
+	// Never act on a request the model asked to have clarified
+	if response.NeedsClarification {
+		response.ShouldCall = false
+	}
+
+	if config.Reflect && response.ShouldCall && len(response.ToolCalls) > 0 {
+		response = a.reflect(ctx, config, userInput, response)
+	}
 
 	a.logger.Info().
 		Bool("should_call", response.ShouldCall).
@@ -264,9 +1184,252 @@ Instructions:
 	return &response, nil
 }
 
+// reflectionPrompt asks the model to check a tool plan against the request it serves
+const reflectionPrompt = `Review this tool plan before it is executed.
+
+User Request: "%s"
+
+Proposed plan:
+%s
+
+Check that every tool call serves the user's request, that its arguments match what the user asked for and the tool's schema, and that no needed call is missing.
+Respond with JSON only, in this format:
+{
+  "aligned": true or false,
+  "issues": "what does not fit the request; empty when aligned",
+  "confidence": 0.0-1.0,
+  "revised": the corrected plan in the response format from the system prompt, or null to keep the plan
+}`
+
+// reflectionConfidenceCap bounds the confidence of a misaligned plan the model did not revise
+const reflectionConfidenceCap = 0.3
+
+// reflectionVerdict is the reply to reflectionPrompt
+type reflectionVerdict struct {
+	Aligned    json.RawMessage `json:"aligned"`
+	Issues     string          `json:"issues"`
+	Confidence json.RawMessage `json:"confidence"`
+	Revised    *Response       `json:"revised"`
+}
+
+// reflect runs the Config.Reflect pass over a parsed tool plan. A plan the model
+// finds misaligned is replaced by its revision when it offers one, and otherwise
+// kept with lowered confidence and the issues added to its explanation. When the
+// pass itself fails, the original plan stands.
+func (a *Agent) reflect(ctx context.Context, config Config, userInput string, response Response) Response {
+	plan, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		a.logger.Warn().Err(err).Msg("Failed to encode tool plan for reflection")
+		return response
+	}
+	chatReq := openai.ChatCompletionRequest{
+		Model: a.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: a.buildSystemPrompt(),
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf(reflectionPrompt, userInput, plan),
+			},
+		},
+		MaxTokens:   config.MaxTokens,
+		Temperature: config.Temperature,
+	}
+	config.applySampling(&chatReq)
+
+	resp, err := a.createChatCompletion(ctx, chatReq)
+	if err != nil {
+		a.logger.Warn().Err(err).Msg("Reflection failed; keeping the original plan")
+		return response
+	}
+	if len(resp.Choices) == 0 {
+		a.logger.Warn().Msg("Reflection returned no choices; keeping the original plan")
+		return response
+	}
+	content := strings.TrimSpace(resp.Choices[0].Message.Content)
+	var verdict reflectionVerdict
+	if err := json.Unmarshal([]byte(content), &verdict); err != nil {
+		a.logger.Warn().Err(err).Str("content", content).Msg("Failed to parse reflection; keeping the original plan")
+		return response
+	}
+	aligned, ok, err := lenientBool(verdict.Aligned)
+	if err != nil || !ok {
+		a.logger.Warn().Str("content", content).Msg("Reflection did not say whether the plan is aligned; keeping the original plan")
+		return response
+	}
+	if aligned {
+		a.logger.Debug().Msg("Reflection confirmed the tool plan")
+		return response
+	}
+
+	issues := strings.TrimSpace(verdict.Issues)
+	if issues == "" {
+		issues = "the plan does not fit the request"
+	}
+	if revised := verdict.Revised; revised != nil {
+		a.logger.Info().
+			Str("issues", issues).
+			Int("tool_calls", len(revised.ToolCalls)).
+			Msg("Reflection revised the tool plan")
+		revised.ToolsUnavailable = response.ToolsUnavailable
+		revised.Reflection = issues
+		a.applyToolArgDefaults(revised.ToolCalls)
+		if revised.NeedsClarification {
+			revised.ShouldCall = false
+		}
+		return *revised
+	}
+
+	confidence, ok, _ := lenientNumber(verdict.Confidence)
+	if !ok || confidence > reflectionConfidenceCap {
+		confidence = reflectionConfidenceCap
+	}
+	a.logger.Info().
+		Str("issues", issues).
+		Float64("confidence", confidence).
+		Msg("Reflection found the tool plan misaligned; lowering confidence")
+	response.Confidence = min(response.Confidence, confidence)
+	response.Explanation = strings.TrimSpace(response.Explanation + "\n\nReflection: " + issues)
+	response.Reflection = issues
+	return response
+}
+
+// remember records an exchange in the conversation memory, dropping the oldest beyond the cap
+func (a *Agent) remember(userPrompt, reply string) {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	a.history = append(a.history,
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: userPrompt},
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply},
+	)
+	if excess := len(a.history) - maxHistoryMessages; excess > 0 {
+		a.history = a.history[excess:]
+	}
+}
+
+// ClearHistory forgets all previous exchanges
+func (a *Agent) ClearHistory() {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	a.history = nil
+}
+
+// History returns a copy of the remembered exchanges, e.g. to carry them over to a
+// reconfigured agent with SetHistory
+func (a *Agent) History() []openai.ChatCompletionMessage {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	return append([]openai.ChatCompletionMessage(nil), a.history...)
+}
+
+// SetHistory replaces the remembered exchanges, keeping only the most recent up to the cap
+func (a *Agent) SetHistory(messages []openai.ChatCompletionMessage) {
+	if excess := len(messages) - maxHistoryMessages; excess > 0 {
+		messages = messages[excess:]
+	}
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	a.history = append([]openai.ChatCompletionMessage(nil), messages...)
+}
+
+// sessionSummaryPrompt instructs the model to recap the conversation memory
+const sessionSummaryPrompt = `You recap research sessions with a tool-using assistant.
+The conversation so far holds the user's requests and the assistant's JSON plans for them.
+Write a brief recap: each request handled, the tools chosen for it, and any key findings or open questions.
+Use short bullet points in plain text. Only report what the conversation shows; do not invent results.`
+
+// SessionSummary recaps the requests handled and results found in the remembered
+// exchanges, which cover the most recent inputs only. It returns an empty string
+// when there is nothing to recap.
+func (a *Agent) SessionSummary(ctx context.Context) (string, error) {
+	history := a.History()
+	if len(history) == 0 {
+		return "", nil
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:       a.config.Model,
+		MaxTokens:   a.config.MaxTokens,
+		Temperature: a.config.Temperature,
+	}
+	req.Messages = append(req.Messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: sessionSummaryPrompt})
+	req.Messages = append(req.Messages, history...)
+	req.Messages = append(req.Messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "Recap this session."})
+	a.config.applySampling(&req)
+
+	resp, err := a.createChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("session summary failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("session summary returned no choices")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// truncatedRetryMaxTokens is the token cap used when retrying a truncated response
+// and no MaxTokens was configured
+const truncatedRetryMaxTokens = 2000
+
+// isTruncated reports whether a completion was likely cut off by the token limit
+func isTruncated(finishReason openai.FinishReason, content string) bool {
+	if finishReason == openai.FinishReasonLength {
+		return true
+	}
+	depth := 0
+	inString, escaped := false, false
+	for _, r := range content {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case inString:
+		case r == '{' || r == '[':
+			depth++
+		case r == '}' || r == ']':
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// raisedTokenLimit doubles the token cap for a retry, with a sensible floor
+func raisedTokenLimit(maxTokens int) int {
+	if maxTokens <= 0 {
+		return truncatedRetryMaxTokens
+	}
+	if maxTokens*2 < truncatedRetryMaxTokens {
+		return truncatedRetryMaxTokens
+	}
+	return maxTokens * 2
+}
+
+// noToolsError wraps ErrNoTools with the reason tools could not be loaded
+func (a *Agent) noToolsError() error {
+	a.toolsMu.RLock()
+	defer a.toolsMu.RUnlock()
+	if a.toolsErr != nil {
+		return fmt.Errorf("%w: %v", ErrNoTools, a.toolsErr)
+	}
+	return ErrNoTools
+}
+
+// ToolsUnavailableReason explains why the agent has no tools, or returns "" when tools are loaded
+func (a *Agent) ToolsUnavailableReason() string {
+	if len(a.Tools()) > 0 {
+		return ""
+	}
+	return a.noToolsError().Error()
+}
+
 // buildSystemPrompt creates the system prompt that defines the agent's behavior
 func (a *Agent) buildSystemPrompt() string {
-	toolsJSON, _ := json.MarshalIndent(a.tools, "", "  ")
+	toolsJSON, _ := json.MarshalIndent(a.Tools(), "", "  ")
 	var guidelines []string
 	// We intentionally don't expose a standalone summarize tool; summarize text directly only when part of combined flow
 	guidelines = append(guidelines,
@@ -276,6 +1439,21 @@ func (a *Agent) buildSystemPrompt() string {
 		"- Extract parameters accurately from user input",
 		"- Set confidence based on how clear the user's intent is",
 	)
+	clarification := ""
+	if version := a.config.schemaVersion(); version >= 2 {
+		guidelines = append(guidelines,
+			"- If the request is too ambiguous to act on (e.g. \"summarize that\" with nothing to refer to), set \"needs_clarification\" to true, ask one short question in \"clarifying_question\", and make no tool calls",
+			"- Earlier messages in the conversation are context; use them to resolve follow-ups and answers to your clarifying questions",
+		)
+		clarification = fmt.Sprintf(`,
+	"needs_clarification": false,
+	"clarifying_question": "",
+	"schema_version": %d`, version)
+	} else {
+		guidelines = append(guidelines,
+			"- Earlier messages in the conversation are context; use them to resolve follow-ups",
+		)
+	}
 
 	return fmt.Sprintf(`You are an intelligent agent that helps users with tasks. You have access to the following tools:
 
@@ -302,18 +1480,91 @@ IMPORTANT: You must respond in valid JSON format with this exact structure:
 	"should_call": true/false,
 	"confidence": 0.0-1.0,
 	"explanation": "Detailed explanation of your analysis and decisions",
-	"post_process": "Summarize"
+	"post_process": "Summarize"%s
 }
 
 Guidelines:
-%s`, string(toolsJSON), strings.Join(guidelines, "\n"))
+%s`, string(toolsJSON), clarification, strings.Join(guidelines, "\n"))
+}
+
+// DescribeTool renders a tool's description and parameters as human-readable text
+func (a *Agent) DescribeTool(name string) (string, error) {
+	var toolDef *ToolDefinition
+	for _, tool := range a.Tools() {
+		if tool.Name == name {
+			toolDef = &tool
+			break
+		}
+	}
+	if toolDef == nil {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", toolDef.Name)
+	if toolDef.Description != "" {
+		fmt.Fprintf(&b, "  %s\n", toolDef.Description)
+	}
+	if toolDef.Parameters == nil || len(toolDef.Parameters.Properties) == 0 {
+		b.WriteString("  Parameters: none\n")
+		return b.String(), nil
+	}
+
+	required := make(map[string]bool, len(toolDef.Parameters.Required))
+	for _, r := range toolDef.Parameters.Required {
+		required[r] = true
+	}
+	names := make([]string, 0, len(toolDef.Parameters.Properties))
+	for paramName := range toolDef.Parameters.Properties {
+		names = append(names, paramName)
+	}
+	// Required parameters first, then alphabetical
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+
+	b.WriteString("  Parameters:\n")
+	for _, paramName := range names {
+		param := toolDef.Parameters.Properties[paramName]
+		status := "optional"
+		if required[paramName] {
+			status = "required"
+		}
+		fmt.Fprintf(&b, "    - %s (%s, %s)", paramName, schemaTypeName(param), status)
+		if param.Description != "" {
+			fmt.Fprintf(&b, ": %s", param.Description)
+		}
+		if len(param.Enum) > 0 {
+			fmt.Fprintf(&b, " [one of: %v]", param.Enum)
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// schemaTypeName describes a schema's type, including array item types
+func schemaTypeName(schema *jsonschema.Schema) string {
+	typeName := schema.Type
+	if typeName == "" && len(schema.Types) > 0 {
+		typeName = strings.Join(schema.Types, "|")
+	}
+	if typeName == "" {
+		typeName = "any"
+	}
+	if typeName == "array" && schema.Items != nil {
+		return "array of " + schemaTypeName(schema.Items)
+	}
+	return typeName
 }
 
 // ValidateToolCall checks if a tool call is valid
 func (a *Agent) ValidateToolCall(toolCall ToolCall) error {
 	// Find the tool definition
 	var toolDef *ToolDefinition
-	for _, tool := range a.tools {
+	for _, tool := range a.Tools() {
 		if tool.Name == toolCall.Name {
 			toolDef = &tool
 			break
@@ -323,6 +1574,9 @@ func (a *Agent) ValidateToolCall(toolCall ToolCall) error {
 	if toolDef == nil {
 		return fmt.Errorf("unknown tool: %s", toolCall.Name)
 	}
+	if toolDef.Parameters == nil {
+		return nil
+	}
 
 	// Validate required parameters
 	params := toolDef.Parameters.Properties
@@ -340,12 +1594,48 @@ func (a *Agent) ValidateToolCall(toolCall ToolCall) error {
 			if err := a.validateParameterType(paramName, paramValue, expectedType); err != nil {
 				return err
 			}
+			if err := a.validateParameterEnum(paramName, paramValue, paramDef.Enum); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// ValidateResponse validates every tool call in the response and normalizes its
+// ranges in place. All problems are reported together in the returned error.
+func (a *Agent) ValidateResponse(response *Response) error {
+	if response == nil {
+		return fmt.Errorf("response is nil")
+	}
+
+	// Normalize confidence into [0, 1]
+	switch {
+	case math.IsNaN(response.Confidence) || response.Confidence < 0:
+		response.Confidence = 0
+	case response.Confidence > 1:
+		response.Confidence = 1
+	}
+
+	if response.ShouldCall && (len(response.ToolCalls) == 0 || response.NeedsClarification) {
+		response.ShouldCall = false
+	}
+
+	var problems []error
+	for i, toolCall := range response.ToolCalls {
+		if strings.TrimSpace(toolCall.Name) == "" {
+			problems = append(problems, fmt.Errorf("tool call %d: missing tool name", i+1))
+			continue
+		}
+		if err := a.ValidateToolCall(toolCall); err != nil {
+			problems = append(problems, fmt.Errorf("tool call %d (%s): %w", i+1, toolCall.Name, err))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
 // validateParameterType validates a parameter's type
 func (a *Agent) validateParameterType(paramName string, value interface{}, expectedType string) error {
 	switch expectedType {
@@ -368,3 +1658,20 @@ func (a *Agent) validateParameterType(paramName string, value interface{}, expec
 	}
 	return nil
 }
+
+// validateParameterEnum checks a parameter against the allowed values of its schema, if any
+func (a *Agent) validateParameterEnum(paramName string, value interface{}, enum []any) error {
+	if len(enum) == 0 {
+		return nil
+	}
+	// Objects and arrays decoded from JSON cannot be compared with ==
+	if t := reflect.TypeOf(value); t != nil && !t.Comparable() {
+		return fmt.Errorf("parameter '%s' must be one of %v", paramName, enum)
+	}
+	for _, allowed := range enum {
+		if allowed == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("parameter '%s' must be one of %v", paramName, enum)
+}
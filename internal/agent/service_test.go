@@ -0,0 +1,1020 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/audit"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeLLM is an OpenAI-compatible chat completions endpoint that records each
+// request and answers with respond, called with the zero-based call number
+type fakeLLM struct {
+	*httptest.Server
+	respond func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse
+
+	mu       sync.Mutex
+	requests []openai.ChatCompletionRequest
+}
+
+func newFakeLLM(t *testing.T, respond func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse) *fakeLLM {
+	t.Helper()
+	f := &fakeLLM{respond: respond}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		call := len(f.requests)
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(f.respond(call, req))
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Requests returns the chat completion requests received so far
+func (f *fakeLLM) Requests() []openai.ChatCompletionRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), f.requests...)
+}
+
+// replies answers the calls in order with the given contents, repeating the last
+func replies(contents ...string) func(int, openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+	return func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+		return reply(contents[min(call, len(contents)-1)])
+	}
+}
+
+// reply is a completion of content using 100 prompt and 50 completion tokens
+func reply(content string) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		ID:    "chatcmpl-test",
+		Model: "test-model",
+		Choices: []openai.ChatCompletionChoice{{
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+			FinishReason: openai.FinishReasonStop,
+		}},
+		Usage: openai.Usage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150},
+	}
+}
+
+// newTestAgent creates an agent talking to llm without an MCP server, offering
+// testTools instead; config's Model defaults to test-model
+func newTestAgent(t *testing.T, llm *fakeLLM, config Config) *Agent {
+	t.Helper()
+	config.APIKey = "test-key"
+	config.BaseURL = llm.URL + "/v1"
+	if config.Model == "" {
+		config.Model = "test-model"
+	}
+	agent, err := NewAgent(config, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewAgent: %v", err)
+	}
+	agent.tools = testTools()
+	agent.toolsErr = nil
+	return agent
+}
+
+// testTools mirrors the scrape_url and summarize tools of the MCP server
+func testTools() []ToolDefinition {
+	return []ToolDefinition{
+		{
+			Name:        "scrape_url",
+			Description: "Scrape a web page",
+			Parameters: &jsonschema.Schema{
+				Type: "object",
+				Properties: map[string]*jsonschema.Schema{
+					"url":      {Type: "string"},
+					"selector": {Type: "string"},
+				},
+				Required: []string{"url"},
+			},
+		},
+		{
+			Name:        "summarize",
+			Description: "Summarize text",
+			Parameters: &jsonschema.Schema{
+				Type: "object",
+				Properties: map[string]*jsonschema.Schema{
+					"content":    {Type: "string"},
+					"style":      {Type: "string", Enum: []any{"concise", "detailed", "bullet-points"}},
+					"max_length": {Type: "integer"},
+				},
+				Required: []string{"content"},
+			},
+		},
+	}
+}
+
+// plan is a model reply calling the named tool with args
+func plan(tool string, args map[string]any) string {
+	reply, _ := json.Marshal(map[string]any{
+		"message":     "Working on it",
+		"tool_calls":  []map[string]any{{"name": tool, "arguments": args, "reasoning": "needed"}},
+		"should_call": true,
+		"confidence":  0.9,
+		"explanation": "The request names a page",
+	})
+	return string(reply)
+}
+
+func TestValidateResponse(t *testing.T) {
+	agent := newTestAgent(t, newFakeLLM(t, replies("{}")), Config{})
+	response := &Response{
+		ShouldCall: true,
+		Confidence: 1.7,
+		ToolCalls: []ToolCall{
+			{Name: "scrape_url", Arguments: map[string]any{"url": "https://example.com"}},
+			{Name: "summarize", Arguments: map[string]any{"content": "text", "style": "poetic"}},
+			{Name: "delete_everything", Arguments: map[string]any{}},
+		},
+	}
+
+	err := agent.ValidateResponse(response)
+	if err == nil {
+		t.Fatal("ValidateResponse accepted invalid tool calls")
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "tool call 1") {
+		t.Errorf("the valid call was reported: %v", err)
+	}
+	for _, want := range []string{"tool call 2 (summarize)", "style", "tool call 3 (delete_everything)", "unknown tool"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not mention %q", msg, want)
+		}
+	}
+	if response.Confidence != 1 {
+		t.Errorf("Confidence = %v, want it clamped to 1", response.Confidence)
+	}
+	if !response.ShouldCall {
+		t.Error("ShouldCall was cleared although the response has tool calls")
+	}
+}
+
+func TestValidateResponseNormalizes(t *testing.T) {
+	agent := newTestAgent(t, newFakeLLM(t, replies("{}")), Config{})
+	response := &Response{ShouldCall: true, Confidence: -0.2}
+
+	if err := agent.ValidateResponse(response); err != nil {
+		t.Fatalf("ValidateResponse: %v", err)
+	}
+	if response.Confidence != 0 {
+		t.Errorf("Confidence = %v, want it clamped to 0", response.Confidence)
+	}
+	if response.ShouldCall {
+		t.Error("ShouldCall is still set on a response without tool calls")
+	}
+}
+
+func TestResponseUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    Response
+		wantErr string
+	}{
+		{
+			name:  "version 1 reply without the newer fields",
+			reply: `{"message": "Hi", "should_call": false, "confidence": 0.9, "explanation": "greeting"}`,
+			want:  Response{Message: "Hi", Confidence: 0.9, Explanation: "greeting", SchemaVersion: 1},
+		},
+		{
+			name:  "unknown keys are ignored",
+			reply: `{"message": "Hi", "should_call": false, "confidence": 0.9, "schema_version": 3, "intent": "chat", "mood": {"tone": "warm"}}`,
+			want:  Response{Message: "Hi", Confidence: 0.9, SchemaVersion: 3},
+		},
+		{
+			name:  "missing keys take defaults",
+			reply: `{"tool_calls": [{"name": "scrape_url", "arguments": {"url": "https://example.com"}}]}`,
+			want: Response{
+				ToolCalls:     []ToolCall{{Name: "scrape_url", Arguments: map[string]interface{}{"url": "https://example.com"}}},
+				ShouldCall:    true,
+				Confidence:    defaultConfidence,
+				SchemaVersion: 1,
+			},
+		},
+		{
+			name:  "lone tool call and string values",
+			reply: `{"tool_calls": {"name": "scrape_url", "arguments": "{\"url\": \"https://example.com\"}"}, "should_call": "true", "confidence": "0.8", "needs_clarification": "false", "schema_version": "2"}`,
+			want: Response{
+				ToolCalls:     []ToolCall{{Name: "scrape_url", Arguments: map[string]interface{}{"url": "https://example.com"}}},
+				ShouldCall:    true,
+				Confidence:    0.8,
+				SchemaVersion: 2,
+			},
+		},
+		{
+			name:  "clarification",
+			reply: `{"message": "", "needs_clarification": true, "clarifying_question": "Which page?", "schema_version": 2}`,
+			want:  Response{Confidence: defaultConfidence, NeedsClarification: true, ClarifyingQuestion: "Which page?", SchemaVersion: 2},
+		},
+		{name: "bad confidence", reply: `{"confidence": "high"}`, wantErr: "confidence"},
+		{name: "bad tool calls", reply: `{"tool_calls": 7}`, wantErr: "tool_calls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Response
+			err := json.Unmarshal([]byte(tt.reply), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Unmarshal error = %v, want one about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaVersionInPrompt(t *testing.T) {
+	tests := []struct {
+		version     int
+		want        string
+		wantMissing string
+	}{
+		{version: 0, want: `"schema_version": 2`},
+		{version: 2, want: `"needs_clarification": false`},
+		{version: 1, wantMissing: "needs_clarification"},
+	}
+	for _, tt := range tests {
+		llm := newFakeLLM(t, replies(`{"message": "Hi", "should_call": false, "confidence": 0.9}`))
+		agent := newTestAgent(t, llm, Config{SchemaVersion: tt.version})
+		response, err := agent.ProcessInput(context.Background(), "hello", nil)
+		if err != nil {
+			t.Fatalf("ProcessInput: %v", err)
+		}
+		if response.Message != "Hi" || response.SchemaVersion != 1 {
+			t.Errorf("SchemaVersion %d: response = %+v, want a version 1 reply accepted", tt.version, response)
+		}
+		system := llm.Requests()[0].Messages[0].Content
+		if tt.want != "" && !strings.Contains(system, tt.want) {
+			t.Errorf("SchemaVersion %d: system prompt lacks %s", tt.version, tt.want)
+		}
+		if tt.wantMissing != "" && strings.Contains(system, tt.wantMissing) {
+			t.Errorf("SchemaVersion %d: system prompt asks for %s", tt.version, tt.wantMissing)
+		}
+	}
+}
+
+func TestProcessInputRetriesTruncatedJSON(t *testing.T) {
+	valid := plan("scrape_url", map[string]any{"url": "https://example.com"})
+	llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+		if call == 0 {
+			resp := reply(valid[:len(valid)/2])
+			resp.Choices[0].FinishReason = openai.FinishReasonLength
+			return resp
+		}
+		return reply(valid)
+	})
+	agent := newTestAgent(t, llm, Config{MaxTokens: 100})
+
+	response, err := agent.ProcessInput(context.Background(), "scrape https://example.com", nil)
+	if err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+
+	requests := llm.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d completions, want the truncated one and the retry", len(requests))
+	}
+	if requests[0].MaxTokens != 100 || requests[1].MaxTokens != truncatedRetryMaxTokens {
+		t.Errorf("MaxTokens = %d then %d, want 100 then %d", requests[0].MaxTokens, requests[1].MaxTokens, truncatedRetryMaxTokens)
+	}
+	if !response.ShouldCall || len(response.ToolCalls) != 1 || response.ToolCalls[0].Name != "scrape_url" {
+		t.Errorf("response = %+v, want the retried plan", response)
+	}
+}
+
+func TestParseRetryEscalatesTemperature(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   []float32
+	}{
+		{"default step", Config{Temperature: 0.3, ParseRetries: 2}, []float32{0.3, 0.5, 0.7}},
+		{"default cap", Config{Temperature: 0.9, ParseRetries: 2}, []float32{0.9, 1.0, 1.0}},
+		{"default cap follows a hot start", Config{Temperature: 1.5, ParseRetries: 2}, []float32{1.5, 1.5, 1.5}},
+		{"explicit schedule", Config{Temperature: 0.3, ParseRetries: 2, TemperatureStep: 0.25, MaxTemperature: 0.7}, []float32{0.3, 0.55, 0.7}},
+	}
+	valid := plan("scrape_url", map[string]any{"url": "https://example.com"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+				if call < len(tt.want)-1 {
+					return reply("Sure! Here is the plan: scrape it.")
+				}
+				return reply(valid)
+			})
+			agent := newTestAgent(t, llm, tt.config)
+
+			if _, err := agent.ProcessInput(context.Background(), "scrape https://example.com", nil); err != nil {
+				t.Fatalf("ProcessInput: %v", err)
+			}
+			requests := llm.Requests()
+			if len(requests) != len(tt.want) {
+				t.Fatalf("got %d completions, want %d", len(requests), len(tt.want))
+			}
+			for i, req := range requests {
+				if diff := req.Temperature - tt.want[i]; diff > 1e-6 || diff < -1e-6 {
+					t.Errorf("attempt %d temperature = %g, want %g", i, req.Temperature, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   []string // substrings of the error; empty means valid
+	}{
+		{name: "defaults", config: Config{Model: "m"}},
+		{name: "hot start under the default cap", config: Config{Model: "m", Temperature: 1.5}},
+		{name: "explicit cap at Temperature", config: Config{Model: "m", Temperature: 0.8, MaxTemperature: 0.8}},
+		{name: "missing model", config: Config{}, want: []string{"Model is required"}},
+		{
+			name:   "cap below Temperature",
+			config: Config{Model: "m", Temperature: 1.5, MaxTemperature: 1.2},
+			want:   []string{"MaxTemperature (1.2) must not be below Temperature (1.5)"},
+		},
+		{
+			name:   "out of range",
+			config: Config{Model: "m", Temperature: 2.5, MaxTemperature: 3, TopP: 1.5},
+			want:   []string{"Temperature must be between 0 and 2", "MaxTemperature must be between 0 and 2", "TopP must be between 0 and 1"},
+		},
+		{
+			name:   "negative values",
+			config: Config{Model: "m", MaxTokens: -1, TemperatureStep: -0.1, ToolsTTL: -time.Second, CondenseToolOutput: -1},
+			want:   []string{"MaxTokens must not be negative", "TemperatureStep must not be negative", "ToolsTTL must not be negative", "CondenseToolOutput must not be negative"},
+		},
+		{name: "bad base URL", config: Config{Model: "m", BaseURL: "ftp://example.com"}, want: []string{"BaseURL"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsTruncated(t *testing.T) {
+	tests := []struct {
+		name         string
+		finishReason openai.FinishReason
+		content      string
+		want         bool
+	}{
+		{"complete object", openai.FinishReasonStop, `{"message": "hi"}`, false},
+		{"length finish reason", openai.FinishReasonLength, `{"message": "hi"}`, true},
+		{"unbalanced braces", openai.FinishReasonStop, `{"tool_calls": [{"name": "scrape_url"`, true},
+		{"braces inside strings", openai.FinishReasonStop, `{"message": "use { and [ freely"}`, false},
+		{"escaped quote", openai.FinishReasonStop, `{"message": "say \"{\""}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTruncated(tt.finishReason, tt.content); got != tt.want {
+				t.Errorf("isTruncated(%q, %q) = %v, want %v", tt.finishReason, tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplingParamsReachRequest(t *testing.T) {
+	llm := newFakeLLM(t, replies(plan("scrape_url", map[string]any{"url": "https://example.com"})))
+	agent := newTestAgent(t, llm, Config{TopP: 0.8, FrequencyPenalty: 0.5, PresencePenalty: -0.3})
+
+	if _, err := agent.ProcessInput(context.Background(), "scrape https://example.com", nil); err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	req := llm.Requests()[0]
+	if req.TopP != 0.8 || req.FrequencyPenalty != 0.5 || req.PresencePenalty != -0.3 {
+		t.Errorf("sampling = top_p %v, frequency %v, presence %v; want 0.8, 0.5, -0.3",
+			req.TopP, req.FrequencyPenalty, req.PresencePenalty)
+	}
+}
+
+func TestSamplingParamsUnsetByDefault(t *testing.T) {
+	llm := newFakeLLM(t, replies(plan("scrape_url", map[string]any{"url": "https://example.com"})))
+	agent := newTestAgent(t, llm, Config{})
+
+	if _, err := agent.ProcessInput(context.Background(), "scrape https://example.com", nil); err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	// Zero values are omitted from the request body, leaving the provider's defaults
+	req := llm.Requests()[0]
+	if req.TopP != 0 || req.FrequencyPenalty != 0 || req.PresencePenalty != 0 {
+		t.Errorf("sampling = top_p %v, frequency %v, presence %v; want all unset",
+			req.TopP, req.FrequencyPenalty, req.PresencePenalty)
+	}
+}
+
+func TestCallOptions(t *testing.T) {
+	zero, hot := float32(0), float32(1.4)
+	tests := []struct {
+		name            string
+		opts            *CallOptions
+		wantMaxTokens   int
+		wantTemperature float32
+	}{
+		{name: "nil keeps the config", wantMaxTokens: 500, wantTemperature: 0.7},
+		{name: "zero values keep the config", opts: &CallOptions{}, wantMaxTokens: 500, wantTemperature: 0.7},
+		{name: "both overridden", opts: &CallOptions{MaxTokens: 3000, Temperature: &hot}, wantMaxTokens: 3000, wantTemperature: 1.4},
+		{name: "temperature overridden to zero", opts: &CallOptions{Temperature: &zero}, wantMaxTokens: 500, wantTemperature: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, replies(plan("scrape_url", map[string]any{"url": "https://example.com"})))
+			agent := newTestAgent(t, llm, Config{MaxTokens: 500, Temperature: 0.7})
+
+			if _, err := agent.ProcessInput(context.Background(), "scrape https://example.com", tt.opts); err != nil {
+				t.Fatalf("ProcessInput: %v", err)
+			}
+			req := llm.Requests()[0]
+			if req.MaxTokens != tt.wantMaxTokens || req.Temperature != tt.wantTemperature {
+				t.Errorf("request max_tokens %d, temperature %g; want %d, %g", req.MaxTokens, req.Temperature, tt.wantMaxTokens, tt.wantTemperature)
+			}
+			// The override is for one call only
+			if agent.config.MaxTokens != 500 || agent.config.Temperature != 0.7 {
+				t.Errorf("config changed to max_tokens %d, temperature %g", agent.config.MaxTokens, agent.config.Temperature)
+			}
+		})
+	}
+
+	t.Run("invalid overrides rejected", func(t *testing.T) {
+		llm := newFakeLLM(t, replies(plan("scrape_url", map[string]any{"url": "https://example.com"})))
+		agent := newTestAgent(t, llm, Config{})
+		tooHot := float32(2.5)
+
+		_, err := agent.ProcessInput(context.Background(), "scrape https://example.com", &CallOptions{MaxTokens: -1, Temperature: &tooHot})
+		if err == nil || !strings.Contains(err.Error(), "MaxTokens must not be negative") || !strings.Contains(err.Error(), "Temperature must be between 0 and 2") {
+			t.Errorf("ProcessInput error = %v, want both problems reported", err)
+		}
+		if got := len(llm.Requests()); got != 0 {
+			t.Errorf("made %d completions with invalid overrides, want none", got)
+		}
+	})
+}
+
+// unreachableURL returns the address of a server that is no longer listening
+func unreachableURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestUnreachableMCPServer(t *testing.T) {
+	mcpURL := unreachableURL(t)
+
+	t.Run("RequireTools fails", func(t *testing.T) {
+		llm := newFakeLLM(t, replies("{}"))
+		agent, err := NewAgent(Config{APIKey: "test-key", BaseURL: llm.URL + "/v1", Model: "test-model", MCPServer: mcpURL, RequireTools: true}, zerolog.Nop())
+		if err != nil {
+			t.Fatalf("NewAgent: %v", err)
+		}
+		defer agent.Close()
+
+		_, err = agent.ProcessInput(context.Background(), "scrape https://example.com", nil)
+		if !errors.Is(err, ErrNoTools) {
+			t.Errorf("ProcessInput error = %v, want ErrNoTools", err)
+		}
+		if n := len(llm.Requests()); n != 0 {
+			t.Errorf("got %d completions, want none without tools", n)
+		}
+	})
+
+	t.Run("toolless replies are flagged", func(t *testing.T) {
+		llm := newFakeLLM(t, replies(`{"message": "I cannot scrape pages right now.", "should_call": false, "confidence": 0.9}`))
+		agent, err := NewAgent(Config{APIKey: "test-key", BaseURL: llm.URL + "/v1", Model: "test-model", MCPServer: mcpURL}, zerolog.Nop())
+		if err != nil {
+			t.Fatalf("NewAgent: %v", err)
+		}
+		defer agent.Close()
+
+		response, err := agent.ProcessInput(context.Background(), "scrape https://example.com", nil)
+		if err != nil {
+			t.Fatalf("ProcessInput: %v", err)
+		}
+		if !response.ToolsUnavailable {
+			t.Error("ToolsUnavailable is not set")
+		}
+	})
+}
+
+const clarification = `{"message": "Which page?", "needs_clarification": true, "clarifying_question": "Which page should I summarize?", "should_call": true, "tool_calls": [{"name": "scrape_url", "arguments": {"url": "https://example.com"}}], "confidence": 0.4}`
+
+func TestClarificationRemembersContext(t *testing.T) {
+	llm := newFakeLLM(t, replies(clarification, plan("scrape_url", map[string]any{"url": "https://example.com/news"})))
+	agent := newTestAgent(t, llm, Config{})
+
+	response, err := agent.ProcessInput(context.Background(), "summarize that", nil)
+	if err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	if !response.NeedsClarification || response.ClarifyingQuestion != "Which page should I summarize?" {
+		t.Errorf("response = %+v, want the clarifying question", response)
+	}
+	if response.ShouldCall {
+		t.Error("ShouldCall is set on a response that needs clarification")
+	}
+
+	response, err = agent.ProcessInput(context.Background(), "the news page on example.com", nil)
+	if err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	if !response.ShouldCall || response.ToolCalls[0].Arguments["url"] != "https://example.com/news" {
+		t.Errorf("response = %+v, want the plan for the answer", response)
+	}
+
+	// The answer is sent with the original request and the question in memory
+	answer := llm.Requests()[1]
+	var sawRequest, sawQuestion bool
+	for _, message := range answer.Messages[1 : len(answer.Messages)-1] {
+		sawRequest = sawRequest || strings.Contains(message.Content, "summarize that")
+		sawQuestion = sawQuestion || strings.Contains(message.Content, "Which page should I summarize?")
+	}
+	if !sawRequest || !sawQuestion {
+		t.Errorf("follow-up messages lack the earlier exchange: %+v", answer.Messages)
+	}
+	if n := len(agent.History()); n != 4 {
+		t.Errorf("History has %d messages, want both exchanges", n)
+	}
+}
+
+func TestSessionSummary(t *testing.T) {
+	t.Run("empty history", func(t *testing.T) {
+		llm := newFakeLLM(t, replies("- Nothing happened"))
+		agent := newTestAgent(t, llm, Config{})
+
+		recap, err := agent.SessionSummary(context.Background())
+		if err != nil || recap != "" {
+			t.Errorf("SessionSummary() = %q, %v, want an empty recap", recap, err)
+		}
+		if n := len(llm.Requests()); n != 0 {
+			t.Errorf("got %d completions, want none without history", n)
+		}
+	})
+
+	t.Run("populated history", func(t *testing.T) {
+		llm := newFakeLLM(t, replies("  - Scraped example.com/news and summarized the budget story\n"))
+		agent := newTestAgent(t, llm, Config{})
+		history := []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "summarize https://example.com/news"},
+			{Role: openai.ChatMessageRoleAssistant, Content: plan("scrape_url", map[string]any{"url": "https://example.com/news"})},
+		}
+		agent.SetHistory(history)
+
+		recap, err := agent.SessionSummary(context.Background())
+		if err != nil {
+			t.Fatalf("SessionSummary: %v", err)
+		}
+		if recap != "- Scraped example.com/news and summarized the budget story" {
+			t.Errorf("SessionSummary() = %q, want the trimmed model reply", recap)
+		}
+		req := llm.Requests()[0]
+		if len(req.Messages) != len(history)+2 || req.Messages[0].Role != openai.ChatMessageRoleSystem {
+			t.Fatalf("request messages = %+v, want the system prompt, the history and the recap request", req.Messages)
+		}
+		for i, message := range history {
+			if got := req.Messages[i+1]; got.Role != message.Role || got.Content != message.Content {
+				t.Errorf("message %d = %+v, want the remembered %+v", i+1, got, message)
+			}
+		}
+		if len(agent.History()) != len(history) {
+			t.Errorf("SessionSummary changed the history to %d messages", len(agent.History()))
+		}
+	})
+}
+
+func TestHistoryConcurrentUse(t *testing.T) {
+	agent := newTestAgent(t, newFakeLLM(t, replies(plan("scrape_url", map[string]any{"url": "https://example.com"}))), Config{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			agent.ProcessInput(context.Background(), "scrape https://example.com", nil)
+		}()
+		go func() {
+			defer wg.Done()
+			agent.SetHistory(agent.History())
+		}()
+		go func() {
+			defer wg.Done()
+			agent.ClearHistory()
+		}()
+	}
+	wg.Wait()
+	if n := len(agent.History()); n > maxHistoryMessages {
+		t.Errorf("History has %d messages, over the cap of %d", n, maxHistoryMessages)
+	}
+}
+
+// fakeMCP is an MCP server over SSE whose tools a test can change between calls
+type fakeMCP struct {
+	*httptest.Server
+	server *mcp.Server
+}
+
+type fakeToolArgs struct {
+	URL string `json:"url"`
+}
+
+func newFakeMCP(t *testing.T) *fakeMCP {
+	t.Helper()
+	server := mcp.NewServer(&mcp.Implementation{Name: "fake-tools", Version: "1.0.0"}, nil)
+	f := &fakeMCP{server: server}
+	f.Server = httptest.NewServer(mcp.NewSSEHandler(func(*http.Request) *mcp.Server { return server }))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// addTool offers a tool named name that echoes its url argument
+func (f *fakeMCP) addTool(name string) {
+	mcp.AddTool(f.server, &mcp.Tool{Name: name, Description: "Fetch " + name},
+		func(ctx context.Context, req *mcp.CallToolRequest, args fakeToolArgs) (*mcp.CallToolResult, any, error) {
+			return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: name + " " + args.URL}}}, nil, nil
+		})
+}
+
+// toolNames returns the names of the agent's tools, sorted
+func toolNames(agent *Agent) []string {
+	var names []string
+	for _, tool := range agent.Tools() {
+		names = append(names, tool.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestRefreshToolsPicksUpNewTools(t *testing.T) {
+	tools := newFakeMCP(t)
+	tools.addTool("scrape_url")
+	llm := newFakeLLM(t, replies("{}"))
+	agent, err := NewAgent(Config{APIKey: "test-key", BaseURL: llm.URL + "/v1", Model: "test-model", MCPServer: tools.URL}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewAgent: %v", err)
+	}
+	defer agent.Close()
+
+	if got := toolNames(agent); !slices.Equal(got, []string{"scrape_url"}) {
+		t.Fatalf("tools after NewAgent = %q", got)
+	}
+	first := agent.ToolsRefreshedAt()
+	if first.IsZero() {
+		t.Error("ToolsRefreshedAt is zero after a successful fetch")
+	}
+
+	tools.addTool("summarize")
+	time.Sleep(time.Millisecond)
+	if err := agent.RefreshTools(context.Background()); err != nil {
+		t.Fatalf("RefreshTools: %v", err)
+	}
+	if got := toolNames(agent); !slices.Equal(got, []string{"scrape_url", "summarize"}) {
+		t.Errorf("tools after RefreshTools = %q, want the added tool too", got)
+	}
+	if !agent.ToolsRefreshedAt().After(first) {
+		t.Error("ToolsRefreshedAt did not advance")
+	}
+}
+
+func TestRefreshToolsRecoversFromFailedStart(t *testing.T) {
+	tools := newFakeMCP(t)
+	tools.addTool("scrape_url")
+	// The server is down while the agent starts
+	var up atomic.Bool
+	sse := tools.Config.Handler
+	tools.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		sse.ServeHTTP(w, r)
+	})
+	llm := newFakeLLM(t, replies("{}"))
+	agent, err := NewAgent(Config{APIKey: "test-key", BaseURL: llm.URL + "/v1", Model: "test-model", MCPServer: tools.URL}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewAgent: %v", err)
+	}
+	defer agent.Close()
+	if len(agent.Tools()) != 0 || agent.ToolsUnavailableReason() == "" {
+		t.Fatalf("agent has tools %q from a server that is down", toolNames(agent))
+	}
+
+	up.Store(true)
+	if err := agent.RefreshTools(context.Background()); err != nil {
+		t.Fatalf("RefreshTools: %v", err)
+	}
+	if got := toolNames(agent); !slices.Equal(got, []string{"scrape_url"}) {
+		t.Errorf("tools = %q, want the server's tool", got)
+	}
+	if reason := agent.ToolsUnavailableReason(); reason != "" {
+		t.Errorf("ToolsUnavailableReason = %q, want none after recovery", reason)
+	}
+}
+
+func TestDescribeTool(t *testing.T) {
+	agent := newTestAgent(t, newFakeLLM(t, replies("{}")), Config{})
+
+	description, err := agent.DescribeTool("summarize")
+	if err != nil {
+		t.Fatalf("DescribeTool: %v", err)
+	}
+	want := `summarize
+  Summarize text
+  Parameters:
+    - content (string, required)
+    - max_length (integer, optional)
+    - style (string, optional) [one of: [concise detailed bullet-points]]
+`
+	if description != want {
+		t.Errorf("DescribeTool(summarize) =\n%s\nwant\n%s", description, want)
+	}
+
+	if _, err := agent.DescribeTool("delete_everything"); err == nil || !strings.Contains(err.Error(), "unknown tool: delete_everything") {
+		t.Errorf("DescribeTool(unknown) error = %v, want unknown tool", err)
+	}
+}
+
+func TestAuditLogCapturesPrompts(t *testing.T) {
+	llm := newFakeLLM(t, replies(plan("scrape_url", map[string]any{"url": "https://example.com"})))
+	var log bytes.Buffer
+	agent := newTestAgent(t, llm, Config{AuditLog: &log})
+
+	input := "scrape https://example.com, my key is test-key"
+	if _, err := agent.ProcessInput(context.Background(), input, nil); err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+
+	var entry audit.Entry
+	if err := json.Unmarshal(log.Bytes(), &entry); err != nil {
+		t.Fatalf("audit log is not one JSON entry: %v\n%s", err, log.String())
+	}
+	if entry.Component != "agent" || entry.Response == nil || len(entry.Response.Choices) != 1 {
+		t.Errorf("entry = %+v, want the agent's request and response", entry)
+	}
+	prompt := entry.Request.Messages[len(entry.Request.Messages)-1].Content
+	if !strings.Contains(prompt, "scrape https://example.com, my key is [REDACTED]") {
+		t.Errorf("audited prompt = %q, want the input with the API key redacted", prompt)
+	}
+	if strings.Contains(log.String(), "test-key") {
+		t.Error("audit log contains the API key")
+	}
+}
+
+func TestAuditLogOffByDefault(t *testing.T) {
+	llm := newFakeLLM(t, replies(plan("scrape_url", map[string]any{"url": "https://example.com"})))
+	agent := newTestAgent(t, llm, Config{})
+	if _, err := agent.ProcessInput(context.Background(), "scrape https://example.com", nil); err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	if agent.audit != nil {
+		t.Error("audit log is set without Config.AuditLog")
+	}
+}
+
+type fakeCrawlArgs struct {
+	URL      string   `json:"url"`
+	Selector string   `json:"selector,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+func TestToolArgDefaults(t *testing.T) {
+	tools := newFakeMCP(t)
+	mcp.AddTool(tools.server, &mcp.Tool{Name: "crawl", Description: "Crawl a site"},
+		func(ctx context.Context, req *mcp.CallToolRequest, args fakeCrawlArgs) (*mcp.CallToolResult, any, error) {
+			return &mcp.CallToolResult{}, nil, nil
+		})
+	tags := []any{"news"}
+	defaults := map[string]map[string]any{
+		"crawl": {
+			"selector": "article",
+			"tags":     tags,
+			"url":      42, // the wrong type
+			"depth":    3,  // not a parameter of crawl
+		},
+		"missing_tool": {"selector": "main"},
+	}
+	llm := newFakeLLM(t, replies(
+		plan("crawl", map[string]any{"url": "https://a.example"}),
+		plan("crawl", map[string]any{"url": "https://b.example", "selector": "#content"}),
+		plan("crawl", map[string]any{"url": "https://c.example"}),
+	))
+	agent, err := NewAgent(Config{APIKey: "test-key", BaseURL: llm.URL + "/v1", Model: "test-model", MCPServer: tools.URL, ToolArgDefaults: defaults}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewAgent: %v", err)
+	}
+	defer agent.Close()
+
+	// Defaults are checked and copied at construction
+	if _, ok := agent.toolArgDefaults["missing_tool"]; ok {
+		t.Error("kept defaults for a tool the server does not offer")
+	}
+	if _, ok := agent.toolArgDefaults["crawl"]["depth"]; ok {
+		t.Error("kept a default for an unknown parameter")
+	}
+	if _, ok := agent.toolArgDefaults["crawl"]["url"]; ok {
+		t.Error("kept a default of the wrong type")
+	}
+	tags[0] = "changed"
+	defaults["crawl"]["selector"] = "changed"
+
+	// The model omits selector: the default fills it in
+	first, err := agent.ProcessInput(context.Background(), "crawl a", nil)
+	if err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	args := first.ToolCalls[0].Arguments
+	if args["selector"] != "article" || !slices.Equal(args["tags"].([]any), []any{"news"}) {
+		t.Errorf("arguments = %v, want the defaults as configured at construction", args)
+	}
+	args["tags"].([]any)[0] = "mutated"
+
+	// The model sets selector: its value wins
+	second, err := agent.ProcessInput(context.Background(), "crawl b", nil)
+	if err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	if got := second.ToolCalls[0].Arguments["selector"]; got != "#content" {
+		t.Errorf("selector = %v, want the model's #content", got)
+	}
+
+	// Changing one call's arguments leaves the defaults of the next alone
+	third, err := agent.ProcessInput(context.Background(), "crawl c", nil)
+	if err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	if got := third.ToolCalls[0].Arguments["tags"]; !slices.Equal(got.([]any), []any{"news"}) {
+		t.Errorf("tags = %v, want an unshared copy of the default", got)
+	}
+}
+
+func TestToolArgDefaultsMustEncode(t *testing.T) {
+	config := Config{APIKey: "test-key", ToolArgDefaults: map[string]map[string]any{"crawl": {"selector": func() {}}}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "ToolArgDefaults[crawl][selector]") {
+		t.Errorf("Validate = %v, want the unencodable default reported", err)
+	}
+}
+
+func TestCondenseToolResult(t *testing.T) {
+	large := strings.Repeat("The council debated the budget line by line. ", 50)
+	tests := []struct {
+		name          string
+		threshold     int
+		result        string
+		fail          bool
+		wantCondensed bool
+		wantErr       bool
+	}{
+		{name: "large result", threshold: 200, result: large, wantCondensed: true},
+		{name: "small result", threshold: 200, result: "Short page.", wantCondensed: false},
+		{name: "disabled", threshold: 0, result: large, wantCondensed: false},
+		{name: "failure keeps the full result", threshold: 200, result: large, fail: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+				if tt.fail {
+					return openai.ChatCompletionResponse{}
+				}
+				return reply("The page covers the council's budget debate.")
+			})
+			agent := newTestAgent(t, llm, Config{CondenseToolOutput: tt.threshold})
+
+			shown, condensed, err := agent.CondenseToolResult(context.Background(), "scrape_url", "what did the council decide?", tt.result)
+			if (err != nil) != tt.wantErr || condensed != tt.wantCondensed {
+				t.Fatalf("CondenseToolResult = condensed %v, error %v; want condensed %v, error %v", condensed, err, tt.wantCondensed, tt.wantErr)
+			}
+			if !tt.wantCondensed {
+				if shown != tt.result {
+					t.Errorf("shown = %q, want the result unchanged", shown)
+				}
+				if n := len(llm.Requests()); n != 0 && !tt.fail {
+					t.Errorf("got %d completions, want none", n)
+				}
+				return
+			}
+			if shown != "The page covers the council's budget debate." {
+				t.Errorf("shown = %q, want the condensed summary", shown)
+			}
+			prompt := llm.Requests()[0].Messages
+			if last := prompt[len(prompt)-1].Content; !strings.Contains(last, "output of the scrape_url tool") || !strings.Contains(last, large) {
+				t.Errorf("condense prompt = %q, want the instruction and the full result", last)
+			}
+		})
+	}
+}
+
+func TestReflect(t *testing.T) {
+	const request = "summarize https://example.com/budget"
+	// The first plan scrapes the wrong page
+	mismatched := plan("scrape_url", map[string]any{"url": "https://example.com/sports"})
+	revised := plan("scrape_url", map[string]any{"url": "https://example.com/budget"})
+	verdict := func(aligned bool, revision string) string {
+		if revision == "" {
+			revision = "null"
+		}
+		return fmt.Sprintf(`{"aligned": %t, "issues": "the plan scrapes the sports page, not the budget page", "confidence": 0.2, "revised": %s}`, aligned, revision)
+	}
+
+	tests := []struct {
+		name           string
+		reflect        bool
+		replies        []string
+		wantURL        string
+		wantConfidence float64
+		wantReflection bool
+		wantCalls      int
+	}{
+		{name: "corrects the plan", reflect: true, replies: []string{mismatched, verdict(false, revised)}, wantURL: "https://example.com/budget", wantConfidence: 0.9, wantReflection: true, wantCalls: 2},
+		{name: "lowers confidence without a revision", reflect: true, replies: []string{mismatched, verdict(false, "")}, wantURL: "https://example.com/sports", wantConfidence: 0.2, wantReflection: true, wantCalls: 2},
+		{name: "keeps an aligned plan", reflect: true, replies: []string{mismatched, verdict(true, "")}, wantURL: "https://example.com/sports", wantConfidence: 0.9, wantCalls: 2},
+		{name: "keeps the plan when reflection fails", reflect: true, replies: []string{mismatched, "not JSON"}, wantURL: "https://example.com/sports", wantConfidence: 0.9, wantCalls: 2},
+		{name: "off by default", replies: []string{mismatched, verdict(false, revised)}, wantURL: "https://example.com/sports", wantConfidence: 0.9, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, replies(tt.replies...))
+			agent := newTestAgent(t, llm, Config{Reflect: tt.reflect})
+
+			response, err := agent.ProcessInput(context.Background(), request, nil)
+			if err != nil {
+				t.Fatalf("ProcessInput: %v", err)
+			}
+			if len(response.ToolCalls) != 1 || response.ToolCalls[0].Arguments["url"] != tt.wantURL {
+				t.Errorf("ToolCalls = %+v, want a scrape of %s", response.ToolCalls, tt.wantURL)
+			}
+			if response.Confidence != tt.wantConfidence {
+				t.Errorf("Confidence = %v, want %v", response.Confidence, tt.wantConfidence)
+			}
+			if got := response.Reflection != ""; got != tt.wantReflection {
+				t.Errorf("Reflection = %q, want it set: %v", response.Reflection, tt.wantReflection)
+			}
+			requests := llm.Requests()
+			if len(requests) != tt.wantCalls {
+				t.Fatalf("got %d completions, want %d", len(requests), tt.wantCalls)
+			}
+			if tt.wantCalls == 2 {
+				prompt := requests[1].Messages[len(requests[1].Messages)-1].Content
+				if !strings.Contains(prompt, request) || !strings.Contains(prompt, "https://example.com/sports") {
+					t.Errorf("reflection prompt = %q, want the request and the plan", prompt)
+				}
+			}
+		})
+	}
+
+	t.Run("not run without tool calls", func(t *testing.T) {
+		llm := newFakeLLM(t, replies(`{"message": "Hello!", "should_call": false, "confidence": 0.9}`))
+		agent := newTestAgent(t, llm, Config{Reflect: true})
+		if _, err := agent.ProcessInput(context.Background(), "hi", nil); err != nil {
+			t.Fatalf("ProcessInput: %v", err)
+		}
+		if n := len(llm.Requests()); n != 1 {
+			t.Errorf("got %d completions, want no reflection of a reply without tool calls", n)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+// Package audit records the exact prompts sent to LLM providers and the replies
+// they return, for debugging and compliance, as one JSON line per completion with
+// the API key redacted. Auditing is off unless a writer is configured. Audit logs
+// can contain scraped content and user input, so treat them as sensitive data.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/HeidiZHH/skull/internal/textutil"
+	"github.com/sashabaranov/go-openai"
+)
+
+// redacted replaces secrets found in audited text
+const redacted = "[REDACTED]"
+
+// Log writes one JSON line per chat completion to a writer. A nil *Log records nothing.
+type Log struct {
+	mu         sync.Mutex
+	encoder    *json.Encoder
+	maxContent int
+	secrets    []string
+}
+
+// Entry is one audited chat completion
+type Entry struct {
+	Time      time.Time                      `json:"time"`
+	Component string                         `json:"component"`
+	Duration  time.Duration                  `json:"duration_ns"`
+	Request   openai.ChatCompletionRequest   `json:"request"`
+	Response  *openai.ChatCompletionResponse `json:"response,omitempty"`
+	Error     string                         `json:"error,omitempty"`
+}
+
+// New creates an audit log writing to w. Message content longer than maxContent
+// characters is truncated (zero keeps it whole), and every occurrence of the
+// given secrets, such as the API key, is replaced with "[REDACTED]".
+func New(w io.Writer, maxContent int, secrets ...string) *Log {
+	var nonEmpty []string
+	for _, secret := range secrets {
+		if secret != "" {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+	return &Log{encoder: json.NewEncoder(w), maxContent: maxContent, secrets: nonEmpty}
+}
+
+// Record writes a chat completion request and its response or error
+func (l *Log) Record(component string, start time.Time, req openai.ChatCompletionRequest, resp *openai.ChatCompletionResponse, err error) error {
+	if l == nil {
+		return nil
+	}
+
+	entry := Entry{
+		Time:      start,
+		Component: component,
+		Duration:  time.Since(start),
+		Request:   req,
+	}
+	entry.Request.Messages = l.redactMessages(req.Messages)
+	if resp != nil {
+		copied := *resp
+		copied.Choices = make([]openai.ChatCompletionChoice, len(resp.Choices))
+		for i, choice := range resp.Choices {
+			choice.Message = l.redactMessages([]openai.ChatCompletionMessage{choice.Message})[0]
+			copied.Choices[i] = choice
+		}
+		entry.Response = &copied
+	}
+	if err != nil {
+		entry.Error = l.redact(err.Error())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.encoder.Encode(entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// redactMessages returns copies of the messages with secrets removed and long content truncated
+func (l *Log) redactMessages(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	out := make([]openai.ChatCompletionMessage, len(messages))
+	for i, message := range messages {
+		message.Content = l.truncate(l.redact(message.Content))
+		if message.MultiContent != nil {
+			parts := make([]openai.ChatMessagePart, len(message.MultiContent))
+			for j, part := range message.MultiContent {
+				part.Text = l.truncate(l.redact(part.Text))
+				parts[j] = part
+			}
+			message.MultiContent = parts
+		}
+		out[i] = message
+	}
+	return out
+}
+
+// redact replaces every configured secret in s
+func (l *Log) redact(s string) string {
+	for _, secret := range l.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// truncate shortens s to maxContent characters, noting how much was dropped
+func (l *Log) truncate(s string) string {
+	cut := textutil.TruncateRunes(s, l.maxContent)
+	if cut == s {
+		return s
+	}
+	return fmt.Sprintf("%s [truncated %d chars]", cut, utf8.RuneCountInString(s)-l.maxContent)
+}
@@ -0,0 +1,68 @@
+// Package llm holds settings shared by the clients of OpenAI-compatible endpoints.
+package llm
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// NormalizeBaseURL cleans an OpenAI-compatible endpoint URL so that variants of
+// the same endpoint behave alike. It trims whitespace, adds a scheme when missing
+// (http for localhost, https otherwise), strips trailing slashes, and appends /v1
+// when the URL has no path. An empty URL stays empty, selecting the client's default.
+func NormalizeBaseURL(raw string) (string, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return "", nil
+	}
+	if !strings.Contains(trimmed, "://") {
+		if isLocalHost(trimmed) {
+			trimmed = "http://" + trimmed
+		} else {
+			trimmed = "https://" + trimmed
+		}
+	}
+
+	parsed, err := url.Parse(trimmed)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", raw, err)
+	}
+	switch {
+	case parsed.Scheme != "http" && parsed.Scheme != "https":
+		return "", fmt.Errorf("invalid base URL %q: scheme must be http or https", raw)
+	case parsed.Hostname() == "":
+		return "", fmt.Errorf("invalid base URL %q: missing host", raw)
+	case parsed.Port() != "" && !validPort(parsed.Port()):
+		return "", fmt.Errorf("invalid base URL %q: port must be between 1 and 65535", raw)
+	case parsed.RawQuery != "" || parsed.Fragment != "":
+		return "", fmt.Errorf("invalid base URL %q: must not have a query or fragment", raw)
+	}
+
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = ""
+	if parsed.Path == "" {
+		parsed.Path = "/v1"
+	}
+	return parsed.String(), nil
+}
+
+// validPort reports whether port is a number in the TCP port range
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// isLocalHost reports whether a scheme-less URL points at this machine
+func isLocalHost(hostAndPath string) bool {
+	parsed, err := url.Parse("http://" + hostAndPath)
+	if err != nil {
+		return false
+	}
+	switch parsed.Hostname() {
+	case "localhost", "127.0.0.1", "::1", "0.0.0.0":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr string // substring of the error; empty means valid
+	}{
+		{name: "empty", raw: "", want: ""},
+		{name: "blank", raw: "  \t", want: ""},
+		{name: "already normal", raw: "https://api.openai.com/v1", want: "https://api.openai.com/v1"},
+		{name: "trimmed", raw: "  https://api.example.com/v1  ", want: "https://api.example.com/v1"},
+		{name: "trailing slashes", raw: "https://api.example.com/v1//", want: "https://api.example.com/v1"},
+		{name: "no path gets /v1", raw: "https://api.example.com", want: "https://api.example.com/v1"},
+		{name: "root path gets /v1", raw: "https://api.example.com/", want: "https://api.example.com/v1"},
+		{name: "custom path kept", raw: "https://gateway.example.com/openai/v2/", want: "https://gateway.example.com/openai/v2"},
+		{name: "missing scheme", raw: "api.example.com/v1", want: "https://api.example.com/v1"},
+		{name: "localhost defaults to http", raw: "localhost:11434", want: "http://localhost:11434/v1"},
+		{name: "loopback defaults to http", raw: "127.0.0.1:8080/v1", want: "http://127.0.0.1:8080/v1"},
+		{name: "explicit http kept", raw: "http://llm.internal:8000", want: "http://llm.internal:8000/v1"},
+		{name: "bad scheme", raw: "ftp://example.com", wantErr: "scheme must be http or https"},
+		{name: "missing host", raw: "https:///v1", wantErr: "missing host"},
+		{name: "port out of range", raw: "https://example.com:99999", wantErr: "port must be between 1 and 65535"},
+		{name: "port zero", raw: "localhost:0", wantErr: "port must be between 1 and 65535"},
+		{name: "query", raw: "https://example.com/v1?key=abc", wantErr: "must not have a query or fragment"},
+		{name: "unparseable", raw: "https://exa mple.com", wantErr: "invalid base URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeBaseURL(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("NormalizeBaseURL(%q) = %q, %v; want an error mentioning %q", tt.raw, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizeBaseURL(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -25,6 +26,10 @@ type ServerConfig struct {
 // ToolsConfig represents tools configuration
 type ToolsConfig struct {
 	Scraper ScraperConfig `yaml:"scraper"`
+	// DefaultTimeout bounds every tool call; zero disables the limit
+	DefaultTimeout time.Duration `yaml:"defaultTimeout"`
+	// Timeouts overrides DefaultTimeout for individual tools, keyed by tool name
+	Timeouts map[string]time.Duration `yaml:"timeouts"`
 }
 
 // ScraperConfig represents web scraper configuration
@@ -34,6 +39,12 @@ type ScraperConfig struct {
 	MaxRetries  int           `yaml:"maxRetries"`
 	RateLimit   time.Duration `yaml:"rateLimit"`
 	MaxBodySize int64         `yaml:"maxBodySize"`
+	// AllowedSchemes restricts scraped URL schemes; empty means http and https
+	AllowedSchemes []string `yaml:"allowedSchemes"`
+	// BreakerThreshold is how many consecutive upstream failures open a host's
+	// circuit breaker for BreakerCooldown; zero disables the breaker
+	BreakerThreshold int           `yaml:"breakerThreshold"`
+	BreakerCooldown  time.Duration `yaml:"breakerCooldown"`
 }
 
 // Server represents the MCP server using the official SDK
@@ -42,6 +53,7 @@ type Server struct {
 	logger         zerolog.Logger
 	server         *mcp.Server
 	scraperService *scraper.Service
+	breaker        *scraper.CircuitBreaker
 }
 
 // NewServer creates a new MCP server instance using the official SDK
@@ -54,19 +66,24 @@ func NewServer(config *Config, logger zerolog.Logger) (*Server, error) {
 
 	// Initialize services
 	scraperConfig := scraper.Config{
-		UserAgent:   config.Tools.Scraper.UserAgent,
-		Timeout:     config.Tools.Scraper.Timeout,
-		MaxRetries:  config.Tools.Scraper.MaxRetries,
-		RateLimit:   config.Tools.Scraper.RateLimit,
-		MaxBodySize: config.Tools.Scraper.MaxBodySize,
+		UserAgent:      config.Tools.Scraper.UserAgent,
+		Timeout:        config.Tools.Scraper.Timeout,
+		MaxRetries:     config.Tools.Scraper.MaxRetries,
+		RateLimit:      config.Tools.Scraper.RateLimit,
+		MaxBodySize:    config.Tools.Scraper.MaxBodySize,
+		AllowedSchemes: config.Tools.Scraper.AllowedSchemes,
+	}
+	scraperService, err := scraper.NewService(scraperConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scraper: %w", err)
 	}
-	scraperService := scraper.NewService(scraperConfig, logger)
 
 	s := &Server{
 		config:         config,
 		logger:         logger,
 		server:         mcpServer,
 		scraperService: scraperService,
+		breaker:        scraper.NewCircuitBreaker(config.Tools.Scraper.BreakerThreshold, config.Tools.Scraper.BreakerCooldown),
 	}
 
 	// Register tools
@@ -82,8 +99,70 @@ func (s *Server) registerTools() {
 		Name:        "scrape_url",
 		Description: "Scrape content from a single URL",
 	}
-	mcp.AddTool(s.server, scrapeURLTool, s.handleScrapeURL)
+	addTool(s, scrapeURLTool, s.handleScrapeURL)
+}
+
+// addTool registers a typed handler wrapped in the tool's configured timeout
+func addTool[In, Out any](s *Server, tool *mcp.Tool, handler mcp.ToolHandlerFor[In, Out]) {
+	mcp.AddTool(s.server, tool, withTimeout(s, tool.Name, handler))
+}
+
+// toolTimeout returns the timeout for the named tool, falling back to the global default
+func (s *Server) toolTimeout(name string) time.Duration {
+	if timeout, ok := s.config.Tools.Timeouts[name]; ok {
+		return timeout
+	}
+	return s.config.Tools.DefaultTimeout
+}
 
+// withTimeout bounds a tool handler by its configured timeout. When the deadline
+// passes the handler's context is cancelled and a timeout IsError result is
+// returned, even if the handler itself has not yet returned.
+func withTimeout[In, Out any](s *Server, name string, handler mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, args In) (*mcp.CallToolResult, Out, error) {
+		timeout := s.toolTimeout(name)
+		if timeout <= 0 {
+			return handler(ctx, req, args)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		type outcome struct {
+			result *mcp.CallToolResult
+			out    Out
+			err    error
+		}
+		done := make(chan outcome, 1)
+		go func() {
+			result, out, err := handler(ctx, req, args)
+			done <- outcome{result: result, out: out, err: err}
+		}()
+
+		select {
+		case o := <-done:
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return o.result, o.out, o.err
+			}
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// The caller went away; there is nobody left to report to.
+				var zero Out
+				return nil, zero, ctx.Err()
+			}
+		}
+
+		s.logger.Warn().Str("tool", name).Dur("timeout", timeout).Msg("Tool call timed out")
+		var zero Out
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: fmt.Sprintf("Tool %s timed out after %s", name, timeout),
+				},
+			},
+			IsError: true,
+		}, zero, nil
+	}
 }
 
 // Tool handlers - these implement the actual tool functionality
@@ -96,59 +175,72 @@ type ScrapeURLParams struct {
 
 // ScrapeURLResult represents the result of scrape_url tool
 type ScrapeURLResult struct {
-	Content string `json:"content"`
-	URL     string `json:"url"`
+	Content    string `json:"content"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	ErrorCode  string `json:"error_code,omitempty"`
 }
 
 // handleScrapeURL handles the scrape_url tool
-func (s *Server) handleScrapeURL(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[ScrapeURLParams]) (*mcp.CallToolResultFor[ScrapeURLResult], error) {
-	url := params.Arguments.URL
-	selector := params.Arguments.Selector
+func (s *Server) handleScrapeURL(ctx context.Context, req *mcp.CallToolRequest, args ScrapeURLParams) (*mcp.CallToolResult, ScrapeURLResult, error) {
+	url := args.URL
+	selector := args.Selector
 
 	s.logger.Info().Str("url", url).Str("selector", selector).Msg("Scraping URL")
 
-	// Use the actual scraper service
-	result, err := s.scraperService.ScrapeURL(ctx, url, selector)
+	// Use the actual scraper service, failing fast while the host's breaker is open
+	result, err := s.scrape(ctx, url, selector)
 	if err != nil {
-		return &mcp.CallToolResultFor[ScrapeURLResult]{
+		code, status := scraper.ErrorCode(err)
+		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				&mcp.TextContent{
 					Text: fmt.Sprintf("Error scraping URL: %v", err),
 				},
 			},
 			IsError: true,
-		}, nil
+		}, ScrapeURLResult{URL: url, StatusCode: status, ErrorCode: code}, nil
 	}
 
-	return &mcp.CallToolResultFor[ScrapeURLResult]{
+	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{
 				Text: fmt.Sprintf("Successfully scraped %s\n\nTitle: %s\n\nContent:\n%s",
 					result.URL, result.Title, result.CleanText),
 			},
 		},
-		StructuredContent: ScrapeURLResult{
-			Content: result.CleanText,
-			URL:     result.URL,
-		},
+	}, ScrapeURLResult{
+		Content:    result.CleanText,
+		URL:        result.URL,
+		StatusCode: result.StatusCode,
 	}, nil
 }
 
+// scrape scrapes a URL unless its host's circuit breaker is open, recording the outcome
+func (s *Server) scrape(ctx context.Context, url, selector string) (*scraper.Result, error) {
+	if err := s.breaker.Allow(url); err != nil {
+		return nil, err
+	}
+	result, err := s.scraperService.ScrapeURL(ctx, url, selector)
+	s.breaker.Record(url, err)
+	return result, err
+}
+
 // Start starts the MCP server using stdio transport (standard for MCP)
 func (s *Server) Start(ctx context.Context) error {
 	s.logger.Info().Str("name", s.config.Server.Name).Str("version", s.config.Server.Version).Msg("Starting MCP server")
 
 	// Create stdio transport (standard for MCP servers)
-	transport := mcp.NewStdioTransport()
+	transport := &mcp.StdioTransport{}
 
 	// Connect the server to the transport
-	conn, err := s.server.Connect(ctx, transport)
+	session, err := s.server.Connect(ctx, transport, nil)
 	if err != nil {
 		return fmt.Errorf("failed to connect server: %w", err)
 	}
 
-	// Wait for the connection to close
-	return conn.Wait()
+	// Wait for the session to close
+	return session.Wait()
 }
 
 // Helper function for min
@@ -0,0 +1,26 @@
+// Package pagetype names the kinds of web page the scraper classifies and the
+// summarizer tailors its summaries to.
+package pagetype
+
+// Page types, as assigned to scraper.Result.PageType and accepted in
+// summarizer.Request.PageType
+const (
+	Article = "article"
+	Product = "product"
+	Docs    = "docs"
+	Forum   = "forum"
+	Other   = "other"
+)
+
+// All lists every page type, with Other last
+var All = []string{Article, Product, Docs, Forum, Other}
+
+// Valid reports whether name is one of All
+func Valid(name string) bool {
+	for _, pageType := range All {
+		if name == pageType {
+			return true
+		}
+	}
+	return false
+}
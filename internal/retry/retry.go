@@ -0,0 +1,236 @@
+// Package retry runs operations again after transient failures, with
+// exponential backoff, jitter, and support for server-requested delays.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy describes how an operation is retried
+type Policy struct {
+	// MaxAttempts is the total number of attempts; zero or one means a single attempt
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubling for each later one; zero means 500ms
+	BaseDelay time.Duration
+	// MaxDelay caps every delay, including server-requested ones; zero means 30s
+	MaxDelay time.Duration
+	// Jitter shortens each delay by a random fraction of up to Jitter (0-1) so
+	// that clients failing together do not retry in lockstep
+	Jitter float64
+	// Retryable reports whether an error is worth retrying; nil retries every error
+	Retryable func(error) bool
+}
+
+// Defaults used for zero-valued Policy fields
+const (
+	DefaultBaseDelay = 500 * time.Millisecond
+	DefaultMaxDelay  = 30 * time.Second
+)
+
+// Validate checks the policy for values that cannot work, reporting every problem found
+func (p Policy) Validate() error {
+	var problems []error
+	if p.MaxAttempts < 0 {
+		problems = append(problems, fmt.Errorf("MaxAttempts must not be negative (got %d)", p.MaxAttempts))
+	}
+	if p.BaseDelay < 0 {
+		problems = append(problems, fmt.Errorf("BaseDelay must not be negative (got %s)", p.BaseDelay))
+	}
+	if p.MaxDelay < 0 {
+		problems = append(problems, fmt.Errorf("MaxDelay must not be negative (got %s)", p.MaxDelay))
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		problems = append(problems, fmt.Errorf("Jitter must be between 0 and 1 (got %g)", p.Jitter))
+	}
+	return errors.Join(problems...)
+}
+
+// Delay returns the backoff before the given retry (1 for the first retry)
+func (p Policy) Delay(retry int) time.Duration {
+	base, maxDelay := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = DefaultBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxDelay
+	}
+
+	delay := base
+	for i := 1; i < retry && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	if p.Jitter > 0 {
+		delay -= time.Duration(float64(delay) * min(p.Jitter, 1) * rand.Float64())
+	}
+	return delay
+}
+
+// Do calls fn until it succeeds, returns an error the policy does not retry, or
+// the attempts run out, waiting between attempts per the policy. An error
+// wrapped with After sets the next delay instead. Do returns fn's last error,
+// or ctx's error when ctx ends while waiting.
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return err
+		}
+
+		delay := policy.Delay(attempt)
+		if requested, ok := RequestedDelay(err); ok {
+			delay = requested
+			if policy.MaxDelay > 0 {
+				delay = min(delay, policy.MaxDelay)
+			}
+		}
+		if sleepErr := Sleep(ctx, delay); sleepErr != nil {
+			return sleepErr
+		}
+	}
+}
+
+// afterError carries a server-requested delay before the next attempt
+type afterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *afterError) Error() string { return e.err.Error() }
+
+func (e *afterError) Unwrap() error { return e.err }
+
+// After wraps err so that Do waits d before the next attempt, e.g. to honor a
+// Retry-After header. A nil err stays nil.
+func After(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &afterError{err: err, delay: max(d, 0)}
+}
+
+// RequestedDelay returns the delay err carries from After, if any
+func RequestedDelay(err error) (time.Duration, bool) {
+	var after *afterError
+	if errors.As(err, &after) {
+		return after.delay, true
+	}
+	return 0, false
+}
+
+// Hint records the delay a server requested in a Retry-After header while
+// handling one attempt's HTTP requests
+type Hint struct {
+	mu    sync.Mutex
+	delay time.Duration
+	ok    bool
+}
+
+type hintKey struct{}
+
+// WithHint returns a context whose HTTP requests, when sent through Transport,
+// record a Retry-After delay in the returned Hint
+func WithHint(ctx context.Context) (context.Context, *Hint) {
+	hint := &Hint{}
+	return context.WithValue(ctx, hintKey{}, hint), hint
+}
+
+// Wrap returns err wrapped with After when the server requested a delay, and
+// err unchanged otherwise
+func (h *Hint) Wrap(err error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil || !h.ok {
+		return err
+	}
+	return After(err, h.delay)
+}
+
+// Transport wraps base, or http.DefaultTransport when base is nil, so that the
+// Retry-After header of a retryable error response is recorded in the Hint of
+// the request's context. Requests without a Hint pass through untouched.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &hintTransport{base: base}
+}
+
+type hintTransport struct {
+	base http.RoundTripper
+}
+
+func (t *hintTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	hint, _ := req.Context().Value(hintKey{}).(*Hint)
+	if err != nil || hint == nil || !IsRetryableStatus(resp.StatusCode) {
+		return resp, err
+	}
+	if delay, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		hint.mu.Lock()
+		hint.delay, hint.ok = delay, true
+		hint.mu.Unlock()
+	}
+	return resp, err
+}
+
+// Sleep waits for d or until ctx ends, returning ctx's error in the latter case
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ParseRetryAfter parses a Retry-After header value, given either in seconds or
+// as an HTTP date, into the delay from now
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// IsRetryableStatus reports whether an HTTP status is usually transient: 408, 429, or 5xx
+// other than 501 Not Implemented
+func IsRetryableStatus(code int) bool {
+	switch {
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	case code == http.StatusNotImplemented:
+		return false
+	default:
+		return code >= 500 && code < 600
+	}
+}
@@ -0,0 +1,260 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		retry  int
+		want   time.Duration
+	}{
+		{name: "default base", policy: Policy{}, retry: 1, want: DefaultBaseDelay},
+		{name: "doubles", policy: Policy{BaseDelay: 100 * time.Millisecond}, retry: 3, want: 400 * time.Millisecond},
+		{name: "capped", policy: Policy{BaseDelay: time.Second, MaxDelay: 3 * time.Second}, retry: 5, want: 3 * time.Second},
+		{name: "default cap", policy: Policy{BaseDelay: time.Second}, retry: 50, want: DefaultMaxDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.retry); got != tt.want {
+				t.Errorf("Delay(%d) = %s, want %s", tt.retry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyDelayJitter(t *testing.T) {
+	policy := Policy{BaseDelay: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := policy.Delay(1); got < 500*time.Millisecond || got > time.Second {
+			t.Fatalf("Delay(1) = %s, want between 500ms and 1s", got)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	if err := (Policy{MaxAttempts: 3, Jitter: 1}).Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	err := Policy{MaxAttempts: -1, BaseDelay: -1, MaxDelay: -1, Jitter: 2}.Validate()
+	for _, want := range []string{"MaxAttempts", "BaseDelay", "MaxDelay", "Jitter"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want a problem with %s", err, want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	tests := []struct {
+		name      string
+		policy    Policy
+		failures  []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", policy: Policy{MaxAttempts: 3}, wantCalls: 1},
+		{name: "single attempt by default", failures: []error{errTransient}, wantCalls: 1, wantErr: errTransient},
+		{name: "retried until success", policy: Policy{MaxAttempts: 3}, failures: []error{errTransient, errTransient}, wantCalls: 3},
+		{name: "attempts run out", policy: Policy{MaxAttempts: 2}, failures: []error{errTransient, errTransient}, wantCalls: 2, wantErr: errTransient},
+		{
+			name:      "not retryable",
+			policy:    Policy{MaxAttempts: 3, Retryable: func(err error) bool { return err == errTransient }},
+			failures:  []error{errTransient, errPermanent, errTransient},
+			wantCalls: 2,
+			wantErr:   errPermanent,
+		},
+		{
+			name:      "classification sees through After",
+			policy:    Policy{MaxAttempts: 3, Retryable: func(err error) bool { return errors.Is(err, errTransient) }},
+			failures:  []error{After(errTransient, 0)},
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.BaseDelay = time.Millisecond
+			calls := 0
+			err := Do(context.Background(), tt.policy, func(context.Context) error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Do() = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoRequestedDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		after   time.Duration
+		atLeast time.Duration
+		below   time.Duration
+	}{
+		{name: "overrides the backoff", policy: Policy{MaxAttempts: 2, BaseDelay: time.Hour}, after: 50 * time.Millisecond, atLeast: 50 * time.Millisecond, below: time.Second},
+		{name: "capped by MaxDelay", policy: Policy{MaxAttempts: 2, MaxDelay: 50 * time.Millisecond}, after: time.Hour, atLeast: 50 * time.Millisecond, below: time.Second},
+		{name: "zero retries at once", policy: Policy{MaxAttempts: 2, BaseDelay: time.Hour}, after: 0, below: 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			start := time.Now()
+			err := Do(context.Background(), tt.policy, func(context.Context) error {
+				calls++
+				if calls == 1 {
+					return After(errors.New("rate limited"), tt.after)
+				}
+				return nil
+			})
+			elapsed := time.Since(start)
+			if err != nil || calls != 2 {
+				t.Fatalf("Do() = %v after %d calls, want success on the second", err, calls)
+			}
+			if elapsed < tt.atLeast || elapsed >= tt.below {
+				t.Errorf("waited %s, want at least %s and under %s", elapsed, tt.atLeast, tt.below)
+			}
+		})
+	}
+}
+
+func TestDoStopsWhenContextEnds(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := Do(ctx, Policy{MaxAttempts: 5, BaseDelay: time.Hour}, func(context.Context) error {
+		calls++
+		return errors.New("transient")
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want the deadline after 1", err, calls)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	if After(nil, time.Second) != nil {
+		t.Error("After(nil) is not nil")
+	}
+	base := errors.New("rate limited")
+	err := After(base, -time.Second)
+	if !errors.Is(err, base) || err.Error() != base.Error() {
+		t.Errorf("After() = %v, want it to wrap %v", err, base)
+	}
+	if delay, ok := RequestedDelay(err); !ok || delay != 0 {
+		t.Errorf("RequestedDelay() = %s, %v, want a negative delay raised to 0", delay, ok)
+	}
+	if _, ok := RequestedDelay(base); ok {
+		t.Error("RequestedDelay() found a delay in a plain error")
+	}
+}
+
+func TestTransportRecordsHint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		switch r.URL.Path {
+		case "/limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	client := &http.Client{Transport: Transport(nil)}
+	failure := errors.New("request failed")
+
+	tests := []struct {
+		path      string
+		wantDelay time.Duration
+		wantOK    bool
+	}{
+		{path: "/limited", wantDelay: 7 * time.Second, wantOK: true},
+		{path: "/missing"},
+		{path: "/ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ctx, hint := WithHint(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+tt.path, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			resp.Body.Close()
+
+			delay, ok := RequestedDelay(hint.Wrap(failure))
+			if ok != tt.wantOK || delay != tt.wantDelay {
+				t.Errorf("requested delay = %s, %v, want %s, %v", delay, ok, tt.wantDelay, tt.wantOK)
+			}
+			if hint.Wrap(nil) != nil {
+				t.Error("Wrap(nil) is not nil")
+			}
+		})
+	}
+
+	t.Run("no hint", func(t *testing.T) {
+		resp, err := client.Get(server.URL + "/limited")
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "120", want: 2 * time.Minute, wantOK: true},
+		{value: " 0 ", want: 0, wantOK: true},
+		{value: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second, wantOK: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOK: true},
+		{value: ""},
+		{value: "-5"},
+		{value: "soon"},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	tests := map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusBadRequest:          false,
+		http.StatusNotFound:            false,
+		http.StatusRequestTimeout:      true,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusNotImplemented:      false,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+	}
+	for code, want := range tests {
+		if got := IsRetryableStatus(code); got != want {
+			t.Errorf("IsRetryableStatus(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
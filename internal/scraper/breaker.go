@@ -0,0 +1,126 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrHostUnavailable is wrapped by errors for hosts whose circuit breaker is open
+var ErrHostUnavailable = errors.New("host temporarily unavailable")
+
+// CircuitBreaker stops scraping a host after Threshold consecutive upstream
+// failures. While open, Allow fails fast with ErrHostUnavailable; once Cooldown
+// has passed a single probe request is let through (half-open), and its outcome
+// either closes the breaker or re-opens it for another cooldown.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostCircuit
+}
+
+// hostCircuit is the breaker state of one host
+type hostCircuit struct {
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker creates a per-host circuit breaker; a threshold of zero or less disables it
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*hostCircuit),
+	}
+}
+
+// Allow reports whether a request to the URL's host may proceed
+func (b *CircuitBreaker) Allow(rawURL string) error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	host := breakerHost(rawURL)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	circuit, ok := b.hosts[host]
+	if !ok || circuit.failures < b.threshold {
+		return nil
+	}
+	if retryIn := b.cooldown - time.Since(circuit.openedAt); retryIn > 0 || circuit.probing {
+		if retryIn < 0 {
+			retryIn = 0
+		}
+		return fmt.Errorf("%w: %s failed %d times in a row, retry in %s", ErrHostUnavailable, host, circuit.failures, retryIn.Round(time.Second))
+	}
+	circuit.probing = true
+	return nil
+}
+
+// Record updates the URL's host with the outcome of a request that Allow let through.
+// Only upstream failures (timeouts, network errors, 429 and 5xx statuses) count
+// against the host; invalid URLs, cancellations, and other statuses do not.
+func (b *CircuitBreaker) Record(rawURL string, err error) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	host := breakerHost(rawURL)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	circuit, ok := b.hosts[host]
+	if !isUpstreamFailure(err) {
+		if !ok {
+			return
+		}
+		// Any answer from the host closes the breaker; an aborted probe just frees the slot
+		if code, _ := ErrorCode(err); err == nil || code == ErrorCodeHTTPStatus {
+			delete(b.hosts, host)
+		} else {
+			circuit.probing = false
+		}
+		return
+	}
+	if !ok {
+		circuit = &hostCircuit{}
+		b.hosts[host] = circuit
+	}
+	circuit.failures++
+	circuit.probing = false
+	if circuit.failures >= b.threshold {
+		circuit.openedAt = time.Now()
+	}
+}
+
+// isUpstreamFailure reports whether err suggests the host itself is failing
+func isUpstreamFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	code, status := ErrorCode(err)
+	switch code {
+	case ErrorCodeTimeout, ErrorCodeNetwork:
+		return true
+	case ErrorCodeHTTPStatus:
+		return status == 429 || status >= 500
+	default:
+		return false
+	}
+}
+
+// breakerHost returns the lower-cased host of a URL, or the URL itself when it cannot be parsed
+func breakerHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(parsed.Host)
+}
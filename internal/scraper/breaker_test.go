@@ -0,0 +1,87 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	const url = "https://flaky.example/page"
+	serverError := &StatusError{StatusCode: 503, Err: errors.New("service unavailable")}
+	breaker := NewCircuitBreaker(3, 50*time.Millisecond)
+
+	// Failures below the threshold leave the breaker closed
+	for i := 0; i < 2; i++ {
+		if err := breaker.Allow(url); err != nil {
+			t.Fatalf("Allow after %d failures: %v", i, err)
+		}
+		breaker.Record(url, serverError)
+	}
+
+	// The third consecutive failure opens it for that host only
+	if err := breaker.Allow(url); err != nil {
+		t.Fatalf("Allow before tripping: %v", err)
+	}
+	breaker.Record(url, serverError)
+	if err := breaker.Allow(url); !errors.Is(err, ErrHostUnavailable) {
+		t.Fatalf("Allow while open = %v, want ErrHostUnavailable", err)
+	}
+	if code, _ := ErrorCode(breaker.Allow("https://FLAKY.example/other")); code != ErrorCodeHostUnavailable {
+		t.Errorf("another page of the host got code %q, want %q", code, ErrorCodeHostUnavailable)
+	}
+	if err := breaker.Allow("https://healthy.example/"); err != nil {
+		t.Errorf("Allow for another host: %v", err)
+	}
+
+	// After the cooldown one probe goes through; a failed probe re-opens the breaker
+	time.Sleep(60 * time.Millisecond)
+	if err := breaker.Allow(url); err != nil {
+		t.Fatalf("probe after cooldown: %v", err)
+	}
+	if err := breaker.Allow(url); !errors.Is(err, ErrHostUnavailable) {
+		t.Errorf("second request while probing = %v, want ErrHostUnavailable", err)
+	}
+	breaker.Record(url, fmt.Errorf("scrape: %w", context.DeadlineExceeded))
+	if err := breaker.Allow(url); !errors.Is(err, ErrHostUnavailable) {
+		t.Fatalf("Allow after a failed probe = %v, want ErrHostUnavailable", err)
+	}
+
+	// A successful probe closes it again
+	time.Sleep(60 * time.Millisecond)
+	if err := breaker.Allow(url); err != nil {
+		t.Fatalf("probe after second cooldown: %v", err)
+	}
+	breaker.Record(url, nil)
+	for i := 0; i < 3; i++ {
+		if err := breaker.Allow(url); err != nil {
+			t.Fatalf("Allow after recovery: %v", err)
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	const url = "https://example.com/missing"
+	breaker := NewCircuitBreaker(2, time.Minute)
+	for i := 0; i < 5; i++ {
+		breaker.Record(url, &StatusError{StatusCode: 404, Err: errors.New("not found")})
+		breaker.Record(url, context.Canceled)
+	}
+	if err := breaker.Allow(url); err != nil {
+		t.Errorf("Allow after 404s and cancellations = %v, want the breaker closed", err)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	const url = "https://down.example/"
+	for _, breaker := range []*CircuitBreaker{nil, NewCircuitBreaker(0, time.Minute)} {
+		for i := 0; i < 10; i++ {
+			breaker.Record(url, &StatusError{StatusCode: 500, Err: errors.New("boom")})
+		}
+		if err := breaker.Allow(url); err != nil {
+			t.Errorf("disabled breaker Allow = %v, want nil", err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/retry"
+)
+
+// ResumableCrawlOptions configures a ResumableCrawl
+type ResumableCrawlOptions struct {
+	// MaxPages and MaxDepth bound the whole crawl, across every resumed run
+	CrawlOptions
+	// CheckpointPath is the file holding the crawl's frontier and visited set. An
+	// existing checkpoint for the same start URL is resumed; otherwise a new crawl starts.
+	CheckpointPath string
+	// CheckpointEvery saves the checkpoint after this many pages; zero means after every page
+	CheckpointEvery int
+	// Delay is the pause between page requests; zero means Config.RateLimit
+	Delay time.Duration
+	// OnPage receives every page as it is scraped, since a large crawl cannot keep
+	// its pages in memory. An error stops the crawl, and the page is fetched again
+	// on resume.
+	OnPage func(*Result) error
+}
+
+// CrawlProgress reports where a ResumableCrawl stopped
+type CrawlProgress struct {
+	// Fetched and Failed count pages across every run of the crawl
+	Fetched int `json:"fetched"`
+	Failed  int `json:"failed"`
+	// Pending is how many discovered URLs are left to fetch
+	Pending int `json:"pending"`
+	// BudgetExceeded reports that Config.MaxCrawlDuration expired during this run
+	BudgetExceeded bool `json:"budget_exceeded"`
+}
+
+// crawlCheckpoint is the on-disk state of a resumable crawl
+type crawlCheckpoint struct {
+	StartURL  string         `json:"start_url"`
+	Frontier  []frontierItem `json:"frontier"`
+	Visited   []string       `json:"visited"` // every URL fetched or queued
+	Fetched   int            `json:"fetched"`
+	Failed    []string       `json:"failed,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// frontierItem is a URL waiting to be fetched and its link distance from the start URL
+type frontierItem struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// ResumableCrawl crawls startURL's same host breadth first like Crawl, but saves
+// its progress to opts.CheckpointPath so that an interrupted crawl resumes where
+// it left off instead of fetching everything again. Pages are passed to opts.OnPage
+// rather than collected. When ctx is cancelled the checkpoint is saved and ctx's
+// error returned; calling ResumableCrawl again with the same checkpoint continues
+// the crawl. A finished crawl leaves an empty frontier, so resuming it does nothing.
+func (s *Service) ResumableCrawl(ctx context.Context, startURL string, opts ResumableCrawlOptions) (*CrawlProgress, error) {
+	if opts.CheckpointPath == "" {
+		return nil, fmt.Errorf("a checkpoint path is required")
+	}
+	checkpoint, err := loadCrawlCheckpoint(opts.CheckpointPath, startURL)
+	if err != nil {
+		return nil, err
+	}
+	seen := &memoryVisitedSet{urls: make(map[string]struct{}, len(checkpoint.Visited))}
+	for _, url := range checkpoint.Visited {
+		seen.urls[url] = struct{}{}
+	}
+	if checkpoint.Fetched > 0 || len(checkpoint.Failed) > 0 {
+		s.logger.Info().
+			Str("url", startURL).
+			Int("fetched", checkpoint.Fetched).
+			Int("pending", len(checkpoint.Frontier)).
+			Msg("Resuming crawl from checkpoint")
+	}
+
+	crawlCtx := ctx
+	if s.config.MaxCrawlDuration > 0 {
+		var cancel context.CancelFunc
+		crawlCtx, cancel = context.WithTimeout(ctx, s.config.MaxCrawlDuration)
+		defer cancel()
+	}
+	delay := opts.Delay
+	if delay <= 0 {
+		delay = s.config.RateLimit
+	}
+	every := max(opts.CheckpointEvery, 1)
+
+	unsaved := 0
+	requested := false
+	for len(checkpoint.Frontier) > 0 {
+		if opts.MaxPages > 0 && checkpoint.Fetched >= opts.MaxPages {
+			break
+		}
+		// Be polite between requests, even across pages that failed
+		if requested && retry.Sleep(crawlCtx, delay) != nil {
+			break
+		}
+		if crawlCtx.Err() != nil {
+			break
+		}
+		next := checkpoint.Frontier[0]
+		requested = true
+
+		result, links, err := s.ScrapeWithFrontier(crawlCtx, next.URL, seen)
+		if err != nil {
+			if crawlCtx.Err() != nil {
+				break
+			}
+			s.logger.Warn().Err(err).Str("url", next.URL).Msg("Skipping page that failed to crawl")
+			checkpoint.Frontier = checkpoint.Frontier[1:]
+			checkpoint.Failed = append(checkpoint.Failed, next.URL)
+			continue
+		}
+		// The links are now marked visited, so queue them before anything can stop the crawl
+		if opts.MaxDepth <= 0 || next.Depth < opts.MaxDepth {
+			for _, link := range links {
+				checkpoint.Frontier = append(checkpoint.Frontier, frontierItem{URL: link, Depth: next.Depth + 1})
+			}
+		}
+		if opts.OnPage != nil {
+			if err := opts.OnPage(result); err != nil {
+				// Keep the page at the head of the frontier so a resumed crawl fetches it again
+				if saveErr := checkpoint.save(opts.CheckpointPath, seen); saveErr != nil {
+					s.logger.Warn().Err(saveErr).Msg("Failed to save crawl checkpoint")
+				}
+				return checkpoint.progress(false), fmt.Errorf("crawl stopped at %s: %w", next.URL, err)
+			}
+		}
+		checkpoint.Frontier = checkpoint.Frontier[1:]
+		checkpoint.Fetched++
+
+		if unsaved++; unsaved >= every {
+			if err := checkpoint.save(opts.CheckpointPath, seen); err != nil {
+				return checkpoint.progress(false), err
+			}
+			unsaved = 0
+		}
+	}
+
+	if err := checkpoint.save(opts.CheckpointPath, seen); err != nil {
+		return checkpoint.progress(false), err
+	}
+	if ctx.Err() != nil {
+		return checkpoint.progress(false), ctx.Err()
+	}
+	budgetExceeded := errors.Is(crawlCtx.Err(), context.DeadlineExceeded)
+	if budgetExceeded {
+		s.logger.Info().Dur("budget", s.config.MaxCrawlDuration).Int("fetched", checkpoint.Fetched).Msg("Crawl budget exhausted; resume to continue")
+	}
+
+	s.logger.Info().
+		Str("url", startURL).
+		Int("fetched", checkpoint.Fetched).
+		Int("pending", len(checkpoint.Frontier)).
+		Msg("Crawl run completed")
+	return checkpoint.progress(budgetExceeded), nil
+}
+
+// loadCrawlCheckpoint reads the checkpoint at path, or starts a new crawl of startURL
+// when there is none. A checkpoint of a different crawl is an error.
+func loadCrawlCheckpoint(path, startURL string) (*crawlCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		start := normalizeURL(startURL)
+		return &crawlCheckpoint{
+			StartURL: startURL,
+			Frontier: []frontierItem{{URL: start}},
+			Visited:  []string{start},
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crawl checkpoint: %w", err)
+	}
+
+	var checkpoint crawlCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse crawl checkpoint %s: %w", path, err)
+	}
+	if normalizeURL(checkpoint.StartURL) != normalizeURL(startURL) {
+		return nil, fmt.Errorf("crawl checkpoint %s belongs to a crawl of %s, not %s", path, checkpoint.StartURL, startURL)
+	}
+	return &checkpoint, nil
+}
+
+// save writes the checkpoint to a temporary file and renames it over path, so an
+// interruption mid-write never leaves a corrupt checkpoint behind
+func (c *crawlCheckpoint) save(path string, seen *memoryVisitedSet) error {
+	seen.mu.Lock()
+	c.Visited = make([]string, 0, len(seen.urls))
+	for url := range seen.urls {
+		c.Visited = append(c.Visited, url)
+	}
+	seen.mu.Unlock()
+	sort.Strings(c.Visited)
+	c.UpdatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode crawl checkpoint: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save crawl checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save crawl checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save crawl checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save crawl checkpoint: %w", err)
+	}
+	return nil
+}
+
+// progress summarizes the checkpoint for the caller
+func (c *crawlCheckpoint) progress(budgetExceeded bool) *CrawlProgress {
+	return &CrawlProgress{
+		Fetched:        c.Fetched,
+		Failed:         len(c.Failed),
+		Pending:        len(c.Frontier),
+		BudgetExceeded: budgetExceeded,
+	}
+}
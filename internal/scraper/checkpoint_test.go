@@ -0,0 +1,187 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// crawlSite serves a small site of linked pages, counting the requests for each path
+func crawlSite(t *testing.T) (*httptest.Server, func() map[string]int) {
+	t.Helper()
+	pages := map[string]string{
+		"/":         `<a href="/a">A</a> <a href="/b">B</a> <a href="/c">C</a>`,
+		"/a":        `<a href="/a/deeper">Deeper</a> <a href="/">Home</a>`,
+		"/b":        `<a href="/b/deeper">Deeper</a>`,
+		"/c":        `<a href="https://elsewhere.example/">Off site</a>`,
+		"/a/deeper": `<a href="/a">Back</a>`,
+		"/b/deeper": `No links here.`,
+	}
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		counts[r.URL.Path]++
+		mu.Unlock()
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head><title>" + r.URL.Path + "</title></head><body><p>" + body + "</p></body></html>"))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		copied := make(map[string]int, len(counts))
+		for path, count := range counts {
+			copied[path] = count
+		}
+		return copied
+	}
+}
+
+// titles returns the sorted titles of results, which crawlSite sets to their paths
+func titles(results []*Result) []string {
+	var paths []string
+	for _, result := range results {
+		paths = append(paths, result.Title)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestResumableCrawlInterruptAndResume(t *testing.T) {
+	server, counts := crawlSite(t)
+	service := newTestService(t, Config{})
+	checkpoint := filepath.Join(t.TempDir(), "crawl.json")
+
+	// The first run is interrupted after two pages
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var first []*Result
+	progress, err := service.ResumableCrawl(ctx, server.URL, ResumableCrawlOptions{
+		CheckpointPath: checkpoint,
+		OnPage: func(result *Result) error {
+			if first = append(first, result); len(first) == 2 {
+				cancel()
+			}
+			return nil
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted ResumableCrawl error = %v, want context.Canceled", err)
+	}
+	if progress.Fetched != 2 || progress.Pending == 0 {
+		t.Errorf("progress after the interruption = %+v, want 2 fetched and more pending", progress)
+	}
+
+	// The second run picks up where the first stopped
+	var second []*Result
+	progress, err = service.ResumableCrawl(context.Background(), server.URL, ResumableCrawlOptions{
+		CheckpointPath: checkpoint,
+		OnPage: func(result *Result) error {
+			second = append(second, result)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("resumed ResumableCrawl: %v", err)
+	}
+	if progress.Fetched != 6 || progress.Pending != 0 || progress.Failed != 0 {
+		t.Errorf("progress after resuming = %+v, want all 6 pages fetched", progress)
+	}
+	want := []string{"/", "/a", "/a/deeper", "/b", "/b/deeper", "/c"}
+	if got := titles(append(first, second...)); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("crawled %q across both runs, want %q", got, want)
+	}
+	for path, count := range counts() {
+		if count != 1 {
+			t.Errorf("%s was requested %d times, want once across both runs", path, count)
+		}
+	}
+
+	// A finished crawl has nothing left to do
+	progress, err = service.ResumableCrawl(context.Background(), server.URL, ResumableCrawlOptions{CheckpointPath: checkpoint})
+	if err != nil || progress.Fetched != 6 || progress.Pending != 0 {
+		t.Errorf("resuming a finished crawl = %+v, %v", progress, err)
+	}
+	if got := counts()["/"]; got != 1 {
+		t.Errorf("resuming a finished crawl requested / again (%d requests)", got)
+	}
+}
+
+func TestResumableCrawlRefetchesRejectedPage(t *testing.T) {
+	server, counts := crawlSite(t)
+	service := newTestService(t, Config{})
+	checkpoint := filepath.Join(t.TempDir(), "crawl.json")
+
+	errFull := errors.New("disk full")
+	_, err := service.ResumableCrawl(context.Background(), server.URL, ResumableCrawlOptions{
+		CheckpointPath: checkpoint,
+		OnPage: func(result *Result) error {
+			if result.Title == "/a" {
+				return errFull
+			}
+			return nil
+		},
+	})
+	if !errors.Is(err, errFull) {
+		t.Fatalf("ResumableCrawl error = %v, want the OnPage error", err)
+	}
+
+	var resumed []*Result
+	if _, err := service.ResumableCrawl(context.Background(), server.URL, ResumableCrawlOptions{
+		CheckpointPath: checkpoint,
+		OnPage: func(result *Result) error {
+			resumed = append(resumed, result)
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("resumed ResumableCrawl: %v", err)
+	}
+	if len(resumed) == 0 || resumed[0].Title != "/a" {
+		t.Errorf("resumed crawl started with %q, want the rejected /a again", titles(resumed))
+	}
+	if got := counts(); got["/a"] != 2 || got["/"] != 1 {
+		t.Errorf("requests = %v, want /a fetched twice and / once", got)
+	}
+}
+
+func TestResumableCrawlBudgetSpansRuns(t *testing.T) {
+	server, counts := crawlSite(t)
+	service := newTestService(t, Config{})
+	checkpoint := filepath.Join(t.TempDir(), "crawl.json")
+	opts := ResumableCrawlOptions{CrawlOptions: CrawlOptions{MaxPages: 3}, CheckpointPath: checkpoint}
+
+	progress, err := service.ResumableCrawl(context.Background(), server.URL, opts)
+	if err != nil || progress.Fetched != 3 {
+		t.Fatalf("ResumableCrawl = %+v, %v, want 3 pages", progress, err)
+	}
+	progress, err = service.ResumableCrawl(context.Background(), server.URL, opts)
+	if err != nil || progress.Fetched != 3 {
+		t.Errorf("resumed ResumableCrawl = %+v, %v, want the budget already spent", progress, err)
+	}
+	total := 0
+	for _, count := range counts() {
+		total += count
+	}
+	if total != 3 {
+		t.Errorf("made %d requests, want 3 across both runs", total)
+	}
+
+	if _, err := service.ResumableCrawl(context.Background(), server.URL+"/b", opts); err == nil || !strings.Contains(err.Error(), "belongs to a crawl of") {
+		t.Errorf("resuming another crawl's checkpoint = %v, want an error", err)
+	}
+	if _, err := service.ResumableCrawl(context.Background(), server.URL, ResumableCrawlOptions{}); err == nil {
+		t.Error("ResumableCrawl ran without a checkpoint path")
+	}
+}
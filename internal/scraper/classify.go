@@ -0,0 +1,119 @@
+package scraper
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/HeidiZHH/skull/internal/pagetype"
+	"github.com/gocolly/colly/v2"
+)
+
+// Page types assigned to Result.PageType by Config.ClassifyPages; a
+// Config.PageClassifier may return any of pagetype.All
+const (
+	PageTypeArticle = pagetype.Article
+	PageTypeProduct = pagetype.Product
+	PageTypeDocs    = pagetype.Docs
+	PageTypeForum   = pagetype.Forum
+	PageTypeOther   = pagetype.Other
+)
+
+// minPageTypeScore is the score a page type needs before the heuristics commit to it
+const minPageTypeScore = 3
+
+// jsonLDPageTypes maps schema.org types to page types
+var jsonLDPageTypes = map[string]string{
+	"Article":                PageTypeArticle,
+	"NewsArticle":            PageTypeArticle,
+	"BlogPosting":            PageTypeArticle,
+	"Report":                 PageTypeArticle,
+	"ScholarlyArticle":       PageTypeArticle,
+	"Product":                PageTypeProduct,
+	"ProductGroup":           PageTypeProduct,
+	"Offer":                  PageTypeProduct,
+	"AggregateOffer":         PageTypeProduct,
+	"TechArticle":            PageTypeDocs,
+	"APIReference":           PageTypeDocs,
+	"HowTo":                  PageTypeDocs,
+	"DiscussionForumPosting": PageTypeForum,
+	"SocialMediaPosting":     PageTypeForum,
+	"QAPage":                 PageTypeForum,
+}
+
+// pageTypeURLPatterns match URL paths typical of each page type
+var pageTypeURLPatterns = map[string]*regexp.Regexp{
+	PageTypeArticle: regexp.MustCompile(`(?i)/(blog|news|articles?|posts?|stories)/|/(19|20)\d{2}/\d{1,2}/`),
+	PageTypeProduct: regexp.MustCompile(`(?i)/(products?|shop|store|item|dp|p)/`),
+	PageTypeDocs:    regexp.MustCompile(`(?i)/(docs?|documentation|reference|api|manual|guides?|tutorials?)(/|$)`),
+	PageTypeForum:   regexp.MustCompile(`(?i)/(forums?|threads?|t|topics?|questions|comments|discussions?)/`),
+}
+
+// pageTypeSelectors match elements typical of each page type, each worth a point
+var pageTypeSelectors = map[string][]string{
+	PageTypeArticle: {"article", `[itemprop="articleBody"]`, `[rel="author"], .byline, .author`, "time[datetime]"},
+	PageTypeProduct: {`[itemprop="price"], .price, [data-price]`, `[itemprop="sku"], [data-sku]`, `[name="add-to-cart"], .add-to-cart, #add-to-cart, form[action*="cart"]`, `[itemprop="aggregateRating"], .rating, .reviews`},
+	PageTypeDocs:    {"pre code", "nav.sidebar, .sidebar nav, .toc, #toc, .table-of-contents", ".highlight, .code-block, .language-go, .language-python", `[class*="breadcrumb"]`},
+	PageTypeForum:   {".comment, .reply, .post-reply", `[itemprop="comment"], [itemprop="answer"]`, ".upvote, .vote, .score, .votes", ".username, .user-link, .hnuser"},
+}
+
+// classifyPage labels the page by scoring each page type on its structured data,
+// URL, and markup, returning PageTypeOther when no type scores high enough
+func classifyPage(e *colly.HTMLElement, result *Result) string {
+	scores := make(map[string]int)
+
+	// Structured data is the strongest signal
+	for _, schemaType := range findJSONLDStrings(result.StructuredData, "@type") {
+		if pageType, ok := jsonLDPageTypes[schemaType]; ok {
+			scores[pageType] += minPageTypeScore
+		}
+	}
+	switch ogType := strings.ToLower(result.Metadata["og:type"]); {
+	case ogType == "article":
+		scores[PageTypeArticle] += 2
+	case ogType == "product" || strings.HasPrefix(ogType, "product."):
+		scores[PageTypeProduct] += minPageTypeScore
+	}
+	if result.Thread != nil {
+		scores[PageTypeForum] += minPageTypeScore
+	}
+
+	for pageType, pattern := range pageTypeURLPatterns {
+		if pattern.MatchString(e.Request.URL.Path) {
+			scores[pageType] += 2
+		}
+	}
+	for pageType, selectors := range pageTypeSelectors {
+		for _, selector := range selectors {
+			if e.DOM.Find(selector).Length() > 0 {
+				scores[pageType]++
+			}
+		}
+	}
+
+	best, bestScore := PageTypeOther, minPageTypeScore-1
+	// Iterate in a fixed order so ties always resolve the same way
+	for _, pageType := range pagetype.All {
+		if scores[pageType] > bestScore {
+			best, bestScore = pageType, scores[pageType]
+		}
+	}
+	return best
+}
+
+// consultPageClassifier asks Config.PageClassifier to label a page the heuristics
+// could not, keeping PageTypeOther when it fails or answers with an unknown type
+func (s *Service) consultPageClassifier(ctx context.Context, result *Result) {
+	pageType, err := s.config.PageClassifier(ctx, result)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("url", result.URL).Msg("Page classifier failed")
+		return
+	}
+	pageType = strings.ToLower(strings.TrimSpace(pageType))
+	if pagetype.Valid(pageType) {
+		result.PageType = pageType
+		result.Metadata["page_type_source"] = "classifier"
+		return
+	}
+	s.logger.Warn().Str("url", result.URL).Str("page_type", pageType).Msg("Page classifier returned an unknown page type")
+}
@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const articlePage = `<html><head><title>Council passes budget</title>
+<meta property="og:type" content="article">
+<script type="application/ld+json">{"@context": "https://schema.org", "@type": "NewsArticle", "headline": "Council passes budget"}</script>
+</head><body><article>
+<p class="byline">By Jo Reporter</p><time datetime="2024-01-02">January 2</time>
+<p>The council approved next year's budget on Tuesday after a long debate.</p>
+</article></body></html>`
+
+const productPage = `<html><head><title>Trail Runner 2</title>
+<meta property="og:type" content="product">
+<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Product", "name": "Trail Runner 2", "offers": {"@type": "Offer", "price": "89.00"}}</script>
+</head><body><main>
+<h1>Trail Runner 2</h1><span itemprop="price" content="89.00">$89.00</span>
+<span data-sku="TR2-42">SKU TR2-42</span>
+<form action="/cart/add"><button name="add-to-cart">Add to cart</button></form>
+<div class="reviews">4.5 stars from 120 reviews</div>
+</main></body></html>`
+
+// productMarkupPage has product markup but no structured data
+const productMarkupPage = `<html><head><title>Trail Runner 2</title></head><body>
+<h1>Trail Runner 2</h1><span class="price">$89.00</span>
+<span data-sku="TR2-42">SKU TR2-42</span>
+<button class="add-to-cart">Add to cart</button>
+</body></html>`
+
+const docsPage = `<html><head><title>Config reference</title></head><body>
+<div class="breadcrumbs">Docs / Reference</div>
+<nav class="sidebar"><a href="/docs/start">Start</a></nav>
+<p>Set the timeout before creating the client.</p>
+<pre><code>client := New(Config{Timeout: time.Second})</code></pre>
+</body></html>`
+
+const plainPage = `<html><head><title>Hello</title></head><body><p>Welcome to my homepage.</p></body></html>`
+
+func TestClassifyPages(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{name: "article from structured data", page: articlePage, want: PageTypeArticle},
+		{name: "product from structured data", page: productPage, want: PageTypeProduct},
+		{name: "product from markup alone", page: productMarkupPage, want: PageTypeProduct},
+		{name: "docs from markup", page: docsPage, want: PageTypeDocs},
+		{name: "nothing to go on", page: plainPage, want: PageTypeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scrapeHTML(t, Config{ClassifyPages: true}, tt.page)
+			if result.PageType != tt.want {
+				t.Errorf("PageType = %q, want %q", result.PageType, tt.want)
+			}
+			if result.Metadata["page_type_source"] != "heuristic" {
+				t.Errorf("page_type_source = %q, want heuristic", result.Metadata["page_type_source"])
+			}
+		})
+	}
+
+	t.Run("off by default", func(t *testing.T) {
+		if result := scrapeHTML(t, Config{}, productPage); result.PageType != "" {
+			t.Errorf("PageType = %q without ClassifyPages", result.PageType)
+		}
+	})
+}
+
+func TestClassifyPagesByURL(t *testing.T) {
+	const page = `<html><head><title>Item</title></head><body><span class="price">$5</span><p>A small widget.</p></body></html>`
+	server := servePages(t, map[string]string{"/shop/widget": page, "/about": page})
+	service := newTestService(t, Config{ClassifyPages: true})
+
+	for path, want := range map[string]string{"/shop/widget": PageTypeProduct, "/about": PageTypeOther} {
+		result, err := service.ScrapeURL(context.Background(), server.URL+path, "")
+		if err != nil {
+			t.Fatalf("ScrapeURL(%s): %v", path, err)
+		}
+		if result.PageType != want {
+			t.Errorf("%s: PageType = %q, want %q", path, result.PageType, want)
+		}
+	}
+}
+
+func TestPageClassifier(t *testing.T) {
+	tests := []struct {
+		name       string
+		page       string
+		answer     string
+		err        error
+		want       string
+		wantSource string
+		wantCalls  int
+	}{
+		{name: "consulted when unsure", page: plainPage, answer: " Docs ", want: PageTypeDocs, wantSource: "classifier", wantCalls: 1},
+		{name: "unknown answer", page: plainPage, answer: "recipe", want: PageTypeOther, wantSource: "heuristic", wantCalls: 1},
+		{name: "failure", page: plainPage, err: errors.New("model unavailable"), want: PageTypeOther, wantSource: "heuristic", wantCalls: 1},
+		{name: "not consulted when sure", page: productPage, answer: "docs", want: PageTypeProduct, wantSource: "heuristic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			classifier := func(ctx context.Context, result *Result) (string, error) {
+				calls++
+				return tt.answer, tt.err
+			}
+			result := scrapeHTML(t, Config{ClassifyPages: true, PageClassifier: classifier}, tt.page)
+			if result.PageType != tt.want || result.Metadata["page_type_source"] != tt.wantSource {
+				t.Errorf("PageType = %q from %q, want %q from %q", result.PageType, result.Metadata["page_type_source"], tt.want, tt.wantSource)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("classifier called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Result kinds, reported in Result.Kind
+const (
+	KindHTML = "html"
+	KindPDF  = "pdf"
+	KindFeed = "feed"
+	KindJSON = "json"
+)
+
+// detectKind classifies a response by its Content-Type, sniffing the body when
+// the type is missing or generic. It returns "" for content it cannot extract.
+func detectKind(contentType string, body []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+	head := bytes.TrimSpace(body[:min(len(body), 512)])
+
+	switch {
+	case strings.Contains(mediaType, "html"):
+		return KindHTML
+	case mediaType == "application/pdf":
+		return KindPDF
+	case mediaType == "application/rss+xml", mediaType == "application/atom+xml":
+		return KindFeed
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		return KindJSON
+	case strings.HasSuffix(mediaType, "xml"):
+		if isFeed(head) {
+			return KindFeed
+		}
+		return ""
+	case mediaType == "", mediaType == "application/octet-stream", mediaType == "text/plain":
+		switch {
+		case bytes.HasPrefix(head, []byte("%PDF-")):
+			return KindPDF
+		case isFeed(head):
+			return KindFeed
+		case (bytes.HasPrefix(head, []byte("{")) || bytes.HasPrefix(head, []byte("["))) && json.Valid(body):
+			return KindJSON
+		}
+	}
+	return ""
+}
+
+// isFeed reports whether an XML document's start looks like RSS or Atom
+func isFeed(head []byte) bool {
+	return bytes.Contains(head, []byte("<rss")) || bytes.Contains(head, []byte("<feed")) || bytes.Contains(head, []byte("<rdf:RDF"))
+}
+
+// extractDocument fills result from a non-HTML body of the given kind
+func (s *Service) extractDocument(kind string, body []byte, result *Result) error {
+	switch kind {
+	case KindFeed:
+		return s.extractFeed(body, result)
+	case KindJSON:
+		return s.extractJSON(body, result)
+	case KindPDF:
+		return s.extractPDF(body, result)
+	}
+	return nil
+}
+
+// feedDocument decodes RSS 2.0, RSS 1.0 (RDF), and Atom feeds
+type feedDocument struct {
+	Channel struct {
+		Title string     `xml:"title"`
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0 places items beside the channel rather than inside it
+	Items []feedItem `xml:"item"`
+	// Atom
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// feedItem is one RSS item
+type feedItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+// atomEntry is one Atom entry
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Updated   string `xml:"updated"`
+	Published string `xml:"published"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+}
+
+// extractFeed turns a feed's entries into text blocks of title, link, date, and summary
+func (s *Service) extractFeed(body []byte, result *Result) error {
+	var feed feedDocument
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	// Bodies with a declared charset have already been converted to UTF-8
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := decoder.Decode(&feed); err != nil {
+		return fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	type entry struct{ title, link, date, summary string }
+	var entries []entry
+	result.Title = strings.TrimSpace(feed.Channel.Title)
+	for _, item := range append(feed.Channel.Items, feed.Items...) {
+		summary := item.Encoded
+		if summary == "" {
+			summary = item.Description
+		}
+		entries = append(entries, entry{item.Title, item.Link, item.PubDate, summary})
+	}
+	if result.Title == "" {
+		result.Title = strings.TrimSpace(feed.Title)
+	}
+	for _, atom := range feed.Entries {
+		link := ""
+		for _, l := range atom.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+		date := atom.Published
+		if date == "" {
+			date = atom.Updated
+		}
+		summary := atom.Content
+		if summary == "" {
+			summary = atom.Summary
+		}
+		entries = append(entries, entry{atom.Title, link, date, summary})
+	}
+
+	var blocks []string
+	for _, e := range entries {
+		var lines []string
+		for _, line := range []string{e.title, e.link, e.date, htmlToText(e.summary)} {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if link := strings.TrimSpace(e.link); link != "" {
+			result.Links = append(result.Links, link)
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	result.Content = strings.Join(blocks, "\n\n")
+	result.CleanText = s.cleanText(result.Content)
+	result.Metadata["feed_items"] = strconv.Itoa(len(entries))
+	return nil
+}
+
+// htmlToText returns the text of an HTML fragment, as found in feed summaries
+func htmlToText(fragment string) string {
+	if !strings.Contains(fragment, "<") {
+		return fragment
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return fragment
+	}
+	return doc.Text()
+}
+
+// extractJSON pretty-prints a JSON body into Content and flattens it into
+// "path: value" lines in CleanText, taking a top-level "title" or "name" as the title
+func (s *Service) extractJSON(body []byte, result *Result) error {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, body, "", "  "); err == nil {
+		result.Content = pretty.String()
+	}
+	var lines []string
+	flattenJSON("", value, &lines)
+	result.CleanText = strings.Join(lines, "\n")
+
+	if object, ok := value.(map[string]interface{}); ok {
+		for _, key := range []string{"title", "name"} {
+			if title, ok := object[key].(string); ok && strings.TrimSpace(title) != "" {
+				result.Title = strings.TrimSpace(title)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// flattenJSON appends one "path: value" line per scalar in value, with object
+// keys in sorted order and array elements as path[i]
+func flattenJSON(path string, value interface{}, lines *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			flattenJSON(child, v[key], lines)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenJSON(fmt.Sprintf("%s[%d]", path, i), item, lines)
+		}
+	case nil:
+		// Nulls carry no text
+	default:
+		text := strings.TrimSpace(fmt.Sprint(v))
+		if text == "" {
+			return
+		}
+		if path == "" {
+			*lines = append(*lines, text)
+		} else {
+			*lines = append(*lines, path+": "+text)
+		}
+	}
+}
+
+// pdfTitle matches the title in a PDF's document information dictionary
+var pdfTitle = regexp.MustCompile(`/Title\s*\(((?:[^()\\]|\\.)*)\)`)
+
+// extractPDF pulls the text shown by a PDF's content streams. It is a best-effort
+// extractor without font decoding: it reads literal strings from uncompressed and
+// Flate-compressed streams, so PDFs that encode text through embedded (CID) fonts
+// or hex strings yield little or no text.
+func (s *Service) extractPDF(body []byte, result *Result) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(body[:min(len(body), 1024)]), []byte("%PDF-")) {
+		return fmt.Errorf("failed to parse PDF: missing %%PDF header")
+	}
+	if match := pdfTitle.FindSubmatch(body); match != nil {
+		result.Title = strings.TrimSpace(pdfString(match[1]))
+	}
+
+	var text strings.Builder
+	for pos := 0; ; {
+		i := bytes.Index(body[pos:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		i += pos
+		pos = i + len("stream")
+		if bytes.HasSuffix(body[:i], []byte("end")) {
+			continue
+		}
+
+		// The stream's data follows the keyword's end of line; its dictionary precedes it
+		start := pos
+		if start < len(body) && body[start] == '\r' {
+			start++
+		}
+		if start < len(body) && body[start] == '\n' {
+			start++
+		}
+		end := bytes.Index(body[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		dict := body[max(bytes.LastIndex(body[:i], []byte("obj")), 0):i]
+		data := body[start : start+end]
+		pos = start + end + len("endstream")
+
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			// A cut-off stream still yields the text inflated before the break
+			data, _ = io.ReadAll(reader)
+		case bytes.Contains(dict, []byte("/Filter")):
+			// Images and other encodings hold no readable text
+			continue
+		}
+		if bytes.Contains(data, []byte("BT")) {
+			text.WriteString(pdfContentText(data))
+		}
+	}
+
+	result.Content = strings.TrimSpace(text.String())
+	result.CleanText = s.cleanText(result.Content)
+	if result.CleanText == "" {
+		s.logger.Warn().Str("url", result.URL).Msg("No extractable text in PDF; it may use embedded font encodings or be scanned")
+	}
+	return nil
+}
+
+// pdfContentText returns the text drawn by the Tj, TJ, ' and " operators of a
+// content stream, starting new lines at line-positioning operators and text block ends
+func pdfContentText(data []byte) string {
+	var out, pending strings.Builder
+	newline := func() {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '(':
+			str, next := pdfLiteral(data, i)
+			pending.WriteString(pdfString(str))
+			i = next
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			// Large negative TJ adjustments are the gaps between words
+			j := i + 1
+			for j < len(data) && (data[j] == '.' || (data[j] >= '0' && data[j] <= '9')) {
+				j++
+			}
+			if n, err := strconv.ParseFloat(string(data[i:j]), 64); err == nil && n < -200 && pending.Len() > 0 {
+				pending.WriteString(" ")
+			}
+			i = j
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		case isPDFRegular(c):
+			j := i
+			for j < len(data) && isPDFRegular(data[j]) {
+				j++
+			}
+			switch string(data[i:j]) {
+			case "Tj", "TJ":
+				out.WriteString(pending.String())
+				pending.Reset()
+			case "'", `"`:
+				newline()
+				out.WriteString(pending.String())
+				pending.Reset()
+			case "T*", "Td", "TD", "ET":
+				newline()
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return out.String()
+}
+
+// isPDFRegular reports whether c is a PDF regular character, i.e. not whitespace or a delimiter
+func isPDFRegular(c byte) bool {
+	return !strings.ContainsRune(" \t\r\n\f\x00()<>[]{}/%", rune(c))
+}
+
+// pdfLiteral returns the raw bytes of the literal string starting at data[start]
+// == '(', honoring nested parentheses and escapes, and the index after it
+func pdfLiteral(data []byte, start int) ([]byte, int) {
+	depth := 0
+	for i := start; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return data[start+1 : i], i + 1
+			}
+		}
+	}
+	return data[start+1:], len(data)
+}
+
+// pdfString decodes the escapes of a literal string, reading bytes as Latin-1
+// (close to PDFDocEncoding) and dropping control characters
+func pdfString(raw []byte) string {
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c == '\\' && i+1 < len(raw) {
+			i++
+			switch e := raw[i]; e {
+			case 'n', 'r':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte(' ')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(raw) && j < i+3 && raw[j] >= '0' && raw[j] <= '7' {
+						j++
+					}
+					n, _ := strconv.ParseUint(string(raw[i:j]), 8, 8)
+					c, i = byte(n), j-1
+				} else {
+					c = e
+				}
+				if r := rune(c); !unicode.IsControl(r) {
+					out.WriteRune(r)
+				}
+			}
+			continue
+		}
+		if r := rune(c); !unicode.IsControl(r) || r == '\n' {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,211 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pdfFixture builds a minimal PDF titled title whose page draws content, with
+// the content stream Flate-compressed when compress is set
+func pdfFixture(title, content string, compress bool) []byte {
+	stream, filter := []byte(content), ""
+	if compress {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(stream)
+		w.Close()
+		stream, filter = buf.Bytes(), " /Filter /FlateDecode"
+	}
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	pdf.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	pdf.WriteString("2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n")
+	pdf.WriteString("3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>\nendobj\n")
+	fmt.Fprintf(&pdf, "4 0 obj\n<< /Length %d%s >>\nstream\n", len(stream), filter)
+	pdf.Write(stream)
+	pdf.WriteString("\nendstream\nendobj\n")
+	fmt.Fprintf(&pdf, "5 0 obj\n<< /Title (%s) >>\nendobj\n", title)
+	pdf.WriteString("trailer\n<< /Root 1 0 R /Info 5 0 R >>\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+// pdfPage is a content stream drawing two lines, the second with a TJ word gap
+const pdfPage = "BT /F1 12 Tf 72 720 Td (Quarterly report) Tj 0 -14 Td [(Revenue)-250(grew \\(again\\))] TJ ET"
+
+const rssFixture = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Example News</title>
+<item><title>Budget passes</title><link>https://example.com/budget</link><pubDate>Tue, 02 Jan 2024 10:00:00 GMT</pubDate><description>&lt;p&gt;The council &lt;b&gt;approved&lt;/b&gt; it.&lt;/p&gt;</description></item>
+<item><title>Parks reopen</title><link>https://example.com/parks</link><description>Just in time for spring.</description></item>
+</channel></rss>`
+
+const atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Example Blog</title>
+<entry><title>Release notes</title><link rel="alternate" href="https://example.com/release"/><updated>2024-01-02T10:00:00Z</updated><summary>Faster builds.</summary></entry>
+</feed>`
+
+const jsonFixture = `{"name": "Widget API", "version": 2, "tags": ["fast", "small"], "owner": {"team": "platform"}, "retired": null}`
+
+func TestDetectKind(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{name: "html", contentType: "text/html; charset=utf-8", body: "<html></html>", want: KindHTML},
+		{name: "xhtml", contentType: "application/xhtml+xml", body: "<html></html>", want: KindHTML},
+		{name: "pdf", contentType: "application/pdf", body: "%PDF-1.7", want: KindPDF},
+		{name: "rss", contentType: "application/rss+xml", body: rssFixture, want: KindFeed},
+		{name: "feed served as xml", contentType: "text/xml", body: atomFixture, want: KindFeed},
+		{name: "other xml", contentType: "application/xml", body: `<?xml version="1.0"?><sitemap/>`, want: ""},
+		{name: "json", contentType: "application/json", body: jsonFixture, want: KindJSON},
+		{name: "json suffix", contentType: "application/ld+json", body: jsonFixture, want: KindJSON},
+		{name: "sniffed pdf", contentType: "application/octet-stream", body: "%PDF-1.4\n", want: KindPDF},
+		{name: "sniffed feed", contentType: "text/plain", body: rssFixture, want: KindFeed},
+		{name: "sniffed json", contentType: "", body: " [1, 2]", want: KindJSON},
+		{name: "plain text", contentType: "text/plain", body: "{not json", want: ""},
+		{name: "image", contentType: "image/png", body: "\x89PNG", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectKind(tt.contentType, []byte(tt.body)); got != tt.want {
+				t.Errorf("detectKind(%q) = %q, want %q", tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapeDispatchesByContentType(t *testing.T) {
+	type fixture struct {
+		contentType string
+		body        []byte
+	}
+	fixtures := map[string]fixture{
+		"/page":      {"text/html; charset=utf-8", []byte(`<html><head><title>Page</title></head><body><p>An ordinary article.</p></body></html>`)},
+		"/report":    {"application/pdf", pdfFixture("Q3 Report", pdfPage, false)},
+		"/flate":     {"application/pdf", pdfFixture("Q3 Report", pdfPage, true)},
+		"/download":  {"application/octet-stream", pdfFixture("Q3 Report", pdfPage, true)},
+		"/rss":       {"application/rss+xml", []byte(rssFixture)},
+		"/atom":      {"text/xml", []byte(atomFixture)},
+		"/api":       {"application/json", []byte(jsonFixture)},
+		"/untyped":   {"text/plain", []byte(jsonFixture)},
+		"/scanned":   {"application/pdf", pdfFixture("Scan", "q 612 0 0 792 0 0 cm /Im1 Do Q", false)},
+		"/not-a-pdf": {"application/pdf", []byte("<html>an error page</html>")},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", f.contentType)
+		w.Write(f.body)
+	}))
+	t.Cleanup(server.Close)
+
+	pdfText := []string{"Quarterly report", "Revenue grew (again)"}
+	tests := []struct {
+		path      string
+		wantKind  string
+		wantTitle string
+		wantText  []string // in order
+		wantLinks []string
+		wantMeta  map[string]string
+	}{
+		{path: "/page", wantKind: KindHTML, wantTitle: "Page", wantText: []string{"An ordinary article."}},
+		{path: "/report", wantKind: KindPDF, wantTitle: "Q3 Report", wantText: pdfText},
+		{path: "/flate", wantKind: KindPDF, wantTitle: "Q3 Report", wantText: pdfText},
+		{path: "/download", wantKind: KindPDF, wantTitle: "Q3 Report", wantText: pdfText},
+		{
+			path:      "/rss",
+			wantKind:  KindFeed,
+			wantTitle: "Example News",
+			wantText:  []string{"Budget passes", "https://example.com/budget", "The council approved it.", "Parks reopen", "Just in time for spring."},
+			wantLinks: []string{"https://example.com/budget", "https://example.com/parks"},
+			wantMeta:  map[string]string{"feed_items": "2"},
+		},
+		{
+			path:      "/atom",
+			wantKind:  KindFeed,
+			wantTitle: "Example Blog",
+			wantText:  []string{"Release notes", "https://example.com/release", "2024-01-02T10:00:00Z", "Faster builds."},
+			wantLinks: []string{"https://example.com/release"},
+			wantMeta:  map[string]string{"feed_items": "1"},
+		},
+		{path: "/api", wantKind: KindJSON, wantTitle: "Widget API", wantText: []string{"name: Widget API", "owner.team: platform", "tags[0]: fast", "tags[1]: small", "version: 2"}},
+		{path: "/untyped", wantKind: KindJSON, wantTitle: "Widget API", wantText: []string{"name: Widget API"}},
+		{path: "/scanned", wantKind: KindPDF, wantTitle: "Scan"},
+		{path: "/not-a-pdf", wantKind: KindPDF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := newTestService(t, Config{}).ScrapeURL(context.Background(), server.URL+tt.path, "")
+			if err != nil {
+				t.Fatalf("ScrapeURL: %v", err)
+			}
+			if result.Kind != tt.wantKind || result.Title != tt.wantTitle {
+				t.Errorf("Kind = %q, Title = %q; want %q, %q", result.Kind, result.Title, tt.wantKind, tt.wantTitle)
+			}
+			rest := result.CleanText
+			for _, want := range tt.wantText {
+				i := strings.Index(rest, want)
+				if i < 0 {
+					t.Fatalf("CleanText = %q, want %q in order", result.CleanText, tt.wantText)
+				}
+				rest = rest[i+len(want):]
+			}
+			if len(tt.wantText) == 0 && result.Kind != KindHTML && result.CleanText != "" {
+				t.Errorf("CleanText = %q, want none", result.CleanText)
+			}
+			for i, want := range tt.wantLinks {
+				if i >= len(result.Links) || result.Links[i] != want {
+					t.Errorf("Links = %q, want %q", result.Links, tt.wantLinks)
+					break
+				}
+			}
+			for key, want := range tt.wantMeta {
+				if got := result.Metadata[key]; got != want {
+					t.Errorf("Metadata[%q] = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractJSONPrettyPrints(t *testing.T) {
+	result := &Result{Metadata: map[string]string{}}
+	if err := newTestService(t, Config{}).extractJSON([]byte(`{"a":[1,2]}`), result); err != nil {
+		t.Fatalf("extractJSON: %v", err)
+	}
+	if want := "{\n  \"a\": [\n    1,\n    2\n  ]\n}"; result.Content != want {
+		t.Errorf("Content = %q, want %q", result.Content, want)
+	}
+	if err := newTestService(t, Config{}).extractJSON([]byte(`{"a":`), result); err == nil {
+		t.Error("extractJSON accepted truncated JSON")
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: `plain`, want: "plain"},
+		{raw: `nested \(parens\) and \\ slash`, want: `nested (parens) and \ slash`},
+		{raw: `tab\there`, want: "tab here"},
+		{raw: `caf\351`, want: "café"},
+		{raw: "line\\\ncontinued", want: "linecontinued"},
+		{raw: `bell\007gone`, want: "bellgone"},
+	}
+	for _, tt := range tests {
+		if got := pdfString([]byte(tt.raw)); got != tt.want {
+			t.Errorf("pdfString(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
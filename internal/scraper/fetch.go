@@ -0,0 +1,185 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/HeidiZHH/skull/internal/retry"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// ResponseInfo describes the response behind a FetchReader stream
+type ResponseInfo struct {
+	URL string `json:"url"`
+	// FinalURL is where redirects led; equal to URL when there were none
+	FinalURL    string      `json:"final_url"`
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header"`
+	ContentType string      `json:"content_type"`
+	// ContentLength is the encoded body size in bytes, or -1 when the server does not say
+	ContentLength int64 `json:"content_length"`
+	// Decoded is set when a text body was converted from another charset to UTF-8
+	Decoded bool `json:"decoded"`
+	// Attempts is how many requests were made, including retries
+	Attempts int `json:"attempts"`
+
+	truncated *atomic.Bool
+}
+
+// Truncated reports whether the stream was cut off at Config.MaxBodySize or by
+// Config.Deadline. It is only final once the stream has been read to EOF.
+func (i *ResponseInfo) Truncated() bool {
+	return i.truncated != nil && i.truncated.Load()
+}
+
+// FetchReader fetches url and returns its body as a stream, without any
+// extraction, for callers that pipe it to a file or their own parser. The request
+// goes through the same checks and settings as ScrapeURL: URL validation,
+// Transport, User-Agent, Referer, Deadline, robots.txt, and retries. Text bodies
+// in another charset, declared by the Content-Type header or evident from bytes
+// that are not UTF-8, are decoded to UTF-8, and at most MaxBodySize bytes of the
+// encoded body are read; under RejectOversized, a longer body fails with
+// ErrBodyTooLarge, up front when its Content-Length says so and otherwise
+// mid-read. Error statuses are returned as a *StatusError along with the
+// ResponseInfo. The caller must close the stream.
+func (s *Service) FetchReader(ctx context.Context, url string) (io.ReadCloser, *ResponseInfo, error) {
+	url, err := NormalizeURL(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.validateURL(url); err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.checkRobots(ctx, url); err != nil {
+		return nil, nil, err
+	}
+	referer := ""
+	if s.config.AutoReferer {
+		if referer = s.config.Referer; referer == "" {
+			referer = originOf(url)
+		}
+	}
+
+	// The deadline must outlive this call while the caller reads, so it ends on Close
+	cancel := context.CancelFunc(func() {})
+	if s.config.Deadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.config.Deadline)
+	}
+
+	info := &ResponseInfo{URL: url, truncated: &atomic.Bool{}}
+	var stream io.ReadCloser
+	err = retry.Do(ctx, s.retryPolicy(), func(ctx context.Context) error {
+		info.Attempts++
+		resp, err := s.fetchOnce(ctx, url, referer)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			info.FinalURL = resp.Request.URL.String()
+			info.StatusCode = resp.StatusCode
+			info.Header = resp.Header
+			info.ContentType = resp.Header.Get("Content-Type")
+			info.ContentLength = resp.ContentLength
+
+			if s.config.RejectOversized && resp.ContentLength > s.config.MaxBodySize {
+				resp.Body.Close()
+				return fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrBodyTooLarge, resp.ContentLength, s.config.MaxBodySize)
+			}
+			limited := &partialBody{body: resp.Body, remaining: s.config.MaxBodySize, reject: s.config.RejectOversized, partial: info.truncated}
+			body := io.Reader(limited)
+			if isTextContent(info.ContentType) {
+				// Sniff the charset from the header or the document's own declaration
+				buffered := bufio.NewReader(limited)
+				preview, _ := buffered.Peek(1024)
+				body = buffered
+				// Without a declared charset, only bytes that are not UTF-8 are decoded
+				encoding, name, certain := charset.DetermineEncoding(preview, info.ContentType)
+				if name != "utf-8" && (certain || !validUTF8Prefix(preview)) {
+					body, info.Decoded = transform.NewReader(buffered, encoding.NewDecoder()), true
+				}
+			}
+			stream = &fetchBody{Reader: body, closer: resp.Body, cancel: cancel}
+			return nil
+		}
+
+		delay := s.fetchRetryDelay(resp, err, info.Attempts)
+		if resp != nil {
+			info.StatusCode = resp.StatusCode
+			info.Header = resp.Header
+			info.ContentType = resp.Header.Get("Content-Type")
+			resp.Body.Close()
+			err = &StatusError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to fetch %s", url)}
+		}
+		if delay < 0 || ctx.Err() != nil {
+			return err
+		}
+		s.logger.Info().Int("attempt", info.Attempts).Dur("delay", delay).Str("url", url).Msg("Retrying fetch")
+		return retry.After(err, delay)
+	})
+	if err != nil {
+		cancel()
+		return nil, info, err
+	}
+	return stream, info, nil
+}
+
+// fetchOnce sends a single GET for FetchReader
+func (s *Service) fetchOnce(ctx context.Context, url, referer string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidURL, url, err)
+	}
+	if s.config.UserAgent != "" {
+		req.Header.Set("User-Agent", s.config.UserAgent)
+	}
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	return resp, nil
+}
+
+// fetchRetryDelay applies Config.ShouldRetry, or defaultRetryDelay when it is
+// unset, to the given retry (1 for the first) of a failed FetchReader request
+func (s *Service) fetchRetryDelay(resp *http.Response, err error, attempt int) time.Duration {
+	if s.config.ShouldRetry == nil {
+		return defaultRetryDelay(resp, err, attempt)
+	}
+	return s.config.ShouldRetry(resp, err)
+}
+
+// validUTF8Prefix reports whether preview is valid UTF-8, allowing a rune cut off at its end
+func validUTF8Prefix(preview []byte) bool {
+	for cut := 0; cut < utf8.UTFMax && cut <= len(preview); cut++ {
+		if utf8.Valid(preview[:len(preview)-cut]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTextContent reports whether a content type is text that may need charset decoding
+func isTextContent(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "html") || strings.Contains(contentType, "xml")
+}
+
+// fetchBody is a FetchReader stream; closing it releases the connection and the deadline
+type fetchBody struct {
+	io.Reader
+	closer io.Closer
+	cancel context.CancelFunc
+}
+
+func (b *fetchBody) Close() error {
+	defer b.cancel()
+	return b.closer.Close()
+}
@@ -0,0 +1,115 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveBody serves body as text/plain, without a Content-Length when chunked is set
+func serveBody(t *testing.T, body string, chunked bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if chunked {
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchReaderSizeCap(t *testing.T) {
+	body := strings.Repeat("0123456789", 100)
+	tests := []struct {
+		name          string
+		maxBodySize   int64
+		reject        bool
+		chunked       bool
+		want          string
+		wantTruncated bool
+		wantOpenErr   bool
+		wantReadErr   bool
+	}{
+		{name: "under the cap", maxBodySize: 2000, want: body},
+		{name: "exactly at the cap", maxBodySize: 1000, want: body},
+		{name: "cut at the cap", maxBodySize: 250, want: body[:250], wantTruncated: true},
+		{name: "cut at the cap without a length", maxBodySize: 250, chunked: true, want: body[:250], wantTruncated: true},
+		{name: "rejected up front", maxBodySize: 250, reject: true, wantOpenErr: true},
+		{name: "rejected mid-read", maxBodySize: 250, reject: true, chunked: true, wantReadErr: true},
+		{name: "exactly at the cap under reject", maxBodySize: 1000, reject: true, chunked: true, want: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveBody(t, body, tt.chunked)
+			service := newTestService(t, Config{MaxBodySize: tt.maxBodySize, RejectOversized: tt.reject})
+
+			stream, info, err := service.FetchReader(context.Background(), server.URL)
+			if tt.wantOpenErr {
+				if !errors.Is(err, ErrBodyTooLarge) {
+					t.Errorf("FetchReader error = %v, want ErrBodyTooLarge", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchReader: %v", err)
+			}
+			defer stream.Close()
+
+			data, err := io.ReadAll(stream)
+			if tt.wantReadErr {
+				if !errors.Is(err, ErrBodyTooLarge) || int64(len(data)) > tt.maxBodySize {
+					t.Errorf("read %d bytes with error %v, want ErrBodyTooLarge within the cap", len(data), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reading the stream: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("read %d bytes, want %d", len(data), len(tt.want))
+			}
+			if info.Truncated() != tt.wantTruncated {
+				t.Errorf("Truncated() = %v, want %v", info.Truncated(), tt.wantTruncated)
+			}
+			if wantLength := int64(len(body)); !tt.chunked && info.ContentLength != wantLength || tt.chunked && info.ContentLength != -1 {
+				t.Errorf("ContentLength = %d, want the server's", info.ContentLength)
+			}
+		})
+	}
+}
+
+func TestFetchReaderResponseInfo(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Header().Set("X-Served-By", "test")
+		w.Write([]byte("<p>Caf\xe9 cr\xe8me</p>"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	stream, info, err := newTestService(t, Config{}).FetchReader(context.Background(), server.URL+"/old")
+	if err != nil {
+		t.Fatalf("FetchReader: %v", err)
+	}
+	defer stream.Close()
+	data, _ := io.ReadAll(stream)
+	if string(data) != "<p>Café crème</p>" || !info.Decoded {
+		t.Errorf("body = %q, Decoded = %v, want it decoded to UTF-8", data, info.Decoded)
+	}
+	if info.URL != server.URL+"/old" || info.FinalURL != server.URL+"/new" || info.StatusCode != http.StatusOK || info.Attempts != 1 {
+		t.Errorf("info = %+v, want the redirect followed in one attempt", info)
+	}
+	if info.Header.Get("X-Served-By") != "test" || info.ContentType != "text/html; charset=iso-8859-1" {
+		t.Errorf("Header = %v, ContentType = %q, want the response's", info.Header, info.ContentType)
+	}
+}
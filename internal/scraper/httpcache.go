@@ -0,0 +1,252 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// cachingTransport is a private HTTP cache in the spirit of RFC 7234. Fresh
+// responses are served from disk, stale ones are revalidated with
+// If-None-Match/If-Modified-Since, and a 304 refreshes the stored entry.
+// Only complete GET responses with status 200 are stored.
+type cachingTransport struct {
+	base   http.RoundTripper
+	dir    string
+	logger zerolog.Logger
+}
+
+// variedHeaderPrefix stores the request header values a cached response varies on
+const variedHeaderPrefix = "X-Varied-"
+
+// fromCacheHeader marks responses served from the cache
+const fromCacheHeader = "X-From-Cache"
+
+// newCachingTransport wraps base with an on-disk cache rooted at dir
+func newCachingTransport(base http.RoundTripper, dir string, logger zerolog.Logger) *cachingTransport {
+	return &cachingTransport{base: base, dir: dir, logger: logger}
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	cached := t.load(key, req)
+	if cached != nil && varyMatches(cached, req) {
+		if isFresh(cached.Header, req.Header) {
+			t.logger.Debug().Str("url", req.URL.String()).Msg("Serving fresh response from HTTP cache")
+			cached.Header.Set(fromCacheHeader, "1")
+			return cached, nil
+		}
+
+		// Stale: revalidate with the stored validators
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	} else if cached != nil {
+		cached.Body.Close()
+		cached = nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return nil, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		t.logger.Debug().Str("url", req.URL.String()).Msg("Revalidated response from HTTP cache")
+		resp.Body.Close()
+		for _, header := range []string{"Cache-Control", "Date", "Expires", "ETag", "Last-Modified"} {
+			if value := resp.Header.Get(header); value != "" {
+				cached.Header.Set(header, value)
+			}
+		}
+		body, err := io.ReadAll(cached.Body)
+		cached.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.store(key, cached, body)
+		cached.Body = io.NopCloser(bytes.NewReader(body))
+		cached.Header.Set(fromCacheHeader, "1")
+		return cached, nil
+	}
+	if cached != nil {
+		cached.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK || !isStorable(resp.Header, req.Header) {
+		return resp, nil
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		resp.Header.Set(variedHeaderPrefix+name, req.Header.Get(name))
+	}
+	// Store once the caller has read the whole body; partial reads are never cached
+	resp.Body = &cachingBody{body: resp.Body, onEOF: func(body []byte) { t.store(key, resp, body) }}
+	return resp, nil
+}
+
+// load reads a cached response for key, or returns nil
+func (t *cachingTransport) load(key string, req *http.Request) *http.Response {
+	data, err := os.ReadFile(filepath.Join(t.dir, key))
+	if err != nil {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		t.logger.Debug().Err(err).Msg("Discarding unreadable HTTP cache entry")
+		return nil
+	}
+	return resp
+}
+
+// store writes resp with the given body to the cache, logging failures
+func (t *cachingTransport) store(key string, resp *http.Response, body []byte) {
+	stored := *resp
+	stored.Header = resp.Header.Clone()
+	stored.Header.Del(fromCacheHeader)
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	dump, err := httputil.DumpResponse(&stored, true)
+	if err == nil {
+		if err = os.MkdirAll(t.dir, 0o755); err == nil {
+			err = os.WriteFile(filepath.Join(t.dir, key), dump, 0o644)
+		}
+	}
+	if err != nil {
+		t.logger.Warn().Err(err).Msg("Failed to write HTTP cache entry")
+	}
+}
+
+// cachingBody buffers a response body and hands it to onEOF once fully read
+type cachingBody struct {
+	body  io.ReadCloser
+	buf   bytes.Buffer
+	onEOF func([]byte)
+}
+
+func (c *cachingBody) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	c.buf.Write(p[:n])
+	if err == io.EOF && c.onEOF != nil {
+		c.onEOF(c.buf.Bytes())
+		c.onEOF = nil
+	}
+	return n, err
+}
+
+func (c *cachingBody) Close() error { return c.body.Close() }
+
+// cacheKey identifies a cache entry by request URL
+func cacheKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// varyHeaders returns the request header names listed in the response's Vary header
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// varyMatches reports whether req carries the same varied headers as the cached request
+func varyMatches(cached *http.Response, req *http.Request) bool {
+	for _, name := range varyHeaders(cached.Header) {
+		if name == "*" || cached.Header.Get(variedHeaderPrefix+name) != req.Header.Get(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheControl parses a Cache-Control header into its directives
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// isStorable reports whether a response may be stored at all
+func isStorable(respHeader, reqHeader http.Header) bool {
+	if _, ok := cacheControl(respHeader)["no-store"]; ok {
+		return false
+	}
+	if _, ok := cacheControl(reqHeader)["no-store"]; ok {
+		return false
+	}
+	for _, name := range varyHeaders(respHeader) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// isFresh reports whether a cached response can be served without revalidation
+func isFresh(respHeader, reqHeader http.Header) bool {
+	reqCC := cacheControl(reqHeader)
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	respCC := cacheControl(respHeader)
+	if _, ok := respCC["no-cache"]; ok {
+		return false
+	}
+
+	date, err := http.ParseTime(respHeader.Get("Date"))
+	if err != nil {
+		return false
+	}
+	age := time.Since(date)
+	if ageHeader, err := strconv.Atoi(respHeader.Get("Age")); err == nil {
+		age += time.Duration(ageHeader) * time.Second
+	}
+
+	var lifetime time.Duration
+	if maxAge, err := strconv.Atoi(respCC["max-age"]); err == nil {
+		lifetime = time.Duration(maxAge) * time.Second
+	} else if expires, err := http.ParseTime(respHeader.Get("Expires")); err == nil {
+		lifetime = expires.Sub(date)
+	} else {
+		return false
+	}
+	if maxAge, err := strconv.Atoi(reqCC["max-age"]); err == nil && time.Duration(maxAge)*time.Second < lifetime {
+		lifetime = time.Duration(maxAge) * time.Second
+	}
+	return age < lifetime
+}
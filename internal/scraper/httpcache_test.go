@@ -0,0 +1,87 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// cachedPageServer serves a page with the given Cache-Control and an ETag,
+// answering matching If-None-Match requests with 304, and records the
+// conditional header of every request
+func cachedPageServer(t *testing.T, cacheControl string) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var conditions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", cacheControl)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Cached</title></head><body><p>Stable content.</p></body></html>`))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), conditions...)
+	}
+}
+
+func TestHTTPCacheRevalidates(t *testing.T) {
+	server, conditions := cachedPageServer(t, "no-cache")
+	service := newTestService(t, Config{HTTPCacheDir: t.TempDir()})
+
+	for i := 0; i < 2; i++ {
+		result, err := service.ScrapeURL(context.Background(), server.URL, "")
+		if err != nil {
+			t.Fatalf("scrape %d: %v", i+1, err)
+		}
+		if result.Title != "Cached" || result.CleanText != "Stable content." || result.StatusCode != http.StatusOK {
+			t.Errorf("scrape %d = %q / %q (status %d), want the cached page", i+1, result.Title, result.CleanText, result.StatusCode)
+		}
+	}
+	if got := conditions(); len(got) != 2 || got[0] != "" || got[1] != `"v1"` {
+		t.Errorf("If-None-Match per request = %q, want an unconditional fetch then a revalidation", got)
+	}
+}
+
+func TestHTTPCacheServesFresh(t *testing.T) {
+	server, conditions := cachedPageServer(t, "max-age=3600")
+	service := newTestService(t, Config{HTTPCacheDir: t.TempDir()})
+
+	for i := 0; i < 2; i++ {
+		result, err := service.ScrapeURL(context.Background(), server.URL, "")
+		if err != nil {
+			t.Fatalf("scrape %d: %v", i+1, err)
+		}
+		if result.Title != "Cached" {
+			t.Errorf("scrape %d title = %q", i+1, result.Title)
+		}
+	}
+	if got := conditions(); len(got) != 1 {
+		t.Errorf("server saw %d requests, want the fresh entry served from disk", len(got))
+	}
+}
+
+func TestHTTPCacheOffByDefault(t *testing.T) {
+	server, conditions := cachedPageServer(t, "max-age=3600")
+	service := newTestService(t, Config{})
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.ScrapeURL(context.Background(), server.URL, ""); err != nil {
+			t.Fatalf("scrape %d: %v", i+1, err)
+		}
+	}
+	if got := conditions(); len(got) != 2 {
+		t.Errorf("server saw %d requests, want every scrape fetched", len(got))
+	}
+}
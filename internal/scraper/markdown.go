@@ -0,0 +1,377 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Output formats for Config.OutputFormat
+const (
+	// OutputFormatText extracts only the flattened CleanText
+	OutputFormatText = "text"
+	// OutputFormatMarkdown also converts the extracted content into Result.Markdown
+	OutputFormatMarkdown = "markdown"
+)
+
+// ScrapeURLAsMarkdown scrapes a URL like ScrapeURL and also converts the content
+// it extracts, the selector's matches or the default main content, into
+// Result.Markdown, whatever Config.OutputFormat says
+func (s *Service) ScrapeURLAsMarkdown(ctx context.Context, url string, selector string) (*Result, error) {
+	markdown := *s
+	markdown.config.OutputFormat = OutputFormatMarkdown
+	return markdown.ScrapeURL(ctx, url, selector)
+}
+
+// validateOutputFormat reports an unknown Config.OutputFormat
+func validateOutputFormat(format string) error {
+	switch format {
+	case "", OutputFormatText, OutputFormatMarkdown:
+		return nil
+	}
+	return fmt.Errorf("unknown OutputFormat %q (supported: %s, %s)", format, OutputFormatText, OutputFormatMarkdown)
+}
+
+// markdownSkipTags are dropped with their content, as the text extractor drops scripts and styles
+var markdownSkipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "object": true, "head": true,
+	"button": true, "select": true, "input": true, "textarea": true,
+}
+
+// markdownBlockTags start a new Markdown block rather than continuing the current line
+var markdownBlockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"header": true, "footer": true, "aside": true, "nav": true, "body": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "pre": true, "blockquote": true,
+	"table": true, "hr": true, "figure": true, "figcaption": true,
+	"dl": true, "dt": true, "dd": true, "details": true, "summary": true,
+	"form": true, "address": true, "fieldset": true,
+}
+
+// markdownSpaces collapses the space runs left where inline elements meet
+var markdownSpaces = regexp.MustCompile(` {2,}`)
+
+// markdownConverter renders a DOM subtree as Markdown; absolute resolves link and image URLs
+type markdownConverter struct {
+	absolute func(string) string
+}
+
+// toMarkdown converts every element of a selection into Markdown, preserving
+// headings, lists, links, emphasis, code blocks, quotes, and tables
+func toMarkdown(sel *goquery.Selection, absolute func(string) string) string {
+	converter := markdownConverter{absolute: absolute}
+	var blocks []string
+	for _, node := range sel.Nodes {
+		blocks = append(blocks, converter.block(node)...)
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// blocks renders the children of n, gathering runs of text and inline elements into paragraphs
+func (c markdownConverter) blocks(n *html.Node) []string {
+	var blocks []string
+	var line strings.Builder
+	flush := func() {
+		if text := tidyInline(line.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		line.Reset()
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && markdownBlockTags[child.Data] {
+			flush()
+			blocks = append(blocks, c.block(child)...)
+			continue
+		}
+		line.WriteString(c.inline(child))
+	}
+	flush()
+	return blocks
+}
+
+// block renders one element as Markdown blocks
+func (c markdownConverter) block(n *html.Node) []string {
+	if n.Type != html.ElementNode {
+		if text := tidyInline(c.inline(n)); text != "" {
+			return []string{text}
+		}
+		return nil
+	}
+	if markdownSkipTags[n.Data] {
+		return nil
+	}
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.ReplaceAll(tidyInline(c.inlineChildren(n)), "\n", " ")
+		if text == "" {
+			return nil
+		}
+		return []string{strings.Repeat("#", int(n.Data[1]-'0')) + " " + text}
+	case "ul", "ol":
+		if list := c.list(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case "pre":
+		return []string{codeBlock(n)}
+	case "blockquote":
+		inner := strings.Join(c.blocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return []string{strings.Join(lines, "\n")}
+	case "hr":
+		return []string{"---"}
+	case "table":
+		if table := c.table(n); table != "" {
+			return []string{table}
+		}
+		return nil
+	}
+	return c.blocks(n)
+}
+
+// list renders a <ul> or <ol>; nested lists are indented under their item
+func (c markdownConverter) list(n *html.Node) string {
+	var items []string
+	number := 1
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "li" {
+			continue
+		}
+		marker := "- "
+		if n.Data == "ol" {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		content := strings.Join(c.blocks(child), "\n")
+		if content == "" {
+			continue
+		}
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.ReplaceAll(content, "\n", "\n"+indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders a table as a Markdown pipe table, taking its first row as the
+// header. Only the table's own rows are read; a table nested in a cell is
+// flattened into that cell's text.
+func (c markdownConverter) table(n *html.Node) string {
+	var rows [][]string
+	addRow := func(tr *goquery.Selection) {
+		var cells []string
+		tr.ChildrenFiltered("th, td").Each(func(i int, cell *goquery.Selection) {
+			text := strings.ReplaceAll(tidyInline(c.inlineChildren(cell.Nodes[0])), "\n", " ")
+			cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+		})
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	}
+	goquery.NewDocumentFromNode(n).ChildrenFiltered("thead, tbody, tfoot, tr").Each(func(i int, section *goquery.Selection) {
+		if goquery.NodeName(section) == "tr" {
+			addRow(section)
+			return
+		}
+		section.ChildrenFiltered("tr").Each(func(i int, tr *goquery.Selection) { addRow(tr) })
+	})
+	if len(rows) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |")
+		if i == 0 {
+			b.WriteString("\n|" + strings.Repeat(" --- |", width))
+		}
+		if i < len(rows)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// inline renders a node within a line of text
+func (c markdownConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return collapseTextSpace(n.Data)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if markdownSkipTags[n.Data] {
+		return ""
+	}
+
+	switch n.Data {
+	case "br":
+		return "\n"
+	case "a":
+		text := strings.TrimSpace(c.inlineChildren(n))
+		href := strings.TrimSpace(attr(n, "href"))
+		if text == "" || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return text
+		}
+		if c.absolute != nil {
+			if absolute := c.absolute(href); absolute != "" {
+				href = absolute
+			}
+		}
+		return "[" + text + "](" + href + ")"
+	case "strong", "b":
+		return wrapInline(c.inlineChildren(n), "**")
+	case "em", "i":
+		return wrapInline(c.inlineChildren(n), "*")
+	case "code", "kbd", "samp":
+		text := nodeText(n)
+		if strings.TrimSpace(text) == "" {
+			return ""
+		}
+		fence := "`"
+		if strings.Contains(text, "`") {
+			fence = "``"
+		}
+		return fence + text + fence
+	case "img":
+		src := strings.TrimSpace(attr(n, "src"))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return ""
+		}
+		if c.absolute != nil {
+			if absolute := c.absolute(src); absolute != "" {
+				src = absolute
+			}
+		}
+		return "![" + strings.TrimSpace(attr(n, "alt")) + "](" + src + ")"
+	}
+	// Block elements nested in inline ones, and the cells of a nested table, are
+	// flattened into the line
+	text := c.inlineChildren(n)
+	if markdownBlockTags[n.Data] || n.Data == "tr" || n.Data == "td" || n.Data == "th" {
+		text = " " + text + " "
+	}
+	return text
+}
+
+// inlineChildren renders the children of n as one line of text
+func (c markdownConverter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(c.inline(child))
+	}
+	return b.String()
+}
+
+// codeBlock renders a <pre> as a fenced code block, taking the language from a
+// language-* or lang-* class on it or its <code>
+func codeBlock(n *html.Node) string {
+	language := codeLanguage(n)
+	for child := n.FirstChild; child != nil && language == ""; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "code" {
+			language = codeLanguage(child)
+		}
+	}
+	code := strings.Trim(nodeText(n), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// codeLanguage reads the language from a language-go or lang-go style class
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if language, ok := strings.CutPrefix(class, prefix); ok {
+				return language
+			}
+		}
+	}
+	return ""
+}
+
+// wrapInline surrounds text with an emphasis marker, keeping the marker off the
+// surrounding spaces so the Markdown stays valid
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+// tidyInline trims a line of rendered inline content, collapsing the space runs
+// left between elements and the spaces around line breaks
+func tidyInline(text string) string {
+	lines := strings.Split(markdownSpaces.ReplaceAllString(text, " "), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// collapseTextSpace folds each whitespace run of a text node into a single space
+func collapseTextSpace(text string) string {
+	if strings.TrimSpace(text) == "" {
+		if text == "" {
+			return ""
+		}
+		return " "
+	}
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if strings.TrimLeft(text, " \t\r\n\f") != text {
+		collapsed = " " + collapsed
+	}
+	if strings.TrimRight(text, " \t\r\n\f") != text {
+		collapsed += " "
+	}
+	return collapsed
+}
+
+// nodeText returns the raw text under n, whitespace and all
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(nodeText(child))
+	}
+	return b.String()
+}
+
+// attr returns an attribute of n, or "" when it is not set
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// markdownOf converts the <article> of a page served with body, returning the
+// Markdown and the URL the page was served from
+func markdownOf(t *testing.T, body string) (string, string) {
+	t.Helper()
+	server := serveHTML(t, "<html><head><title>Doc</title></head><body><article>"+body+"</article></body></html>")
+	result, err := newTestService(t, Config{}).ScrapeURLAsMarkdown(context.Background(), server.URL+"/guide/", "article")
+	if err != nil {
+		t.Fatalf("ScrapeURLAsMarkdown: %v", err)
+	}
+	return result.Markdown, server.URL
+}
+
+func TestMarkdownNestedTable(t *testing.T) {
+	got, _ := markdownOf(t, `<table>
+<thead><tr><th>Name</th><th>Details</th></tr></thead>
+<tbody>
+<tr><td>Outer</td><td><table><tr><td>inner one</td></tr><tr><td>inner two</td></tr></table></td></tr>
+</tbody>
+<tfoot><tr><td>Total</td><td>1</td></tr></tfoot>
+</table>`)
+	// The inner table's rows stay in their cell rather than becoming rows of the outer table
+	want := "| Name | Details |\n| --- | --- |\n| Outer | inner one inner two |\n| Total | 1 |"
+	if got != want {
+		t.Errorf("Markdown =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestScrapeURLAsMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// want may use {{base}} for the URL the page is served from
+		want string
+	}{
+		{
+			name: "headings",
+			body: `<h1>Install</h1><p>Intro text.</p><h2>On <em>Linux</em></h2><h3>Step   one</h3>`,
+			want: "# Install\n\nIntro text.\n\n## On *Linux*\n\n### Step one",
+		},
+		{
+			name: "unordered, ordered, and nested lists",
+			body: `<ul><li>Apples</li><li>Pears<ul><li>Conference</li><li>Bosc</li></ul></li></ul>` +
+				`<ol><li>First</li><li>Second<ol><li>Nested</li></ol></li></ol>`,
+			want: "- Apples\n- Pears\n  - Conference\n  - Bosc\n\n1. First\n2. Second\n   1. Nested",
+		},
+		{
+			name: "relative links resolved",
+			body: `<p>See <a href="../setup">setup</a>, <a href="/api?x=1">the API</a>, <a href="https://go.dev/">Go</a> and <a href="#top">top</a>.</p>` +
+				`<p><img src="img/arch.png" alt="Architecture"></p>`,
+			want: "See [setup]({{base}}/setup), [the API]({{base}}/api?x=1), [Go](https://go.dev/) and top.\n\n![Architecture]({{base}}/guide/img/arch.png)",
+		},
+		{
+			name: "fenced code keeps its language",
+			body: "<pre><code class=\"language-go\">func main() {\n\tfmt.Println(\"hi\")\n}</code></pre>" +
+				`<pre class="lang-sh">go test ./...</pre><pre><code>plain</code></pre>`,
+			want: "```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\n```sh\ngo test ./...\n```\n\n```\nplain\n```",
+		},
+		{
+			name: "table",
+			body: `<table><tr><th>Flag</th><th>Default</th></tr><tr><td>--budget</td><td>none</td></tr><tr><td>a|b</td></tr></table>`,
+			want: "| Flag | Default |\n| --- | --- |\n| --budget | none |\n| a\\|b |  |",
+		},
+		{
+			name: "scripts and styles stripped",
+			body: `<p>Visible text.</p><script>var tracking = "secret";</script><style>.x { color: red }</style>` +
+				`<p>More <span>text</span><script>alert(1)</script>.</p><noscript>Enable JS</noscript>`,
+			want: "Visible text.\n\nMore text.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, base := markdownOf(t, tt.body)
+			if want := strings.ReplaceAll(tt.want, "{{base}}", base); got != want {
+				t.Errorf("Markdown =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestMarkdownOutputFormat(t *testing.T) {
+	const page = `<html><head><title>Doc</title></head><body><main><h1>Guide</h1><p>Enough words here to count as the main content of this page.</p></main></body></html>`
+	if result := scrapeHTML(t, Config{}, page); result.Markdown != "" {
+		t.Errorf("Markdown = %q without OutputFormatMarkdown", result.Markdown)
+	}
+	if result := scrapeHTML(t, Config{OutputFormat: OutputFormatMarkdown}, page); !strings.HasPrefix(result.Markdown, "# Guide") {
+		t.Errorf("Markdown = %q, want the converted main content", result.Markdown)
+	}
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"fmt"
+	"net"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// schemePrefix matches an explicit scheme such as "http:" or "mailto:", but not a
+// host followed by a port ("example.com:8080")
+var schemePrefix = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:(?:[^0-9]|$)`)
+
+// NormalizeURL turns casually typed input into a URL to scrape: surrounding
+// whitespace is trimmed and a bare domain such as "example.com/docs" gets an
+// https:// scheme. Input with an explicit scheme, including http://, is kept as
+// typed. Input that cannot be a web address (empty, containing spaces, or a bare
+// word without a domain) is rejected with an error wrapping ErrInvalidURL.
+func NormalizeURL(raw string) (string, error) {
+	url := strings.TrimSpace(raw)
+	if url == "" {
+		return "", fmt.Errorf("%w: nothing was entered", ErrInvalidURL)
+	}
+	if strings.IndexFunc(url, unicode.IsSpace) >= 0 {
+		return "", fmt.Errorf("%w %q: URLs cannot contain spaces", ErrInvalidURL, url)
+	}
+
+	switch {
+	case strings.HasPrefix(url, "//"):
+		return "https:" + url, nil
+	case strings.Contains(url, "://") || schemePrefix.MatchString(url):
+		// Explicit schemes are left to the scheme checks of the scraper
+		return url, nil
+	}
+
+	normalized := "https://" + url
+	parsed, err := neturl.Parse(normalized)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidURL, url, err)
+	}
+	if err := checkBareHost(parsed.Hostname()); err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidURL, url, err)
+	}
+	return normalized, nil
+}
+
+// checkBareHost rejects hosts of scheme-less input that are not plausibly a web
+// address: they must be a domain name with a dot, localhost, or an IP address
+func checkBareHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if net.ParseIP(host) != nil || strings.EqualFold(host, "localhost") {
+		return nil
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(labels) < 2 || strings.IndexFunc(labels[len(labels)-1], unicode.IsLetter) < 0 {
+		return fmt.Errorf("%q is not a domain name (expected e.g. example.com)", host)
+	}
+	for _, label := range labels {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("%q is not a valid domain name", host)
+		}
+		for _, r := range label {
+			if r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return fmt.Errorf("%q is not a valid domain name", host)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "bare domain", input: "example.com", want: "https://example.com"},
+		{name: "bare domain with a path", input: "example.com/docs?page=2", want: "https://example.com/docs?page=2"},
+		{name: "subdomain and port", input: "docs.example.co.uk:8443/start", want: "https://docs.example.co.uk:8443/start"},
+		{name: "trailing spaces", input: "example.com   ", want: "https://example.com"},
+		{name: "surrounding whitespace", input: "\t example.com/news \n", want: "https://example.com/news"},
+		{name: "explicit http kept", input: "http://example.com", want: "http://example.com"},
+		{name: "explicit https with spaces", input: "  https://example.com/a  ", want: "https://example.com/a"},
+		{name: "protocol-relative", input: "//cdn.example.com/file", want: "https://cdn.example.com/file"},
+		{name: "localhost", input: "localhost:8080/health", want: "https://localhost:8080/health"},
+		{name: "IP address", input: "192.168.1.10/status", want: "https://192.168.1.10/status"},
+		{name: "internationalized domain", input: "bücher.example", want: "https://bücher.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeURL(tt.input)
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizeURL(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeURLRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "empty", input: "", want: "nothing was entered"},
+		{name: "only spaces", input: "   ", want: "nothing was entered"},
+		{name: "inner spaces", input: "example .com", want: "URLs cannot contain spaces"},
+		{name: "sentence", input: "summarize the news", want: "URLs cannot contain spaces"},
+		{name: "bare word", input: "notaurl", want: "is not a domain name"},
+		{name: "numeric top-level label", input: "1.2.3/x", want: "is not a domain name"},
+		{name: "empty label", input: "example..com", want: "is not a valid domain name"},
+		{name: "leading hyphen", input: "-example.com", want: "is not a valid domain name"},
+		{name: "underscore", input: "my_site.com", want: "is not a valid domain name"},
+		{name: "missing host", input: "/just/a/path", want: "missing host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeURL(tt.input)
+			if !errors.Is(err, ErrInvalidURL) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NormalizeURL(%q) = %q, %v, want ErrInvalidURL mentioning %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// recordingTransport answers every request with page and records the URLs requested
+type recordingTransport struct {
+	page string
+
+	mu   sync.Mutex
+	urls []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.urls = append(rt.urls, req.URL.String())
+	rt.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       io.NopCloser(strings.NewReader(rt.page)),
+		Request:    req,
+	}, nil
+}
+
+func TestScrapeURLNormalizesInput(t *testing.T) {
+	transport := &recordingTransport{page: `<html><head><title>Home</title></head><body><p>Welcome.</p></body></html>`}
+	service := newTestService(t, Config{Transport: transport})
+
+	result, err := service.ScrapeURL(context.Background(), "  example.com/welcome ", "")
+	if err != nil {
+		t.Fatalf("ScrapeURL: %v", err)
+	}
+	if len(transport.urls) != 1 || transport.urls[0] != "https://example.com/welcome" {
+		t.Errorf("requested %q, want https://example.com/welcome", transport.urls)
+	}
+	if result.URL != "https://example.com/welcome" || result.Title != "Home" {
+		t.Errorf("result = %s %q, want the normalized URL", result.URL, result.Title)
+	}
+
+	if _, err := service.ScrapeURL(context.Background(), "not a url", ""); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("ScrapeURL error = %v, want ErrInvalidURL", err)
+	}
+	if len(transport.urls) != 1 {
+		t.Errorf("invalid input was fetched: %q", transport.urls)
+	}
+}
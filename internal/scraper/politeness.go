@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Politeness presets for Config.Politeness
+const (
+	// PolitenessGentle suits scraping sites you do not own: robots.txt is respected,
+	// each host gets one request at a time, and requests are 5s apart
+	PolitenessGentle = "gentle"
+	// PolitenessNormal matches the scraper's long-standing defaults
+	PolitenessNormal = "normal"
+	// PolitenessAggressive maximizes throughput for sites you own or have permission
+	// to load: no delay, high concurrency, and robots.txt is not consulted
+	PolitenessAggressive = "aggressive"
+)
+
+// politenessPreset is the bundle of settings a Config.Politeness name stands for
+type politenessPreset struct {
+	RateLimit          time.Duration
+	MaxConcurrency     int
+	PerHostConcurrency int
+	MaxRetries         int
+	RespectRobotsTxt   bool
+}
+
+// politenessPresets documents the settings behind each preset name
+var politenessPresets = map[string]politenessPreset{
+	PolitenessGentle: {
+		RateLimit:          5 * time.Second,
+		MaxConcurrency:     2,
+		PerHostConcurrency: 1,
+		MaxRetries:         2,
+		RespectRobotsTxt:   true,
+	},
+	PolitenessNormal: {
+		RateLimit:          time.Second,
+		MaxConcurrency:     DefaultMaxConcurrency,
+		PerHostConcurrency: 2,
+		MaxRetries:         3,
+	},
+	PolitenessAggressive: {
+		RateLimit:          0,
+		MaxConcurrency:     16,
+		PerHostConcurrency: 0, // unlimited
+		MaxRetries:         5,
+	},
+}
+
+// politenessNames lists the preset names for error messages
+func politenessNames() []string {
+	names := make([]string, 0, len(politenessPresets))
+	for name := range politenessPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validatePoliteness reports an unknown Config.Politeness name
+func validatePoliteness(name string) error {
+	if _, ok := politenessPresets[strings.ToLower(name)]; name != "" && !ok {
+		return fmt.Errorf("unknown Politeness %q (supported: %s)", name, strings.Join(politenessNames(), ", "))
+	}
+	return nil
+}
+
+// withPoliteness fills the fields c leaves at zero from its Politeness preset, so
+// explicitly set fields always win. RespectRobotsTxt can only be turned on by a
+// preset, since an explicit false cannot be told apart from an unset field. For
+// the same reason an explicit zero RateLimit or MaxRetries is taken as unset:
+// under gentle or normal, no delay or no retries cannot be asked for.
+func (c Config) withPoliteness() Config {
+	preset, ok := politenessPresets[strings.ToLower(c.Politeness)]
+	if !ok {
+		return c
+	}
+	if c.RateLimit == 0 {
+		c.RateLimit = preset.RateLimit
+	}
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = preset.MaxConcurrency
+	}
+	if c.PerHostConcurrency == 0 {
+		c.PerHostConcurrency = preset.PerHostConcurrency
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = preset.MaxRetries
+	}
+	c.RespectRobotsTxt = c.RespectRobotsTxt || preset.RespectRobotsTxt
+	return c
+}
@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolitenessPresets(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   politenessPreset
+	}{
+		{
+			name:   "gentle",
+			config: Config{Politeness: PolitenessGentle},
+			want:   politenessPreset{RateLimit: 5 * time.Second, MaxConcurrency: 2, PerHostConcurrency: 1, MaxRetries: 2, RespectRobotsTxt: true},
+		},
+		{
+			name:   "normal",
+			config: Config{Politeness: PolitenessNormal},
+			want:   politenessPreset{RateLimit: time.Second, MaxConcurrency: DefaultMaxConcurrency, PerHostConcurrency: 2, MaxRetries: 3},
+		},
+		{
+			name:   "aggressive",
+			config: Config{Politeness: PolitenessAggressive},
+			want:   politenessPreset{RateLimit: 0, MaxConcurrency: 16, PerHostConcurrency: 0, MaxRetries: 5},
+		},
+		{
+			name:   "case-insensitive",
+			config: Config{Politeness: "Gentle"},
+			want:   politenessPreset{RateLimit: 5 * time.Second, MaxConcurrency: 2, PerHostConcurrency: 1, MaxRetries: 2, RespectRobotsTxt: true},
+		},
+		{
+			name:   "explicit fields win",
+			config: Config{Politeness: PolitenessGentle, RateLimit: time.Second, MaxConcurrency: 8, MaxRetries: 1},
+			want:   politenessPreset{RateLimit: time.Second, MaxConcurrency: 8, PerHostConcurrency: 1, MaxRetries: 1, RespectRobotsTxt: true},
+		},
+		{
+			name:   "robots turned on explicitly",
+			config: Config{Politeness: PolitenessAggressive, RespectRobotsTxt: true},
+			want:   politenessPreset{RateLimit: 0, MaxConcurrency: 16, PerHostConcurrency: 0, MaxRetries: 5, RespectRobotsTxt: true},
+		},
+		{
+			name:   "no preset",
+			config: Config{RateLimit: 2 * time.Second},
+			want:   politenessPreset{RateLimit: 2 * time.Second, MaxConcurrency: DefaultMaxConcurrency},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestService(t, tt.config).config
+			got := politenessPreset{
+				RateLimit:          config.RateLimit,
+				MaxConcurrency:     config.MaxConcurrency,
+				PerHostConcurrency: config.PerHostConcurrency,
+				MaxRetries:         config.MaxRetries,
+				RespectRobotsTxt:   config.RespectRobotsTxt,
+			}
+			if got != tt.want {
+				t.Errorf("settings = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
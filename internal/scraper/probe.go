@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProbeResult describes a URL's response without its body
+type ProbeResult struct {
+	URL string `json:"url"`
+	// FinalURL is where redirects led; equal to URL when there were none
+	FinalURL    string `json:"final_url"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	// ContentLength is the full body size in bytes, or -1 when the server does not say
+	ContentLength int64 `json:"content_length"`
+	// Method is the request that produced the result: HEAD, or GET with a one-byte Range
+	Method string `json:"method"`
+}
+
+// IsHTML reports whether the probed resource is an HTML page
+func (p *ProbeResult) IsHTML() bool {
+	return strings.Contains(strings.ToLower(p.ContentType), "html")
+}
+
+// Probe cheaply checks a URL's status, type, and size without downloading the
+// body. It sends a HEAD request and, for servers that reject HEAD, falls back to a
+// GET for only the first byte. The URL and every redirect target must pass the
+// same checks as ScrapeURL, an allowed scheme and a non-empty host; there is no
+// SSRF guard, so private and loopback addresses are probed like any other.
+func (s *Service) Probe(ctx context.Context, url string) (*ProbeResult, error) {
+	url, err := NormalizeURL(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateURL(url); err != nil {
+		return nil, err
+	}
+
+	// Copy the client so redirects are checked without changing scraping behavior
+	client := *s.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return s.validateURL(req.URL.String())
+	}
+
+	result, err := s.probe(ctx, &client, http.MethodHead, url)
+	if err == nil && result.StatusCode != http.StatusMethodNotAllowed && result.StatusCode != http.StatusNotImplemented {
+		return result, nil
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("failed to probe URL %s: %w", url, ctx.Err())
+	}
+	s.logger.Debug().Err(err).Str("url", url).Msg("HEAD rejected; probing with a ranged GET")
+	return s.probe(ctx, &client, http.MethodGet, url)
+}
+
+// probe sends one probe request; GET requests ask for a single byte and the body is never read
+func (s *Service) probe(ctx context.Context, client *http.Client, method, url string) (*ProbeResult, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidURL, url, err)
+	}
+	if s.config.UserAgent != "" {
+		req.Header.Set("User-Agent", s.config.UserAgent)
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe URL %s: %w", url, err)
+	}
+	// Closing without reading drops the connection rather than downloading the rest
+	resp.Body.Close()
+
+	result := &ProbeResult{
+		URL:           url,
+		FinalURL:      resp.Request.URL.String(),
+		StatusCode:    resp.StatusCode,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		Method:        method,
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		result.ContentLength = contentRangeTotal(resp.Header.Get("Content-Range"))
+	}
+	return result, nil
+}
+
+// contentRangeTotal returns the complete length from a "bytes 0-0/1234" Content-Range header, or -1
+func contentRangeTotal(header string) int64 {
+	_, total, ok := strings.Cut(header, "/")
+	if !ok {
+		return -1
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
@@ -0,0 +1,170 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/retry"
+	"github.com/temoto/robotstxt"
+)
+
+// ErrDisallowedByRobots is wrapped by errors for URLs that the host's robots.txt
+// disallows when Config.RespectRobotsTxt is set
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// maxRobotsSize caps the robots.txt bytes read; Google reads at most 500KiB
+const maxRobotsSize = 512 * 1024
+
+// robotsServerErrorTTL is how long a host's server error (5xx) for robots.txt is
+// cached: the error disallows everything, so it is retried well before RobotsTTL
+// rather than blocking the host for a day over a passing outage
+const robotsServerErrorTTL = time.Minute
+
+// robotsCache holds each host's robots.txt rules for Config.RobotsTTL, and paces
+// visits to hosts that ask for a Crawl-delay
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+// robotsEntry is the robots.txt state of one host; its mutex is held while the
+// file is fetched, so concurrent scrapes of a host share a single fetch
+type robotsEntry struct {
+	mu      sync.Mutex
+	data    *robotstxt.RobotsData
+	expires time.Time
+	// nextVisit is when the host's Crawl-delay next lets a page be fetched
+	nextVisit time.Time
+}
+
+// entry returns the state of a host, creating it on first use
+func (c *robotsCache) entry(host string) *robotsEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hosts == nil {
+		c.hosts = make(map[string]*robotsEntry)
+	}
+	entry, ok := c.hosts[host]
+	if !ok {
+		entry = &robotsEntry{}
+		c.hosts[host] = entry
+	}
+	return entry
+}
+
+// checkRobots enforces the host's robots.txt for a URL when Config.RespectRobotsTxt
+// is set: a disallowed URL returns an error wrapping ErrDisallowedByRobots, and an
+// allowed one waits out the host's Crawl-delay when that is slower than RateLimit.
+// It returns the delay to keep between the host's requests.
+func (s *Service) checkRobots(ctx context.Context, rawURL string) (time.Duration, error) {
+	if !s.config.RespectRobotsTxt {
+		return s.config.RateLimit, nil
+	}
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q: %v", ErrInvalidURL, rawURL, err)
+	}
+	origin := parsed.Scheme + "://" + parsed.Host
+	entry := s.robots.entry(breakerHost(rawURL))
+
+	entry.mu.Lock()
+	if entry.data == nil || time.Now().After(entry.expires) {
+		data, serverError := s.fetchRobots(ctx, origin)
+		if ctx.Err() != nil {
+			// A cancelled fetch says nothing about the file, so it is not cached
+			entry.mu.Unlock()
+			return 0, ctx.Err()
+		}
+		ttl := s.config.RobotsTTL
+		if serverError {
+			ttl = min(ttl, robotsServerErrorTTL)
+		}
+		entry.data, entry.expires = data, time.Now().Add(ttl)
+	}
+	data := entry.data
+
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if parsed.RawQuery != "" {
+		path += "?" + parsed.RawQuery
+	}
+	if !data.TestAgent(path, s.config.UserAgent) {
+		entry.mu.Unlock()
+		return 0, fmt.Errorf("%w: %s", ErrDisallowedByRobots, rawURL)
+	}
+
+	// Crawl-delay only ever slows the scraper down
+	delay := s.config.RateLimit
+	if crawlDelay := data.FindGroup(s.config.UserAgent).CrawlDelay; crawlDelay > delay {
+		delay = crawlDelay
+	}
+	var wait time.Duration
+	if delay > s.config.RateLimit {
+		// Reserve the next slot so concurrent scrapes of the host queue up behind each other
+		now := time.Now()
+		visit := now
+		if entry.nextVisit.After(now) {
+			visit = entry.nextVisit
+		}
+		entry.nextVisit = visit.Add(delay)
+		wait = visit.Sub(now)
+	}
+	entry.mu.Unlock()
+
+	if wait > 0 {
+		s.logger.Debug().Str("url", rawURL).Dur("wait", wait).Dur("crawl_delay", delay).Msg("Honoring robots.txt Crawl-delay")
+		if err := retry.Sleep(ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+	return delay, nil
+}
+
+// fetchRobots downloads and parses an origin's robots.txt. As crawlers
+// conventionally do, a missing file (4xx) allows everything and a server error
+// (5xx) disallows everything, reported by serverError so that it is cached only
+// briefly; a file that cannot be fetched or parsed allows everything, so an
+// unreachable robots.txt does not block scraping.
+func (s *Service) fetchRobots(ctx context.Context, origin string) (data *robotstxt.RobotsData, serverError bool) {
+	allowAll, _ := robotstxt.FromBytes(nil)
+	robotsURL := origin + "/robots.txt"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return allowAll, false
+	}
+	if s.config.UserAgent != "" {
+		req.Header.Set("User-Agent", s.config.UserAgent)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("url", robotsURL).Msg("Failed to fetch robots.txt; allowing all paths")
+		return allowAll, false
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		s.logger.Warn().Err(err).Str("url", robotsURL).Msg("Failed to read robots.txt; allowing all paths")
+		return allowAll, false
+	}
+	data, err = robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("url", robotsURL).Int("status", resp.StatusCode).Msg("Failed to parse robots.txt; allowing all paths")
+		return allowAll, false
+	}
+	if resp.StatusCode >= 500 {
+		s.logger.Warn().Str("url", robotsURL).Int("status", resp.StatusCode).Dur("retry_in", robotsServerErrorTTL).Msg("robots.txt returned a server error; disallowing all paths for now")
+		return data, true
+	}
+	s.logger.Debug().Str("url", robotsURL).Int("status", resp.StatusCode).Msg("Fetched robots.txt")
+	return data, false
+}
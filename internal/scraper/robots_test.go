@@ -0,0 +1,63 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRobotsServerErrorCachedBriefly(t *testing.T) {
+	var robotsFailing atomic.Bool
+	robotsFailing.Store(true)
+	var robotsFetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			if robotsFailing.Load() {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Page</title></head><body><p>Some text.</p></body></html>`))
+	}))
+	t.Cleanup(server.Close)
+	service := newTestService(t, Config{RespectRobotsTxt: true})
+	entry := service.robots.entry(breakerHost(server.URL))
+
+	// A server error disallows everything, but only until robotsServerErrorTTL
+	if _, err := service.ScrapeURL(context.Background(), server.URL+"/page", ""); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Fatalf("ScrapeURL during the outage error = %v, want ErrDisallowedByRobots", err)
+	}
+	if expires := time.Until(entry.expires); expires > robotsServerErrorTTL {
+		t.Errorf("server error cached for %v, want at most %v", expires, robotsServerErrorTTL)
+	}
+	if _, err := service.ScrapeURL(context.Background(), server.URL+"/page", ""); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("ScrapeURL within the error TTL error = %v, want the cached disallow", err)
+	}
+	if got := robotsFetches.Load(); got != 1 {
+		t.Errorf("robots.txt fetched %d times within the error TTL, want once", got)
+	}
+
+	// Once it expires the file is fetched again, and a good one is kept for RobotsTTL
+	robotsFailing.Store(false)
+	entry.expires = time.Now().Add(-time.Second)
+	if _, err := service.ScrapeURL(context.Background(), server.URL+"/page", ""); err != nil {
+		t.Fatalf("ScrapeURL after the outage: %v", err)
+	}
+	if expires := time.Until(entry.expires); expires < DefaultRobotsTTL-time.Minute {
+		t.Errorf("robots.txt cached for %v, want about %v", expires, DefaultRobotsTTL)
+	}
+	if _, err := service.ScrapeURL(context.Background(), server.URL+"/private", ""); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("ScrapeURL(/private) error = %v, want ErrDisallowedByRobots", err)
+	}
+	if got := robotsFetches.Load(); got != 2 {
+		t.Errorf("robots.txt fetched %d times, want twice", got)
+	}
+}
@@ -2,13 +2,28 @@ package scraper
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	neturl "net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
 	"github.com/rs/zerolog"
+
+	"github.com/HeidiZHH/skull/internal/retry"
 )
 
 // Service handles web scraping operations
@@ -16,17 +31,243 @@ type Service struct {
 	config Config
 	logger zerolog.Logger
 	client *http.Client
+	robots *robotsCache
 }
 
 // Config represents scraper configuration
 type Config struct {
-	UserAgent   string
-	Timeout     time.Duration
-	MaxRetries  int
-	RateLimit   time.Duration
-	MaxBodySize int64
+	UserAgent string
+	// Timeout bounds each HTTP request; zero means DefaultTimeout
+	Timeout time.Duration
+	// MaxRetries is how many times a failed request is retried (see ShouldRetry)
+	MaxRetries int
+	RateLimit  time.Duration
+	// MaxBodySize caps the bytes read from a response body; zero means
+	// DefaultMaxBodySize. Longer bodies are cut off and parsed as-is, with
+	// Result.Truncated set, or fail with ErrBodyTooLarge under RejectOversized.
+	MaxBodySize     int64
+	RejectOversized bool
+	// AllowedSchemes lists the URL schemes ScrapeURL accepts; empty means http and https
+	AllowedSchemes []string
+	// Debug records content extraction decisions in Result.Metadata under "debug:" keys
+	Debug bool
+	// ShouldRetry classifies failed requests as retryable. It receives the failed
+	// response (nil on transport errors) and error, and returns the delay before the
+	// next attempt or a negative value to stop. Attempts are capped by MaxRetries.
+	// When unset, 5xx responses and network errors are retried with exponential
+	// backoff and jitter (or after the server's Retry-After), and anything else, such
+	// as a 404, fails at once. retry.IsRetryableStatus and retry.ParseRetryAfter cover
+	// the usual transient statuses and Retry-After headers for custom policies.
+	ShouldRetry func(resp *http.Response, err error) time.Duration
+	// AutoReferer sends a Referer header for sites that gate on it: Referer when
+	// set, otherwise the target's origin (e.g. https://example.com/)
+	AutoReferer bool
+	Referer     string
+	// MaxCrawlDuration is the wall-clock budget for a whole Crawl; zero means unbounded
+	MaxCrawlDuration time.Duration
+	// VisibleTextOnly drops elements hidden with the hidden attribute, aria-hidden="true",
+	// or an inline display:none / visibility:hidden style before extracting content.
+	// Without a CSS engine, stylesheet rules and classes like "visually-hidden" are not seen.
+	VisibleTextOnly bool
+	// Deadline is a hard limit on a single scrape. A body still streaming when it
+	// passes is cut off and parsed as-is, with Result.Partial set.
+	Deadline time.Duration
+	// Transport replaces the default HTTP transport, e.g. to route through a proxy
+	Transport http.RoundTripper
+	// DetectSoftErrors flags pages that return 200 but look like error pages
+	// (a tiny body under a "not found" style title) via Result.SoftError
+	DetectSoftErrors bool
+	// ExtractComments moves comment sections (#comments, .comments, Disqus threads)
+	// out of the main content and into Result.Comments
+	ExtractComments bool
+	// FollowPagination follows rel="next" and "Next" pagination links and joins the
+	// pages' content into one Result, reading at most MaxPages pages (zero means DefaultMaxPages)
+	FollowPagination bool
+	MaxPages         int
+	// HTTPCacheDir enables an on-disk HTTP cache that honors Cache-Control, Expires,
+	// ETag/Last-Modified revalidation, and Vary; empty disables caching
+	HTTPCacheDir string
+	// CaptureRaw keeps each fetched page's headers and body in Result.Raw, which
+	// SaveWARC needs. It is off by default since it holds whole bodies in memory.
+	CaptureRaw bool
+	// RetryEmptyContent re-fetches success responses whose extracted text is shorter
+	// than this many characters, for origins that serve a placeholder while warming
+	// up; zero disables it. Up to MaxRetries re-fetches are made, EmptyContentDelay
+	// apart (zero means DefaultEmptyContentDelay). Pages that look like JavaScript app
+	// shells are not retried, since fetching them again cannot fill them in.
+	RetryEmptyContent int
+	EmptyContentDelay time.Duration
+	// PreExtract rewrites the parsed document before anything is extracted from it,
+	// for site-specific fixes such as unwrapping AMP elements or expanding <noscript>
+	// fallbacks. It runs on every HTML page, and changes it makes to the document are
+	// seen by all extraction: title, metadata, links, and content.
+	PreExtract func(doc *goquery.Document)
+	// MergeNoscript replaces <noscript> elements with their fallback markup before
+	// extraction, recovering text and images that JS-gated pages only show there.
+	// It is off by default since the fallback often repeats the scripted content.
+	MergeNoscript bool
+	// ThreadSites enables discussion thread extraction into Result.Thread, keyed by
+	// host (e.g. "news.ycombinator.com"; a leading "www." is ignored). Other hosts
+	// are scraped as usual.
+	ThreadSites map[string]ThreadSelectors
+	// ClassifyPages labels each HTML page as an article, product, docs, or forum page
+	// in Result.PageType, from its structured data, URL, and markup. Pages the
+	// heuristics cannot place are passed to PageClassifier (e.g. an LLM, see
+	// summarizer.Service.ClassifyPage) when set, and are otherwise PageTypeOther.
+	ClassifyPages  bool
+	PageClassifier func(ctx context.Context, result *Result) (string, error)
+	// Politeness picks a bundle of rate, concurrency, retry, and robots settings:
+	// PolitenessGentle, PolitenessNormal, or PolitenessAggressive. The preset only
+	// fills fields left at zero, so explicit settings override it; empty uses none.
+	Politeness string
+	// MaxConcurrency caps the scrapes ScrapeStream and ScrapeMultiple run at once;
+	// zero means DefaultMaxConcurrency
+	MaxConcurrency int
+	// PerHostConcurrency caps how many of those scrapes hit one host at a time; zero means no per-host cap
+	PerHostConcurrency int
+	// RespectRobotsTxt checks each host's robots.txt before scraping it, failing
+	// disallowed URLs with ErrDisallowedByRobots, and honors a Crawl-delay slower
+	// than RateLimit. Each host's file is cached for RobotsTTL (zero means DefaultRobotsTTL),
+	// but a server error for it, which disallows everything, only for a minute.
+	RespectRobotsTxt bool
+	RobotsTTL        time.Duration
+	// ResolveSrcset reads the srcset of <img> and <picture> elements into
+	// Result.ResponsiveImages, adds each one's highest-resolution candidate to
+	// Result.Images, and upgrades MainImage to it when MainImage is its plain src
+	ResolveSrcset bool
+	// OutputFormat is OutputFormatText (the default when empty) or
+	// OutputFormatMarkdown, which also fills Result.Markdown from the extracted content
+	OutputFormat string
 }
 
+// Defaults applied by NewService to zero-valued Config fields
+const (
+	DefaultTimeout     = 30 * time.Second
+	DefaultMaxBodySize = 10 * 1024 * 1024 // 10MB
+
+	DefaultEmptyContentDelay = time.Second
+	DefaultMaxConcurrency    = 3
+	DefaultRobotsTTL         = 24 * time.Hour
+)
+
+// Validate checks the configuration for values that cannot work, reporting every problem found
+func (c Config) Validate() error {
+	var problems []error
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"Timeout", c.Timeout},
+		{"RateLimit", c.RateLimit},
+		{"MaxCrawlDuration", c.MaxCrawlDuration},
+		{"Deadline", c.Deadline},
+		{"EmptyContentDelay", c.EmptyContentDelay},
+		{"RobotsTTL", c.RobotsTTL},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			problems = append(problems, fmt.Errorf("%s must not be negative (got %s)", d.name, d.value))
+		}
+	}
+	if c.MaxRetries < 0 {
+		problems = append(problems, fmt.Errorf("MaxRetries must not be negative (got %d)", c.MaxRetries))
+	}
+	if c.RetryEmptyContent < 0 {
+		problems = append(problems, fmt.Errorf("RetryEmptyContent must not be negative (got %d)", c.RetryEmptyContent))
+	}
+	if c.MaxPages < 0 {
+		problems = append(problems, fmt.Errorf("MaxPages must not be negative (got %d)", c.MaxPages))
+	}
+	if c.MaxBodySize < 0 {
+		problems = append(problems, fmt.Errorf("MaxBodySize must not be negative (got %d)", c.MaxBodySize))
+	}
+	if c.MaxConcurrency < 0 {
+		problems = append(problems, fmt.Errorf("MaxConcurrency must not be negative (got %d)", c.MaxConcurrency))
+	}
+	if c.PerHostConcurrency < 0 {
+		problems = append(problems, fmt.Errorf("PerHostConcurrency must not be negative (got %d)", c.PerHostConcurrency))
+	}
+	if err := validatePoliteness(c.Politeness); err != nil {
+		problems = append(problems, err)
+	}
+	if err := validateOutputFormat(c.OutputFormat); err != nil {
+		problems = append(problems, err)
+	}
+	for host, selectors := range c.ThreadSites {
+		if strings.TrimSpace(selectors.Comment) == "" {
+			problems = append(problems, fmt.Errorf("ThreadSites[%q] needs a Comment selector", host))
+		}
+	}
+	for _, scheme := range c.AllowedSchemes {
+		if scheme == "" || strings.ContainsAny(scheme, ":/ ") {
+			problems = append(problems, fmt.Errorf("AllowedSchemes entry %q is not a bare scheme such as \"https\"", scheme))
+		}
+	}
+	if err := errors.Join(problems...); err != nil {
+		return fmt.Errorf("invalid scraper config: %w", err)
+	}
+	return nil
+}
+
+// ErrInvalidURL is wrapped by errors for URLs that are malformed or use a disallowed scheme
+var ErrInvalidURL = errors.New("invalid URL")
+
+// ErrBodyTooLarge is wrapped by errors for bodies over Config.MaxBodySize when
+// Config.RejectOversized is set
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// StatusError reports a scrape that failed because the server answered with an error status
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string { return fmt.Sprintf("HTTP %d: %v", e.StatusCode, e.Err) }
+
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// Error codes returned by ErrorCode
+const (
+	ErrorCodeInvalidURL = "invalid_url"
+	ErrorCodeHTTPStatus = "http_status"
+	ErrorCodeTimeout    = "timeout"
+	ErrorCodeCancelled  = "cancelled"
+	ErrorCodeNetwork    = "network_error"
+	// ErrorCodeHostUnavailable is returned for ErrHostUnavailable
+	ErrorCodeHostUnavailable = "host_unavailable"
+	// ErrorCodeDisallowedByRobots is returned for ErrDisallowedByRobots
+	ErrorCodeDisallowedByRobots = "disallowed_by_robots"
+	// ErrorCodeBodyTooLarge is returned for ErrBodyTooLarge
+	ErrorCodeBodyTooLarge = "body_too_large"
+)
+
+// ErrorCode classifies a ScrapeURL error into a stable machine-readable code and
+// returns the HTTP status code when the server sent one
+func ErrorCode(err error) (string, int) {
+	var statusErr *StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return ErrorCodeHTTPStatus, statusErr.StatusCode
+	case errors.Is(err, ErrInvalidURL):
+		return ErrorCodeInvalidURL, 0
+	case errors.Is(err, ErrHostUnavailable):
+		return ErrorCodeHostUnavailable, 0
+	case errors.Is(err, ErrDisallowedByRobots):
+		return ErrorCodeDisallowedByRobots, 0
+	case errors.Is(err, ErrBodyTooLarge):
+		return ErrorCodeBodyTooLarge, 0
+	case errors.Is(err, context.Canceled):
+		return ErrorCodeCancelled, 0
+	case isTimeout(err):
+		return ErrorCodeTimeout, 0
+	default:
+		return ErrorCodeNetwork, 0
+	}
+}
+
+// defaultAllowedSchemes are accepted when Config.AllowedSchemes is empty
+var defaultAllowedSchemes = []string{"http", "https"}
+
 // Result represents a scraping result
 type Result struct {
 	URL         string            `json:"url"`
@@ -38,69 +279,325 @@ type Result struct {
 	Metadata    map[string]string `json:"metadata"`
 	StatusCode  int               `json:"status_code"`
 	ContentType string            `json:"content_type"`
+	// Kind is how the content was extracted: "html", "pdf", "feed", "json", or empty
+	// for content types that are not extracted
+	Kind        string    `json:"kind"`
+	PublishedAt time.Time `json:"published_at"`
+	Author      string    `json:"author"`
+	MainImage   string    `json:"main_image"`
+	// ResponsiveImages lists the srcset candidates of the page's images when
+	// Config.ResolveSrcset is set
+	ResponsiveImages []ResponsiveImage `json:"responsive_images,omitempty"`
+	// SiteName is the publication's name, e.g. for labelling the source in a feed
+	SiteName string `json:"site_name"`
+	// FaviconURL is the site's icon, falling back to /favicon.ico when the page declares none
+	FaviconURL  string `json:"favicon_url"`
+	ContentHash string `json:"content_hash"`
+	// Partial is set whenever the content is incomplete: a deadline cut the body
+	// off, the server answered 206 Partial Content, or Truncated is set
+	Partial bool `json:"partial"`
+	// Truncated is set when the body was longer than Config.MaxBodySize and only its
+	// start was extracted, so the content may be incomplete
+	Truncated bool `json:"truncated"`
+	// Attempts is how many requests the page took, including retries
+	Attempts int `json:"attempts"`
+	// SoftError is set by Config.DetectSoftErrors for success responses that look like error pages
+	SoftError bool      `json:"soft_error"`
+	WordCount int       `json:"word_count"`
+	Outline   []Heading `json:"outline"`
+	// Sections is CleanText split at the outline's headings, for structure-aware chunking
+	Sections []Section `json:"sections"`
+	// Markdown is the extracted content with its headings, lists, links, and code
+	// blocks kept, when Config.OutputFormat is OutputFormatMarkdown or the page was
+	// scraped with ScrapeURLAsMarkdown
+	Markdown string `json:"markdown,omitempty"`
+	// Comments holds the text of each reader comment when Config.ExtractComments is set
+	Comments []string `json:"comments"`
+	// Thread is the post and nested comments of a discussion page on one of Config.ThreadSites
+	Thread *ThreadNode `json:"thread,omitempty"`
+	// PageType is one of pagetype.All when Config.ClassifyPages is set
+	PageType string `json:"page_type,omitempty"`
+	// StructuredData holds the page's JSON-LD objects, such as schema.org Article
+	// metadata; a top-level array in a script contributes each of its objects
+	StructuredData []map[string]interface{} `json:"structured_data,omitempty"`
+	// Raw holds the HTTP exchange of every page read, when Config.CaptureRaw is set
+	Raw []RawResponse `json:"-"`
+}
+
+// Heading is one h1-h6 element of a page outline
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+// Section is a heading and the text that follows it up to the next heading.
+// Text before the first heading forms a section with an empty Heading.
+type Section struct {
+	Heading string `json:"heading"`
+	Text    string `json:"text"`
+}
+
+// IsLowContent reports whether the page has fewer than minWords words of text,
+// which usually means a cookie banner, stub, or client-rendered shell
+func (r *Result) IsLowContent(minWords int) bool {
+	return r.WordCount < minWords
+}
+
+// ContentHash returns the hex SHA-256 of text after normalization. Normalization
+// collapses every run of whitespace (spaces, tabs, newlines) into a single space
+// and trims both ends, so reflowed or re-indented content hashes identically.
+// Case and punctuation are preserved.
+func ContentHash(text string) string {
+	normalized := strings.Join(strings.Fields(text), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsOlderThan reports whether the page was published more than d ago.
+// Pages without a known publish date are never considered older.
+func (r *Result) IsOlderThan(d time.Duration) bool {
+	if r.PublishedAt.IsZero() {
+		return false
+	}
+	return time.Since(r.PublishedAt) > d
 }
 
 // NewService creates a new scraper service
-func NewService(config Config, logger zerolog.Logger) *Service {
+func NewService(config Config, logger zerolog.Logger) (*Service, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config = config.withPoliteness()
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	if config.MaxConcurrency == 0 {
+		config.MaxConcurrency = DefaultMaxConcurrency
+	}
+	if config.RobotsTTL == 0 {
+		config.RobotsTTL = DefaultRobotsTTL
+	}
+	logger = logger.With().Str("component", "scraper").Logger()
+
+	// One transport per Service pools connections across concurrent scrapes; idle
+	// connections are closed after IdleConnTimeout. The per-host idle limit matches
+	// the total so concurrent calls to one host reuse connections instead of
+	// redialing (the net/http default keeps only 2 per host).
+	var transport http.RoundTripper = &http.Transport{
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     30 * time.Second,
+		DisableCompression:  false,
+	}
+	if config.Transport != nil {
+		transport = config.Transport
+	}
+	if config.HTTPCacheDir != "" {
+		transport = newCachingTransport(transport, config.HTTPCacheDir, logger)
+	}
+
 	client := &http.Client{
-		Timeout: config.Timeout,
-		Transport: &http.Transport{
-			MaxIdleConns:       10,
-			IdleConnTimeout:    30 * time.Second,
-			DisableCompression: false,
-		},
+		Timeout:   config.Timeout,
+		Transport: transport,
 	}
 
 	return &Service{
 		config: config,
-		logger: logger.With().Str("component", "scraper").Logger(),
+		logger: logger,
 		client: client,
-	}
+		robots: &robotsCache{},
+	}, nil
 }
 
-// ScrapeURL scrapes content from a single URL
+// ScrapeURL scrapes content from a single URL. HTML pages, PDFs, RSS/Atom feeds,
+// and JSON are each extracted according to their content type (see Result.Kind).
+// The URL is first put through NormalizeURL, so bare domains are fetched over https.
 func (s *Service) ScrapeURL(ctx context.Context, url string, selector string) (*Result, error) {
+	return s.scrape(ctx, url, selector, "")
+}
+
+// ScrapeURLWithReferer scrapes a single URL sending the given Referer header,
+// overriding Config.AutoReferer for this request
+func (s *Service) ScrapeURLWithReferer(ctx context.Context, url string, selector string, referer string) (*Result, error) {
+	return s.scrape(ctx, url, selector, referer)
+}
+
+// scrape implements ScrapeURL; a non-empty referer overrides the configured one
+func (s *Service) scrape(ctx context.Context, url string, selector string, referer string) (*Result, error) {
+	url, err := NormalizeURL(url)
+	if err != nil {
+		return nil, err
+	}
+	result, next, err := s.scrapePage(ctx, url, selector, referer)
+	retries := 0
+	for err == nil && s.isTransientlyEmpty(result) && retries < s.config.MaxRetries {
+		retries++
+		delay := s.config.EmptyContentDelay
+		if delay == 0 {
+			delay = DefaultEmptyContentDelay
+		}
+		s.logger.Info().Str("url", url).Int("attempt", retries).Int("content_length", len(result.CleanText)).Dur("delay", delay).Msg("Content is suspiciously empty; re-fetching")
+		if retry.Sleep(ctx, delay) != nil {
+			break
+		}
+		retried, retriedNext, retryErr := s.scrapePage(ctx, url, selector, referer)
+		if retryErr != nil {
+			s.logger.Warn().Err(retryErr).Str("url", url).Msg("Re-fetch of empty content failed; keeping first response")
+			break
+		}
+		retried.Attempts += result.Attempts
+		result, next = retried, retriedNext
+		result.Metadata["empty_content_retries"] = strconv.Itoa(retries)
+	}
+	if err != nil || !s.config.FollowPagination {
+		return result, err
+	}
+	s.followPagination(ctx, result, next, selector)
+	return result, nil
+}
+
+// isTransientlyEmpty reports whether Config.RetryEmptyContent treats result as a
+// placeholder worth fetching again: a success response with too little text that
+// is not a JavaScript app shell
+func (s *Service) isTransientlyEmpty(result *Result) bool {
+	if s.config.RetryEmptyContent <= 0 || result.Metadata["requires_js"] == "true" {
+		return false
+	}
+	if result.StatusCode < 200 || result.StatusCode >= 300 || result.StatusCode == http.StatusNoContent {
+		return false
+	}
+	return len(strings.TrimSpace(result.CleanText)) < s.config.RetryEmptyContent
+}
+
+// scrapePage scrapes a single page, also returning the URL of the article's next
+// page when Config.FollowPagination is set and one is linked
+func (s *Service) scrapePage(ctx context.Context, url string, selector string, referer string) (*Result, string, error) {
 	s.logger.Info().Str("url", url).Str("selector", selector).Msg("Starting scrape")
 
+	if err := s.validateURL(url); err != nil {
+		return nil, "", err
+	}
+	delay, err := s.checkRobots(ctx, url)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if referer == "" && s.config.AutoReferer {
+		referer = s.config.Referer
+		if referer == "" {
+			referer = originOf(url)
+		}
+		s.logger.Info().Str("url", url).Str("referer", referer).Msg("Applying auto referer")
+	}
+
+	// Bound the whole scrape so endpoints that never finish streaming cannot hang it
+	if s.config.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Deadline)
+		defer cancel()
+	}
+
 	// Create collector with configuration
 	c := colly.NewCollector(
 		colly.UserAgent(s.config.UserAgent),
+		colly.StdlibContext(ctx),
 	)
+	// checkRobots applies robots.txt with a cache shared across scrapes
+	c.IgnoreRobotsTxt = true
+	var partial, truncated atomic.Bool
+	var status atomic.Int32
+	c.WithTransport(&successStatusTransport{
+		base: &partialBodyTransport{
+			base:      s.client.Transport,
+			maxSize:   s.config.MaxBodySize,
+			reject:    s.config.RejectOversized,
+			partial:   &partial,
+			truncated: &truncated,
+		},
+		status: &status,
+	})
 
 	// Set limits
 	c.Limit(&colly.LimitRule{
 		DomainGlob:  "*",
 		Parallelism: 1,
-		Delay:       s.config.RateLimit,
+		Delay:       delay,
 	})
 
 	// Set timeout
 	c.SetRequestTimeout(s.config.Timeout)
 
+	if referer != "" {
+		c.OnRequest(func(r *colly.Request) {
+			r.Headers.Set("Referer", referer)
+		})
+	}
+
 	result := &Result{
 		URL:      url,
 		Links:    []string{},
 		Images:   []string{},
 		Metadata: make(map[string]string),
+		Outline:  []Heading{},
+		Comments: []string{},
 	}
 
-	// Handle errors
+	// Record failures; the visit below decides whether to retry them
+	succeeded := false
+	var failed *colly.Response
 	c.OnError(func(r *colly.Response, err error) {
 		s.logger.Error().Err(err).Str("url", r.Request.URL.String()).Msg("Scraping error")
+		failed = r
 	})
 
 	// Handle responses
 	c.OnResponse(func(r *colly.Response) {
+		succeeded = true
 		result.StatusCode = r.StatusCode
+		if real := int(status.Load()); real != 0 {
+			result.StatusCode = real
+		}
 		result.ContentType = r.Headers.Get("Content-Type")
 		s.logger.Debug().Int("status", r.StatusCode).Str("content-type", result.ContentType).Msg("Received response")
+		if s.config.CaptureRaw {
+			result.Raw = append(result.Raw, captureRaw(r))
+		}
+
+		// HTML is parsed by the OnHTML callback below; other kinds are extracted here
+		result.Kind = detectKind(result.ContentType, r.Body)
+		if result.Kind != KindHTML {
+			if err := s.extractDocument(result.Kind, r.Body, result); err != nil {
+				s.logger.Warn().Err(err).Str("url", url).Str("kind", result.Kind).Msg("Failed to extract document content")
+			}
+		}
 	})
 
 	// Parse HTML content
+	var nextPage string
+	appShell := false
 	c.OnHTML("html", func(e *colly.HTMLElement) {
+		// Let the caller normalize the DOM before extraction
+		if s.config.PreExtract != nil {
+			s.config.PreExtract(documentOf(e))
+		}
+
+		appShell = isAppShell(e.DOM)
+		if s.config.MergeNoscript {
+			if merged := mergeNoscript(e.DOM); merged > 0 {
+				s.logger.Debug().Int("merged", merged).Msg("Merged noscript fallbacks")
+			}
+		}
+
 		// Extract title
 		result.Title = e.ChildText("title")
 
+		if s.config.FollowPagination {
+			nextPage = findNextPage(e)
+		}
+
 		// Extract metadata
 		e.ForEach("meta", func(i int, meta *colly.HTMLElement) {
 			name := meta.Attr("name")
@@ -115,6 +612,15 @@ func (s *Service) ScrapeURL(ctx context.Context, url string, selector string) (*
 			}
 		})
 
+		// Extract schema.org metadata, which the date, byline, and page type read from
+		result.StructuredData = s.extractStructuredData(e)
+
+		// Extract publish date
+		result.PublishedAt = extractPublishedAt(e, result.Metadata, result.StructuredData)
+
+		// Extract the byline for attribution
+		result.Author = extractAuthor(e, result.Metadata, result.StructuredData)
+
 		// Extract links
 		e.ForEach("a[href]", func(i int, link *colly.HTMLElement) {
 			href := link.Attr("href")
@@ -133,26 +639,127 @@ func (s *Service) ScrapeURL(ctx context.Context, url string, selector string) (*
 			}
 		})
 
+		// Extract the heading outline in document order
+		e.ForEach("h1, h2, h3, h4, h5, h6", func(i int, h *colly.HTMLElement) {
+			text := strings.Join(strings.Fields(h.Text), " ")
+			if text != "" {
+				result.Outline = append(result.Outline, Heading{Level: int(h.Name[1] - '0'), Text: text})
+			}
+		})
+
+		// Pick the single best image for previews
+		result.MainImage = extractMainImage(e, result.Metadata)
+
+		if s.config.ResolveSrcset {
+			result.ResponsiveImages = extractResponsiveImages(e)
+			for _, image := range result.ResponsiveImages {
+				if !slices.Contains(result.Images, image.Best) {
+					result.Images = append(result.Images, image.Best)
+				}
+				if image.Src != "" && image.Src == result.MainImage {
+					result.MainImage = image.Best
+				}
+			}
+		}
+
+		// Extract the source's branding for feed cards
+		result.SiteName = extractSiteName(result.Title, result.Metadata)
+		result.FaviconURL = extractFavicon(e)
+
+		if selectors, ok := s.threadSelectorsFor(e.Request.URL.Hostname()); ok {
+			if result.Thread = extractThread(e.DOM, selectors); result.Thread != nil {
+				result.Metadata["thread_comments"] = strconv.Itoa(result.Thread.Count() - 1)
+			}
+		}
+
+		// Pull out the discussion before the main content is extracted
+		if s.config.ExtractComments {
+			result.Comments = extractComments(e.DOM)
+		}
+
+		if s.config.VisibleTextOnly {
+			if removed := removeHiddenElements(e.DOM); removed > 0 {
+				s.logger.Debug().Int("removed", removed).Msg("Dropped hidden elements")
+			}
+		}
+
 		// Extract content based on selector or default strategy
+		var content *goquery.Selection
 		if selector != "" {
 			// Use custom selector
-			result.Content = e.ChildText(selector)
+			content = e.DOM.Find(selector)
+			result.Content = content.Text()
 			result.CleanText = strings.TrimSpace(result.Content)
+			if s.config.Debug {
+				result.Metadata["debug:selector"] = "custom:" + selector
+			}
 		} else {
 			// Default content extraction strategy
-			s.extractDefaultContent(e, result)
+			content = s.extractDefaultContent(e, result)
+		}
+		if s.config.OutputFormat == OutputFormatMarkdown {
+			result.Markdown = toMarkdown(content, e.Request.AbsoluteURL)
+		}
+
+		if s.config.ClassifyPages {
+			result.PageType = classifyPage(e, result)
+			result.Metadata["page_type_source"] = "heuristic"
 		}
 	})
 
-	// Visit the URL
-	err := c.Visit(url)
+	// Visit the URL, retrying the failures the ShouldRetry policy accepts
+	c.AllowURLRevisit = true
+	attempts, failedStatus := 0, 0
+	err = retry.Do(ctx, s.retryPolicy(), func(ctx context.Context) error {
+		attempts++
+		failed, failedStatus = nil, 0
+		visitErr := c.Visit(url)
+		if visitErr == nil || succeeded {
+			return nil
+		}
+		if failed != nil {
+			failedStatus = failed.StatusCode
+		}
+		delay := s.retryDelay(failed, visitErr, attempts)
+		if delay < 0 || ctx.Err() != nil {
+			return visitErr
+		}
+		s.logger.Info().Int("attempt", attempts).Dur("delay", delay).Str("url", url).Msg("Retrying scrape")
+		return retry.After(visitErr, delay)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to scrape URL %s: %w", url, err)
+		if failedStatus >= 400 && (ctx.Err() == nil || !errors.Is(err, ctx.Err())) {
+			err = &StatusError{StatusCode: failedStatus, Err: err}
+		}
+		return nil, "", fmt.Errorf("failed to scrape URL %s: %w", url, err)
 	}
 
 	// Wait for completion
 	c.Wait()
 
+	result.ContentHash = ContentHash(result.CleanText)
+	result.WordCount = len(strings.Fields(result.CleanText))
+	result.Sections = splitSections(result.CleanText, result.Outline)
+	result.Partial = partial.Load() || result.StatusCode == http.StatusPartialContent
+	result.Truncated = truncated.Load()
+	result.Attempts = attempts
+	if result.PageType == PageTypeOther && s.config.PageClassifier != nil {
+		s.consultPageClassifier(ctx, result)
+	}
+	if appShell && result.WordCount < appShellMaxWords {
+		result.Metadata["requires_js"] = "true"
+	}
+	if result.StatusCode == http.StatusNonAuthoritativeInfo {
+		s.logger.Warn().Str("url", url).Msg("Response was modified by a proxy (203 Non-Authoritative Information)")
+	}
+	if s.config.DetectSoftErrors && isSoftError(result) {
+		result.SoftError = true
+		s.logger.Warn().Str("url", url).Str("title", result.Title).Msg("Page looks like an error page despite a success status")
+	}
+	if result.Partial {
+		s.logger.Warn().Str("url", url).Msg("Response body was cut off; returning partial content")
+	}
+
 	s.logger.Info().
 		Str("url", url).
 		Int("status", result.StatusCode).
@@ -161,11 +768,301 @@ func (s *Service) ScrapeURL(ctx context.Context, url string, selector string) (*
 		Int("images", len(result.Images)).
 		Msg("Scraping completed")
 
-	return result, nil
+	return result, nextPage, nil
 }
 
-// extractDefaultContent extracts content using a default strategy
-func (s *Service) extractDefaultContent(e *colly.HTMLElement, result *Result) {
+// DefaultMaxPages is the page limit for FollowPagination when Config.MaxPages is zero
+const DefaultMaxPages = 5
+
+// followPagination scrapes the article's following pages, starting at next, and
+// appends their content to result until no next page is linked, a page repeats,
+// or Config.MaxPages pages have been read in total
+func (s *Service) followPagination(ctx context.Context, result *Result, next string, selector string) {
+	maxPages := s.config.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	seen := map[string]bool{normalizeURL(result.URL): true}
+	pages := 1
+	referer := result.URL
+	for next != "" && pages < maxPages && !seen[normalizeURL(next)] {
+		seen[normalizeURL(next)] = true
+		page, following, err := s.scrapePage(ctx, next, selector, referer)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("url", next).Msg("Failed to scrape next page; keeping pages read so far")
+			break
+		}
+		pages++
+
+		result.Content = strings.TrimSpace(result.Content + "\n\n" + page.Content)
+		result.CleanText = strings.TrimSpace(result.CleanText + "\n\n" + page.CleanText)
+		result.Markdown = strings.TrimSpace(result.Markdown + "\n\n" + page.Markdown)
+		result.Links = append(result.Links, page.Links...)
+		result.Images = append(result.Images, page.Images...)
+		result.Outline = append(result.Outline, page.Outline...)
+		result.Comments = append(result.Comments, page.Comments...)
+		result.Raw = append(result.Raw, page.Raw...)
+		result.Partial = result.Partial || page.Partial
+		result.Truncated = result.Truncated || page.Truncated
+		referer, next = next, following
+	}
+	if pages == 1 {
+		return
+	}
+
+	result.ContentHash = ContentHash(result.CleanText)
+	result.WordCount = len(strings.Fields(result.CleanText))
+	result.Sections = splitSections(result.CleanText, result.Outline)
+	result.Metadata["pages"] = strconv.Itoa(pages)
+	s.logger.Info().Str("url", result.URL).Int("pages", pages).Msg("Followed pagination")
+}
+
+// nextPageTexts are link texts that commonly point to an article's next page
+var nextPageTexts = map[string]bool{
+	"next":       true,
+	"next page":  true,
+	"next »":     true,
+	"next ›":     true,
+	"next >":     true,
+	"next →":     true,
+	"»":          true,
+	"›":          true,
+	"continue →": true,
+}
+
+// findNextPage returns the absolute URL of the page's next page, from rel="next"
+// or a "Next" link in a pagination block, limited to the page's own host
+func findNextPage(e *colly.HTMLElement) string {
+	candidates := []string{
+		e.ChildAttr(`link[rel~="next"]`, "href"),
+		e.ChildAttr(`a[rel~="next"]`, "href"),
+	}
+	e.ForEachWithBreak(".pagination a[href], .pager a[href], .page-numbers a[href], .page-links a[href], a.next[href], a.next-page[href]", func(i int, a *colly.HTMLElement) bool {
+		text := strings.ToLower(strings.Join(strings.Fields(a.Text), " "))
+		class := " " + a.Attr("class") + " "
+		if nextPageTexts[text] || strings.Contains(class, " next ") || strings.Contains(class, " next-page ") {
+			candidates = append(candidates, a.Attr("href"))
+			return false
+		}
+		return true
+	})
+
+	for _, href := range candidates {
+		if strings.TrimSpace(href) == "" {
+			continue
+		}
+		next := e.Request.AbsoluteURL(href)
+		parsed, err := neturl.Parse(next)
+		if err != nil || parsed.Host != e.Request.URL.Host || normalizeURL(next) == normalizeURL(e.Request.URL.String()) {
+			continue
+		}
+		return next
+	}
+	return ""
+}
+
+// successStatusTransport reports 2xx responses from 203 up, such as 206 Partial
+// Content, as 200 OK, since colly fails them as errors before parsing. The real
+// status of the last response is kept in status.
+type successStatusTransport struct {
+	base   http.RoundTripper
+	status *atomic.Int32
+}
+
+func (t *successStatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.status.Store(int32(resp.StatusCode))
+	if resp.StatusCode >= 203 && resp.StatusCode < 300 {
+		resp.StatusCode = http.StatusOK
+	}
+	return resp, nil
+}
+
+// partialBodyTransport wraps response bodies so that a read interrupted by a
+// timeout, or one exceeding maxSize, ends cleanly with the bytes read so far.
+// With reject set, bodies exceeding maxSize fail with ErrBodyTooLarge instead.
+type partialBodyTransport struct {
+	base      http.RoundTripper
+	maxSize   int64
+	reject    bool
+	partial   *atomic.Bool
+	truncated *atomic.Bool
+}
+
+func (t *partialBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// A declared length over the cap is refused before any of the body is read
+	if t.reject && t.maxSize > 0 && resp.ContentLength > t.maxSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrBodyTooLarge, resp.ContentLength, t.maxSize)
+	}
+	resp.Body = &partialBody{body: resp.Body, remaining: t.maxSize, reject: t.reject, partial: t.partial, truncated: t.truncated}
+	return resp, nil
+}
+
+// partialBody reports io.EOF instead of a timeout error, and stops after remaining
+// bytes when remaining is positive, flagging partial in both cases. A body that
+// goes on past the limit also flags truncated, or fails with ErrBodyTooLarge when
+// reject is set.
+type partialBody struct {
+	body      io.ReadCloser
+	remaining int64
+	reject    bool
+	partial   *atomic.Bool
+	truncated *atomic.Bool
+	// done is what every read reports once the limit is reached: io.EOF, or the
+	// ErrBodyTooLarge of a rejected body, for readers that read past the end or
+	// drop an error, as bufio.Reader.Peek does
+	done error
+}
+
+func (b *partialBody) Read(p []byte) (int, error) {
+	if b.done != nil {
+		return 0, b.done
+	}
+	limited := b.remaining > 0
+	if limited && int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.body.Read(p)
+	if limited {
+		b.remaining -= int64(n)
+		if b.remaining <= 0 && err == nil {
+			b.done = io.EOF
+			// A body exactly at the limit is complete; only one that goes on is cut off
+			var next [1]byte
+			if more, _ := io.ReadFull(b.body, next[:]); more == 0 {
+				return n, io.EOF
+			}
+			if b.reject {
+				b.done = fmt.Errorf("%w: body exceeds the byte limit", ErrBodyTooLarge)
+				return n, b.done
+			}
+			b.partial.Store(true)
+			if b.truncated != nil {
+				b.truncated.Store(true)
+			}
+			return n, io.EOF
+		}
+	}
+	if err != nil && err != io.EOF && isTimeout(err) {
+		b.partial.Store(true)
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func (b *partialBody) Close() error { return b.body.Close() }
+
+// isTimeout reports whether err came from a deadline rather than a broken connection
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// originOf returns the scheme and host of a URL as a root URL, e.g. https://example.com/
+func originOf(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host + "/"
+}
+
+// retryDelay applies Config.ShouldRetry, or defaultRetryDelay when it is unset, to
+// the given retry (1 for the first) of a failed request
+func (s *Service) retryDelay(r *colly.Response, err error, attempt int) time.Duration {
+	var resp *http.Response
+	if r != nil && r.StatusCode != 0 {
+		resp = &http.Response{StatusCode: r.StatusCode}
+		if r.Headers != nil {
+			resp.Header = *r.Headers
+		}
+	}
+	if s.config.ShouldRetry == nil {
+		return defaultRetryDelay(resp, err, attempt)
+	}
+	return s.config.ShouldRetry(resp, err)
+}
+
+// retryPolicy allows MaxRetries retries of a request, each after the delay its
+// attempt wrapped with retry.After; failures returned without one are final
+func (s *Service) retryPolicy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts: s.config.MaxRetries + 1,
+		Retryable: func(err error) bool {
+			_, ok := retry.RequestedDelay(err)
+			return ok
+		},
+	}
+}
+
+// defaultRetryBackoff spaces default retries 500ms, 1s, 2s, ... apart, each
+// shortened by up to half so that scrapes failing together spread out
+var defaultRetryBackoff = retry.Policy{Jitter: 0.5}
+
+// defaultRetryDelay retries 5xx responses and network errors with exponential
+// backoff, honoring a Retry-After header; other failures are not retried
+func defaultRetryDelay(resp *http.Response, err error, attempt int) time.Duration {
+	switch {
+	case resp == nil:
+		if errors.Is(err, ErrInvalidURL) || errors.Is(err, ErrBodyTooLarge) || errors.Is(err, context.Canceled) {
+			return -1
+		}
+	case resp.StatusCode >= 500:
+		if after, ok := retry.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return min(after, retry.DefaultMaxDelay)
+		}
+	default:
+		return -1
+	}
+	return defaultRetryBackoff.Delay(attempt)
+}
+
+// validateURL rejects URLs that are malformed, lack a host, or use a scheme that is not allowed
+func (s *Service) validateURL(rawURL string) error {
+	parsed, err := neturl.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidURL, rawURL, err)
+	}
+	if parsed.Scheme == "" {
+		return fmt.Errorf("%w %q: missing scheme (expected e.g. https://)", ErrInvalidURL, rawURL)
+	}
+
+	allowed := s.config.AllowedSchemes
+	if len(allowed) == 0 {
+		allowed = defaultAllowedSchemes
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	permitted := false
+	for _, a := range allowed {
+		if strings.EqualFold(a, scheme) {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		return fmt.Errorf("%w %q: scheme %q is not allowed (allowed: %s)", ErrInvalidURL, rawURL, scheme, strings.Join(allowed, ", "))
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%w %q: missing host", ErrInvalidURL, rawURL)
+	}
+	return nil
+}
+
+// extractDefaultContent extracts content using a default strategy, returning the
+// elements the content was taken from
+func (s *Service) extractDefaultContent(e *colly.HTMLElement, result *Result) *goquery.Selection {
 	// Priority selectors for main content
 	contentSelectors := []string{
 		"main",
@@ -180,12 +1077,20 @@ func (s *Service) extractDefaultContent(e *colly.HTMLElement, result *Result) {
 	}
 
 	// Try each selector to find main content
+	var candidates []string
 	for _, selector := range contentSelectors {
 		content := e.ChildText(selector)
+		if s.config.Debug {
+			candidates = append(candidates, fmt.Sprintf("%s=%d", selector, len(strings.TrimSpace(content))))
+		}
 		if content != "" && len(strings.TrimSpace(content)) > 100 {
 			result.Content = content
 			result.CleanText = s.cleanText(content)
-			return
+			if s.config.Debug {
+				result.Metadata["debug:selector"] = selector
+				result.Metadata["debug:candidates"] = strings.Join(candidates, ",")
+			}
+			return e.DOM.Find(selector)
 		}
 	}
 
@@ -200,13 +1105,508 @@ func (s *Service) extractDefaultContent(e *colly.HTMLElement, result *Result) {
 	doc := e.DOM
 	bodyContent := doc.Find("body").Clone()
 
+	var removed []string
 	for _, excludeSelector := range excludeSelectors {
-		bodyContent.Find(excludeSelector).Remove()
+		matches := bodyContent.Find(excludeSelector)
+		if s.config.Debug && matches.Length() > 0 {
+			removed = append(removed, fmt.Sprintf("%s=%d", excludeSelector, len(strings.TrimSpace(matches.Text()))))
+		}
+		matches.Remove()
 	}
 
 	content := bodyContent.Text()
 	result.Content = content
 	result.CleanText = s.cleanText(content)
+	if s.config.Debug {
+		result.Metadata["debug:selector"] = "body-fallback"
+		result.Metadata["debug:candidates"] = strings.Join(candidates, ",")
+		result.Metadata["debug:excluded"] = strings.Join(removed, ",")
+	}
+	return bodyContent
+}
+
+// minMainImageSize is the smallest declared width or height accepted for a main image,
+// which filters out icons and tracking pixels
+const minMainImageSize = 100
+
+// extractMainImage picks og:image, then the largest in-content <img>, then twitter:image
+func extractMainImage(e *colly.HTMLElement, metadata map[string]string) string {
+	if image := strings.TrimSpace(metadata["og:image"]); image != "" {
+		return e.Request.AbsoluteURL(image)
+	}
+
+	images := e.DOM.Find("main img[src], article img[src], [role=main] img[src]")
+	if images.Length() == 0 {
+		images = e.DOM.Find("body img[src]")
+	}
+	best, bestArea := "", -1
+	images.Each(func(i int, img *goquery.Selection) {
+		src := strings.TrimSpace(img.AttrOr("src", ""))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		width, _ := strconv.Atoi(strings.TrimSuffix(img.AttrOr("width", ""), "px"))
+		height, _ := strconv.Atoi(strings.TrimSuffix(img.AttrOr("height", ""), "px"))
+		if (width > 0 && width < minMainImageSize) || (height > 0 && height < minMainImageSize) {
+			return
+		}
+		if area := width * height; area > bestArea {
+			best, bestArea = src, area
+		}
+	})
+	if best != "" {
+		return e.Request.AbsoluteURL(best)
+	}
+
+	for _, key := range []string{"twitter:image", "twitter:image:src"} {
+		if image := strings.TrimSpace(metadata[key]); image != "" {
+			return e.Request.AbsoluteURL(image)
+		}
+	}
+	return ""
+}
+
+// siteNameSeparators split page titles like "Article | Site" into the article and site name
+var siteNameSeparators = []string{" | ", " – ", " — ", " - ", " · ", " :: ", " » "}
+
+// extractSiteName picks og:site_name, then application-name, then the trailing
+// part of a title such as "Story headline | Example News"
+func extractSiteName(title string, metadata map[string]string) string {
+	for _, key := range []string{"og:site_name", "application-name"} {
+		if name := strings.TrimSpace(metadata[key]); name != "" {
+			return name
+		}
+	}
+
+	title = strings.TrimSpace(title)
+	for _, sep := range siteNameSeparators {
+		if i := strings.LastIndex(title, sep); i > 0 {
+			if name := strings.TrimSpace(title[i+len(sep):]); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// extractFavicon picks the first rel=icon link, then an apple-touch-icon, then
+// falls back to /favicon.ico on the page's origin
+func extractFavicon(e *colly.HTMLElement) string {
+	var icon, touchIcon string
+	e.ForEach("link[rel][href]", func(i int, link *colly.HTMLElement) {
+		href := strings.TrimSpace(link.Attr("href"))
+		if href == "" || strings.HasPrefix(href, "data:") {
+			return
+		}
+		for _, rel := range strings.Fields(strings.ToLower(link.Attr("rel"))) {
+			switch {
+			case rel == "icon" && icon == "":
+				icon = href
+			case strings.HasPrefix(rel, "apple-touch-icon") && touchIcon == "":
+				touchIcon = href
+			}
+		}
+	})
+
+	switch {
+	case icon != "":
+		return e.Request.AbsoluteURL(icon)
+	case touchIcon != "":
+		return e.Request.AbsoluteURL(touchIcon)
+	default:
+		return e.Request.AbsoluteURL("/favicon.ico")
+	}
+}
+
+// commentContainerSelectors match the sections holding a page's reader comments
+var commentContainerSelectors = []string{
+	"#comments",
+	".comments",
+	"#disqus_thread",
+	".comment-list",
+	".commentlist",
+	"#respond",
+	"[data-comments]",
+}
+
+// commentItemSelector matches individual comments inside a comment container
+const commentItemSelector = `.comment, [itemprop="comment"], .comment-item, li.review`
+
+// commentBodySelector matches the text of a comment, excluding its author and reply links
+const commentBodySelector = `.comment-body, .comment-content, .comment-text, [itemprop="text"]`
+
+// extractComments returns the text of each comment on the page and removes the
+// comment containers from doc so they stay out of the main content. A container
+// without recognizable individual comments is returned as a single entry.
+func extractComments(doc *goquery.Selection) []string {
+	comments := []string{}
+	containers := doc.Find(strings.Join(commentContainerSelectors, ", "))
+	// Skip containers nested in another container so their comments are not collected twice
+	containers = containers.FilterFunction(func(i int, container *goquery.Selection) bool {
+		return container.ParentsFiltered(strings.Join(commentContainerSelectors, ", ")).Length() == 0
+	})
+
+	containers.Each(func(i int, container *goquery.Selection) {
+		items := container.Find(commentItemSelector)
+		if items.Length() == 0 {
+			if text := strings.Join(strings.Fields(container.Text()), " "); text != "" {
+				comments = append(comments, text)
+			}
+			return
+		}
+		items.Each(func(j int, item *goquery.Selection) {
+			// Threaded replies are separate items; drop them from their parent's text
+			body := item.Clone()
+			body.Find(commentItemSelector).Remove()
+			if own := body.Find(commentBodySelector); own.Length() > 0 {
+				body = own.First()
+			}
+			if text := strings.Join(strings.Fields(body.Text()), " "); text != "" {
+				comments = append(comments, text)
+			}
+		})
+	})
+	containers.Remove()
+	return comments
+}
+
+// removeHiddenElements removes elements hidden by attribute or inline style and returns how many were removed
+func removeHiddenElements(doc *goquery.Selection) int {
+	hidden := doc.Find("[hidden], [aria-hidden=true], [style]").FilterFunction(func(i int, el *goquery.Selection) bool {
+		if _, ok := el.Attr("hidden"); ok {
+			return true
+		}
+		if strings.EqualFold(el.AttrOr("aria-hidden", ""), "true") {
+			return true
+		}
+		style := strings.ToLower(strings.ReplaceAll(el.AttrOr("style", ""), " ", ""))
+		return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+	})
+	count := hidden.Length()
+	hidden.Remove()
+	return count
+}
+
+// publishedDateLayouts lists the date formats commonly found in page metadata
+var publishedDateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+}
+
+// extractPublishedAt finds the publish date from meta tags, JSON-LD, then <time> elements
+func extractPublishedAt(e *colly.HTMLElement, metadata map[string]string, structuredData []map[string]interface{}) time.Time {
+	var candidates []string
+	for _, key := range []string{"article:published_time", "og:published_time", "date", "pubdate", "publishdate", "dc.date"} {
+		if value, ok := metadata[key]; ok {
+			candidates = append(candidates, value)
+		}
+	}
+	candidates = append(candidates, findJSONLDStrings(structuredData, "datePublished")...)
+	e.ForEach("time[datetime]", func(i int, t *colly.HTMLElement) {
+		candidates = append(candidates, t.Attr("datetime"))
+	})
+
+	for _, candidate := range candidates {
+		if t, ok := parseDate(candidate); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// bylineSelectors are common elements holding an article's byline, most specific first
+var bylineSelectors = []string{
+	`[itemprop="author"] [itemprop="name"]`,
+	`[itemprop="author"]`,
+	".byline-author",
+	".byline",
+	".article-author",
+	".post-author",
+	".entry-author",
+	".author-name",
+	".author",
+}
+
+// extractAuthor finds the article author from JSON-LD, meta tags, rel=author
+// links, then common byline elements, returning "" when none is found
+func extractAuthor(e *colly.HTMLElement, metadata map[string]string, structuredData []map[string]interface{}) string {
+	if authors := findJSONLDAuthors(structuredData); len(authors) > 0 {
+		return strings.Join(authors, ", ")
+	}
+	for _, key := range []string{"author", "article:author"} {
+		// article:author is often a profile URL rather than a name
+		if value := strings.TrimSpace(metadata[key]); value != "" && !strings.HasPrefix(value, "http") {
+			return value
+		}
+	}
+	if author := cleanByline(e.ChildText(`a[rel~="author"]`)); author != "" {
+		return author
+	}
+	for _, selector := range bylineSelectors {
+		if author := cleanByline(e.DOM.Find(selector).First().Text()); author != "" {
+			return author
+		}
+	}
+	return ""
+}
+
+// maxBylineLength rejects byline candidates that are really author bio blocks
+const maxBylineLength = 100
+
+// cleanByline normalizes byline text, dropping a leading "By"
+func cleanByline(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > 3 && strings.EqualFold(text[:3], "by ") {
+		text = strings.TrimSpace(text[3:])
+	}
+	if len(text) > maxBylineLength {
+		return ""
+	}
+	return text
+}
+
+// findJSONLDAuthors returns the author names of the first JSON-LD "author" field,
+// which may be a name, a Person or Organization object, or a list of either
+func findJSONLDAuthors(objects []map[string]interface{}) []string {
+	var names []string
+	var collect func(author interface{})
+	collect = func(author interface{}) {
+		switch v := author.(type) {
+		case string:
+			if name := strings.TrimSpace(v); name != "" {
+				names = append(names, name)
+			}
+		case map[string]interface{}:
+			collect(v["name"])
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		}
+	}
+
+	var walk func(node interface{}) bool
+	walk = func(node interface{}) bool {
+		switch v := node.(type) {
+		case []interface{}:
+			for _, item := range v {
+				if walk(item) {
+					return true
+				}
+			}
+		case map[string]interface{}:
+			if author, ok := v["author"]; ok {
+				collect(author)
+				if len(names) > 0 {
+					return true
+				}
+			}
+			for _, child := range v {
+				if walk(child) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, object := range objects {
+		if walk(object) {
+			break
+		}
+	}
+	return names
+}
+
+// parseDate parses a date string using the known publish date layouts
+func parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range publishedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// extractStructuredData decodes the page's JSON-LD scripts into objects. Blocks
+// that are not valid JSON are skipped with a debug log, as are non-object values.
+func (s *Service) extractStructuredData(e *colly.HTMLElement) []map[string]interface{} {
+	var objects []map[string]interface{}
+	e.ForEach(`script[type="application/ld+json"]`, func(i int, script *colly.HTMLElement) {
+		var node interface{}
+		if err := json.Unmarshal([]byte(script.Text), &node); err != nil {
+			s.logger.Debug().Err(err).Str("url", e.Request.URL.String()).Int("block", i).Msg("Skipping invalid JSON-LD block")
+			return
+		}
+		switch v := node.(type) {
+		case map[string]interface{}:
+			objects = append(objects, v)
+		case []interface{}:
+			for _, item := range v {
+				if object, ok := item.(map[string]interface{}); ok {
+					objects = append(objects, object)
+				}
+			}
+		}
+	})
+	return objects
+}
+
+// findJSONLDStrings walks decoded JSON-LD (including @graph arrays) and returns
+// the string values stored under key
+func findJSONLDStrings(objects []map[string]interface{}, key string) []string {
+	var values []string
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch v := node.(type) {
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]interface{}:
+			if value, ok := v[key].(string); ok {
+				values = append(values, value)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	for _, object := range objects {
+		walk(object)
+	}
+	return values
+}
+
+// appShellMaxWords is the most words a page may have and still be reported as a
+// JavaScript app shell; larger pages have content without running scripts
+const appShellMaxWords = 50
+
+// appShellRoots match the mount points of client-rendered apps
+const appShellRoots = "#root, #app, #__next, #__nuxt, [data-reactroot], [ng-app], app-root"
+
+// mergeNoscript replaces each <noscript> element with its contents parsed as HTML;
+// the parser keeps them as raw text, as browsers with scripting enabled do. It
+// returns the number of elements merged.
+func mergeNoscript(doc *goquery.Selection) int {
+	noscripts := doc.Find("noscript")
+	noscripts.Each(func(i int, noscript *goquery.Selection) {
+		noscript.ReplaceWithHtml(noscript.Text())
+	})
+	return noscripts.Length()
+}
+
+// documentOf returns the whole parsed document an element belongs to, sharing its nodes
+func documentOf(e *colly.HTMLElement) *goquery.Document {
+	root := e.DOM.Nodes[0]
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	doc := goquery.NewDocumentFromNode(root)
+	doc.Url = e.Request.URL
+	return doc
+}
+
+// isAppShell reports whether a page relies on JavaScript to render its content:
+// its noscript text asks for JavaScript, or it has an empty app mount point and
+// external scripts to fill it
+func isAppShell(doc *goquery.Selection) bool {
+	if strings.Contains(strings.ToLower(doc.Find("noscript").Text()), "javascript") {
+		return true
+	}
+	if doc.Find("script[src]").Length() == 0 {
+		return false
+	}
+	empty := false
+	doc.Find(appShellRoots).EachWithBreak(func(i int, root *goquery.Selection) bool {
+		empty = strings.TrimSpace(root.Text()) == ""
+		return !empty
+	})
+	return empty
+}
+
+// softErrorMaxWords is the largest page isSoftError considers an error page
+const softErrorMaxWords = 150
+
+// softErrorTitle matches titles and headings typical of error pages
+var softErrorTitle = regexp.MustCompile(`(?i)\b(404|not found|page (does not|doesn't|could not|couldn't) (exist|be found)|no longer (exists|available))\b`)
+
+// isSoftError reports whether a success response looks like an error page: a
+// tiny body under a title or first heading such as "Page Not Found"
+func isSoftError(result *Result) bool {
+	if result.WordCount > softErrorMaxWords {
+		return false
+	}
+	if softErrorTitle.MatchString(result.Title) {
+		return true
+	}
+	return len(result.Outline) > 0 && softErrorTitle.MatchString(result.Outline[0].Text)
+}
+
+// splitSections splits text at the outline's headings, located in document order.
+// Headings that cannot be found in the text (e.g. outside the extracted content)
+// are skipped; text without any located heading becomes a single section.
+func splitSections(text string, outline []Heading) []Section {
+	sections := []Section{}
+	if strings.TrimSpace(text) == "" {
+		return sections
+	}
+
+	type boundary struct {
+		heading    string
+		start, end int
+	}
+	var boundaries []boundary
+	pos := 0
+	for _, heading := range outline {
+		// An empty heading would match anywhere and split nothing useful
+		if heading.Text == "" {
+			continue
+		}
+		// Prefer the heading on a line of its own over a mention inside a paragraph
+		start := -1
+		if strings.HasPrefix(text[pos:], heading.Text+"\n") || text[pos:] == heading.Text {
+			start = pos
+		} else if i := strings.Index(text[pos:], "\n"+heading.Text); i >= 0 {
+			start = pos + i + 1
+		} else if i := strings.Index(text[pos:], heading.Text); i >= 0 {
+			start = pos + i
+		}
+		if start < 0 {
+			continue
+		}
+		boundaries = append(boundaries, boundary{heading: heading.Text, start: start, end: start + len(heading.Text)})
+		pos = start + len(heading.Text)
+	}
+
+	if len(boundaries) == 0 || strings.TrimSpace(text[:boundaries[0].start]) != "" {
+		end := len(text)
+		if len(boundaries) > 0 {
+			end = boundaries[0].start
+		}
+		sections = append(sections, Section{Text: strings.TrimSpace(text[:end])})
+	}
+	for i, b := range boundaries {
+		end := len(text)
+		if i+1 < len(boundaries) {
+			end = boundaries[i+1].start
+		}
+		sections = append(sections, Section{Heading: b.heading, Text: strings.TrimSpace(text[b.end:end])})
+	}
+	return sections
 }
 
 // cleanText cleans and normalizes extracted text
@@ -225,36 +1625,246 @@ func (s *Service) cleanText(text string) string {
 	return strings.Join(cleanLines, "\n")
 }
 
-// ScrapeMultiple scrapes multiple URLs concurrently
-func (s *Service) ScrapeMultiple(ctx context.Context, urls []string, selector string) ([]*Result, error) {
-	results := make([]*Result, len(urls))
-	errChan := make(chan error, len(urls))
+// VisitedSet tracks URLs a crawler has already visited or queued
+type VisitedSet interface {
+	Visited(url string) bool
+	MarkVisited(url string)
+}
+
+// memoryVisitedSet is an in-memory VisitedSet safe for concurrent use
+type memoryVisitedSet struct {
+	mu   sync.Mutex
+	urls map[string]struct{}
+}
+
+// NewVisitedSet creates an empty in-memory VisitedSet
+func NewVisitedSet() VisitedSet {
+	return &memoryVisitedSet{urls: make(map[string]struct{})}
+}
+
+func (m *memoryVisitedSet) Visited(url string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.urls[url]
+	return ok
+}
+
+func (m *memoryVisitedSet) MarkVisited(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.urls[url] = struct{}{}
+}
+
+// ScrapeWithFrontier scrapes a page and returns it together with the normalized,
+// deduplicated same-host links that have not been seen yet. The page and every
+// returned link are marked in seen, so repeated calls never hand out a URL twice.
+// A nil seen set only deduplicates within this page.
+func (s *Service) ScrapeWithFrontier(ctx context.Context, url string, seen VisitedSet) (*Result, []string, error) {
+	if seen == nil {
+		seen = NewVisitedSet()
+	}
+
+	result, err := s.ScrapeURL(ctx, url, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base, err := neturl.Parse(normalizeURL(url))
+	if err != nil {
+		return result, nil, fmt.Errorf("invalid URL %q: %w", url, err)
+	}
+	seen.MarkVisited(base.String())
+
+	frontier := []string{}
+	for _, link := range result.Links {
+		normalized := normalizeURL(link)
+		parsed, err := neturl.Parse(normalized)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			continue
+		}
+		if !strings.EqualFold(parsed.Hostname(), base.Hostname()) || seen.Visited(normalized) {
+			continue
+		}
+		seen.MarkVisited(normalized)
+		frontier = append(frontier, normalized)
+	}
+
+	s.logger.Debug().Str("url", url).Int("links", len(result.Links)).Int("frontier", len(frontier)).Msg("Built crawl frontier")
+	return result, frontier, nil
+}
 
-	// Create a semaphore to limit concurrent requests
-	semaphore := make(chan struct{}, 3) // Max 3 concurrent requests
+// CrawlOptions bounds a Crawl; zero values mean no limit
+type CrawlOptions struct {
+	MaxPages int
+	MaxDepth int
+}
+
+// CrawlResult holds the pages gathered by a Crawl
+type CrawlResult struct {
+	Pages []*Result `json:"pages"`
+	// BudgetExceeded reports that Config.MaxCrawlDuration expired before the crawl finished
+	BudgetExceeded bool `json:"budget_exceeded"`
+}
 
+// Crawl scrapes startURL and then its same-host links breadth first, within the
+// page and depth limits of opts. When Config.MaxCrawlDuration expires the pages
+// gathered so far are returned without error and BudgetExceeded is set. Pages that
+// fail to scrape are logged and skipped.
+func (s *Service) Crawl(ctx context.Context, startURL string, opts CrawlOptions) (*CrawlResult, error) {
+	crawlCtx := ctx
+	if s.config.MaxCrawlDuration > 0 {
+		var cancel context.CancelFunc
+		crawlCtx, cancel = context.WithTimeout(ctx, s.config.MaxCrawlDuration)
+		defer cancel()
+	}
+
+	type queued struct {
+		url   string
+		depth int
+	}
+	crawl := &CrawlResult{Pages: []*Result{}}
+	seen := NewVisitedSet()
+	queue := []queued{{url: startURL}}
+
+	for len(queue) > 0 {
+		if opts.MaxPages > 0 && len(crawl.Pages) >= opts.MaxPages {
+			break
+		}
+		if crawlCtx.Err() != nil {
+			break
+		}
+		next := queue[0]
+		queue = queue[1:]
+
+		result, links, err := s.ScrapeWithFrontier(crawlCtx, next.url, seen)
+		if err != nil {
+			if crawlCtx.Err() != nil {
+				break
+			}
+			s.logger.Warn().Err(err).Str("url", next.url).Msg("Skipping page that failed to crawl")
+			continue
+		}
+		crawl.Pages = append(crawl.Pages, result)
+
+		if opts.MaxDepth > 0 && next.depth >= opts.MaxDepth {
+			continue
+		}
+		for _, link := range links {
+			queue = append(queue, queued{url: link, depth: next.depth + 1})
+		}
+	}
+
+	if ctx.Err() != nil {
+		return crawl, ctx.Err()
+	}
+	if errors.Is(crawlCtx.Err(), context.DeadlineExceeded) {
+		crawl.BudgetExceeded = true
+		s.logger.Info().Dur("budget", s.config.MaxCrawlDuration).Int("pages", len(crawl.Pages)).Msg("Crawl budget exhausted")
+	}
+
+	s.logger.Info().Str("url", startURL).Int("pages", len(crawl.Pages)).Msg("Crawl completed")
+	return crawl, nil
+}
+
+// normalizeURL canonicalizes a URL for deduplication: lowercase scheme and host,
+// no fragment, no default port, and "/" for an empty path. Unparseable input is
+// returned unchanged.
+func normalizeURL(rawURL string) string {
+	parsed, err := neturl.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port := parsed.Port(); port != "" && !(parsed.Scheme == "http" && port == "80") && !(parsed.Scheme == "https" && port == "443") {
+		host = host + ":" + port
+	}
+	parsed.Host = host
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	return parsed.String()
+}
+
+// ScrapeOutcome is the result of one URL in a streamed batch, tagged with its input index
+type ScrapeOutcome struct {
+	Index  int
+	URL    string
+	Result *Result
+	Err    error
+}
+
+// ScrapeStream scrapes the URLs concurrently and delivers each outcome on the
+// returned channel as soon as it finishes. The channel is closed after every URL
+// has produced exactly one outcome.
+func (s *Service) ScrapeStream(ctx context.Context, urls []string, selector string) <-chan ScrapeOutcome {
+	outcomes := make(chan ScrapeOutcome, len(urls))
+
+	// Create semaphores to limit concurrent requests, overall and per host
+	semaphore := make(chan struct{}, s.config.MaxConcurrency)
+	hostSlots := make(map[string]chan struct{})
+	if s.config.PerHostConcurrency > 0 {
+		for _, u := range urls {
+			if host := breakerHost(u); hostSlots[host] == nil {
+				hostSlots[host] = make(chan struct{}, s.config.PerHostConcurrency)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
 	for i, url := range urls {
+		wg.Add(1)
 		go func(index int, u string) {
-			semaphore <- struct{}{}        // Acquire
-			defer func() { <-semaphore }() // Release
+			defer wg.Done()
 
-			result, err := s.ScrapeURL(ctx, u, selector)
-			if err != nil {
-				errChan <- fmt.Errorf("failed to scrape %s: %w", u, err)
+			// Take the host's slot first so waiting on a busy host never holds an overall slot
+			if slots := hostSlots[breakerHost(u)]; slots != nil {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					outcomes <- ScrapeOutcome{Index: index, URL: u, Err: ctx.Err()}
+					return
+				}
+				defer func() { <-slots }()
+			}
+
+			select {
+			case semaphore <- struct{}{}: // Acquire
+			case <-ctx.Done():
+				outcomes <- ScrapeOutcome{Index: index, URL: u, Err: ctx.Err()}
 				return
 			}
+			defer func() { <-semaphore }() // Release
 
-			results[index] = result
-			errChan <- nil
+			result, err := s.ScrapeURL(ctx, u, selector)
+			outcomes <- ScrapeOutcome{Index: index, URL: u, Result: result, Err: err}
 		}(i, url)
 	}
 
-	// Wait for all to complete
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	return outcomes
+}
+
+// ScrapeMultiple scrapes multiple URLs concurrently
+func (s *Service) ScrapeMultiple(ctx context.Context, urls []string, selector string) ([]*Result, error) {
+	results := make([]*Result, len(urls))
+
 	var errors []error
-	for i := 0; i < len(urls); i++ {
-		if err := <-errChan; err != nil {
-			errors = append(errors, err)
+	for outcome := range s.ScrapeStream(ctx, urls, selector) {
+		if outcome.Err != nil {
+			errors = append(errors, fmt.Errorf("failed to scrape %s: %w", outcome.URL, outcome.Err))
+			continue
 		}
+		results[outcome.Index] = outcome.Result
 	}
 
 	if len(errors) > 0 {
@@ -263,3 +1873,39 @@ func (s *Service) ScrapeMultiple(ctx context.Context, urls []string, selector st
 
 	return results, nil
 }
+
+// ScrapeMultiplePartial scrapes the URLs concurrently like ScrapeMultiple, but
+// treats the end of ctx (e.g. a context.WithTimeout deadline) as a time box
+// rather than a failure: outstanding scrapes are cancelled and the results that
+// finished are returned with cutShort set. Unfinished URLs have nil results; the
+// error reports only scrapes that failed on their own rather than being cut off.
+func (s *Service) ScrapeMultiplePartial(ctx context.Context, urls []string, selector string) (results []*Result, cutShort bool, err error) {
+	results = make([]*Result, len(urls))
+
+	var errs []error
+	for outcome := range s.ScrapeStream(ctx, urls, selector) {
+		if outcome.Err == nil {
+			results[outcome.Index] = outcome.Result
+			continue
+		}
+		if cutShortBy(ctx, outcome.Err) {
+			cutShort = true
+			continue
+		}
+		errs = append(errs, fmt.Errorf("failed to scrape %s: %w", outcome.URL, outcome.Err))
+	}
+	if cutShort {
+		s.logger.Warn().Err(ctx.Err()).Int("urls", len(urls)).Msg("Batch scrape cut short; returning finished results")
+	}
+	return results, cutShort, errors.Join(errs...)
+}
+
+// cutShortBy reports whether err is the failure of work abandoned because ctx
+// ended. A failure of its own, such as an HTTP error, is not, even when it is
+// read after ctx has ended.
+func cutShortBy(ctx context.Context, err error) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
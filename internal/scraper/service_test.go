@@ -0,0 +1,1368 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/rs/zerolog"
+)
+
+// newTestService creates a scraper with a short timeout and no retries
+func newTestService(t *testing.T, config Config) *Service {
+	t.Helper()
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	service, err := NewService(config, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return service
+}
+
+// serveHTML serves page as text/html at every path
+func serveHTML(t *testing.T, page string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// scrapeHTML scrapes page with a service built from config
+func scrapeHTML(t *testing.T, config Config, page string) *Result {
+	t.Helper()
+	server := serveHTML(t, page)
+	result, err := newTestService(t, config).ScrapeURL(context.Background(), server.URL, "")
+	if err != nil {
+		t.Fatalf("ScrapeURL: %v", err)
+	}
+	return result
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   []string // substrings of the error; empty means valid
+	}{
+		{name: "defaults", config: Config{}},
+		{name: "preset and markdown", config: Config{Politeness: "Gentle", OutputFormat: OutputFormatMarkdown}},
+		{
+			name:   "negative durations",
+			config: Config{Timeout: -time.Second, RateLimit: -time.Millisecond, RobotsTTL: -time.Hour},
+			want:   []string{"Timeout must not be negative", "RateLimit must not be negative", "RobotsTTL must not be negative"},
+		},
+		{
+			name:   "negative limits",
+			config: Config{MaxRetries: -1, MaxBodySize: -1, MaxConcurrency: -2},
+			want:   []string{"MaxRetries must not be negative", "MaxBodySize must not be negative", "MaxConcurrency must not be negative"},
+		},
+		{name: "unknown preset", config: Config{Politeness: "rude"}, want: []string{`unknown Politeness "rude"`}},
+		{name: "unknown output format", config: Config{OutputFormat: "pdf"}, want: []string{`unknown OutputFormat "pdf"`}},
+		{name: "scheme with separator", config: Config{AllowedSchemes: []string{"https://"}}, want: []string{`AllowedSchemes entry "https://"`}},
+		{
+			name:   "thread site without comments",
+			config: Config{ThreadSites: map[string]ThreadSelectors{"forum.example": {}}},
+			want:   []string{`ThreadSites["forum.example"] needs a Comment selector`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want it to mention %q", err, want)
+				}
+			}
+			if _, err := NewService(tt.config, zerolog.Nop()); err == nil {
+				t.Error("NewService accepted the invalid config")
+			}
+		})
+	}
+}
+
+func TestExtractPublishedAt(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		body string
+		want time.Time
+	}{
+		{
+			name: "article meta tag",
+			head: `<meta property="article:published_time" content="2024-03-05T10:30:00Z">`,
+			want: time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "JSON-LD datePublished",
+			head: `<script type="application/ld+json">{"@type":"NewsArticle","datePublished":"2023-11-20"}</script>`,
+			want: time.Date(2023, 11, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "JSON-LD inside @graph",
+			head: `<script type="application/ld+json">{"@graph":[{"@type":"WebSite"},{"@type":"Article","datePublished":"2022-01-02T03:04:05Z"}]}</script>`,
+			want: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "time element",
+			body: `<time datetime="January 7, 2021">last winter</time>`,
+			want: time.Date(2021, 1, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "meta wins over time element",
+			head: `<meta name="date" content="2020-06-01">`,
+			body: `<time datetime="2019-01-01">old</time>`,
+			want: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "unparseable values are skipped",
+			head: `<meta name="date" content="yesterday">`,
+			body: `<time datetime="2018-08-08">then</time>`,
+			want: time.Date(2018, 8, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "no date",
+			body: `<p>Undated</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scrapeHTML(t, Config{}, "<html><head><title>Dated</title>"+tt.head+"</head><body>"+tt.body+"</body></html>")
+			if !result.PublishedAt.Equal(tt.want) {
+				t.Errorf("PublishedAt = %v, want %v", result.PublishedAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOlderThan(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	if (&Result{}).IsOlderThan(time.Nanosecond) {
+		t.Error("a result without a publish date is older than 1ns")
+	}
+	if !(&Result{PublishedAt: time.Now().Add(-2 * week)}).IsOlderThan(week) {
+		t.Error("a page published two weeks ago is not older than a week")
+	}
+	if (&Result{PublishedAt: time.Now().Add(-time.Hour)}).IsOlderThan(week) {
+		t.Error("a page published an hour ago is older than a week")
+	}
+}
+
+func TestScrapeURLRejectsSchemes(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		config Config
+		want   string
+	}{
+		{name: "file URL", url: "file:///etc/passwd", want: `scheme "file" is not allowed`},
+		{name: "ftp URL", url: "ftp://example.com/file.txt", want: `scheme "ftp" is not allowed`},
+		{name: "data URL", url: "data:text/html,<p>hi</p>", want: `scheme "data" is not allowed`},
+		// Bare domains get https:// (see NormalizeURL); other scheme-less strings are rejected
+		{name: "scheme-less string", url: "notaurl", want: "not a domain name"},
+		{name: "missing host", url: "https:///page", want: "missing host"},
+		{name: "scheme outside a custom list", url: "http://example.com", config: Config{AllowedSchemes: []string{"https"}}, want: `scheme "http" is not allowed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestService(t, tt.config).ScrapeURL(context.Background(), tt.url, "")
+			if !errors.Is(err, ErrInvalidURL) {
+				t.Fatalf("ScrapeURL(%q) error = %v, want ErrInvalidURL", tt.url, err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMainImage(t *testing.T) {
+	article := `<article><p>Body text of the article, long enough to be content.</p>
+		<img src="/img/pixel.gif" width="1" height="1">
+		<img src="/img/small.png" width="200" height="150">
+		<img src="/img/large.png" width="800" height="600"></article>`
+	tests := []struct {
+		name string
+		head string
+		body string
+		want string // path on the test server, or empty for none
+	}{
+		{
+			name: "og:image wins",
+			head: `<meta property="og:image" content="/img/og.png"><meta name="twitter:image" content="/img/tw.png">`,
+			body: article,
+			want: "/img/og.png",
+		},
+		{
+			name: "largest in-content image without og:image",
+			head: `<meta name="twitter:image" content="/img/tw.png">`,
+			body: article,
+			want: "/img/large.png",
+		},
+		{
+			name: "twitter:image fallback",
+			head: `<meta name="twitter:image" content="/img/tw.png">`,
+			body: `<article><p>Text only.</p><img src="/img/icon.png" width="16" height="16"></article>`,
+			want: "/img/tw.png",
+		},
+		{
+			name: "no suitable image",
+			body: `<article><p>Text only.</p><img src="data:image/png;base64,AAAA"></article>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveHTML(t, "<html><head><title>Story</title>"+tt.head+"</head><body>"+tt.body+"</body></html>")
+			result, err := newTestService(t, Config{}).ScrapeURL(context.Background(), server.URL, "")
+			if err != nil {
+				t.Fatalf("ScrapeURL: %v", err)
+			}
+			want := ""
+			if tt.want != "" {
+				want = server.URL + tt.want
+			}
+			if result.MainImage != want {
+				t.Errorf("MainImage = %q, want %q", result.MainImage, want)
+			}
+		})
+	}
+}
+
+func TestSiteBranding(t *testing.T) {
+	tests := []struct {
+		name        string
+		title       string
+		head        string
+		wantSite    string
+		wantFavicon string // path on the test server
+	}{
+		{
+			name:        "og:site_name and icon link",
+			title:       "Budget passes | Ignored Name",
+			head:        `<meta property="og:site_name" content="Example News"><link rel="apple-touch-icon" href="/touch.png"><link rel="shortcut icon" href="/static/icon.png">`,
+			wantSite:    "Example News",
+			wantFavicon: "/static/icon.png",
+		},
+		{
+			name:        "title suffix and touch icon",
+			title:       "Budget passes - Example Daily",
+			head:        `<link rel="icon" href="data:image/png;base64,AAAA"><link rel="apple-touch-icon-precomposed" href="touch.png">`,
+			wantSite:    "Example Daily",
+			wantFavicon: "/touch.png",
+		},
+		{
+			name:        "application-name and default icon",
+			title:       "Budget passes",
+			head:        `<meta name="application-name" content="Example App">`,
+			wantSite:    "Example App",
+			wantFavicon: "/favicon.ico",
+		},
+		{
+			name:        "no site name",
+			title:       "Budget passes",
+			wantFavicon: "/favicon.ico",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveHTML(t, "<html><head><title>"+tt.title+"</title>"+tt.head+"</head><body><p>The council passed the budget.</p></body></html>")
+			result, err := newTestService(t, Config{}).ScrapeURL(context.Background(), server.URL, "")
+			if err != nil {
+				t.Fatalf("ScrapeURL: %v", err)
+			}
+			if result.SiteName != tt.wantSite {
+				t.Errorf("SiteName = %q, want %q", result.SiteName, tt.wantSite)
+			}
+			if result.FaviconURL != server.URL+tt.wantFavicon {
+				t.Errorf("FaviconURL = %q, want %q", result.FaviconURL, server.URL+tt.wantFavicon)
+			}
+		})
+	}
+}
+
+func TestScrapeWithFrontier(t *testing.T) {
+	server := serveHTML(t, `<html><head><title>Index</title></head><body><main>
+		<p>Links to follow from this page.</p>
+		<a href="/docs">Docs</a>
+		<a href="/docs#install">Docs again</a>
+		<a href="/blog">Blog</a>
+		<a href="/">Home</a>
+		<a href="https://other.example/page">Elsewhere</a>
+		<a href="mailto:team@example.com">Mail</a>
+	</main></body></html>`)
+	seen := NewVisitedSet()
+	seen.MarkVisited(server.URL + "/blog")
+
+	_, frontier, err := newTestService(t, Config{}).ScrapeWithFrontier(context.Background(), server.URL, seen)
+	if err != nil {
+		t.Fatalf("ScrapeWithFrontier: %v", err)
+	}
+	want := []string{server.URL + "/docs"}
+	if !slices.Equal(frontier, want) {
+		t.Errorf("frontier = %q, want %q", frontier, want)
+	}
+	if !seen.Visited(server.URL+"/") || !seen.Visited(server.URL+"/docs") {
+		t.Error("the page and its new links were not marked visited")
+	}
+
+	// A second call over the same set hands out nothing new
+	_, frontier, err = newTestService(t, Config{}).ScrapeWithFrontier(context.Background(), server.URL, seen)
+	if err != nil {
+		t.Fatalf("ScrapeWithFrontier: %v", err)
+	}
+	if len(frontier) != 0 {
+		t.Errorf("second frontier = %q, want none", frontier)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	base := ContentHash("The council approved the budget.\nParks get more money.")
+	same := []string{
+		"  The council approved the budget. Parks get more money.  ",
+		"The council\tapproved the budget.\n\n\nParks   get more money.",
+	}
+	for _, text := range same {
+		if got := ContentHash(text); got != base {
+			t.Errorf("ContentHash(%q) = %s, want %s like the other whitespace variants", text, got, base)
+		}
+	}
+	for _, text := range []string{"the council approved the budget. Parks get more money.", "The council approved the budget. Parks get more money!"} {
+		if ContentHash(text) == base {
+			t.Errorf("ContentHash(%q) matches although the content differs", text)
+		}
+	}
+}
+
+func TestContentHashOfScrape(t *testing.T) {
+	page := `<html><head><title>News</title></head><body><article>%s</article></body></html>`
+	text := strings.Repeat("The council approved the new budget on Tuesday. ", 4)
+	first := scrapeHTML(t, Config{}, fmt.Sprintf(page, "<p>"+text+"</p>"))
+	reflowed := scrapeHTML(t, Config{}, fmt.Sprintf(page, "\n\t<p>\n"+strings.ReplaceAll(text, " ", "\n  ")+"\n</p>\n"))
+	if first.ContentHash == "" || first.ContentHash != reflowed.ContentHash {
+		t.Errorf("hashes %q and %q differ for reflowed content", first.ContentHash, reflowed.ContentHash)
+	}
+}
+
+func TestDebugExtractionDecisions(t *testing.T) {
+	long := strings.Repeat("This paragraph carries the main story of the page. ", 4)
+	tests := []struct {
+		name   string
+		config Config
+		page   string
+		want   map[string]string // expected metadata; an empty value means absent
+	}{
+		{
+			name:   "content selector wins",
+			config: Config{Debug: true},
+			page:   `<html><body><nav>Menu</nav><article><p>` + long + `</p></article></body></html>`,
+			want: map[string]string{
+				"debug:selector":   "article",
+				"debug:candidates": "main=0,article=" + fmt.Sprint(len(strings.TrimSpace(long))),
+			},
+		},
+		{
+			name:   "body fallback",
+			config: Config{Debug: true},
+			page:   `<html><body><nav>Home About</nav><div>Short text.</div><footer>Copyright</footer></body></html>`,
+			want: map[string]string{
+				"debug:selector": "body-fallback",
+				"debug:excluded": "nav=10,footer=9",
+			},
+		},
+		{
+			name:   "off by default",
+			config: Config{},
+			page:   `<html><body><article><p>` + long + `</p></article></body></html>`,
+			want:   map[string]string{"debug:selector": "", "debug:candidates": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scrapeHTML(t, tt.config, tt.page)
+			for key, want := range tt.want {
+				if got := result.Metadata[key]; got != want {
+					t.Errorf("Metadata[%q] = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// flakyServer answers the first failures requests with status and later ones with
+// page, counting the requests it receives
+func flakyServer(t *testing.T, failures, status int, page string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(hits.Add(1)) <= failures {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestSoftError(t *testing.T) {
+	longBody := "<p>" + strings.Repeat("Plenty of real article text here. ", 60) + "</p>"
+	tests := []struct {
+		name   string
+		detect bool
+		page   string
+		want   bool
+	}{
+		{
+			name:   "not found title",
+			detect: true,
+			page:   `<html><head><title>Page Not Found | Example</title></head><body><p>Sorry, we looked everywhere.</p><a href="/">Home</a></body></html>`,
+			want:   true,
+		},
+		{
+			name:   "404 heading",
+			detect: true,
+			page:   `<html><head><title>Example</title></head><body><h1>404</h1><p>This page does not exist.</p></body></html>`,
+			want:   true,
+		},
+		{
+			name:   "long page about errors",
+			detect: true,
+			page:   `<html><head><title>Fixing 404 Not Found errors</title></head><body>` + longBody + `</body></html>`,
+		},
+		{
+			name:   "ordinary short page",
+			detect: true,
+			page:   `<html><head><title>Contact</title></head><body><p>Write to us.</p></body></html>`,
+		},
+		{
+			name: "detection off",
+			page: `<html><head><title>Page Not Found</title></head><body><p>Sorry.</p></body></html>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scrapeHTML(t, Config{DetectSoftErrors: tt.detect}, tt.page)
+			if result.SoftError != tt.want {
+				t.Errorf("SoftError = %v, want %v (title %q, %d words)", result.SoftError, tt.want, result.Title, result.WordCount)
+			}
+		})
+	}
+}
+
+func TestSuccessStatuses(t *testing.T) {
+	const page = `<html><head><title>Article</title></head><body><p>Some of the article.</p></body></html>`
+	tests := []struct {
+		status      int
+		wantPartial bool
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusNonAuthoritativeInfo},
+		{status: http.StatusPartialContent, wantPartial: true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(page))
+			}))
+			t.Cleanup(server.Close)
+
+			result, err := newTestService(t, Config{}).ScrapeURL(context.Background(), server.URL, "")
+			if err != nil {
+				t.Fatalf("ScrapeURL: %v", err)
+			}
+			if result.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", result.StatusCode, tt.status)
+			}
+			if result.Partial != tt.wantPartial {
+				t.Errorf("Partial = %v, want %v", result.Partial, tt.wantPartial)
+			}
+			if !strings.Contains(result.CleanText, "Some of the article.") {
+				t.Errorf("CleanText = %q, want the page text", result.CleanText)
+			}
+		})
+	}
+}
+
+func TestShouldRetryOverridesClassification(t *testing.T) {
+	const page = `<html><head><title>Recovered</title></head><body><p>Served after a proxy rotation.</p></body></html>`
+
+	t.Run("custom policy retries 403 once", func(t *testing.T) {
+		server, hits := flakyServer(t, 1, http.StatusForbidden, page)
+		var seen []int
+		service := newTestService(t, Config{
+			MaxRetries: 3,
+			ShouldRetry: func(resp *http.Response, err error) time.Duration {
+				if resp == nil {
+					return -1
+				}
+				seen = append(seen, resp.StatusCode)
+				if resp.StatusCode == http.StatusForbidden && len(seen) == 1 {
+					return 0
+				}
+				return -1
+			},
+		})
+		result, err := service.ScrapeURL(context.Background(), server.URL, "")
+		if err != nil {
+			t.Fatalf("ScrapeURL: %v", err)
+		}
+		if result.Title != "Recovered" {
+			t.Errorf("Title = %q, want the page served on retry", result.Title)
+		}
+		if hits.Load() != 2 || !slices.Equal(seen, []int{http.StatusForbidden}) {
+			t.Errorf("got %d requests and policy calls %v, want 2 requests and one call for the 403", hits.Load(), seen)
+		}
+	})
+
+	t.Run("default policy does not retry 403", func(t *testing.T) {
+		server, hits := flakyServer(t, 1, http.StatusForbidden, page)
+		_, err := newTestService(t, Config{MaxRetries: 3}).ScrapeURL(context.Background(), server.URL, "")
+		if err == nil {
+			t.Fatal("ScrapeURL succeeded on a 403")
+		}
+		if hits.Load() != 1 {
+			t.Errorf("got %d requests, want 1", hits.Load())
+		}
+	})
+}
+
+func TestRetries(t *testing.T) {
+	const page = `<html><head><title>Back</title></head><body><p>Served once the outage ended.</p></body></html>`
+	retryNow := func(resp *http.Response, err error) time.Duration {
+		if resp != nil && resp.StatusCode >= 500 {
+			return 0
+		}
+		return -1
+	}
+	tests := []struct {
+		name         string
+		failures     int
+		status       int
+		maxRetries   int
+		wantAttempts int32
+		wantStatus   int
+	}{
+		{name: "recovers", failures: 2, status: http.StatusServiceUnavailable, maxRetries: 3, wantAttempts: 3},
+		{name: "retries run out", failures: 5, status: http.StatusServiceUnavailable, maxRetries: 2, wantAttempts: 3, wantStatus: http.StatusServiceUnavailable},
+		{name: "not retried", failures: 1, status: http.StatusNotFound, maxRetries: 3, wantAttempts: 1, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run("ScrapeURL "+tt.name, func(t *testing.T) {
+			server, hits := flakyServer(t, tt.failures, tt.status, page)
+			service := newTestService(t, Config{MaxRetries: tt.maxRetries, ShouldRetry: retryNow})
+			result, err := service.ScrapeURL(context.Background(), server.URL, "")
+			if hits.Load() != tt.wantAttempts {
+				t.Errorf("got %d requests, want %d", hits.Load(), tt.wantAttempts)
+			}
+			if tt.wantStatus != 0 {
+				var statusErr *StatusError
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+					t.Errorf("ScrapeURL error = %v, want a %d StatusError", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScrapeURL: %v", err)
+			}
+			if result.Attempts != int(tt.wantAttempts) || result.Title != "Back" {
+				t.Errorf("Attempts = %d, Title = %q, want %d and the recovered page", result.Attempts, result.Title, tt.wantAttempts)
+			}
+		})
+
+		t.Run("FetchReader "+tt.name, func(t *testing.T) {
+			server, hits := flakyServer(t, tt.failures, tt.status, page)
+			service := newTestService(t, Config{MaxRetries: tt.maxRetries, ShouldRetry: retryNow})
+			body, info, err := service.FetchReader(context.Background(), server.URL)
+			if hits.Load() != tt.wantAttempts || info.Attempts != int(tt.wantAttempts) {
+				t.Errorf("got %d requests and Attempts = %d, want %d", hits.Load(), info.Attempts, tt.wantAttempts)
+			}
+			if tt.wantStatus != 0 {
+				var statusErr *StatusError
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+					t.Errorf("FetchReader error = %v, want a %d StatusError", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchReader: %v", err)
+			}
+			defer body.Close()
+			if data, _ := io.ReadAll(body); !strings.Contains(string(data), "outage ended") {
+				t.Errorf("FetchReader body = %q, want the recovered page", data)
+			}
+		})
+	}
+}
+
+// scriptedServer answers each request with the next step, repeating the last:
+// an HTTP status code, or 0 to drop the connection without a response. Keep-alives
+// are off so that the HTTP client does not silently retry a dropped reused connection.
+func scriptedServer(t *testing.T, page string, steps ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch status := steps[min(int(hits.Add(1)), len(steps))-1]; status {
+		case 0:
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		case http.StatusOK:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(page))
+		default:
+			http.Error(w, http.StatusText(status), status)
+		}
+	}))
+	server.Config.SetKeepAlivesEnabled(false)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	const page = `<html><head><title>Back</title></head><body><p>Served once the outage ended.</p></body></html>`
+	tests := []struct {
+		name         string
+		steps        []int
+		wantAttempts int32
+		// wantStatus is the StatusError code wanted; -1 wants an error that is not one
+		wantStatus int
+	}{
+		{name: "503 then 200", steps: []int{http.StatusServiceUnavailable, http.StatusOK}, wantAttempts: 2},
+		{name: "dropped connection retried", steps: []int{0, http.StatusOK}, wantAttempts: 2},
+		{name: "404 not retried", steps: []int{http.StatusNotFound, http.StatusOK}, wantAttempts: 1, wantStatus: http.StatusNotFound},
+		{name: "503 then a dropped connection is not a 503", steps: []int{http.StatusServiceUnavailable, 0}, wantAttempts: 2, wantStatus: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, hits := scriptedServer(t, page, tt.steps...)
+			// No ShouldRetry, so defaultRetryDelay classifies the failures
+			result, err := newTestService(t, Config{MaxRetries: 1}).ScrapeURL(context.Background(), server.URL, "")
+			if hits.Load() != tt.wantAttempts {
+				t.Errorf("got %d requests, want %d", hits.Load(), tt.wantAttempts)
+			}
+			var statusErr *StatusError
+			switch {
+			case tt.wantStatus == -1:
+				if err == nil || errors.As(err, &statusErr) {
+					t.Errorf("ScrapeURL error = %v, want a network error that is not a StatusError", err)
+				}
+			case tt.wantStatus != 0:
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+					t.Errorf("ScrapeURL error = %v, want a %d StatusError", err, tt.wantStatus)
+				}
+			case err != nil:
+				t.Fatalf("ScrapeURL: %v", err)
+			case result.Attempts != int(tt.wantAttempts) || result.Title != "Back":
+				t.Errorf("Attempts = %d, Title = %q, want %d and the recovered page", result.Attempts, result.Title, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+// sequenceServer serves the pages in order, repeating the last, and counts requests
+func sequenceServer(t *testing.T, pages ...string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit := int(hits.Add(1))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(pages[min(hit, len(pages))-1]))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestRetryEmptyContent(t *testing.T) {
+	const (
+		placeholder = `<html><head><title>Loading</title></head><body><p>Loading</p></body></html>`
+		appShell    = `<html><head><title>App</title><script src="/app.js"></script></head><body><div id="root"></div></body></html>`
+		full        = `<html><head><title>Story</title></head><body><article><p>The council approved the new budget on Tuesday after a long debate about parks.</p></article></body></html>`
+	)
+	tests := []struct {
+		name         string
+		pages        []string
+		threshold    int
+		wantHits     int32
+		wantText     string
+		wantRetries  string
+		wantAttempts int
+	}{
+		{name: "recovers on re-fetch", pages: []string{placeholder, full}, threshold: 40, wantHits: 2, wantText: "approved the new budget", wantRetries: "1", wantAttempts: 2},
+		{name: "gives up after MaxRetries", pages: []string{placeholder}, threshold: 40, wantHits: 3, wantText: "Loading", wantRetries: "2", wantAttempts: 3},
+		{name: "disabled by default", pages: []string{placeholder, full}, wantHits: 1, wantText: "Loading", wantAttempts: 1},
+		{name: "app shell not retried", pages: []string{appShell, full}, threshold: 40, wantHits: 1, wantAttempts: 1},
+		{name: "enough text", pages: []string{full, placeholder}, threshold: 40, wantHits: 1, wantText: "approved the new budget", wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, hits := sequenceServer(t, tt.pages...)
+			service := newTestService(t, Config{MaxRetries: 2, RetryEmptyContent: tt.threshold, EmptyContentDelay: time.Millisecond})
+			result, err := service.ScrapeURL(context.Background(), server.URL, "")
+			if err != nil {
+				t.Fatalf("ScrapeURL: %v", err)
+			}
+			if hits.Load() != tt.wantHits {
+				t.Errorf("got %d requests, want %d", hits.Load(), tt.wantHits)
+			}
+			if !strings.Contains(result.CleanText, tt.wantText) {
+				t.Errorf("CleanText = %q, want %q", result.CleanText, tt.wantText)
+			}
+			if got := result.Metadata["empty_content_retries"]; got != tt.wantRetries {
+				t.Errorf("empty_content_retries = %q, want %q", got, tt.wantRetries)
+			}
+			if result.Attempts != tt.wantAttempts {
+				t.Errorf("Attempts = %d, want %d", result.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestReferer(t *testing.T) {
+	var mu sync.Mutex
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = r.Header.Get("Referer")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><body><p>Gated article.</p></body></html>`))
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name     string
+		config   Config
+		override string
+		want     string
+	}{
+		{name: "off by default", want: ""},
+		{name: "auto referer uses the origin", config: Config{AutoReferer: true}, want: server.URL + "/"},
+		{name: "auto referer uses the configured value", config: Config{AutoReferer: true, Referer: "https://search.example/"}, want: "https://search.example/"},
+		{name: "per-request override", config: Config{AutoReferer: true}, override: "https://news.example/front", want: "https://news.example/front"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(t, tt.config)
+			if _, err := service.ScrapeURLWithReferer(context.Background(), server.URL+"/article", "", tt.override); err != nil {
+				t.Fatalf("ScrapeURLWithReferer: %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if got != tt.want {
+				t.Errorf("Referer = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawlStopsAtBudget(t *testing.T) {
+	// Every page links to the next, so only the budget ends the crawl
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		var n int
+		fmt.Sscanf(r.URL.Path, "/page/%d", &n)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<html><body><main><p>Page %d of an endless site.</p><a href="/page/%d">Next</a></main></body></html>`, n, n+1)
+	}))
+	t.Cleanup(server.Close)
+
+	const budget = 500 * time.Millisecond
+	service := newTestService(t, Config{MaxCrawlDuration: budget})
+	start := time.Now()
+	crawl, err := service.Crawl(context.Background(), server.URL+"/page/0", CrawlOptions{})
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+	if !crawl.BudgetExceeded {
+		t.Error("BudgetExceeded is not set")
+	}
+	if len(crawl.Pages) == 0 {
+		t.Error("no partial results were returned")
+	}
+	if elapsed > budget+time.Second {
+		t.Errorf("crawl took %s, want it to stop near the %s budget", elapsed, budget)
+	}
+}
+
+func TestCrawlWithinBudget(t *testing.T) {
+	server := serveHTML(t, `<html><body><main><p>A single page.</p></main></body></html>`)
+	crawl, err := newTestService(t, Config{MaxCrawlDuration: 5 * time.Second}).Crawl(context.Background(), server.URL, CrawlOptions{})
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+	if crawl.BudgetExceeded || len(crawl.Pages) != 1 {
+		t.Errorf("got %d pages with BudgetExceeded %v, want 1 page within budget", len(crawl.Pages), crawl.BudgetExceeded)
+	}
+}
+
+func TestScrapeMultiplePartial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			<-r.Context().Done()
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>Fast</title></head><body><p>Finished in time.</p></body></html>`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	urls := []string{server.URL + "/fast", server.URL + "/missing", server.URL + "/slow"}
+	results, cutShort, err := newTestService(t, Config{}).ScrapeMultiplePartial(ctx, urls, "")
+
+	if !cutShort {
+		t.Error("cutShort = false, want true after the deadline cut off /slow")
+	}
+	if results[0] == nil || results[0].Title != "Fast" {
+		t.Errorf("results[0] = %+v, want the finished scrape", results[0])
+	}
+	if results[1] != nil || results[2] != nil {
+		t.Errorf("results = %v, want nil for the failed and unfinished URLs", results)
+	}
+	if err == nil || !strings.Contains(err.Error(), "/missing") || strings.Contains(err.Error(), "/slow") {
+		t.Errorf("err = %v, want only the /missing failure", err)
+	}
+}
+
+func TestCutShortBy(t *testing.T) {
+	ended, cancel := context.WithCancel(context.Background())
+	cancel()
+	notFound := &StatusError{StatusCode: http.StatusNotFound, Err: errors.New("Not Found")}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{name: "cancelled work", ctx: ended, err: fmt.Errorf("failed to scrape: %w", context.Canceled), want: true},
+		{name: "HTTP error read after the end", ctx: ended, err: notFound},
+		{name: "cancellation while running", ctx: context.Background(), err: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cutShortBy(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("cutShortBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadlineCutsOffEndlessBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><head><title>Live</title></head><body><p>First update from the live feed.</p>")
+		for i := 0; ; i++ {
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+			fmt.Fprintf(w, "<p>Update %d.</p>", i)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	const deadline = 300 * time.Millisecond
+	service := newTestService(t, Config{Deadline: deadline})
+	start := time.Now()
+	result, err := service.ScrapeURL(context.Background(), server.URL, "")
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("ScrapeURL: %v", err)
+	}
+	if elapsed > deadline+time.Second {
+		t.Errorf("scrape took %s, want it to return by the %s deadline", elapsed, deadline)
+	}
+	if !result.Partial {
+		t.Error("Partial is not set on a body cut off by the deadline")
+	}
+	if !strings.Contains(result.CleanText, "First update from the live feed.") {
+		t.Errorf("CleanText %q lacks the content read before the deadline", result.CleanText)
+	}
+}
+
+func TestMaxBodySizeBoundary(t *testing.T) {
+	const page = `<html><head><title>Sized</title></head><body><p>Exactly sized content.</p></body></html>`
+	tests := []struct {
+		name          string
+		maxBodySize   int64
+		wantTruncated bool
+	}{
+		{"body exactly at the limit", int64(len(page)), false},
+		{"body one byte over the limit", int64(len(page)) - 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scrapeHTML(t, Config{MaxBodySize: tt.maxBodySize}, page)
+			if result.Truncated != tt.wantTruncated || result.Partial != tt.wantTruncated {
+				t.Errorf("Truncated = %v, Partial = %v; want both %v", result.Truncated, result.Partial, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestRejectOversized(t *testing.T) {
+	server := serveHTML(t, `<html><body><p>`+strings.Repeat("x", 200)+`</p></body></html>`)
+	_, err := newTestService(t, Config{MaxBodySize: 100, RejectOversized: true}).ScrapeURL(context.Background(), server.URL, "")
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("ScrapeURL error = %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestVisibleTextOnly(t *testing.T) {
+	const page = `<html><body><div>
+		<p>Visible paragraph.</p>
+		<div style="display: none">Inline display none.</div>
+		<div style="visibility:hidden">Inline visibility hidden.</div>
+		<ul hidden><li>Hidden attribute.</li></ul>
+		<span aria-hidden="true">Aria hidden.</span>
+		<span class="sr-only">Screen reader text.</span>
+	</div></body></html>`
+	hiddenTexts := []string{"Inline display none.", "Inline visibility hidden.", "Hidden attribute.", "Aria hidden."}
+
+	visible := scrapeHTML(t, Config{VisibleTextOnly: true}, page)
+	if !strings.Contains(visible.CleanText, "Visible paragraph.") {
+		t.Errorf("CleanText %q lost the visible text", visible.CleanText)
+	}
+	for _, hidden := range hiddenTexts {
+		if strings.Contains(visible.CleanText, hidden) {
+			t.Errorf("CleanText %q contains hidden text %q", visible.CleanText, hidden)
+		}
+	}
+	// Without a CSS engine, elements hidden by class are kept
+	if !strings.Contains(visible.CleanText, "Screen reader text.") {
+		t.Errorf("CleanText %q dropped text hidden only by a stylesheet class", visible.CleanText)
+	}
+
+	all := scrapeHTML(t, Config{}, page)
+	for _, hidden := range hiddenTexts {
+		if !strings.Contains(all.CleanText, hidden) {
+			t.Errorf("CleanText %q without VisibleTextOnly lacks %q", all.CleanText, hidden)
+		}
+	}
+}
+
+func TestPreExtract(t *testing.T) {
+	const story = "The council approved next year's budget after a long debate, raising spending on parks and libraries."
+	const page = `<html><head><title>AMP story</title></head><body>
+		<amp-article><p>` + story + `</p><a href="/more">More</a></amp-article>
+		<div>Related: other stories.</div>
+	</body></html>`
+
+	// Unwrapping the custom element into an <article> narrows extraction to it
+	var seenURL string
+	unwrap := func(doc *goquery.Document) {
+		seenURL = doc.Url.String()
+		doc.Find("amp-article").Each(func(i int, article *goquery.Selection) {
+			inner, _ := article.Html()
+			article.ReplaceWithHtml("<article>" + inner + "</article>")
+		})
+		doc.Find("title").SetText("Unwrapped story")
+	}
+	server := serveHTML(t, page)
+	result, err := newTestService(t, Config{PreExtract: unwrap}).ScrapeURL(context.Background(), server.URL, "")
+	if err != nil {
+		t.Fatalf("ScrapeURL: %v", err)
+	}
+	if !strings.Contains(result.CleanText, story) || strings.Contains(result.CleanText, "Related") {
+		t.Errorf("CleanText = %q, want only the unwrapped article", result.CleanText)
+	}
+	if result.Title != "Unwrapped story" {
+		t.Errorf("Title = %q, want the hook's rewrite", result.Title)
+	}
+	if len(result.Links) != 1 || result.Links[0] != server.URL+"/more" {
+		t.Errorf("Links = %q, want the article's link resolved", result.Links)
+	}
+	if seenURL != server.URL+"/" {
+		t.Errorf("hook saw document URL %q, want %q", seenURL, server.URL+"/")
+	}
+
+	// Without the hook the whole body is extracted
+	plain := scrapeHTML(t, Config{}, page)
+	if !strings.Contains(plain.CleanText, "Related") || plain.Title != "AMP story" {
+		t.Errorf("without PreExtract: Title = %q, CleanText = %q", plain.Title, plain.CleanText)
+	}
+}
+
+func TestMergeNoscript(t *testing.T) {
+	const page = `<html><head><title>Gallery</title></head><body>
+		<div id="gallery"></div>
+		<noscript><p>The gallery shows <b>twelve</b> photos of the harbour.</p><img src="/harbour.jpg"></noscript>
+	</body></html>`
+
+	merged := scrapeHTML(t, Config{MergeNoscript: true}, page)
+	if !strings.Contains(merged.CleanText, "The gallery shows twelve photos of the harbour.") {
+		t.Errorf("CleanText = %q, want the noscript fallback text", merged.CleanText)
+	}
+	if strings.Contains(merged.CleanText, "<p>") {
+		t.Errorf("CleanText = %q, want the fallback parsed as markup", merged.CleanText)
+	}
+	if !slices.Contains(merged.Images, merged.URL+"/harbour.jpg") {
+		t.Errorf("Images = %q, want the noscript image", merged.Images)
+	}
+
+	plain := scrapeHTML(t, Config{}, page)
+	if strings.Contains(plain.CleanText, "harbour") || len(plain.Images) != 0 {
+		t.Errorf("without MergeNoscript: CleanText = %q, Images = %q, want the fallback ignored", plain.CleanText, plain.Images)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	page := `<html><head><title>Guide</title></head><body>
+		<h1>Getting   started</h1>
+		<p>Intro text.</p>
+		<h2>Install</h2>
+		<h3>On <em>Linux</em></h3>
+		<h3>  </h3>
+		<h3>On macOS</h3>
+		<h2>Configure</h2>
+		<h6>Footnote</h6>
+	</body></html>`
+	result := scrapeHTML(t, Config{}, page)
+
+	want := []Heading{
+		{Level: 1, Text: "Getting started"},
+		{Level: 2, Text: "Install"},
+		{Level: 3, Text: "On Linux"},
+		{Level: 3, Text: "On macOS"},
+		{Level: 2, Text: "Configure"},
+		{Level: 6, Text: "Footnote"},
+	}
+	if !slices.Equal(result.Outline, want) {
+		t.Errorf("Outline = %+v, want %+v", result.Outline, want)
+	}
+}
+
+func TestSections(t *testing.T) {
+	page := `<html><head><title>Guide</title></head><body>
+		<p>Read this first.</p>
+		<h1>Install</h1>
+		<p>Download the binary.</p>
+		<h2>Configure</h2>
+		<p>Edit the Install path if needed.</p>
+		<h2>Run</h2>
+		<p>Start the server.</p>
+	</body></html>`
+	result := scrapeHTML(t, Config{}, page)
+
+	want := []Section{
+		{Text: "Read this first."},
+		{Heading: "Install", Text: "Download the binary."},
+		{Heading: "Configure", Text: "Edit the Install path if needed."},
+		{Heading: "Run", Text: "Start the server."},
+	}
+	if !slices.Equal(result.Sections, want) {
+		t.Errorf("Sections = %+v, want %+v", result.Sections, want)
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		outline []Heading
+		want    []Section
+	}{
+		{
+			name: "no headings gives one section",
+			text: "Just a paragraph.",
+			want: []Section{{Text: "Just a paragraph."}},
+		},
+		{
+			name:    "empty heading is skipped",
+			text:    "Intro.\nUsage\nRun it.",
+			outline: []Heading{{Level: 2, Text: ""}, {Level: 2, Text: "Usage"}},
+			want:    []Section{{Text: "Intro."}, {Heading: "Usage", Text: "Run it."}},
+		},
+		{
+			name:    "only empty headings",
+			text:    "Intro.",
+			outline: []Heading{{Level: 1, Text: ""}},
+			want:    []Section{{Text: "Intro."}},
+		},
+		{
+			name:    "heading missing from the text",
+			text:    "Usage\nRun it.",
+			outline: []Heading{{Level: 1, Text: "Elsewhere"}, {Level: 2, Text: "Usage"}},
+			want:    []Section{{Heading: "Usage", Text: "Run it."}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitSections(tt.text, tt.outline); !slices.Equal(got, tt.want) {
+				t.Errorf("splitSections() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractComments(t *testing.T) {
+	const article = `<article><h1>Widgets</h1><p>The widget ships in March with a longer battery life.</p></article>`
+	const comments = `<section id="comments">
+		<ol class="comment-list">
+			<li class="comment">
+				<span class="author">ann</span>
+				<div class="comment-body">Great news, finally.</div>
+				<ol><li class="comment"><div class="comment-body">Agreed, the old one died fast.</div></li></ol>
+			</li>
+			<li class="comment"><div class="comment-body">Too expensive for me.</div></li>
+		</ol>
+	</section>`
+	tests := []struct {
+		name   string
+		config Config
+		body   string
+		want   []string
+	}{
+		{
+			name:   "threaded comments",
+			config: Config{ExtractComments: true},
+			body:   article + comments,
+			want:   []string{"Great news, finally.", "Agreed, the old one died fast.", "Too expensive for me."},
+		},
+		{
+			name:   "container without items",
+			config: Config{ExtractComments: true},
+			body:   article + `<div id="disqus_thread">Loading the discussion.</div>`,
+			want:   []string{"Loading the discussion."},
+		},
+		{
+			name:   "no comments",
+			config: Config{ExtractComments: true},
+			body:   article,
+			want:   []string{},
+		},
+		{
+			name: "option off",
+			body: article + comments,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scrapeHTML(t, tt.config, `<html><head><title>Widgets</title></head><body>`+tt.body+`</body></html>`)
+			if !slices.Equal(result.Comments, tt.want) {
+				t.Errorf("Comments = %q, want %q", result.Comments, tt.want)
+			}
+			if !strings.Contains(result.CleanText, "longer battery life") {
+				t.Errorf("CleanText lost the article: %q", result.CleanText)
+			}
+			if tt.config.ExtractComments && strings.Contains(result.CleanText, "expensive") {
+				t.Errorf("CleanText still holds the comments: %q", result.CleanText)
+			}
+		})
+	}
+}
+
+// servePages serves each page of pages as text/html at its path
+func servePages(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFollowPagination(t *testing.T) {
+	article := func(title, text, pager string) string {
+		return `<html><head><title>` + title + `</title>` + `</head><body><article><p>` + text + `</p></article>` + pager + `</body></html>`
+	}
+	relNext := map[string]string{
+		"/story":   article("Story", "Part one of the story.", `<link rel="next" href="/story/2">`),
+		"/story/2": article("Story", "Part two of the story.", `<div class="pagination"><a href="/story">1</a> <a href="/story/2">2</a></div>`),
+	}
+	nextLinks := map[string]string{
+		"/story":   article("Story", "Part one of the story.", `<div class="pagination"><a href="/story/2">2</a> <a href="/story/2">Next »</a></div>`),
+		"/story/2": article("Story", "Part two of the story.", `<div class="pagination"><a href="/story/3">Next »</a></div>`),
+		"/story/3": article("Story", "Part three of the story.", `<div class="pagination"><a href="/story">« Back to the start</a></div>`),
+	}
+	tests := []struct {
+		name   string
+		pages  map[string]string
+		config Config
+		want   []string
+	}{
+		{
+			name:   "rel next",
+			pages:  relNext,
+			config: Config{FollowPagination: true},
+			want:   []string{"Part one of the story.", "Part two of the story."},
+		},
+		{
+			name:   "next links",
+			pages:  nextLinks,
+			config: Config{FollowPagination: true},
+			want:   []string{"Part one of the story.", "Part two of the story.", "Part three of the story."},
+		},
+		{
+			name:   "page cap",
+			pages:  nextLinks,
+			config: Config{FollowPagination: true, MaxPages: 2},
+			want:   []string{"Part one of the story.", "Part two of the story."},
+		},
+		{
+			name:  "off",
+			pages: relNext,
+			want:  []string{"Part one of the story."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := servePages(t, tt.pages)
+			result, err := newTestService(t, tt.config).ScrapeURL(context.Background(), server.URL+"/story", "")
+			if err != nil {
+				t.Fatalf("ScrapeURL: %v", err)
+			}
+			last := -1
+			for _, want := range tt.want {
+				i := strings.Index(result.CleanText, want)
+				if i <= last {
+					t.Errorf("CleanText = %q, want %q after the previous page", result.CleanText, want)
+				}
+				last = i
+			}
+			if strings.Count(result.CleanText, "Part ") != len(tt.want) {
+				t.Errorf("CleanText = %q, want exactly the pages %q", result.CleanText, tt.want)
+			}
+			wantPages := ""
+			if len(tt.want) > 1 {
+				wantPages = strconv.Itoa(len(tt.want))
+			}
+			if result.Metadata["pages"] != wantPages {
+				t.Errorf("pages = %q, want %q", result.Metadata["pages"], wantPages)
+			}
+			if result.WordCount != len(strings.Fields(result.CleanText)) {
+				t.Errorf("WordCount = %d, want the joined text's %d", result.WordCount, len(strings.Fields(result.CleanText)))
+			}
+		})
+	}
+}
+
+func TestExtractAuthor(t *testing.T) {
+	const body = `<article><p>Story text.</p></article>`
+	tests := []struct {
+		name string
+		head string
+		body string
+		want string
+	}{
+		{
+			name: "JSON-LD person wins over meta",
+			head: `<script type="application/ld+json">{"@type": "NewsArticle", "author": {"@type": "Person", "name": "Ada Lovelace"}}</script>
+				<meta name="author" content="Meta Author">`,
+			body: body,
+			want: "Ada Lovelace",
+		},
+		{
+			name: "JSON-LD author list in a graph",
+			head: `<script type="application/ld+json">{"@graph": [{"@type": "WebPage"}, {"@type": "Article", "author": [{"name": "Ada Lovelace"}, "Charles Babbage"]}]}</script>`,
+			body: body,
+			want: "Ada Lovelace, Charles Babbage",
+		},
+		{
+			name: "meta author",
+			head: `<meta name="author" content="Grace Hopper">`,
+			body: `<a rel="author" href="/staff/other">Other Person</a>` + body,
+			want: "Grace Hopper",
+		},
+		{
+			name: "article:author name",
+			head: `<meta property="article:author" content="Alan Turing">`,
+			body: body,
+			want: "Alan Turing",
+		},
+		{
+			name: "article:author profile URL falls through to rel=author",
+			head: `<meta property="article:author" content="https://example.com/staff/turing">`,
+			body: `<a rel="author" href="/staff/turing">Alan Turing</a>` + body,
+			want: "Alan Turing",
+		},
+		{
+			name: "byline selector drops the leading By",
+			body: `<p class="byline">By   Katherine Johnson</p>` + body,
+			want: "Katherine Johnson",
+		},
+		{
+			name: "author bio block is not a byline",
+			body: `<div class="author-name">` + strings.Repeat("A long biography of the writer. ", 10) + `</div>` + body,
+			want: "",
+		},
+		{
+			name: "no author",
+			body: body,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := "<html><head><title>Story</title>" + tt.head + "</head><body>" + tt.body + "</body></html>"
+			if got := scrapeHTML(t, Config{}, page).Author; got != tt.want {
+				t.Errorf("Author = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructuredDataFeedsExtraction(t *testing.T) {
+	// An invalid block is skipped, and a top-level array contributes each object
+	page := `<html><head><title>Story</title>
+<script type="application/ld+json">{"@type": "NewsArticle",</script>
+<script type="application/ld+json">[{"@type": "NewsArticle", "author": {"name": "Ada Lovelace"}, "datePublished": "2024-03-04"}, 5]</script>
+</head><body><p>Story text.</p></body></html>`
+	result := scrapeHTML(t, Config{ClassifyPages: true}, page)
+
+	if len(result.StructuredData) != 1 || result.StructuredData[0]["@type"] != "NewsArticle" {
+		t.Fatalf("StructuredData = %v, want the one NewsArticle object", result.StructuredData)
+	}
+	if result.Author != "Ada Lovelace" {
+		t.Errorf("Author = %q, want it from the structured data", result.Author)
+	}
+	if want := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC); !result.PublishedAt.Equal(want) {
+		t.Errorf("PublishedAt = %v, want %v", result.PublishedAt, want)
+	}
+	if result.PageType != PageTypeArticle {
+		t.Errorf("PageType = %q, want %q from the structured data", result.PageType, PageTypeArticle)
+	}
+}
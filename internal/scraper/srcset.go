@@ -0,0 +1,148 @@
+package scraper
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// ImageCandidate is one entry of a srcset: an image URL and its descriptor
+type ImageCandidate struct {
+	URL string `json:"url"`
+	// Descriptor is the width ("800w") or pixel density ("2x") the entry is for;
+	// empty means 1x
+	Descriptor string `json:"descriptor,omitempty"`
+}
+
+// ResponsiveImage is an <img srcset> or <picture> with every resolution it offers
+type ResponsiveImage struct {
+	// Src is the plain src fallback, if any
+	Src string `json:"src,omitempty"`
+	// Best is the highest-resolution candidate
+	Best       string           `json:"best"`
+	Candidates []ImageCandidate `json:"candidates"`
+}
+
+// extractResponsiveImages collects the srcset candidates of every <picture> and
+// <img>, with their URLs made absolute. Images without a srcset are left to the
+// plain img[src] extraction.
+func extractResponsiveImages(e *colly.HTMLElement) []ResponsiveImage {
+	var images []ResponsiveImage
+	e.DOM.Find("picture, img").Each(func(i int, sel *goquery.Selection) {
+		img := sel
+		var candidates []ImageCandidate
+		if goquery.NodeName(sel) == "picture" {
+			sel.ChildrenFiltered("source").Each(func(i int, source *goquery.Selection) {
+				candidates = append(candidates, parseSrcset(srcsetOf(source))...)
+			})
+			img = sel.ChildrenFiltered("img").First()
+		} else if goquery.NodeName(sel.Parent()) == "picture" {
+			return // handled with its <picture>
+		}
+		candidates = append(candidates, parseSrcset(srcsetOf(img))...)
+		if len(candidates) == 0 {
+			return
+		}
+
+		image := ResponsiveImage{}
+		if src := strings.TrimSpace(img.AttrOr("src", "")); src != "" && !strings.HasPrefix(src, "data:") {
+			image.Src = e.Request.AbsoluteURL(src)
+		}
+		for _, candidate := range candidates {
+			if strings.HasPrefix(candidate.URL, "data:") {
+				continue
+			}
+			candidate.URL = e.Request.AbsoluteURL(candidate.URL)
+			if candidate.URL != "" {
+				image.Candidates = append(image.Candidates, candidate)
+			}
+		}
+		if image.Best = bestCandidate(image.Candidates); image.Best != "" {
+			images = append(images, image)
+		}
+	})
+	return images
+}
+
+// srcsetOf returns an element's srcset, falling back to the data-srcset that lazy loaders use
+func srcsetOf(sel *goquery.Selection) string {
+	if srcset := strings.TrimSpace(sel.AttrOr("srcset", "")); srcset != "" {
+		return srcset
+	}
+	return strings.TrimSpace(sel.AttrOr("data-srcset", ""))
+}
+
+// parseSrcset splits a srcset attribute into its candidates. URLs may themselves
+// contain commas, so each URL runs to the next whitespace, as the HTML spec parses it.
+func parseSrcset(srcset string) []ImageCandidate {
+	var candidates []ImageCandidate
+	rest := srcset
+	for {
+		rest = strings.TrimLeftFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == ',' })
+		if rest == "" {
+			return candidates
+		}
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			end = len(rest)
+		}
+		url := rest[:end]
+		rest = rest[end:]
+
+		descriptor := ""
+		if trimmed := strings.TrimRight(url, ","); trimmed != url {
+			// A trailing comma ends the candidate with no descriptor
+			url = trimmed
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			descriptor = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+		}
+		if url != "" {
+			candidates = append(candidates, ImageCandidate{URL: url, Descriptor: descriptor})
+		}
+	}
+}
+
+// bestCandidate picks the highest-resolution candidate: the widest when any
+// declares a width, otherwise the densest
+func bestCandidate(candidates []ImageCandidate) string {
+	best, bestWidth, bestDensity := "", 0.0, 0.0
+	for _, candidate := range candidates {
+		width, density := descriptorSize(candidate.Descriptor)
+		switch {
+		case width > bestWidth:
+			best, bestWidth = candidate.URL, width
+		case bestWidth == 0 && width == 0 && density > bestDensity:
+			best, bestDensity = candidate.URL, density
+		}
+	}
+	return best
+}
+
+// descriptorSize parses a "800w" width or "1.5x" density descriptor; an empty
+// descriptor is 1x and anything else (such as a height) counts as neither
+func descriptorSize(descriptor string) (width, density float64) {
+	if descriptor == "" {
+		return 0, 1
+	}
+	for _, field := range strings.Fields(descriptor) {
+		value, err := strconv.ParseFloat(field[:len(field)-1], 64)
+		if err != nil || value <= 0 {
+			continue
+		}
+		switch field[len(field)-1] {
+		case 'w':
+			width = value
+		case 'x':
+			density = value
+		}
+	}
+	return width, density
+}
@@ -0,0 +1,103 @@
+package scraper
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+const responsivePage = `<html><head><title>Gallery</title></head><body><article>
+<p>Photos from the harbour festival, with boats and lanterns on the water.</p>
+<img src="/img/boats-400.jpg" alt="Boats" srcset="/img/boats-400.jpg 400w, /img/boats-800.jpg 800w, /img/boats-1600.jpg 1600w">
+<picture>
+	<source type="image/webp" srcset="/img/lanterns.webp 1x, /img/lanterns@2x.webp 2x">
+	<source srcset="https://cdn.example.com/lanterns@3x.jpg 3x">
+	<img src="/img/lanterns.jpg" alt="Lanterns">
+</picture>
+<img data-srcset="/img/lazy-small.jpg 1x, /img/lazy-large.jpg 2x" src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" alt="Lazy">
+<img src="/img/plain.jpg" alt="Plain">
+</article></body></html>`
+
+func TestResolveSrcset(t *testing.T) {
+	server := serveHTML(t, responsivePage)
+	base := server.URL
+	result, err := newTestService(t, Config{ResolveSrcset: true}).ScrapeURL(context.Background(), base, "")
+	if err != nil {
+		t.Fatalf("ScrapeURL: %v", err)
+	}
+
+	want := []ResponsiveImage{
+		{
+			Src:  base + "/img/boats-400.jpg",
+			Best: base + "/img/boats-1600.jpg",
+			Candidates: []ImageCandidate{
+				{URL: base + "/img/boats-400.jpg", Descriptor: "400w"},
+				{URL: base + "/img/boats-800.jpg", Descriptor: "800w"},
+				{URL: base + "/img/boats-1600.jpg", Descriptor: "1600w"},
+			},
+		},
+		{
+			Src:  base + "/img/lanterns.jpg",
+			Best: "https://cdn.example.com/lanterns@3x.jpg",
+			Candidates: []ImageCandidate{
+				{URL: base + "/img/lanterns.webp", Descriptor: "1x"},
+				{URL: base + "/img/lanterns@2x.webp", Descriptor: "2x"},
+				{URL: "https://cdn.example.com/lanterns@3x.jpg", Descriptor: "3x"},
+			},
+		},
+		{
+			Best: base + "/img/lazy-large.jpg",
+			Candidates: []ImageCandidate{
+				{URL: base + "/img/lazy-small.jpg", Descriptor: "1x"},
+				{URL: base + "/img/lazy-large.jpg", Descriptor: "2x"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(result.ResponsiveImages, want) {
+		t.Errorf("ResponsiveImages =\n%+v\nwant\n%+v", result.ResponsiveImages, want)
+	}
+	for _, best := range []string{base + "/img/boats-1600.jpg", "https://cdn.example.com/lanterns@3x.jpg", base + "/img/lazy-large.jpg", base + "/img/plain.jpg"} {
+		if !slices.Contains(result.Images, best) {
+			t.Errorf("Images = %q, want %s", result.Images, best)
+		}
+	}
+	if result.MainImage != base+"/img/boats-1600.jpg" {
+		t.Errorf("MainImage = %q, want the first image upgraded to its largest candidate", result.MainImage)
+	}
+
+	plain, err := newTestService(t, Config{}).ScrapeURL(context.Background(), base, "")
+	if err != nil {
+		t.Fatalf("ScrapeURL: %v", err)
+	}
+	if plain.ResponsiveImages != nil || slices.Contains(plain.Images, base+"/img/boats-1600.jpg") {
+		t.Errorf("srcset resolved without ResolveSrcset: %+v", plain.ResponsiveImages)
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		name   string
+		srcset string
+		want   []ImageCandidate
+		best   string
+	}{
+		{name: "widths", srcset: "a.jpg 480w, b.jpg 960w", want: []ImageCandidate{{"a.jpg", "480w"}, {"b.jpg", "960w"}}, best: "b.jpg"},
+		{name: "densities with a bare 1x", srcset: "a.jpg, b.jpg 1.5x", want: []ImageCandidate{{"a.jpg", ""}, {"b.jpg", "1.5x"}}, best: "b.jpg"},
+		{name: "comma in URL", srcset: "img.php?w=1,2 1x, img.php?w=3,4 2x", want: []ImageCandidate{{"img.php?w=1,2", "1x"}, {"img.php?w=3,4", "2x"}}, best: "img.php?w=3,4"},
+		{name: "widths beat densities", srcset: "a.jpg 3x, b.jpg 200w", want: []ImageCandidate{{"a.jpg", "3x"}, {"b.jpg", "200w"}}, best: "b.jpg"},
+		{name: "extra whitespace", srcset: "  a.jpg   2x ,\n b.jpg 1x  ", want: []ImageCandidate{{"a.jpg", "2x"}, {"b.jpg", "1x"}}, best: "a.jpg"},
+		{name: "empty", srcset: "", want: nil, best: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSrcset(tt.srcset)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSrcset(%q) = %+v, want %+v", tt.srcset, got, tt.want)
+			}
+			if best := bestCandidate(got); best != tt.best {
+				t.Errorf("bestCandidate = %q, want %q", best, tt.best)
+			}
+		})
+	}
+}
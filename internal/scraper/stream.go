@@ -0,0 +1,153 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultChunkSize is the chunk size TextChunks uses when none is given
+const DefaultChunkSize = 8000
+
+// streamSkipTags hold no readable content; everything inside them is dropped
+var streamSkipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"nav": true, "header": true, "footer": true, "aside": true, "head": true,
+}
+
+// streamBlockTags end a paragraph, so text on either side is not run together
+var streamBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true,
+	"article": true, "blockquote": true, "pre": true, "table": true, "ul": true, "ol": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// StreamInfo describes a page read by TextChunks. It is only complete once the
+// chunks have been read to the end.
+type StreamInfo struct {
+	// Title is the text of the page's <title> element
+	Title string
+	// WordCount is the number of words in the chunks yielded so far
+	WordCount int
+	// Response describes the response the chunks were read from
+	Response *ResponseInfo
+}
+
+// TextChunks fetches url and yields its readable text in chunks of roughly
+// chunkSize characters (zero means DefaultChunkSize) as the body is parsed, so
+// neither the page nor its text is ever held whole. The body is fetched with
+// FetchReader, so it is charset-decoded, retried, and capped the same way.
+// Chunks end at paragraph breaks where possible; a paragraph longer than twice
+// chunkSize is split between words. Extraction is a lighter pass than ScrapeURL:
+// it drops scripts, styles, and page chrome but does not pick out a main-content
+// element. When info is not nil it is filled in as the page is read. Reading stops
+// as soon as the consumer stops, and a failure is yielded as the final error.
+func (s *Service) TextChunks(ctx context.Context, url string, chunkSize int, info *StreamInfo) iter.Seq2[string, error] {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if info == nil {
+		info = &StreamInfo{}
+	}
+	return func(yield func(string, error) bool) {
+		body, response, err := s.FetchReader(ctx, url)
+		info.Response = response
+		if err != nil {
+			yield("", err)
+			return
+		}
+		defer body.Close()
+
+		var chunk, paragraph, title strings.Builder
+		// flush moves the paragraph into the chunk and reports whether the chunk is full
+		flush := func() bool {
+			words := strings.Fields(paragraph.String())
+			paragraph.Reset()
+			if len(words) > 0 {
+				if chunk.Len() > 0 {
+					chunk.WriteString("\n\n")
+				}
+				chunk.WriteString(strings.Join(words, " "))
+				info.WordCount += len(words)
+			}
+			return chunk.Len() >= chunkSize
+		}
+		emit := func() bool {
+			text := chunk.String()
+			chunk.Reset()
+			return text == "" || yield(text, nil)
+		}
+
+		tokenizer := html.NewTokenizer(body)
+		skipDepth := 0
+		inTitle := false
+		for {
+			switch tokenType := tokenizer.Next(); tokenType {
+			case html.ErrorToken:
+				if err := tokenizer.Err(); !errors.Is(err, io.EOF) {
+					yield("", fmt.Errorf("failed to read %s: %w", url, err))
+					return
+				}
+				flush()
+				emit()
+				return
+			case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+				name, _ := tokenizer.TagName()
+				tag := string(name)
+				// Only the first title counts; an <svg> may carry its own
+				if tag == "title" && tokenType == html.StartTagToken {
+					inTitle = info.Title == ""
+				} else if tag == "title" && inTitle {
+					info.Title = strings.Join(strings.Fields(title.String()), " ")
+					inTitle = false
+				}
+				if streamSkipTags[tag] {
+					switch tokenType {
+					case html.StartTagToken:
+						skipDepth++
+					case html.EndTagToken:
+						skipDepth = max(skipDepth-1, 0)
+					}
+					continue
+				}
+				if streamBlockTags[tag] && flush() && !emit() {
+					return
+				}
+			case html.TextToken:
+				if inTitle {
+					title.Write(tokenizer.Text())
+					continue
+				}
+				if skipDepth > 0 {
+					continue
+				}
+				paragraph.Write(tokenizer.Text())
+				paragraph.WriteByte(' ')
+				// Split a runaway paragraph between words to keep chunks bounded
+				for paragraph.Len() >= 2*chunkSize {
+					text := paragraph.String()
+					cut := strings.LastIndexByte(text[:chunkSize], ' ')
+					if cut <= 0 {
+						cut = chunkSize
+						for cut > 1 && !utf8.RuneStart(text[cut]) {
+							cut--
+						}
+					}
+					paragraph.Reset()
+					paragraph.WriteString(text[:cut])
+					flush()
+					if !emit() {
+						return
+					}
+					paragraph.WriteString(text[cut:])
+				}
+			}
+		}
+	}
+}
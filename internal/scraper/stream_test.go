@@ -0,0 +1,147 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// largePage is a page of paragraphs paragraphs of ten words each, with chrome
+// and scripts that TextChunks drops
+func largePage(paragraphs int) string {
+	var page strings.Builder
+	page.WriteString(`<html><head><title>  Annual   report </title><script>var tracking = "no";</script></head><body>`)
+	page.WriteString(`<nav><a href="/">Home</a></nav><article>`)
+	for i := range paragraphs {
+		fmt.Fprintf(&page, "<p>Paragraph %05d of the report covers one more budget line.</p>\n", i)
+	}
+	page.WriteString(`</article><footer>Copyright</footer></body></html>`)
+	return page.String()
+}
+
+func TestTextChunksBoundsMemory(t *testing.T) {
+	const paragraphs, chunkSize = 2000, 4000
+	server := serveHTML(t, largePage(paragraphs))
+	service := newTestService(t, Config{})
+
+	var info StreamInfo
+	var count, total, largest int
+	for chunk, err := range service.TextChunks(context.Background(), server.URL, chunkSize, &info) {
+		if err != nil {
+			t.Fatalf("TextChunks: %v", err)
+		}
+		count++
+		total += len(chunk)
+		largest = max(largest, len(chunk))
+		if strings.Contains(chunk, "tracking") || strings.Contains(chunk, "Copyright") || strings.Contains(chunk, "Annual") {
+			t.Fatalf("chunk %d kept scripts, chrome, or the title: %q", count, chunk)
+		}
+	}
+
+	// Chunks fill to chunkSize and stop at the next paragraph, so none is held much longer
+	if largest > chunkSize+100 {
+		t.Errorf("largest chunk is %d bytes, want about %d", largest, chunkSize)
+	}
+	if want := total / chunkSize; count < want || count > want+1 {
+		t.Errorf("got %d chunks for %d bytes of text, want %d or %d", count, total, want, want+1)
+	}
+	if info.Title != "Annual report" || info.WordCount != paragraphs*10 {
+		t.Errorf("info = %q with %d words, want the title and %d words", info.Title, info.WordCount, paragraphs*10)
+	}
+	if info.Response == nil || info.Response.StatusCode != http.StatusOK {
+		t.Errorf("info.Response = %+v, want the 200 response", info.Response)
+	}
+}
+
+func TestTextChunksSplitsLongParagraph(t *testing.T) {
+	const chunkSize = 500
+	server := serveHTML(t, "<html><body><p>"+strings.Repeat("word ", 1000)+"</p></body></html>")
+	var count int
+	for chunk, err := range newTestService(t, Config{}).TextChunks(context.Background(), server.URL, chunkSize, nil) {
+		if err != nil {
+			t.Fatalf("TextChunks: %v", err)
+		}
+		count++
+		if len(chunk) > 2*chunkSize {
+			t.Errorf("chunk %d is %d bytes, want at most %d", count, len(chunk), 2*chunkSize)
+		}
+	}
+	if count < 5000/(2*chunkSize) {
+		t.Errorf("got %d chunks, want the paragraph split", count)
+	}
+}
+
+func TestTextChunksStopsWithConsumer(t *testing.T) {
+	server := serveHTML(t, largePage(2000))
+	var info StreamInfo
+	count := 0
+	for _, err := range newTestService(t, Config{}).TextChunks(context.Background(), server.URL, 1000, &info) {
+		if err != nil {
+			t.Fatalf("TextChunks: %v", err)
+		}
+		if count++; count == 3 {
+			break
+		}
+	}
+	if count != 3 || info.WordCount > 1000 {
+		t.Errorf("read %d chunks and %d words, want reading to stop after 3 chunks", count, info.WordCount)
+	}
+}
+
+func TestTextChunksDecodesCharset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		// "Café crème" in Latin-1
+		w.Write([]byte("<html><head><title>Caf\xe9</title></head><body><p>Caf\xe9 cr\xe8me for everyone.</p></body></html>"))
+	}))
+	t.Cleanup(server.Close)
+
+	var info StreamInfo
+	var text []string
+	for chunk, err := range newTestService(t, Config{}).TextChunks(context.Background(), server.URL, 0, &info) {
+		if err != nil {
+			t.Fatalf("TextChunks: %v", err)
+		}
+		text = append(text, chunk)
+	}
+	if got := strings.Join(text, ""); got != "Café crème for everyone." || info.Title != "Café" || !info.Response.Decoded {
+		t.Errorf("text = %q, title = %q, decoded = %v, want UTF-8", got, info.Title, info.Response.Decoded)
+	}
+}
+
+func TestTextChunksRetries(t *testing.T) {
+	const page = `<html><body><p>Served once the outage ended.</p></body></html>`
+	retryNow := func(resp *http.Response, err error) time.Duration {
+		if resp != nil && resp.StatusCode >= 500 {
+			return 0
+		}
+		return -1
+	}
+
+	server, hits := flakyServer(t, 2, http.StatusServiceUnavailable, page)
+	service := newTestService(t, Config{MaxRetries: 3, ShouldRetry: retryNow})
+	var info StreamInfo
+	var text []string
+	for chunk, err := range service.TextChunks(context.Background(), server.URL, 0, &info) {
+		if err != nil {
+			t.Fatalf("TextChunks: %v", err)
+		}
+		text = append(text, chunk)
+	}
+	if hits.Load() != 3 || info.Response.Attempts != 3 || strings.Join(text, "") != "Served once the outage ended." {
+		t.Errorf("got %q after %d requests (Attempts %d), want the page after 3", text, hits.Load(), info.Response.Attempts)
+	}
+
+	server, _ = flakyServer(t, 1, http.StatusNotFound, page)
+	for _, err := range service.TextChunks(context.Background(), server.URL, 0, nil) {
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			t.Errorf("TextChunks error = %v, want a 404 StatusError", err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package scraper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ThreadSelectors locate a discussion thread's root post and comments on one site,
+// for Config.ThreadSites. Comment depth comes from DepthAttr when set (for flat
+// layouts such as Hacker News, whose comments are sibling rows with an indent
+// attribute), and otherwise from how deeply comment nodes are nested in each other
+// (as on old Reddit).
+type ThreadSelectors struct {
+	// Post matches the root post; its text becomes the thread's root node
+	Post string
+	// Comment matches each comment node
+	Comment string
+	// Body matches a comment's text within its node; empty uses the node's text
+	// without its nested replies
+	Body string
+	// Author optionally matches the author's name within the post and each comment
+	Author string
+	// DepthAttr names an attribute holding a comment's depth (0 for a top-level
+	// comment), read from the first element matching DepthSelector in the comment
+	// node, or from the node itself when DepthSelector is empty
+	DepthAttr     string
+	DepthSelector string
+}
+
+// ThreadNode is the root post or a comment of a discussion thread
+type ThreadNode struct {
+	Author string `json:"author,omitempty"`
+	Text   string `json:"text"`
+	// Depth is 0 for the root post, 1 for top-level comments, and so on
+	Depth   int           `json:"depth"`
+	Replies []*ThreadNode `json:"replies,omitempty"`
+}
+
+// Count returns the number of nodes in the thread, including n itself
+func (n *ThreadNode) Count() int {
+	count := 1
+	for _, reply := range n.Replies {
+		count += reply.Count()
+	}
+	return count
+}
+
+// String renders the thread as text with replies indented under their parents,
+// the form the summarizer's "discussion" style expects
+func (n *ThreadNode) String() string {
+	var b strings.Builder
+	n.write(&b)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// write appends the node and its replies to b
+func (n *ThreadNode) write(b *strings.Builder) {
+	indent := strings.Repeat("  ", n.Depth)
+	author := n.Author
+	if author == "" {
+		author = "anonymous"
+	}
+	if n.Depth == 0 {
+		// A thread whose post was not found starts with its comments
+		if n.Text != "" {
+			fmt.Fprintf(b, "Post by %s: %s\n", author, n.Text)
+		}
+	} else {
+		fmt.Fprintf(b, "%s- %s: %s\n", indent, author, n.Text)
+	}
+	for _, reply := range n.Replies {
+		reply.write(b)
+	}
+}
+
+// threadSelectorsFor returns the selectors configured for a host, ignoring a leading "www."
+func (s *Service) threadSelectorsFor(host string) (ThreadSelectors, bool) {
+	host = strings.ToLower(host)
+	if selectors, ok := s.config.ThreadSites[host]; ok {
+		return selectors, true
+	}
+	selectors, ok := s.config.ThreadSites[strings.TrimPrefix(host, "www.")]
+	return selectors, ok
+}
+
+// extractThread builds the thread tree of a page, or returns nil when the page has
+// neither the post nor any comments
+func extractThread(doc *goquery.Selection, selectors ThreadSelectors) *ThreadNode {
+	root := &ThreadNode{}
+	if post := doc.Find(selectors.Post).First(); selectors.Post != "" && post.Length() > 0 {
+		own := post.Clone()
+		if selectors.Author != "" {
+			root.Author = collapseSpace(post.Find(selectors.Author).First().Text())
+			own.Find(selectors.Author).Remove()
+		}
+		root.Text = collapseSpace(own.Text())
+	}
+
+	// Attach comments in document order: each goes under the nearest preceding
+	// comment that is shallower than it
+	parents := []*ThreadNode{root}
+	doc.Find(selectors.Comment).Each(func(i int, comment *goquery.Selection) {
+		node := &ThreadNode{
+			Text:  commentText(comment, selectors),
+			Depth: commentDepth(comment, selectors) + 1,
+		}
+		if node.Text == "" {
+			return
+		}
+		if selectors.Author != "" {
+			node.Author = collapseSpace(comment.Find(selectors.Author).First().Text())
+		}
+		for len(parents) > 1 && parents[len(parents)-1].Depth >= node.Depth {
+			parents = parents[:len(parents)-1]
+		}
+		parent := parents[len(parents)-1]
+		// A comment deeper than its parent's child level is clamped under it
+		node.Depth = min(node.Depth, parent.Depth+1)
+		parent.Replies = append(parent.Replies, node)
+		parents = append(parents, node)
+	})
+
+	if root.Text == "" && len(root.Replies) == 0 {
+		return nil
+	}
+	return root
+}
+
+// commentText returns a comment's own text, leaving out nested replies
+func commentText(comment *goquery.Selection, selectors ThreadSelectors) string {
+	if selectors.Body != "" {
+		return collapseSpace(comment.Find(selectors.Body).First().Text())
+	}
+	own := comment.Clone()
+	own.Find(selectors.Comment).Remove()
+	if selectors.Author != "" {
+		own.Find(selectors.Author).Remove()
+	}
+	return collapseSpace(own.Text())
+}
+
+// commentDepth returns how deep a comment is, 0 being a top-level comment
+func commentDepth(comment *goquery.Selection, selectors ThreadSelectors) int {
+	if selectors.DepthAttr == "" {
+		return comment.ParentsFiltered(selectors.Comment).Length()
+	}
+	holder := comment
+	if selectors.DepthSelector != "" {
+		holder = comment.Find(selectors.DepthSelector).First()
+	}
+	depth, err := strconv.Atoi(strings.TrimSpace(holder.AttrOr(selectors.DepthAttr, "")))
+	if err != nil || depth < 0 {
+		return 0
+	}
+	return depth
+}
+
+// collapseSpace joins the words of text with single spaces
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,110 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// nestedThread is an old-Reddit style page whose replies are nested inside the
+// comment they answer
+const nestedThread = `<html><head><title>Should the city build a new library?</title></head><body>
+<div class="post"><span class="author">mayor_jo</span><p>We plan a new central library. Thoughts?</p></div>
+<div class="comments">
+	<div class="comment"><span class="author">reader1</span><p class="body">Yes, the old one is too small.</p>
+		<div class="comment"><span class="author">reader2</span><p class="body">Agreed, it is always full.</p>
+			<div class="comment"><span class="author">reader3</span><p class="body">Even on weekdays.</p></div>
+		</div>
+		<div class="comment"><span class="author">skeptic</span><p class="body">Renovating would be cheaper.</p></div>
+	</div>
+	<div class="comment"><span class="author">taxpayer</span><p class="body">Who pays for it?</p></div>
+	<div class="comment"><span class="author">ghost</span><p class="body">  </p></div>
+</div>
+</body></html>`
+
+// flatThread is a Hacker News style page whose comments are sibling rows with
+// an indent attribute
+const flatThread = `<html><head><title>Show HN: a tiny scraper</title></head><body>
+<table>
+	<tr class="athing comtr"><td><table><tr><td class="ind" indent="0"></td><td><a class="hnuser">alice</a><div class="commtext">Nice work.</div></td></tr></table></td></tr>
+	<tr class="athing comtr"><td><table><tr><td class="ind" indent="1"></td><td><a class="hnuser">bob</a><div class="commtext">How does it handle JS?</div></td></tr></table></td></tr>
+	<tr class="athing comtr"><td><table><tr><td class="ind" indent="5"></td><td><a class="hnuser">carol</a><div class="commtext">It does not.</div></td></tr></table></td></tr>
+	<tr class="athing comtr"><td><table><tr><td class="ind" indent="0"></td><td><a class="hnuser">dave</a><div class="commtext">Benchmarks?</div></td></tr></table></td></tr>
+</table>
+</body></html>`
+
+// threadOf scrapes page with selectors configured for the test server's host
+func threadOf(t *testing.T, page string, selectors ThreadSelectors) *Result {
+	t.Helper()
+	server := serveHTML(t, page)
+	parsed, _ := url.Parse(server.URL)
+	config := Config{ThreadSites: map[string]ThreadSelectors{parsed.Hostname(): selectors}}
+	result, err := newTestService(t, config).ScrapeURL(context.Background(), server.URL, "")
+	if err != nil {
+		t.Fatalf("ScrapeURL: %v", err)
+	}
+	return result
+}
+
+func TestExtractThreadNested(t *testing.T) {
+	result := threadOf(t, nestedThread, ThreadSelectors{Post: ".post", Comment: ".comment", Body: ".body", Author: ".author"})
+	thread := result.Thread
+	if thread == nil {
+		t.Fatal("Thread is nil")
+	}
+	if thread.Author != "mayor_jo" || thread.Text != "We plan a new central library. Thoughts?" || thread.Depth != 0 {
+		t.Errorf("root = %+v, want the post by mayor_jo", thread)
+	}
+	if got := thread.Count(); got != 6 {
+		t.Errorf("Count() = %d, want the post and 5 comments with text", got)
+	}
+	if result.Metadata["thread_comments"] != "5" {
+		t.Errorf("thread_comments = %q, want 5", result.Metadata["thread_comments"])
+	}
+
+	want := strings.Join([]string{
+		"Post by mayor_jo: We plan a new central library. Thoughts?",
+		"  - reader1: Yes, the old one is too small.",
+		"    - reader2: Agreed, it is always full.",
+		"      - reader3: Even on weekdays.",
+		"    - skeptic: Renovating would be cheaper.",
+		"  - taxpayer: Who pays for it?",
+	}, "\n")
+	if got := thread.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExtractThreadFlat(t *testing.T) {
+	result := threadOf(t, flatThread, ThreadSelectors{
+		Comment:       "tr.comtr",
+		Body:          ".commtext",
+		Author:        ".hnuser",
+		DepthAttr:     "indent",
+		DepthSelector: "td.ind",
+	})
+	if result.Thread == nil {
+		t.Fatal("Thread is nil")
+	}
+	// The post is missing, and carol's indent of 5 is clamped under bob
+	want := strings.Join([]string{
+		"  - alice: Nice work.",
+		"    - bob: How does it handle JS?",
+		"      - carol: It does not.",
+		"  - dave: Benchmarks?",
+	}, "\n")
+	if got := result.Thread.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExtractThreadOptIn(t *testing.T) {
+	result := scrapeHTML(t, Config{ThreadSites: map[string]ThreadSelectors{"news.example.com": {Comment: ".comment"}}}, nestedThread)
+	if result.Thread != nil {
+		t.Errorf("Thread = %s, want none on a host without selectors", result.Thread)
+	}
+	if !strings.Contains(result.CleanText, "Renovating would be cheaper.") {
+		t.Errorf("CleanText = %q, want the page scraped as usual", result.CleanText)
+	}
+}
@@ -0,0 +1,176 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// RawResponse is one HTTP exchange behind a Result, kept for archiving. Body is
+// the body as the client delivered it: already decompressed and possibly
+// converted to UTF-8, so it is not always byte-identical to what was sent.
+type RawResponse struct {
+	FetchedAt     time.Time
+	Method        string
+	URL           string // the final URL, after any redirects
+	RequestHeader http.Header
+	StatusCode    int
+	Header        http.Header
+	Body          []byte
+}
+
+// captureRaw copies a response's exchange so later callbacks cannot change it
+func captureRaw(r *colly.Response) RawResponse {
+	raw := RawResponse{
+		FetchedAt:  time.Now().UTC(),
+		Method:     r.Request.Method,
+		URL:        r.Request.URL.String(),
+		StatusCode: r.StatusCode,
+		Body:       bytes.Clone(r.Body),
+	}
+	if r.Request.Headers != nil {
+		raw.RequestHeader = r.Request.Headers.Clone()
+	}
+	if r.Headers != nil {
+		raw.Header = r.Headers.Clone()
+	}
+	return raw
+}
+
+// SaveWARC writes the raw exchanges of the results to w as WARC 1.1 records: a
+// response record and the request record concurrent to it for every page read.
+// The results must have been scraped with Config.CaptureRaw; nothing is written otherwise.
+func SaveWARC(w io.Writer, results ...*Result) error {
+	for _, result := range results {
+		if result == nil {
+			return fmt.Errorf("cannot archive a nil result")
+		}
+		if len(result.Raw) == 0 {
+			return fmt.Errorf("no raw response captured for %s; scrape with Config.CaptureRaw", result.URL)
+		}
+	}
+
+	for _, result := range results {
+		for _, raw := range result.Raw {
+			if err := writeWARCExchange(w, raw); err != nil {
+				return fmt.Errorf("failed to write WARC records for %s: %w", raw.URL, err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeWARCExchange writes the response record and the request record that refers to it
+func writeWARCExchange(w io.Writer, raw RawResponse) error {
+	target, err := url.Parse(raw.URL)
+	if err != nil {
+		return err
+	}
+	date := raw.FetchedAt.UTC().Format(time.RFC3339)
+
+	// The body has been decoded, so its headers must describe it as sent here
+	header := raw.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Del("Content-Encoding")
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(raw.Body)))
+
+	var response bytes.Buffer
+	fmt.Fprintf(&response, "HTTP/1.1 %d %s\r\n", raw.StatusCode, http.StatusText(raw.StatusCode))
+	writeHTTPHeader(&response, header)
+	response.Write(raw.Body)
+
+	method := raw.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	requestHeader := raw.RequestHeader.Clone()
+	if requestHeader == nil {
+		requestHeader = http.Header{}
+	}
+	requestHeader.Set("Host", target.Host)
+	var request bytes.Buffer
+	fmt.Fprintf(&request, "%s %s HTTP/1.1\r\n", method, target.RequestURI())
+	writeHTTPHeader(&request, requestHeader)
+
+	responseID, err := warcRecordID()
+	if err != nil {
+		return err
+	}
+	requestID, err := warcRecordID()
+	if err != nil {
+		return err
+	}
+	if err := writeWARCRecord(w, []string{
+		"WARC-Type: response",
+		"WARC-Record-ID: " + responseID,
+		"WARC-Date: " + date,
+		"WARC-Target-URI: " + raw.URL,
+		"Content-Type: application/http;msgtype=response",
+	}, response.Bytes()); err != nil {
+		return err
+	}
+	return writeWARCRecord(w, []string{
+		"WARC-Type: request",
+		"WARC-Record-ID: " + requestID,
+		"WARC-Date: " + date,
+		"WARC-Target-URI: " + raw.URL,
+		"WARC-Concurrent-To: " + responseID,
+		"Content-Type: application/http;msgtype=request",
+	}, request.Bytes())
+}
+
+// writeWARCRecord writes one record: the version line, the given fields, the
+// block's digest and length, the block, and the two-CRLF record terminator
+func writeWARCRecord(w io.Writer, fields []string, block []byte) error {
+	digest := sha1.Sum(block)
+	var record bytes.Buffer
+	record.WriteString("WARC/1.1\r\n")
+	for _, field := range fields {
+		record.WriteString(field + "\r\n")
+	}
+	fmt.Fprintf(&record, "WARC-Block-Digest: sha1:%s\r\n", base32.StdEncoding.EncodeToString(digest[:]))
+	fmt.Fprintf(&record, "Content-Length: %d\r\n\r\n", len(block))
+	record.Write(block)
+	record.WriteString("\r\n\r\n")
+	_, err := w.Write(record.Bytes())
+	return err
+}
+
+// writeHTTPHeader writes header fields in sorted order, then the blank line ending the header
+func writeHTTPHeader(buf *bytes.Buffer, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+// warcRecordID returns a random (version 4) UUID URN enclosed in angle brackets
+func warcRecordID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("failed to generate a record ID: %w", err)
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]), nil
+}
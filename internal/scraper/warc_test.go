@@ -0,0 +1,132 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// warcRecord is one record read back from a WARC stream
+type warcRecord struct {
+	fields textproto.MIMEHeader
+	block  []byte
+}
+
+// readWARC parses every record in data, failing the test on malformed framing
+func readWARC(t *testing.T, data []byte) []warcRecord {
+	t.Helper()
+	reader := bufio.NewReader(bytes.NewReader(data))
+	var records []warcRecord
+	for {
+		version, err := reader.ReadString('\n')
+		if err == io.EOF && version == "" {
+			return records
+		}
+		if version != "WARC/1.1\r\n" {
+			t.Fatalf("record %d starts with %q, want the WARC/1.1 version line", len(records), version)
+		}
+		fields, err := textproto.NewReader(reader).ReadMIMEHeader()
+		if err != nil {
+			t.Fatalf("record %d header: %v", len(records), err)
+		}
+		length, err := strconv.Atoi(fields.Get("Content-Length"))
+		if err != nil {
+			t.Fatalf("record %d Content-Length: %v", len(records), err)
+		}
+		block := make([]byte, length)
+		if _, err := io.ReadFull(reader, block); err != nil {
+			t.Fatalf("record %d block: %v", len(records), err)
+		}
+		terminator := make([]byte, 4)
+		if _, err := io.ReadFull(reader, terminator); err != nil || string(terminator) != "\r\n\r\n" {
+			t.Fatalf("record %d ends with %q, want two CRLFs", len(records), terminator)
+		}
+		records = append(records, warcRecord{fields: fields, block: block})
+	}
+}
+
+func TestSaveWARC(t *testing.T) {
+	server := servePages(t, map[string]string{
+		"/first":  `<html><head><title>First</title></head><body><p>The first archived page.</p></body></html>`,
+		"/second": `<html><head><title>Second</title></head><body><p>The second archived page.</p></body></html>`,
+	})
+	service := newTestService(t, Config{CaptureRaw: true, UserAgent: "archiver/1.0"})
+	var results []*Result
+	for _, path := range []string{"/first", "/second"} {
+		result, err := service.ScrapeURL(context.Background(), server.URL+path, "")
+		if err != nil {
+			t.Fatalf("ScrapeURL(%s): %v", path, err)
+		}
+		results = append(results, result)
+	}
+
+	var archive bytes.Buffer
+	if err := SaveWARC(&archive, results...); err != nil {
+		t.Fatalf("SaveWARC: %v", err)
+	}
+	records := readWARC(t, archive.Bytes())
+	if len(records) != 4 {
+		t.Fatalf("read %d records, want a response and a request per page", len(records))
+	}
+
+	for i, path := range []string{"/first", "/second"} {
+		response, request := records[2*i], records[2*i+1]
+		if response.fields.Get("WARC-Type") != "response" || request.fields.Get("WARC-Type") != "request" {
+			t.Errorf("%s record types = %q, %q, want response then request", path, response.fields.Get("WARC-Type"), request.fields.Get("WARC-Type"))
+		}
+		if got := response.fields.Get("WARC-Target-URI"); got != server.URL+path {
+			t.Errorf("%s WARC-Target-URI = %q", path, got)
+		}
+		if request.fields.Get("WARC-Concurrent-To") != response.fields.Get("WARC-Record-ID") {
+			t.Errorf("%s request is concurrent to %q, want the response %q", path, request.fields.Get("WARC-Concurrent-To"), response.fields.Get("WARC-Record-ID"))
+		}
+		if response.fields.Get("WARC-Record-ID") == request.fields.Get("WARC-Record-ID") {
+			t.Errorf("%s records share the ID %q", path, request.fields.Get("WARC-Record-ID"))
+		}
+		for _, record := range []warcRecord{response, request} {
+			digest := sha1.Sum(record.block)
+			if want := "sha1:" + base32.StdEncoding.EncodeToString(digest[:]); record.fields.Get("WARC-Block-Digest") != want {
+				t.Errorf("%s WARC-Block-Digest = %q, want %q", path, record.fields.Get("WARC-Block-Digest"), want)
+			}
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response.block)), nil)
+		if err != nil {
+			t.Fatalf("%s response block: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "archived page") {
+			t.Errorf("%s archived response = %d %q", path, resp.StatusCode, body)
+		}
+
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(request.block)))
+		if err != nil {
+			t.Fatalf("%s request block: %v", path, err)
+		}
+		if req.Method != http.MethodGet || req.URL.Path != path || req.UserAgent() != "archiver/1.0" {
+			t.Errorf("%s archived request = %s %s (User-Agent %q)", path, req.Method, req.URL, req.UserAgent())
+		}
+	}
+}
+
+func TestSaveWARCRequiresCapture(t *testing.T) {
+	result := scrapeHTML(t, Config{}, `<html><body><p>Not captured.</p></body></html>`)
+	var archive bytes.Buffer
+	if err := SaveWARC(&archive, result); err == nil || !strings.Contains(err.Error(), "CaptureRaw") {
+		t.Errorf("SaveWARC error = %v, want a hint to set CaptureRaw", err)
+	}
+	if err := SaveWARC(&archive, nil); err == nil {
+		t.Error("SaveWARC accepted a nil result")
+	}
+	if archive.Len() != 0 {
+		t.Errorf("SaveWARC wrote %d bytes before failing", archive.Len())
+	}
+}
@@ -0,0 +1,152 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/retry"
+	"github.com/rs/zerolog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// EmbeddingConfig represents the configuration of an OpenAI-compatible /embeddings endpoint
+type EmbeddingConfig struct {
+	APIKey  string
+	BaseURL string // For custom OpenAI-compatible endpoints
+	Model   string
+	// BatchSize is the most texts sent in one request; zero means 100
+	BatchSize int
+	// Retry governs retries of failed requests. A zero MaxAttempts retries up to
+	// three times from a 1s backoff; without Retry.Retryable, only rate limits
+	// and server errors are retried.
+	Retry retry.Policy
+	// Transport replaces the default HTTP transport
+	Transport http.RoundTripper
+}
+
+// defaultEmbeddingRetry is the retry policy used when EmbeddingConfig.Retry sets no MaxAttempts
+var defaultEmbeddingRetry = retry.Policy{MaxAttempts: 4, BaseDelay: time.Second, Jitter: 0.2}
+
+// Embedder turns text into embedding vectors
+type Embedder struct {
+	client *openai.Client
+	config EmbeddingConfig
+	logger zerolog.Logger
+}
+
+// NewEmbedder creates a new embeddings client
+func NewEmbedder(config EmbeddingConfig, logger zerolog.Logger) *Embedder {
+	clientConfig := openai.DefaultConfig(config.APIKey)
+
+	// Support custom OpenAI-compatible endpoints
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = config.BaseURL
+	}
+	clientConfig.HTTPClient = &http.Client{Transport: retry.Transport(config.Transport)}
+
+	return &Embedder{
+		client: openai.NewClientWithConfig(clientConfig),
+		config: config,
+		logger: logger.With().Str("component", "embedder").Logger(),
+	}
+}
+
+// Embed returns one embedding per text, in input order. Texts are sent in
+// batches of EmbeddingConfig.BatchSize, and failed batches are retried per
+// EmbeddingConfig.Retry.
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	batchSize := e.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		end := min(start+batchSize, len(texts))
+		batch, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed texts %d-%d: %w", start, end-1, err)
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch embeds one batch, retrying failures per EmbeddingConfig.Retry
+func (e *Embedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	policy := e.config.Retry
+	if policy.MaxAttempts == 0 {
+		policy = defaultEmbeddingRetry
+		policy.Retryable = e.config.Retry.Retryable
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = isRetryableAPIError
+	}
+
+	var resp openai.EmbeddingResponse
+	attempt := 0
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		ctx, hint := retry.WithHint(ctx)
+		attempt++
+		var err error
+		resp, err = e.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+			Input: texts,
+			Model: openai.EmbeddingModel(e.config.Model),
+		})
+		if err != nil {
+			e.logger.Warn().Err(err).Int("attempt", attempt).Msg("Embedding request failed")
+		}
+		return hint.Wrap(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+	// Providers may return the embeddings out of order; Index is authoritative
+	vectors := make([][]float32, len(texts))
+	for _, embedding := range resp.Data {
+		if embedding.Index < 0 || embedding.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", embedding.Index)
+		}
+		vectors[embedding.Index] = embedding.Embedding
+	}
+	return vectors, nil
+}
+
+// isRetryableAPIError reports whether err is a provider error with a transient status
+// such as a rate limit or server error
+func isRetryableAPIError(err error) bool {
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		return retry.IsRetryableStatus(apiErr.HTTPStatusCode)
+	case errors.As(err, &requestErr):
+		return retry.IsRetryableStatus(requestErr.HTTPStatusCode)
+	}
+	return false
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors
+func cosineSimilarity(a, b []float32) (float64, error) {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0, fmt.Errorf("embeddings have mismatched dimensions")
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0, nil
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), nil
+}
@@ -0,0 +1,154 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HeidiZHH/skull/internal/retry"
+	"github.com/rs/zerolog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newFakeEmbeddings serves an OpenAI-compatible /embeddings endpoint that fails
+// the first failures requests with status and then embeds each text as
+// {len(text), position in batch}, listing the embeddings in reverse order.
+// It returns the server and a count of requests received.
+func newFakeEmbeddings(t *testing.T, failures int, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= failures {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error": {"message": "try again later", "type": "server_error"}}`))
+			return
+		}
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := openai.EmbeddingResponse{Object: "list"}
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, openai.Embedding{
+				Object:    "embedding",
+				Index:     i,
+				Embedding: []float32{float32(len(req.Input[i])), float32(i)},
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestEmbed(t *testing.T) {
+	server, calls := newFakeEmbeddings(t, 0, 0)
+	embedder := NewEmbedder(EmbeddingConfig{
+		BaseURL:   server.URL + "/v1",
+		Model:     "test-embedding",
+		BatchSize: 2,
+	}, zerolog.Nop())
+
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "bb", "ccc"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	want := [][]float32{{1, 0}, {2, 1}, {3, 0}}
+	if !slices.EqualFunc(vectors, want, slices.Equal) {
+		t.Errorf("Embed() = %v, want %v", vectors, want)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("made %d requests, want 2 batches", got)
+	}
+}
+
+func TestEmbeddingRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		status    int
+		policy    retry.Policy
+		wantCalls int32
+		wantErr   bool
+	}{
+		{
+			name:      "rate limits retried until success",
+			failures:  2,
+			status:    http.StatusTooManyRequests,
+			policy:    retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			wantCalls: 3,
+		},
+		{
+			name:      "attempts run out",
+			failures:  2,
+			status:    http.StatusServiceUnavailable,
+			policy:    retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+			wantCalls: 2,
+			wantErr:   true,
+		},
+		{
+			name:      "client errors not retried",
+			failures:  1,
+			status:    http.StatusBadRequest,
+			policy:    retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:     "custom Retryable",
+			failures: 1,
+			status:   http.StatusBadRequest,
+			policy: retry.Policy{
+				MaxAttempts: 2,
+				BaseDelay:   time.Millisecond,
+				Retryable:   func(error) bool { return true },
+			},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := newFakeEmbeddings(t, tt.failures, tt.status)
+			service, err := NewService(Config{
+				APIKey:         "test-key",
+				BaseURL:        server.URL + "/v1",
+				Model:          "test-model",
+				EmbeddingModel: "test-embedding",
+				EmbeddingRetry: tt.policy,
+			}, zerolog.Nop())
+			if err != nil {
+				t.Fatalf("NewService() error = %v", err)
+			}
+
+			_, err = service.Embed(context.Background(), []string{"text"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Embed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("made %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestEmbeddingRetryValidated(t *testing.T) {
+	_, err := NewService(Config{
+		Model:          "test-model",
+		EmbeddingModel: "test-embedding",
+		EmbeddingRetry: retry.Policy{MaxAttempts: -1},
+	}, zerolog.Nop())
+	if err == nil {
+		t.Fatal("NewService() accepted a negative EmbeddingRetry.MaxAttempts")
+	}
+}
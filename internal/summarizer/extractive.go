@@ -0,0 +1,95 @@
+package summarizer
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// minExtractiveSentenceWords skips fragments such as headings and captions
+const minExtractiveSentenceWords = 5
+
+// Extractive summarizes content without a model by picking its most representative
+// sentences. Each sentence is scored by how frequent its words are across the
+// text, with a bonus for the opening sentences, and the best are returned in their
+// original order up to about maxWords words (zero means 200). It needs no API key,
+// so it works where the LLM-backed Summarize cannot.
+func Extractive(content string, maxWords int) string {
+	if maxWords <= 0 {
+		maxWords = 200
+	}
+	sentences := splitSentences(content)
+	if len(sentences) == 0 {
+		return ""
+	}
+
+	counts := make(map[string]int)
+	for _, sentence := range sentences {
+		for _, word := range wordPattern.FindAllString(strings.ToLower(sentence), -1) {
+			if len(word) > 2 && !stopwords[word] {
+				counts[word]++
+			}
+		}
+	}
+
+	type scored struct {
+		index int
+		words int
+		score float64
+	}
+	var candidates []scored
+	for i, sentence := range sentences {
+		words := wordPattern.FindAllString(strings.ToLower(sentence), -1)
+		if len(words) < minExtractiveSentenceWords {
+			continue
+		}
+		total := 0
+		for _, word := range words {
+			total += counts[word]
+		}
+		// Normalize by length so long sentences do not win on size alone, and favor
+		// the lead, where most writing states its point
+		score := float64(total) / math.Sqrt(float64(len(words)))
+		score *= 1 + 0.5/float64(i+1)
+		candidates = append(candidates, scored{index: i, words: len(strings.Fields(sentence)), score: score})
+	}
+	if len(candidates) == 0 {
+		return strings.Join(strings.Fields(content), " ")
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	var chosen []int
+	words := 0
+	for _, candidate := range candidates {
+		if words > 0 && words+candidate.words > maxWords {
+			continue
+		}
+		chosen = append(chosen, candidate.index)
+		words += candidate.words
+	}
+	sort.Ints(chosen)
+
+	picked := make([]string, len(chosen))
+	for i, index := range chosen {
+		picked[i] = sentences[index]
+	}
+	return strings.Join(picked, " ")
+}
+
+// splitSentences splits text at sentenceBreak, keeping each sentence's closing
+// punctuation and collapsing its whitespace
+func splitSentences(text string) []string {
+	var sentences []string
+	add := func(sentence string) {
+		if sentence = strings.Join(strings.Fields(sentence), " "); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+	}
+	start := 0
+	for _, loc := range sentenceBreak.FindAllStringIndex(text, -1) {
+		add(text[start:loc[0]] + strings.TrimSpace(text[loc[0]:loc[1]]))
+		start = loc[1]
+	}
+	add(text[start:])
+	return sentences
+}
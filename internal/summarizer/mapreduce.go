@@ -0,0 +1,132 @@
+package summarizer
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+const (
+	// mapReduceFanIn is how many partial summaries are held before they are reduced to one
+	mapReduceFanIn = 8
+	// chunkSummaryWords is the length asked of each chunk's partial summary
+	chunkSummaryWords = 150
+	// minChunkSummaryWords is the size below which a chunk is kept as its own partial summary
+	minChunkSummaryWords = 10
+)
+
+// SummarizeChunks summarizes content that arrives in chunks, such as
+// scraper.Service.TextChunks, without ever holding it whole. Each chunk is
+// summarized as it arrives (map), and whenever mapReduceFanIn partial summaries
+// pile up they are condensed into one (reduce), so memory stays bounded by a chunk
+// plus a few summaries however long the content is. The final summary is written
+// from the remaining partials with req's style, length, and other options;
+// req.Content is ignored. Content that fits in one chunk is summarized directly.
+// Response.Metadata records "chunks", "reduce_rounds", and "max_partials".
+func (s *Service) SummarizeChunks(ctx context.Context, req Request, chunks iter.Seq2[string, error]) (*Response, error) {
+	var (
+		first        string
+		partials     []string
+		chunkCount   int
+		originalSize int
+		reduceRounds int
+		maxPartials  int
+		usage        Response
+	)
+	// add records the usage of one intermediate completion
+	add := func(response *Response) {
+		usage.TokensUsed += response.TokensUsed
+		usage.PromptTokens += response.PromptTokens
+		usage.CompletionTokens += response.CompletionTokens
+		usage.EstimatedCostUSD += response.EstimatedCostUSD
+	}
+	mapChunk := func(chunk string) error {
+		if len(strings.Fields(chunk)) < minChunkSummaryWords {
+			partials = append(partials, chunk)
+		} else {
+			response, err := s.Summarize(ctx, partialRequest(req, chunk))
+			if err != nil {
+				return fmt.Errorf("failed to summarize chunk %d: %w", chunkCount, err)
+			}
+			add(response)
+			partials = append(partials, response.Summary)
+		}
+		maxPartials = max(maxPartials, len(partials))
+		if len(partials) < mapReduceFanIn {
+			return nil
+		}
+		response, err := s.Summarize(ctx, partialRequest(req, strings.Join(partials, "\n\n")))
+		if err != nil {
+			return fmt.Errorf("failed to condense partial summaries: %w", err)
+		}
+		add(response)
+		partials = []string{response.Summary}
+		reduceRounds++
+		return nil
+	}
+
+	for chunk, err := range chunks {
+		if err != nil {
+			return nil, err
+		}
+		if chunk = strings.TrimSpace(chunk); chunk == "" {
+			continue
+		}
+		chunkCount++
+		originalSize += len(chunk)
+		s.logger.Debug().Int("chunk", chunkCount).Int("size", len(chunk)).Msg("Summarizing chunk")
+
+		// Hold the first chunk back until a second shows summarizing it alone is not enough
+		if chunkCount == 1 {
+			first = chunk
+			continue
+		}
+		if chunkCount == 2 {
+			if err := mapChunk(first); err != nil {
+				return nil, err
+			}
+			first = ""
+		}
+		if err := mapChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if chunkCount == 0 {
+		return nil, fmt.Errorf("content cannot be empty")
+	}
+
+	final := req
+	final.Content = first
+	if chunkCount > 1 {
+		final.Content = strings.Join(partials, "\n\n")
+	}
+	response, err := s.Summarize(ctx, final)
+	if err != nil {
+		return nil, err
+	}
+	add(response)
+
+	response.OriginalSize = originalSize
+	response.TokensUsed = usage.TokensUsed
+	response.PromptTokens = usage.PromptTokens
+	response.CompletionTokens = usage.CompletionTokens
+	response.EstimatedCostUSD = usage.EstimatedCostUSD
+	response.Metadata["chunks"] = fmt.Sprintf("%d", chunkCount)
+	response.Metadata["reduce_rounds"] = fmt.Sprintf("%d", reduceRounds)
+	response.Metadata["max_partials"] = fmt.Sprintf("%d", maxPartials)
+	return response, nil
+}
+
+// partialRequest asks for an intermediate summary of part of the content, keeping
+// the options that shape what is worth keeping
+func partialRequest(req Request, content string) Request {
+	return Request{
+		Content:   content,
+		MaxLength: chunkSummaryWords,
+		Style:     "concise",
+		Language:  req.Language,
+		Focus:     req.Focus,
+		PageType:  req.PageType,
+	}
+}
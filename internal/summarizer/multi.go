@@ -0,0 +1,174 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/HeidiZHH/skull/internal/textutil"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxSourceChars bounds each source's share of a SummarizeMultiple prompt
+const maxSourceChars = 8000
+
+// Source is one document in a multi-document synthesis
+type Source struct {
+	// Name labels the source in the prompt and in Metadata["source_inclusion"], e.g. a title or URL
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// MultiRequest asks SummarizeMultiple for one summary across several sources
+type MultiRequest struct {
+	Sources   []Source `json:"sources"`
+	MaxLength int      `json:"max_length,omitempty"`
+	Language  string   `json:"language,omitempty"`
+	// Focus names the aspect the synthesis should emphasize; empty gives a general synthesis
+	Focus string `json:"focus,omitempty"`
+	// Diversity balances the synthesis across sources instead of letting a point
+	// repeated by many similar sources dominate: each source gets comparable weight,
+	// views held by only one or a few sources are stated as such, and every point is
+	// attributed with [S1]-style markers
+	Diversity bool `json:"diversity,omitempty"`
+}
+
+// sourceMarker matches attribution markers such as [S2] or [S1, S3]
+var sourceMarker = regexp.MustCompile(`\[S\d+(?:\s*,\s*S\d+)*\]`)
+
+// SummarizeMultiple synthesizes one summary from several sources. With
+// req.Diversity the summary balances the sources and attributes each point; then
+// Metadata["source_inclusion"] maps each source name to how often it is cited and
+// Metadata["uncited_sources"] lists the ones the summary leaves out.
+func (s *Service) SummarizeMultiple(ctx context.Context, req MultiRequest) (*Response, error) {
+	var sources []Source
+	for i, source := range req.Sources {
+		if source.Content = strings.TrimSpace(source.Content); source.Content == "" {
+			continue
+		}
+		if source.Name = strings.TrimSpace(source.Name); source.Name == "" {
+			source.Name = fmt.Sprintf("Source %d", i+1)
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources to summarize")
+	}
+	if req.MaxLength == 0 {
+		req.MaxLength = 200
+	}
+
+	s.logger.Info().
+		Int("sources", len(sources)).
+		Int("max_length", req.MaxLength).
+		Bool("diversity", req.Diversity).
+		Msg("Starting multi-source summarization")
+
+	var promptBuilder strings.Builder
+	promptBuilder.WriteString(fmt.Sprintf("Synthesize the following %d sources into a single summary of approximately %d words or less.", len(sources), req.MaxLength))
+	if req.Diversity {
+		promptBuilder.WriteString(`
+Balance coverage across the sources: give each source comparable weight, and do not let a point dominate just because several similar sources repeat it.
+Explicitly note minority viewpoints, saying when a view is held by only one or a few sources, and state disagreements between sources rather than smoothing them over.
+Attribute every point to the sources that support it with markers such as [S1] or [S2, S4], using the source numbers below.`)
+	} else {
+		promptBuilder.WriteString(" Combine overlapping points and keep every distinct fact.")
+	}
+	if req.Focus != "" {
+		promptBuilder.WriteString(fmt.Sprintf("\nFocus on: %s.", req.Focus))
+	}
+	if req.Language != "" {
+		promptBuilder.WriteString(fmt.Sprintf("\nWrite the summary in %s.", req.Language))
+	}
+	originalSize := 0
+	for i, source := range sources {
+		originalSize += len(source.Content)
+		promptBuilder.WriteString(fmt.Sprintf("\n\nSOURCE S%d (%s):\n%s", i+1, source.Name, textutil.TruncateRunes(source.Content, maxSourceChars)))
+	}
+
+	chatReq := openai.ChatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "You are a careful analyst who synthesizes several sources into one fair, accurate summary without inventing information.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: promptBuilder.String(),
+			},
+		},
+		MaxTokens:   s.config.MaxTokens,
+		Temperature: 0.3,
+	}
+	s.config.applySampling(&chatReq)
+
+	resp, usageEstimated, err := s.complete(ctx, chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	var debug []RawCompletion
+	if s.config.DebugRaw {
+		debug = []RawCompletion{rawCompletion("summary", resp, usageEstimated)}
+	}
+	if len(resp.Choices) == 0 {
+		return nil, &ReplyError{Err: fmt.Errorf("no response choices returned"), Debug: debug}
+	}
+	summary := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if summary == "" {
+		return nil, &ReplyError{Err: fmt.Errorf("summary is empty"), Debug: debug}
+	}
+
+	response := &Response{
+		Summary:          summary,
+		OriginalSize:     originalSize,
+		SummarySize:      len(summary),
+		Model:            resp.Model,
+		TokensUsed:       resp.Usage.TotalTokens,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		EstimatedCostUSD: s.config.estimateCost(resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+		Debug:            debug,
+		Metadata: map[string]string{
+			"source_count":    fmt.Sprintf("%d", len(sources)),
+			"diversity":       fmt.Sprintf("%t", req.Diversity),
+			"usage_estimated": fmt.Sprintf("%t", usageEstimated),
+		},
+	}
+
+	if req.Diversity {
+		inclusion := sourceInclusion(summary, len(sources))
+		counts := make(map[string]int, len(sources))
+		uncited := []string{}
+		for i, source := range sources {
+			counts[source.Name] += inclusion[i]
+			if inclusion[i] == 0 {
+				uncited = append(uncited, source.Name)
+			}
+		}
+		encodedCounts, _ := json.Marshal(counts)
+		encodedUncited, _ := json.Marshal(uncited)
+		response.Metadata["source_inclusion"] = string(encodedCounts)
+		response.Metadata["uncited_sources"] = string(encodedUncited)
+		response.Metadata["sources_cited"] = fmt.Sprintf("%d", len(sources)-len(uncited))
+	}
+	return response, nil
+}
+
+// sourceInclusion counts the attribution markers citing each of count sources,
+// indexed from zero; markers naming sources out of range are ignored
+func sourceInclusion(summary string, count int) []int {
+	inclusion := make([]int, count)
+	for _, marker := range sourceMarker.FindAllString(summary, -1) {
+		for _, label := range strings.Split(strings.Trim(marker, "[]"), ",") {
+			n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(label), "S"))
+			if err == nil && n >= 1 && n <= count {
+				inclusion[n-1]++
+			}
+		}
+	}
+	return inclusion
+}
@@ -2,9 +2,24 @@ package summarizer
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/HeidiZHH/skull/internal/audit"
+	"github.com/HeidiZHH/skull/internal/llm"
+	"github.com/HeidiZHH/skull/internal/pagetype"
+	"github.com/HeidiZHH/skull/internal/retry"
+	"github.com/HeidiZHH/skull/internal/textutil"
 	"github.com/rs/zerolog"
 	"github.com/sashabaranov/go-openai"
 )
@@ -14,6 +29,9 @@ type Service struct {
 	client *openai.Client
 	config Config
 	logger zerolog.Logger
+	audit  *audit.Log
+	// embedder is set when Config.EmbeddingModel is configured
+	embedder *Embedder
 }
 
 // Config represents summarizer configuration
@@ -23,42 +41,310 @@ type Config struct {
 	BaseURL   string // For custom OpenAI-compatible endpoints
 	Model     string
 	MaxTokens int
+	// VisionModel enables image descriptions for Request.ImageURL when set
+	VisionModel string
+	// Prices maps model names to their token prices for cost estimation
+	Prices map[string]ModelPrice
+	// Optional sampling parameters; zero leaves the provider default
+	TopP             float32
+	FrequencyPenalty float32
+	PresencePenalty  float32
+	// Transport replaces the default HTTP transport of chat and embeddings calls,
+	// e.g. to share pooled connections with other clients
+	Transport http.RoundTripper
+	// EmbeddingModel enables the embeddings client (see Service.Embed), which scores
+	// Request.ScoreCoverage by embedding similarity; empty uses lexical overlap
+	EmbeddingModel string
+	// EmbeddingBaseURL and EmbeddingAPIKey point embeddings at a different
+	// endpoint; empty values reuse BaseURL and APIKey
+	EmbeddingBaseURL string
+	EmbeddingAPIKey  string
+	// EmbeddingBatchSize is the most texts per embeddings request; zero means 100
+	EmbeddingBatchSize int
+	// EmbeddingRetry governs retries of failed embeddings requests; see EmbeddingConfig.Retry
+	EmbeddingRetry retry.Policy
+	// AuditLog, when set, records every chat completion as described in package audit
+	AuditLog io.Writer
+	// AuditMaxContent truncates audited message content to this many characters; zero keeps it whole
+	AuditMaxContent int
+	// BatchConcurrency limits concurrent summaries in batch calls; zero means 3
+	BatchConcurrency int
+	// Retry applies to every completion behind a summary, critique and vision passes
+	// included. By default each gets one attempt; a provider's Retry-After overrides
+	// the backoff, and a nil Retryable retries rate limits and server errors.
+	Retry retry.Policy
+	// DebugRaw records what the provider returned for each completion behind a
+	// summary, vision, critique, faithfulness, and retry passes included, in
+	// Response.Debug, or in a *ReplyError when the reply was unusable. It is for
+	// diagnosing truncated or refused summaries without the full audit log. Off by
+	// default: it repeats the model's raw output.
+	DebugRaw bool
+}
+
+// Validate checks the configuration for values that cannot work, reporting every problem found
+func (c Config) Validate() error {
+	var problems []error
+	if strings.TrimSpace(c.Model) == "" {
+		problems = append(problems, fmt.Errorf("Model is required"))
+	}
+	if c.MaxTokens < 0 {
+		problems = append(problems, fmt.Errorf("MaxTokens must not be negative (got %d)", c.MaxTokens))
+	}
+	if c.TopP < 0 || c.TopP > 1 {
+		problems = append(problems, fmt.Errorf("TopP must be between 0 and 1 (got %g)", c.TopP))
+	}
+	if c.FrequencyPenalty < -2 || c.FrequencyPenalty > 2 {
+		problems = append(problems, fmt.Errorf("FrequencyPenalty must be between -2 and 2 (got %g)", c.FrequencyPenalty))
+	}
+	if c.PresencePenalty < -2 || c.PresencePenalty > 2 {
+		problems = append(problems, fmt.Errorf("PresencePenalty must be between -2 and 2 (got %g)", c.PresencePenalty))
+	}
+	if c.BatchConcurrency < 0 {
+		problems = append(problems, fmt.Errorf("BatchConcurrency must not be negative (got %d)", c.BatchConcurrency))
+	}
+	if c.EmbeddingBatchSize < 0 {
+		problems = append(problems, fmt.Errorf("EmbeddingBatchSize must not be negative (got %d)", c.EmbeddingBatchSize))
+	}
+	if c.AuditMaxContent < 0 {
+		problems = append(problems, fmt.Errorf("AuditMaxContent must not be negative (got %d)", c.AuditMaxContent))
+	}
+	if err := c.Retry.Validate(); err != nil {
+		problems = append(problems, fmt.Errorf("invalid Retry policy: %w", err))
+	}
+	if err := c.EmbeddingRetry.Validate(); err != nil {
+		problems = append(problems, fmt.Errorf("invalid EmbeddingRetry policy: %w", err))
+	}
+	if _, err := llm.NormalizeBaseURL(c.BaseURL); err != nil {
+		problems = append(problems, fmt.Errorf("BaseURL: %w", err))
+	}
+	if _, err := llm.NormalizeBaseURL(c.EmbeddingBaseURL); err != nil {
+		problems = append(problems, fmt.Errorf("EmbeddingBaseURL: %w", err))
+	}
+	for model, price := range c.Prices {
+		if price.PromptPerMillion < 0 || price.CompletionPerMillion < 0 {
+			problems = append(problems, fmt.Errorf("Prices[%q] must not be negative", model))
+		}
+	}
+	if err := errors.Join(problems...); err != nil {
+		return fmt.Errorf("invalid summarizer config: %w", err)
+	}
+	return nil
+}
+
+// applySampling copies the optional sampling parameters onto a chat completion request
+func (c Config) applySampling(req *openai.ChatCompletionRequest) {
+	req.TopP = c.TopP
+	req.FrequencyPenalty = c.FrequencyPenalty
+	req.PresencePenalty = c.PresencePenalty
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	PromptPerMillion     float64
+	CompletionPerMillion float64
+}
+
+// estimateCost returns the USD cost of a completion, or zero when the model has no known price
+func (c Config) estimateCost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := c.Prices[model]
+	if !ok {
+		price, ok = c.Prices[c.Model]
+	}
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.PromptPerMillion + float64(completionTokens)*price.CompletionPerMillion) / 1_000_000
 }
 
 // Request represents a summarization request
 type Request struct {
 	Content   string `json:"content"`
 	MaxLength int    `json:"max_length,omitempty"`
-	Style     string `json:"style,omitempty"` // "concise", "detailed", "bullet_points"
+	Style     string `json:"style,omitempty"` // "concise", "detailed", "bullet_points", "tldr_plus", "social", "discussion"
 	Language  string `json:"language,omitempty"`
+	// VerifyFaithfulness runs an extra completion that checks the summary against the source
+	VerifyFaithfulness bool `json:"verify_faithfulness,omitempty"`
+	// ImageURL is the page's main image (e.g. og:image); it is described by the
+	// vision model and folded into the summary when Config.VisionModel is set
+	ImageURL string `json:"image_url,omitempty"`
+	// TwoPass drafts a summary, then has the model critique and revise it against the source
+	TwoPass bool `json:"two_pass,omitempty"`
+	// Format is "text" (default) or "json"; with "json" the bullet_points style fills Response.Bullets
+	Format string `json:"format,omitempty"`
+	// BulletCount asks the bullet_points style for exactly this many bullets
+	BulletCount int `json:"bullet_count,omitempty"`
+	// PreserveNumbers pins the source's figures in the prompt and flags any number
+	// in the summary that does not appear in the source
+	PreserveNumbers bool `json:"preserve_numbers,omitempty"`
+	// Focus names the aspect the summary should emphasize (e.g. "pricing and
+	// availability"); empty gives a general summary
+	Focus string `json:"focus,omitempty"`
+	// ReadingLevel asks for a summary readable at this US school grade (e.g. 8) and
+	// records the summary's Flesch-Kincaid grade in Response.Metadata; zero means no constraint
+	ReadingLevel int `json:"reading_level,omitempty"`
+	// Platform selects the target of the "social" style: "twitter", "linkedin", or "short" (default)
+	Platform string `json:"platform,omitempty"`
+	// ScoreCoverage adds a 0-1 "coverage_score" to Response.Metadata estimating how
+	// much of the source the summary covers
+	ScoreCoverage bool `json:"score_coverage,omitempty"`
+	// BilingualTarget asks for the summary in the source's own language plus a
+	// translation into this language (e.g. "English"), both in Response.Bilingual.
+	// It cannot be combined with Language, JSON output, or the tldr_plus and social styles.
+	BilingualTarget string `json:"bilingual_target,omitempty"`
+	// RequiredSections makes the summary consist of exactly these sections, in order
+	// (e.g. "Overview", "Risks"), with "N/A" for any the text does not cover. With
+	// Format "json" the model replies with a JSON object of sections. Either way the
+	// sections are returned in Response.Sections, and a reply missing any is retried once.
+	RequiredSections []string `json:"required_sections,omitempty"`
+	// PageType tailors the summary to the kind of page the content came from (one
+	// of pagetype.All, as in scraper.Result.PageType); empty or "other" summarizes
+	// generically
+	PageType string `json:"page_type,omitempty"`
+}
+
+// pageTypeGuidance tells the model what matters in a summary of each page type
+var pageTypeGuidance = map[string]string{
+	pagetype.Article: "The text is a news or blog article: lead with its main claim or news, then the key supporting facts, who is involved, and when",
+	pagetype.Product: "The text is a product page: say what the product is and who it is for, then its key features, price and availability, and notable limitations; leave out marketing language",
+	pagetype.Docs:    "The text is technical documentation: say what the feature or API does, then how to use it, its key parameters or steps, and important caveats",
+	pagetype.Forum:   "The text is a forum discussion: state the question or topic, then the main answers and viewpoints, and where participants agree or disagree",
+}
+
+// Styles lists the supported Request.Style values
+var Styles = []string{"concise", "detailed", "bullet_points", "tldr_plus", "social", "discussion"}
+
+// ValidateStyle checks that style is one of Styles
+func ValidateStyle(style string) error {
+	for _, supported := range Styles {
+		if style == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported style %q (supported: %s)", style, strings.Join(Styles, ", "))
 }
 
 // Response represents a summarization response
 type Response struct {
-	Summary      string            `json:"summary"`
-	OriginalSize int               `json:"original_size"`
-	SummarySize  int               `json:"summary_size"`
-	Model        string            `json:"model"`
-	TokensUsed   int               `json:"tokens_used"`
-	Metadata     map[string]string `json:"metadata"`
+	Summary          string            `json:"summary"`
+	OriginalSize     int               `json:"original_size"`
+	SummarySize      int               `json:"summary_size"`
+	Model            string            `json:"model"`
+	TLDR             string            `json:"tldr,omitempty"`      // set for the "tldr_plus" style
+	Bullets          []string          `json:"bullets,omitempty"`   // set for bullet_points with Format "json"
+	Bilingual        *BilingualSummary `json:"bilingual,omitempty"` // set for Request.BilingualTarget
+	Sections         map[string]string `json:"sections,omitempty"`  // set for Request.RequiredSections
+	TokensUsed       int               `json:"tokens_used"`
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	EstimatedCostUSD float64           `json:"estimated_cost_usd"` // zero for models missing from Config.Prices
+	Metadata         map[string]string `json:"metadata"`
+	// Debug holds the provider's raw replies, in order, when Config.DebugRaw is set
+	Debug []RawCompletion `json:"debug,omitempty"`
+}
+
+// RawCompletion is the key fields of one chat completion as the provider returned them
+type RawCompletion struct {
+	// Purpose says why the completion was made: "summary", or a retry such as "sections_retry"
+	Purpose      string `json:"purpose"`
+	ID           string `json:"id"`
+	Model        string `json:"model"`
+	FinishReason string `json:"finish_reason"`
+	// Content is the reply before any parsing or trimming
+	Content string `json:"content"`
+	// Refusal is set when the provider reports the model declined to answer
+	Refusal          string `json:"refusal,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	// UsageEstimated is set when the provider reported no usage and tokens were estimated
+	UsageEstimated bool `json:"usage_estimated"`
+}
+
+// rawCompletion captures resp for Response.Debug
+func rawCompletion(purpose string, resp openai.ChatCompletionResponse, usageEstimated bool) RawCompletion {
+	raw := RawCompletion{
+		Purpose:          purpose,
+		ID:               resp.ID,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		UsageEstimated:   usageEstimated,
+	}
+	if len(resp.Choices) > 0 {
+		raw.FinishReason = string(resp.Choices[0].FinishReason)
+		raw.Content = resp.Choices[0].Message.Content
+		raw.Refusal = resp.Choices[0].Message.Refusal
+	}
+	return raw
+}
+
+// ReplyError is returned when a completion succeeded but its reply could not be
+// used, such as one without choices or in the wrong format. With Config.DebugRaw
+// set, Debug holds the raw replies received so far, the failed one included.
+type ReplyError struct {
+	Err   error
+	Debug []RawCompletion
+}
+
+func (e *ReplyError) Error() string { return e.Err.Error() }
+
+func (e *ReplyError) Unwrap() error { return e.Err }
+
+// BilingualSummary is a summary in the source's language together with its translation
+type BilingualSummary struct {
+	SourceLanguage string `json:"source_language"`
+	Summary        string `json:"summary"`
+	TargetLanguage string `json:"target_language"`
+	Translation    string `json:"translation"`
 }
 
 // NewService creates a new summarizer service
-func NewService(config Config, logger zerolog.Logger) *Service {
+func NewService(config Config, logger zerolog.Logger) (*Service, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	// Validate has already rejected malformed URLs
+	config.BaseURL, _ = llm.NormalizeBaseURL(config.BaseURL)
+	config.EmbeddingBaseURL, _ = llm.NormalizeBaseURL(config.EmbeddingBaseURL)
 	clientConfig := openai.DefaultConfig(config.APIKey)
 
 	// Support custom OpenAI-compatible endpoints
 	if config.BaseURL != "" {
 		clientConfig.BaseURL = config.BaseURL
 	}
+	// Let retries wait as long as a rate-limited provider asks
+	clientConfig.HTTPClient = &http.Client{Transport: retry.Transport(config.Transport)}
 
 	client := openai.NewClientWithConfig(clientConfig)
 
-	return &Service{
+	service := &Service{
 		client: client,
 		config: config,
 		logger: logger.With().Str("component", "summarizer").Logger(),
 	}
+	if config.EmbeddingModel != "" {
+		embeddingConfig := EmbeddingConfig{
+			APIKey:    config.EmbeddingAPIKey,
+			BaseURL:   config.EmbeddingBaseURL,
+			Model:     config.EmbeddingModel,
+			BatchSize: config.EmbeddingBatchSize,
+			Retry:     config.EmbeddingRetry,
+			Transport: config.Transport,
+		}
+		if embeddingConfig.APIKey == "" {
+			embeddingConfig.APIKey = config.APIKey
+		}
+		if embeddingConfig.BaseURL == "" {
+			embeddingConfig.BaseURL = config.BaseURL
+		}
+		service.embedder = NewEmbedder(embeddingConfig, logger)
+	}
+	if config.AuditLog != nil {
+		service.logger.Warn().Msg("LLM audit logging is enabled; the audit log contains prompts and scraped content")
+		service.audit = audit.New(config.AuditLog, config.AuditMaxContent, config.APIKey)
+	}
+	return service, nil
 }
 
 // Summarize generates a summary of the provided content
@@ -70,156 +356,1443 @@ func (s *Service) Summarize(ctx context.Context, req Request) (*Response, error)
 		req.Style = "concise"
 	}
 
+	if err := validateBilingual(req); err != nil {
+		return nil, err
+	}
+	req.RequiredSections = cleanSectionNames(req.RequiredSections)
+	if err := validateRequiredSections(req); err != nil {
+		return nil, err
+	}
+
 	s.logger.Info().
 		Int("content_length", len(req.Content)).
 		Int("max_length", req.MaxLength).
 		Str("style", req.Style).
 		Msg("Starting summarization")
 
-	// Build the prompt based on style
-	prompt := s.buildPrompt(req)
+	originalSize := len(req.Content)
 
-	// Prepare the chat completion request
-	chatReq := openai.ChatCompletionRequest{
-		Model: s.config.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are a helpful assistant that creates clear, accurate summaries of text content.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-		MaxTokens:   s.config.MaxTokens,
-		Temperature: 0.3, // Lower temperature for more consistent summaries
+	var tally completionTally
+	// fail keeps the raw replies behind a summary that could not be completed
+	fail := func(err error) error {
+		return &ReplyError{Err: err, Debug: tally.debug}
+	}
+
+	// Describe the main image so content locked in infographics reaches the summary
+	var imageUsage openai.Usage
+	imageDescribed := false
+	if req.ImageURL != "" && s.config.VisionModel != "" {
+		description, usage, err := s.describeImage(ctx, req.ImageURL, &tally)
+		imageUsage = usage
+		if err != nil {
+			s.logger.Warn().Err(err).Str("image_url", req.ImageURL).Msg("Failed to describe image")
+		} else if description != "" {
+			req.Content = fmt.Sprintf("%s\n\nDescription of the page's main image:\n%s", req.Content, description)
+			imageDescribed = true
+		}
 	}
 
 	// Call the LLM
-	resp, err := s.client.CreateChatCompletion(ctx, chatReq)
+	chatReq := s.summaryRequest(req)
+	resp, usageEstimated, err := s.complete(ctx, chatReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat completion: %w", err)
 	}
+	tally.add(s.config.DebugRaw, "summary", resp, usageEstimated)
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response choices returned")
+		return nil, fail(fmt.Errorf("no response choices returned"))
 	}
 
 	summary := resp.Choices[0].Message.Content
 	summary = strings.TrimSpace(summary)
 
+	passes := 1
+	if req.TwoPass {
+		revised, err := s.revise(ctx, req, summary, &tally)
+		if err != nil {
+			s.logger.Warn().Err(err).Msg("Failed to revise summary; keeping draft")
+		} else if revised != "" {
+			summary = revised
+			passes = 2
+		}
+	}
+
+	var tldr string
+	if req.Style == "tldr_plus" {
+		tldr, summary, err = parseTwoTier(summary)
+		if err != nil {
+			return nil, fail(err)
+		}
+	}
+
+	var bilingual *BilingualSummary
+	if req.BilingualTarget != "" {
+		bilingual, err = parseBilingual(summary, strings.TrimSpace(req.BilingualTarget))
+		if err != nil {
+			return nil, fail(err)
+		}
+		summary = bilingual.Summary
+	}
+
+	var sections map[string]string
+	if len(req.RequiredSections) > 0 {
+		sections, err = parseRequiredSections(summary, req.RequiredSections, req.Format == "json")
+		if err != nil {
+			// Give the model one chance to supply the missing sections
+			s.logger.Warn().Err(err).Strs("sections", req.RequiredSections).Msg("Retrying sectioned summary")
+			correction := fmt.Sprintf("That reply was invalid (%v). Respond again with exactly these sections, in order: %s. Write N/A for any section the text does not cover.", err, strings.Join(req.RequiredSections, ", "))
+			if retried, ok := s.retryOnce(ctx, chatReq, summary, correction, "sections_retry", &tally); ok {
+				summary = retried
+				sections, err = parseRequiredSections(summary, req.RequiredSections, req.Format == "json")
+			}
+		}
+		if err != nil {
+			return nil, fail(err)
+		}
+		if req.Format == "json" {
+			summary = renderSections(sections, req.RequiredSections)
+		}
+	}
+
+	var bullets []string
+	if req.Style == "bullet_points" && req.Format == "json" {
+		bullets, err = parseBullets(summary, req.BulletCount)
+		if err != nil && req.BulletCount > 0 {
+			// Give the model one chance to correct the bullet count
+			s.logger.Warn().Err(err).Int("bullet_count", req.BulletCount).Msg("Retrying bullet summary")
+			correction := fmt.Sprintf(`That reply was invalid (%v). Respond again with JSON only, in the form {"bullets": [...]}, containing exactly %d bullets.`, err, req.BulletCount)
+			if retried, ok := s.retryOnce(ctx, chatReq, summary, correction, "bullets_retry", &tally); ok {
+				bullets, err = parseBullets(retried, req.BulletCount)
+			}
+		}
+		if err != nil {
+			return nil, fail(err)
+		}
+		summary = "- " + strings.Join(bullets, "\n- ")
+	}
+
+	if req.Style == "social" {
+		summary = s.enforceSocialLimit(ctx, chatReq, req, summary, &tally)
+	}
+
 	response := &Response{
 		Summary:      summary,
-		OriginalSize: len(req.Content),
+		OriginalSize: originalSize,
 		SummarySize:  len(summary),
 		Model:        resp.Model,
-		TokensUsed:   resp.Usage.TotalTokens,
+		TLDR:         tldr,
+		Bullets:      bullets,
+		Bilingual:    bilingual,
+		Sections:     sections,
+		Debug:        tally.debug,
 		Metadata: map[string]string{
-			"style":             req.Style,
-			"language":          req.Language,
-			"prompt_tokens":     fmt.Sprintf("%d", resp.Usage.PromptTokens),
-			"completion_tokens": fmt.Sprintf("%d", resp.Usage.CompletionTokens),
+			"style":           req.Style,
+			"language":        req.Language,
+			"passes":          fmt.Sprintf("%d", passes),
+			"usage_estimated": fmt.Sprintf("%t", tally.estimated),
 		},
 	}
+	response.addUsage(s.config, resp.Model, tally.usage)
+	response.addUsage(s.config, s.config.VisionModel, imageUsage)
+
+	if req.Style == "social" {
+		platform := socialPlatformFor(req.Platform)
+		response.Metadata["platform"] = platform.Name
+		response.Metadata["char_limit"] = fmt.Sprintf("%d", platform.CharLimit)
+	}
+
+	if bilingual != nil {
+		response.Metadata["source_language"] = bilingual.SourceLanguage
+		response.Metadata["bilingual_target"] = bilingual.TargetLanguage
+	}
+
+	if len(sections) > 0 {
+		response.Metadata["required_sections"] = strings.Join(req.RequiredSections, ", ")
+	}
+
+	if imageDescribed {
+		response.Metadata["image_described"] = "true"
+		response.Metadata["image_url"] = req.ImageURL
+	}
+
+	s.annotate(ctx, req, response)
 
 	s.logger.Info().
 		Int("original_size", response.OriginalSize).
 		Int("summary_size", response.SummarySize).
 		Int("tokens_used", response.TokensUsed).
+		Float64("estimated_cost_usd", response.EstimatedCostUSD).
 		Str("model", response.Model).
 		Msg("Summarization completed")
 
 	return response, nil
 }
 
-// buildPrompt constructs the summarization prompt based on the request
-func (s *Service) buildPrompt(req Request) string {
-	var promptBuilder strings.Builder
+// summaryRequest builds the completion request for a summary in req's style
+func (s *Service) summaryRequest(req Request) openai.ChatCompletionRequest {
+	chatReq := openai.ChatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "You are a helpful assistant that creates clear, accurate summaries of text content.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: s.buildPrompt(req),
+			},
+		},
+		MaxTokens:   s.config.MaxTokens,
+		Temperature: 0.3, // Lower temperature for more consistent summaries
+	}
+	s.config.applySampling(&chatReq)
+	return chatReq
+}
 
-	// Base instruction
-	promptBuilder.WriteString("Please summarize the following text")
+// annotate runs the checks req asks for on a finished summary and records them,
+// along with the page type, focus, and reading level, in the response metadata
+func (s *Service) annotate(ctx context.Context, req Request, response *Response) {
+	summary := response.Summary
+	if req.PreserveNumbers {
+		unsupported := unsupportedNumbers(req.Content, summary)
+		encoded, _ := json.Marshal(unsupported)
+		response.Metadata["numbers_checked"] = "true"
+		response.Metadata["unsupported_numbers"] = string(encoded)
+		if len(unsupported) > 0 {
+			s.logger.Warn().Strs("numbers", unsupported).Msg("Summary contains numbers not found in the source")
+		}
+	}
 
-	// Add length constraint
-	if req.MaxLength > 0 {
-		promptBuilder.WriteString(fmt.Sprintf(" in approximately %d words or less", req.MaxLength))
+	if req.VerifyFaithfulness {
+		s.applyFaithfulnessCheck(ctx, req.Content, response)
 	}
 
-	// Add style instructions
-	switch req.Style {
-	case "detailed":
-		promptBuilder.WriteString(". Provide a detailed summary that captures the main points, key arguments, and important details")
-	case "bullet_points":
-		promptBuilder.WriteString(". Format the summary as bullet points, highlighting the key information")
-	case "concise":
-		promptBuilder.WriteString(". Provide a concise summary focusing on the most important information")
-	default:
-		promptBuilder.WriteString(". Provide a clear and informative summary")
+	if req.ScoreCoverage {
+		s.applyCoverageScore(ctx, req.Content, response)
 	}
 
-	// Add language instruction if specified
-	if req.Language != "" {
-		promptBuilder.WriteString(fmt.Sprintf(" in %s", req.Language))
+	if _, ok := pageTypeGuidance[req.PageType]; ok {
+		response.Metadata["page_type"] = req.PageType
 	}
 
-	promptBuilder.WriteString(":\n\n")
-	promptBuilder.WriteString(req.Content)
+	if focus := strings.TrimSpace(req.Focus); focus != "" {
+		response.Metadata["focused"] = "true"
+		response.Metadata["focus"] = focus
+	}
 
-	return promptBuilder.String()
+	if req.ReadingLevel > 0 {
+		response.Metadata["reading_level_target"] = fmt.Sprintf("%d", req.ReadingLevel)
+		response.Metadata["flesch_kincaid_grade"] = fmt.Sprintf("%.1f", fleschKincaidGrade(summary))
+	}
 }
 
-// SummarizeWithKeywords generates a summary and extracts keywords
-func (s *Service) SummarizeWithKeywords(ctx context.Context, req Request) (*Response, []string, error) {
-	// First get the regular summary
-	response, err := s.Summarize(ctx, req)
+// completionTally accumulates the usage of the completions behind one summary,
+// and their raw replies when Config.DebugRaw is set
+type completionTally struct {
+	usage     openai.Usage
+	estimated bool
+	debug     []RawCompletion
+}
+
+// add counts a completion made for purpose, such as "summary" or "bullets_retry"
+func (t *completionTally) add(debugRaw bool, purpose string, resp openai.ChatCompletionResponse, estimated bool) {
+	t.addUsage(resp.Usage, estimated)
+	t.addDebug(debugRaw, purpose, resp, estimated)
+}
+
+// addDebug records the raw reply of a completion whose usage is counted elsewhere,
+// such as the vision model's
+func (t *completionTally) addDebug(debugRaw bool, purpose string, resp openai.ChatCompletionResponse, estimated bool) {
+	if debugRaw {
+		t.debug = append(t.debug, rawCompletion(purpose, resp, estimated))
+	}
+}
+
+// addUsage counts tokens whose completion is recorded elsewhere
+func (t *completionTally) addUsage(usage openai.Usage, estimated bool) {
+	t.usage.PromptTokens += usage.PromptTokens
+	t.usage.CompletionTokens += usage.CompletionTokens
+	t.usage.TotalTokens += usage.TotalTokens
+	t.estimated = t.estimated || estimated
+}
+
+// retryOnce gives the model one chance to fix an invalid reply: it re-sends
+// chatReq with the reply and a correction appended, counts the completion in
+// tally under purpose, and returns the new reply. ok is false when the retry fails.
+func (s *Service) retryOnce(ctx context.Context, chatReq openai.ChatCompletionRequest, reply, correction, purpose string, tally *completionTally) (string, bool) {
+	retryReq := chatReq
+	retryReq.Messages = append(append([]openai.ChatCompletionMessage{}, chatReq.Messages...),
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply},
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: correction},
+	)
+	resp, estimated, err := s.complete(ctx, retryReq)
 	if err != nil {
-		return nil, nil, err
+		s.logger.Warn().Err(err).Str("purpose", purpose).Msg("Retry failed")
+		return "", false
+	}
+	tally.add(s.config.DebugRaw, purpose, resp, estimated)
+	if len(resp.Choices) == 0 {
+		return "", false
 	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), true
+}
 
-	// Extract keywords with a separate request
-	keywordPrompt := fmt.Sprintf(`Extract 5-10 key terms or phrases from the following text. Return only the keywords, separated by commas:
+// addUsage folds the usage of one completion by model into the typed totals and
+// the string metadata kept for older callers. Every completion a summary makes,
+// including the vision, revision, and faithfulness passes, is counted this way.
+func (r *Response) addUsage(config Config, model string, usage openai.Usage) {
+	r.TokensUsed += usage.TotalTokens
+	r.PromptTokens += usage.PromptTokens
+	r.CompletionTokens += usage.CompletionTokens
+	r.EstimatedCostUSD += config.estimateCost(model, usage.PromptTokens, usage.CompletionTokens)
+	r.Metadata["prompt_tokens"] = fmt.Sprintf("%d", r.PromptTokens)
+	r.Metadata["completion_tokens"] = fmt.Sprintf("%d", r.CompletionTokens)
+}
 
-%s`, req.Content)
+// complete runs a chat completion, retried per Config.Retry and waiting out any
+// Retry-After the provider sends, and normalizes
+// provider quirks: an empty model falls back to Config.Model, and missing usage is
+// estimated from text length (about four characters per token). The returned flag
+// reports an estimate.
+func (s *Service) complete(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, bool, error) {
+	policy := s.config.Retry
+	if policy.Retryable == nil {
+		policy.Retryable = isRetryableAPIError
+	}
+	var resp openai.ChatCompletionResponse
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		ctx, hint := retry.WithHint(ctx)
+		start := time.Now()
+		var err error
+		resp, err = s.client.CreateChatCompletion(ctx, req)
+		s.recordAudit(start, req, resp, err)
+		return hint.Wrap(err)
+	})
+	if err != nil {
+		return resp, false, err
+	}
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+	if resp.Usage.TotalTokens > 0 || resp.Usage.PromptTokens > 0 || resp.Usage.CompletionTokens > 0 {
+		if resp.Usage.TotalTokens == 0 {
+			resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+		}
+		return resp, false, nil
+	}
 
-	keywordReq := openai.ChatCompletionRequest{
+	resp.Usage = estimateUsage(req, resp)
+	s.logger.Debug().Str("model", resp.Model).Int("estimated_tokens", resp.Usage.TotalTokens).Msg("Provider omitted usage; estimated token counts")
+	return resp, true, nil
+}
+
+// recordAudit writes a completion to the audit log, if one is configured
+func (s *Service) recordAudit(start time.Time, req openai.ChatCompletionRequest, resp openai.ChatCompletionResponse, err error) {
+	var audited *openai.ChatCompletionResponse
+	if err == nil {
+		audited = &resp
+	}
+	if auditErr := s.audit.Record("summarizer", start, req, audited, err); auditErr != nil {
+		s.logger.Warn().Err(auditErr).Msg("Failed to write audit log")
+	}
+}
+
+// estimateUsage approximates the token counts of a completion from its text length
+func estimateUsage(req openai.ChatCompletionRequest, resp openai.ChatCompletionResponse) openai.Usage {
+	promptChars := 0
+	for _, message := range req.Messages {
+		promptChars += len(message.Content)
+		for _, part := range message.MultiContent {
+			promptChars += len(part.Text)
+		}
+	}
+	completionChars := 0
+	for _, choice := range resp.Choices {
+		completionChars += len(choice.Message.Content)
+	}
+	usage := openai.Usage{PromptTokens: estimateTokens(promptChars), CompletionTokens: estimateTokens(completionChars)}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// estimateTokens approximates a token count from a character count
+func estimateTokens(chars int) int {
+	return (chars + 3) / 4
+}
+
+// revise asks the model to critique a draft summary against its source and return
+// an improved version, counting the completion in tally
+func (s *Service) revise(ctx context.Context, req Request, draft string, tally *completionTally) (string, error) {
+	prompt := fmt.Sprintf(`Below is a SOURCE text and a DRAFT summary of it. Critique the draft for accuracy, omissions of key points, and clarity, then rewrite it to fix every problem you find.
+Keep the same style and length constraints as the original instructions. Return only the revised summary.
+
+ORIGINAL INSTRUCTIONS:
+%s
+
+SOURCE:
+%s
+
+DRAFT:
+%s`, Instructions(req), req.Content, draft)
+
+	reviseReq := openai.ChatCompletionRequest{
 		Model: s.config.Model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
-				Content: "You extract key terms and phrases from text. Return only the keywords separated by commas.",
+				Content: "You are a demanding editor who improves summaries so they are faithful, complete, and clear.",
 			},
 			{
 				Role:    openai.ChatMessageRoleUser,
-				Content: keywordPrompt,
+				Content: prompt,
 			},
 		},
-		MaxTokens:   100,
+		MaxTokens:   s.config.MaxTokens,
+		Temperature: 0.3,
+	}
+	s.config.applySampling(&reviseReq)
+
+	resp, estimated, err := s.complete(ctx, reviseReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	tally.add(s.config.DebugRaw, "revision", resp, estimated)
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response choices returned")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// describeImage asks the vision model for a textual description of the image at
+// imageURL. Its usage is returned to be priced at the vision model's rates, and
+// only its raw reply is recorded in tally.
+func (s *Service) describeImage(ctx context.Context, imageURL string, tally *completionTally) (string, openai.Usage, error) {
+	visionReq := openai.ChatCompletionRequest{
+		Model: s.config.VisionModel,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "You describe images for a summarizer. Transcribe any visible text and explain charts, figures, and infographics faithfully.",
+			},
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: []openai.ChatMessagePart{
+					{
+						Type: openai.ChatMessagePartTypeText,
+						Text: "Describe the content of this image, including any text, numbers, and trends it shows.",
+					},
+					{
+						Type:     openai.ChatMessagePartTypeImageURL,
+						ImageURL: &openai.ChatMessageImageURL{URL: imageURL, Detail: openai.ImageURLDetailAuto},
+					},
+				},
+			},
+		},
+		MaxTokens:   500,
 		Temperature: 0.2,
 	}
 
-	keywordResp, err := s.client.CreateChatCompletion(ctx, keywordReq)
+	resp, estimated, err := s.complete(ctx, visionReq)
 	if err != nil {
-		s.logger.Warn().Err(err).Msg("Failed to extract keywords")
-		return response, []string{}, nil
+		return "", openai.Usage{}, fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	tally.addDebug(s.config.DebugRaw, "image_description", resp, estimated)
+	if len(resp.Choices) == 0 {
+		return "", resp.Usage, fmt.Errorf("no response choices returned")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), resp.Usage, nil
+}
+
+// applyFaithfulnessCheck verifies the summary against its source and records the
+// outcome in the response metadata. Failures are logged and noted but never fail
+// the summarization itself.
+func (s *Service) applyFaithfulnessCheck(ctx context.Context, source string, response *Response) {
+	var tally completionTally
+	claims, err := s.verifyFaithfulness(ctx, source, response.Summary, &tally)
+	response.addUsage(s.config, s.config.Model, tally.usage)
+	response.Debug = append(response.Debug, tally.debug...)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to verify summary faithfulness")
+		response.Metadata["faithfulness_checked"] = "false"
+		response.Metadata["faithfulness_error"] = err.Error()
+		return
 	}
 
-	if len(keywordResp.Choices) > 0 {
-		keywordsText := keywordResp.Choices[0].Message.Content
-		keywords := strings.Split(keywordsText, ",")
-
-		// Clean up keywords
-		var cleanKeywords []string
-		for _, keyword := range keywords {
-			cleaned := strings.TrimSpace(keyword)
-			if cleaned != "" {
-				cleanKeywords = append(cleanKeywords, cleaned)
-			}
+	encoded, _ := json.Marshal(claims)
+	response.Metadata["faithfulness_checked"] = "true"
+	response.Metadata["unsupported_claims"] = string(encoded)
+	if len(claims) > 0 {
+		response.Metadata["hallucination_risk"] = "high"
+	} else {
+		response.Metadata["hallucination_risk"] = "low"
+	}
+}
+
+// verifyFaithfulness asks the model to list claims in the summary that the source
+// does not support, counting the completion in tally
+func (s *Service) verifyFaithfulness(ctx context.Context, source, summary string, tally *completionTally) ([]string, error) {
+	prompt := fmt.Sprintf(`Compare the SUMMARY against the SOURCE. List every claim in the summary that is not directly supported by the source.
+Respond with JSON only, in the form {"unsupported_claims": ["claim", ...]}. Use an empty list when every claim is supported.
+
+SOURCE:
+%s
+
+SUMMARY:
+%s`, source, summary)
+
+	verifyReq := openai.ChatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "You are a meticulous fact checker. You only report claims that the source text does not support.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		MaxTokens:   500,
+		Temperature: 0,
+	}
+
+	resp, estimated, err := s.complete(ctx, verifyReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	tally.add(s.config.DebugRaw, "faithfulness", resp, estimated)
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response choices returned")
+	}
+
+	var verdict struct {
+		UnsupportedClaims []string `json:"unsupported_claims"`
+	}
+	if err := decodeJSON(resp.Choices[0].Message.Content, &verdict); err != nil {
+		return nil, fmt.Errorf("failed to parse faithfulness verdict: %w", err)
+	}
+
+	claims := []string{}
+	for _, claim := range verdict.UnsupportedClaims {
+		if claim = strings.TrimSpace(claim); claim != "" {
+			claims = append(claims, claim)
+		}
+	}
+	return claims, nil
+}
+
+// applyCoverageScore records how well the summary covers the source in the response
+// metadata, using embeddings when Config.EmbeddingModel is set and lexical overlap otherwise
+func (s *Service) applyCoverageScore(ctx context.Context, source string, response *Response) {
+	method := "lexical"
+	score := lexicalCoverage(source, response.Summary)
+	if s.embedder != nil {
+		similarity, err := s.embeddingSimilarity(ctx, source, response.Summary)
+		if err != nil {
+			s.logger.Warn().Err(err).Msg("Embedding coverage failed; using lexical overlap")
+		} else {
+			method = "embedding"
+			score = similarity
 		}
+	}
+	response.Metadata["coverage_score"] = fmt.Sprintf("%.2f", score)
+	response.Metadata["coverage_method"] = method
+}
 
-		return response, cleanKeywords, nil
+// maxEmbeddingChars bounds the source text sent for embedding
+const maxEmbeddingChars = 8000
+
+// ErrNoEmbeddings is returned by Embed when no Config.EmbeddingModel is configured
+var ErrNoEmbeddings = errors.New("embeddings are not configured")
+
+// Embed returns an embedding per text from the configured embeddings endpoint
+func (s *Service) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if s.embedder == nil {
+		return nil, ErrNoEmbeddings
 	}
+	return s.embedder.Embed(ctx, texts)
+}
 
-	return response, []string{}, nil
+// embeddingSimilarity returns the cosine similarity of the source and summary embeddings, clamped to 0-1
+func (s *Service) embeddingSimilarity(ctx context.Context, source, summary string) (float64, error) {
+	if len(source) > maxEmbeddingChars {
+		source = source[:maxEmbeddingChars]
+	}
+	vectors, err := s.Embed(ctx, []string{source, summary})
+	if err != nil {
+		return 0, err
+	}
+	similarity, err := cosineSimilarity(vectors[0], vectors[1])
+	if err != nil {
+		return 0, err
+	}
+	return math.Max(0, math.Min(1, similarity)), nil
+}
+
+// coverageTerms is how many of the source's most frequent terms lexicalCoverage checks
+const coverageTerms = 20
+
+// wordPattern matches the words compared by lexicalCoverage
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// stopwords are common English words ignored by lexicalCoverage
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true, "you": true,
+	"all": true, "any": true, "can": true, "has": true, "had": true, "her": true, "his": true,
+	"was": true, "one": true, "our": true, "out": true, "its": true, "who": true, "did": true,
+	"how": true, "may": true, "she": true, "that": true, "with": true, "have": true, "this": true,
+	"will": true, "your": true, "from": true, "they": true, "been": true, "were": true,
+	"said": true, "each": true, "which": true, "their": true, "there": true, "what": true,
+	"about": true, "would": true, "these": true, "other": true, "into": true, "more": true,
+	"also": true, "than": true, "then": true, "them": true, "some": true, "when": true,
+	"only": true, "over": true, "such": true, "after": true, "most": true,
+}
+
+// lexicalCoverage is a ROUGE-1 style recall: the fraction of the source's most
+// frequent non-stopword terms that also appear in the summary
+func lexicalCoverage(source, summary string) float64 {
+	counts := make(map[string]int)
+	for _, word := range wordPattern.FindAllString(strings.ToLower(source), -1) {
+		if len(word) > 2 && !stopwords[word] {
+			counts[word]++
+		}
+	}
+	if len(counts) == 0 {
+		return 0
+	}
+
+	terms := make([]string, 0, len(counts))
+	for term := range counts {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if counts[terms[i]] != counts[terms[j]] {
+			return counts[terms[i]] > counts[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > coverageTerms {
+		terms = terms[:coverageTerms]
+	}
+
+	inSummary := make(map[string]bool)
+	for _, word := range wordPattern.FindAllString(strings.ToLower(summary), -1) {
+		inSummary[word] = true
+	}
+	covered := 0
+	for _, term := range terms {
+		if inSummary[term] {
+			covered++
+		}
+	}
+	return float64(covered) / float64(len(terms))
+}
+
+// fleschKincaidGrade returns the Flesch-Kincaid grade level of text, using a
+// vowel-group heuristic to count syllables
+func fleschKincaidGrade(text string) float64 {
+	words := wordPattern.FindAllString(text, -1)
+	if len(words) == 0 {
+		return 0
+	}
+	sentences := 0
+	for _, sentence := range sentenceBreak.Split(text, -1) {
+		if wordPattern.MatchString(sentence) {
+			sentences++
+		}
+	}
+	if sentences == 0 {
+		sentences = 1
+	}
+	syllables := 0
+	for _, word := range words {
+		syllables += countSyllables(word)
+	}
+	return 0.39*float64(len(words))/float64(sentences) + 11.8*float64(syllables)/float64(len(words)) - 15.59
+}
+
+// countSyllables estimates a word's syllables as its vowel groups, not counting a silent final "e"
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	count := 0
+	prevVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
+
+// decodeJSON unmarshals a model reply into v, tolerating surrounding prose and markdown code fences
+func decodeJSON(content string, v any) error {
+	content = strings.TrimSpace(content)
+	start := strings.IndexAny(content, "{[")
+	end := strings.LastIndexAny(content, "}]")
+	if start == -1 || end < start {
+		return fmt.Errorf("no JSON found in model reply")
+	}
+	return json.Unmarshal([]byte(content[start:end+1]), v)
+}
+
+// parseTwoTier extracts the TL;DR and full summary from a "tldr_plus" reply
+func parseTwoTier(content string) (string, string, error) {
+	var twoTier struct {
+		TLDR    string `json:"tldr"`
+		Summary string `json:"summary"`
+	}
+	if err := decodeJSON(content, &twoTier); err != nil {
+		return "", "", fmt.Errorf("failed to parse tldr_plus summary: %w", err)
+	}
+	tldr := strings.TrimSpace(twoTier.TLDR)
+	summary := strings.TrimSpace(twoTier.Summary)
+	if tldr == "" || summary == "" {
+		return "", "", fmt.Errorf("tldr_plus summary is missing the tldr or summary field")
+	}
+	return tldr, summary, nil
+}
+
+// validateBilingual rejects BilingualTarget combined with options that shape the reply differently
+func validateBilingual(req Request) error {
+	if strings.TrimSpace(req.BilingualTarget) == "" {
+		return nil
+	}
+	switch {
+	case req.Language != "":
+		return fmt.Errorf("bilingual_target cannot be combined with language")
+	case req.Style == "tldr_plus", req.Style == "social":
+		return fmt.Errorf("bilingual_target cannot be combined with the %s style", req.Style)
+	case req.Format == "json":
+		return fmt.Errorf("bilingual_target cannot be combined with json format")
+	}
+	return nil
+}
+
+// cleanSectionNames trims the required section names, dropping blanks and repeats
+func cleanSectionNames(names []string) []string {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		cleaned = append(cleaned, name)
+	}
+	return cleaned
+}
+
+// validateRequiredSections rejects RequiredSections combined with options that shape the reply differently
+func validateRequiredSections(req Request) error {
+	if len(req.RequiredSections) == 0 {
+		return nil
+	}
+	switch {
+	case req.Style == "tldr_plus", req.Style == "social":
+		return fmt.Errorf("required_sections cannot be combined with the %s style", req.Style)
+	case req.Style == "bullet_points" && req.Format == "json":
+		return fmt.Errorf("required_sections cannot be combined with json bullet_points")
+	case strings.TrimSpace(req.BilingualTarget) != "":
+		return fmt.Errorf("required_sections cannot be combined with bilingual_target")
+	}
+	return nil
+}
+
+// parseRequiredSections extracts each required section from a reply: a JSON object
+// of sections when asJSON is set, otherwise text under "## Name" headings. It fails
+// if any section is missing or empty.
+func parseRequiredSections(content string, names []string, asJSON bool) (map[string]string, error) {
+	found := make(map[string]string)
+	if asJSON {
+		var reply struct {
+			Sections map[string]string `json:"sections"`
+		}
+		if err := decodeJSON(content, &reply); err != nil {
+			return nil, fmt.Errorf("failed to parse sectioned summary: %w", err)
+		}
+		for name, text := range reply.Sections {
+			found[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(text)
+		}
+	} else {
+		wanted := make(map[string]bool)
+		for _, name := range names {
+			wanted[strings.ToLower(name)] = true
+		}
+		current := ""
+		var body []string
+		flush := func() {
+			if current != "" {
+				found[current] = strings.TrimSpace(strings.Join(body, "\n"))
+			}
+			body = nil
+		}
+		for _, line := range strings.Split(content, "\n") {
+			heading := strings.ToLower(strings.TrimSpace(strings.Trim(strings.TrimSpace(line), "#*_: ")))
+			if wanted[heading] {
+				flush()
+				current = heading
+				continue
+			}
+			body = append(body, line)
+		}
+		flush()
+	}
+
+	sections := make(map[string]string, len(names))
+	var missing []string
+	for _, name := range names {
+		text := found[strings.ToLower(name)]
+		if text == "" {
+			missing = append(missing, name)
+			continue
+		}
+		sections[name] = text
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("summary is missing required sections: %s", strings.Join(missing, ", "))
+	}
+	return sections, nil
+}
+
+// renderSections formats sections as markdown, in the required order
+func renderSections(sections map[string]string, names []string) string {
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("## %s\n%s", name, sections[name]))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// parseBilingual extracts the original-language summary and its translation from a bilingual reply
+func parseBilingual(content, target string) (*BilingualSummary, error) {
+	var reply struct {
+		SourceLanguage string `json:"source_language"`
+		Summary        string `json:"summary"`
+		Translation    string `json:"translation"`
+	}
+	if err := decodeJSON(content, &reply); err != nil {
+		return nil, fmt.Errorf("failed to parse bilingual summary: %w", err)
+	}
+	bilingual := &BilingualSummary{
+		SourceLanguage: strings.TrimSpace(reply.SourceLanguage),
+		Summary:        strings.TrimSpace(reply.Summary),
+		TargetLanguage: target,
+		Translation:    strings.TrimSpace(reply.Translation),
+	}
+	if bilingual.Summary == "" || bilingual.Translation == "" {
+		return nil, fmt.Errorf("bilingual summary is missing the summary or translation field")
+	}
+	return bilingual, nil
+}
+
+// numberPattern matches figures such as 1,234.5, -3%, $2.5 and 2024
+var numberPattern = regexp.MustCompile(`[-+]?[$€£]?\d[\d,]*(?:\.\d+)?%?`)
+
+// sentenceBreak splits text into sentences without breaking decimals such as 3.5
+var sentenceBreak = regexp.MustCompile(`[.!?]+\s+|\n+`)
+
+// maxNumericFacts caps how many source figures are pinned in the prompt
+const maxNumericFacts = 40
+
+// numericFacts returns the distinct sentences of text that contain figures, in order
+func numericFacts(text string) []string {
+	var facts []string
+	seen := make(map[string]bool)
+	for _, sentence := range sentenceBreak.Split(text, -1) {
+		sentence = strings.Join(strings.Fields(sentence), " ")
+		if sentence == "" || seen[sentence] || !numberPattern.MatchString(sentence) {
+			continue
+		}
+		seen[sentence] = true
+		facts = append(facts, sentence)
+		if len(facts) == maxNumericFacts {
+			break
+		}
+	}
+	return facts
+}
+
+// unsupportedNumbers lists the figures in summary that do not occur in source,
+// ignoring signs, thousands separators, currency signs, and percent signs
+func unsupportedNumbers(source, summary string) []string {
+	known := make(map[string]bool)
+	for _, n := range numberPattern.FindAllString(source, -1) {
+		known[normalizeNumber(n)] = true
+	}
+	unsupported := []string{}
+	reported := make(map[string]bool)
+	for _, n := range numberPattern.FindAllString(summary, -1) {
+		normalized := normalizeNumber(n)
+		if !known[normalized] && !reported[normalized] {
+			reported[normalized] = true
+			unsupported = append(unsupported, n)
+		}
+	}
+	return unsupported
+}
+
+// normalizeNumber reduces a matched figure to its bare digits for comparison
+func normalizeNumber(n string) string {
+	return strings.Trim(strings.NewReplacer(",", "", "$", "", "€", "", "£", "", "%", "", "+", "", "-", "").Replace(n), ".")
+}
+
+// parseBullets extracts the bullets from a JSON bullet_points reply, checking the count when count is positive
+func parseBullets(content string, count int) ([]string, error) {
+	var reply struct {
+		Bullets []string `json:"bullets"`
+	}
+	if err := decodeJSON(content, &reply); err != nil {
+		return nil, fmt.Errorf("failed to parse bullet summary: %w", err)
+	}
+	bullets := []string{}
+	for _, bullet := range reply.Bullets {
+		if bullet = strings.TrimSpace(strings.TrimLeft(bullet, "-*• ")); bullet != "" {
+			bullets = append(bullets, bullet)
+		}
+	}
+	if len(bullets) == 0 {
+		return nil, fmt.Errorf("bullet summary has no bullets")
+	}
+	if count > 0 && len(bullets) != count {
+		return nil, fmt.Errorf("expected %d bullets, got %d", count, len(bullets))
+	}
+	return bullets, nil
+}
+
+// buildPrompt constructs the summarization prompt based on the request
+func (s *Service) buildPrompt(req Request) string {
+	return Instructions(req) + ":\n\n" + req.Content
+}
+
+// Instructions describes the requested summary length, style, and language as
+// the summarizer prompts for them, for callers that ask a model for a summary
+// themselves and want it to match the summarizer's
+func Instructions(req Request) string {
+	var promptBuilder strings.Builder
+
+	// Base instruction
+	promptBuilder.WriteString("Please summarize the following text")
+
+	// Social posts are bounded by the platform's character limit instead
+	if req.Style == "social" {
+		platform := socialPlatformFor(req.Platform)
+		promptBuilder.WriteString(fmt.Sprintf(" as a %s post of at most %d characters in total. %s", platform.Name, platform.CharLimit, platform.Guidance))
+		if req.Language != "" {
+			promptBuilder.WriteString(fmt.Sprintf(" Write it in %s.", req.Language))
+		}
+		promptBuilder.WriteString(" Return only the post text")
+		return promptBuilder.String()
+	}
+
+	// Add length constraint
+	if req.MaxLength > 0 {
+		promptBuilder.WriteString(fmt.Sprintf(" in approximately %d words or less", req.MaxLength))
+	}
+
+	// Add style instructions
+	switch req.Style {
+	case "detailed":
+		promptBuilder.WriteString(". Provide a detailed summary that captures the main points, key arguments, and important details")
+	case "bullet_points":
+		if req.BulletCount > 0 {
+			promptBuilder.WriteString(fmt.Sprintf(". Format the summary as exactly %d bullet points, highlighting the key information", req.BulletCount))
+		} else {
+			promptBuilder.WriteString(". Format the summary as bullet points, highlighting the key information")
+		}
+	case "concise":
+		promptBuilder.WriteString(". Provide a concise summary focusing on the most important information")
+	case "tldr_plus":
+		promptBuilder.WriteString(". Provide a one-sentence TL;DR and a fuller summary that expands on it")
+	case "discussion":
+		// Content is a thread rendered by scraper.ThreadNode.String
+		promptBuilder.WriteString(". The text is a discussion thread: a post followed by comments, with replies indented under the comment they answer. Briefly state what the post says, then the top points raised in the comments, where commenters reach consensus, and where they disagree, giving more weight to points that drew many replies")
+	default:
+		promptBuilder.WriteString(". Provide a clear and informative summary")
+	}
+
+	// Add language instruction if specified
+	if req.Language != "" {
+		promptBuilder.WriteString(fmt.Sprintf(" in %s", req.Language))
+	}
+
+	if guidance, ok := pageTypeGuidance[req.PageType]; ok {
+		promptBuilder.WriteString(". " + guidance)
+	}
+
+	if focus := strings.TrimSpace(req.Focus); focus != "" {
+		promptBuilder.WriteString(fmt.Sprintf(". Focus the summary on %s: emphasize what the text says about it, mention other points only as needed for context, and say so if the text does not cover it", focus))
+	}
+
+	if req.ReadingLevel > 0 {
+		promptBuilder.WriteString(fmt.Sprintf(". Write at a US grade %d reading level, using short sentences and common words suited to that audience", req.ReadingLevel))
+	}
+
+	if req.PreserveNumbers {
+		if facts := numericFacts(req.Content); len(facts) > 0 {
+			promptBuilder.WriteString(". Use these exact figures from the source wherever they are relevant, without rounding, converting, or altering them:\n- ")
+			promptBuilder.WriteString(strings.Join(facts, "\n- "))
+			promptBuilder.WriteString("\nDo not introduce any number that is not in the source")
+		}
+	}
+	if req.Style == "bullet_points" && req.Format == "json" {
+		promptBuilder.WriteString(`. Respond with JSON only, in the form {"bullets": ["first point", ...]}`)
+	}
+	if req.Style == "tldr_plus" {
+		promptBuilder.WriteString(`. Respond with JSON only, in the form {"tldr": "one sentence", "summary": "fuller summary"}`)
+	}
+	if len(req.RequiredSections) > 0 {
+		names := strings.Join(req.RequiredSections, ", ")
+		if req.Format == "json" {
+			promptBuilder.WriteString(fmt.Sprintf(`. Organize the summary into exactly these sections, in order: %s. Write N/A for any section the text does not cover. Respond with JSON only, in the form {"sections": {"%s": "section text", ...}}`, names, req.RequiredSections[0]))
+		} else {
+			promptBuilder.WriteString(fmt.Sprintf(". Organize the summary into exactly these sections, in order: %s. Start each with a markdown heading of its exact name (e.g. \"## %s\") and write N/A for any section the text does not cover", names, req.RequiredSections[0]))
+		}
+	}
+	if target := strings.TrimSpace(req.BilingualTarget); target != "" {
+		promptBuilder.WriteString(fmt.Sprintf(`. Write the summary in the text's original language, then translate it into %s. Respond with JSON only, in the form {"source_language": "language of the text", "summary": "summary in that language", "translation": "the summary in %s"}`, target, target))
+	}
+
+	return promptBuilder.String()
+}
+
+// socialPlatform describes a social media target for the "social" style
+type socialPlatform struct {
+	Name      string
+	CharLimit int
+	Guidance  string
+}
+
+// socialPlatforms are the supported Request.Platform values
+var socialPlatforms = map[string]socialPlatform{
+	"twitter": {
+		Name:      "twitter",
+		CharLimit: 280,
+		Guidance:  "Make it punchy and conversational, and end with one or two relevant hashtags.",
+	},
+	"linkedin": {
+		Name:      "linkedin",
+		CharLimit: 700,
+		Guidance:  "Use a professional, insightful tone, lead with the key takeaway, and end with up to three relevant hashtags.",
+	},
+	"short": {
+		Name:      "short",
+		CharLimit: 160,
+		Guidance:  "Keep it plain and direct, with no hashtags or emoji.",
+	},
+}
+
+// socialPlatformFor returns the named platform, falling back to the generic short form
+func socialPlatformFor(name string) socialPlatform {
+	if platform, ok := socialPlatforms[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return platform
+	}
+	return socialPlatforms["short"]
+}
+
+// enforceSocialLimit hard-enforces the platform's character limit on a social post.
+// An overlong post is sent back once for shortening; if it is still too long it
+// is cut at a word boundary.
+func (s *Service) enforceSocialLimit(ctx context.Context, chatReq openai.ChatCompletionRequest, req Request, post string, tally *completionTally) string {
+	limit := socialPlatformFor(req.Platform).CharLimit
+	if utf8.RuneCountInString(post) <= limit {
+		return post
+	}
+
+	s.logger.Warn().Int("length", utf8.RuneCountInString(post)).Int("limit", limit).Msg("Social post too long; asking for a shorter one")
+	correction := fmt.Sprintf("That post is %d characters. Rewrite it in at most %d characters. Return only the post text.", utf8.RuneCountInString(post), limit)
+	if shorter, ok := s.retryOnce(ctx, chatReq, post, correction, "social_retry", tally); ok && shorter != "" {
+		post = shorter
+	}
+	return textutil.TruncateWords(post, limit)
+}
+
+// SummarizeWithKeywords generates a summary and extracts keywords. The two completions
+// are independent and run concurrently; a keyword failure still returns the summary.
+func (s *Service) SummarizeWithKeywords(ctx context.Context, req Request) (*Response, []string, error) {
+	var (
+		wg          sync.WaitGroup
+		keywords    []string
+		keywordsErr error
+	)
+	keywordCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		keywords, keywordsErr = s.extractKeywords(keywordCtx, req.Content)
+	}()
+
+	response, err := s.Summarize(ctx, req)
+	if err != nil {
+		// Keywords are useless without the summary
+		cancel()
+	}
+	wg.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if keywordsErr != nil {
+		s.logger.Warn().Err(keywordsErr).Msg("Failed to extract keywords")
+		return response, []string{}, nil
+	}
+	return response, keywords, nil
+}
+
+// extractKeywords asks the model for the key terms of content
+func (s *Service) extractKeywords(ctx context.Context, content string) ([]string, error) {
+	keywordPrompt := fmt.Sprintf(`Extract 5-10 key terms or phrases from the following text. Return only the keywords, separated by commas:
+
+%s`, content)
+
+	keywordReq := openai.ChatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "You extract key terms and phrases from text. Return only the keywords separated by commas.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: keywordPrompt,
+			},
+		},
+		MaxTokens:   100,
+		Temperature: 0.2,
+	}
+
+	keywordResp, _, err := s.complete(ctx, keywordReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clean up keywords
+	cleanKeywords := []string{}
+	if len(keywordResp.Choices) > 0 {
+		for _, keyword := range strings.Split(keywordResp.Choices[0].Message.Content, ",") {
+			if cleaned := strings.TrimSpace(keyword); cleaned != "" {
+				cleanKeywords = append(cleanKeywords, cleaned)
+			}
+		}
+	}
+	return cleanKeywords, nil
+}
+
+// classifyContentLimit is how many characters of a page the classifier reads
+const classifyContentLimit = 3000
+
+// ClassifyPage asks the model which of pagetype.All a page is, for use as a
+// scraper.Config.PageClassifier when the scraper's heuristics are unsure
+func (s *Service) ClassifyPage(ctx context.Context, url, title, content string) (string, error) {
+	classifyReq := openai.ChatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: fmt.Sprintf("You classify web pages. Reply with exactly one of: %s.", strings.Join(pagetype.All, ", ")),
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("URL: %s\nTitle: %s\n\n%s", url, title, textutil.TruncateRunes(content, classifyContentLimit)),
+			},
+		},
+		MaxTokens:   5,
+		Temperature: 0,
+	}
+
+	resp, _, err := s.complete(ctx, classifyReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to classify page: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no classification generated")
+	}
+	answer := strings.ToLower(strings.Trim(strings.TrimSpace(resp.Choices[0].Message.Content), ".\"'"))
+	if !pagetype.Valid(answer) {
+		return "", fmt.Errorf("unexpected page type %q", answer)
+	}
+	return answer, nil
+}
+
+// Entity is a named entity found in content
+type Entity struct {
+	Text string `json:"text"`
+	Type string `json:"type"` // "person", "organization", "location", "product"
+}
+
+// entityTypes are the entity types ExtractEntities accepts from the model
+var entityTypes = map[string]bool{
+	"person":       true,
+	"organization": true,
+	"location":     true,
+	"product":      true,
+}
+
+// ExtractEntities extracts typed named entities from content, deduplicated case-insensitively
+func (s *Service) ExtractEntities(ctx context.Context, content string) ([]Entity, error) {
+	if err := s.ValidateContent(content); err != nil {
+		return nil, err
+	}
+
+	entityPrompt := fmt.Sprintf(`Extract the named entities (people, organizations, locations, and products) mentioned in the following text.
+Respond with JSON only, in the form {"entities": [{"text": "entity name", "type": "person|organization|location|product"}]}. Use an empty list when there are none.
+
+%s`, content)
+
+	entityReq := openai.ChatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "You extract named entities from text and return them as JSON.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: entityPrompt,
+			},
+		},
+		MaxTokens:   500,
+		Temperature: 0.1,
+	}
+
+	entityResp, _, err := s.complete(ctx, entityReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract entities: %w", err)
+	}
+	if len(entityResp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from LLM")
+	}
+
+	entities, err := parseEntities(entityResp.Choices[0].Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info().Int("entities", len(entities)).Msg("Entities extracted")
+	return entities, nil
+}
+
+// parseEntities decodes an entities reply, dropping unknown types and case-insensitive duplicates
+func parseEntities(content string) ([]Entity, error) {
+	var reply struct {
+		Entities []Entity `json:"entities"`
+	}
+	if err := decodeJSON(content, &reply); err != nil {
+		return nil, fmt.Errorf("failed to parse entities: %w", err)
+	}
+
+	entities := []Entity{}
+	seen := make(map[string]bool)
+	for _, entity := range reply.Entities {
+		entity.Text = strings.TrimSpace(entity.Text)
+		entity.Type = strings.ToLower(strings.TrimSpace(entity.Type))
+		if entity.Text == "" || !entityTypes[entity.Type] {
+			continue
+		}
+		key := strings.ToLower(entity.Text)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
+
+// MergeSummaries combines overlapping summaries into one deduplicated summary.
+// Contradictions between the inputs are listed in Metadata["conflicts"].
+func (s *Service) MergeSummaries(ctx context.Context, summaries []string) (*Response, error) {
+	var inputs []string
+	for _, summary := range summaries {
+		if summary = strings.TrimSpace(summary); summary != "" {
+			inputs = append(inputs, summary)
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no summaries to merge")
+	}
+
+	s.logger.Info().Int("summaries", len(inputs)).Msg("Merging summaries")
+
+	var promptBuilder strings.Builder
+	promptBuilder.WriteString(`Merge the following summaries into a single cohesive summary. Remove repeated points, keep every distinct fact, and do not add information that is not in the inputs.
+If the summaries contradict each other, describe each contradiction instead of silently picking a side.
+Respond with JSON only, in the form {"summary": "merged summary", "conflicts": ["description", ...]}. Use an empty list when there are no contradictions.`)
+	for i, summary := range inputs {
+		promptBuilder.WriteString(fmt.Sprintf("\n\nSUMMARY %d:\n%s", i+1, summary))
+	}
+
+	mergeReq := openai.ChatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "You are a careful editor who consolidates overlapping summaries without losing or inventing facts.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: promptBuilder.String(),
+			},
+		},
+		MaxTokens:   s.config.MaxTokens,
+		Temperature: 0.3,
+	}
+	s.config.applySampling(&mergeReq)
+
+	resp, usageEstimated, err := s.complete(ctx, mergeReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response choices returned")
+	}
+
+	var merged struct {
+		Summary   string   `json:"summary"`
+		Conflicts []string `json:"conflicts"`
+	}
+	if err := decodeJSON(resp.Choices[0].Message.Content, &merged); err != nil {
+		return nil, fmt.Errorf("failed to parse merged summary: %w", err)
+	}
+	summary := strings.TrimSpace(merged.Summary)
+	if summary == "" {
+		return nil, fmt.Errorf("merged summary is empty")
+	}
+
+	conflicts := []string{}
+	for _, conflict := range merged.Conflicts {
+		if conflict = strings.TrimSpace(conflict); conflict != "" {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	encoded, _ := json.Marshal(conflicts)
+
+	originalSize := 0
+	for _, input := range inputs {
+		originalSize += len(input)
+	}
+
+	return &Response{
+		Summary:          summary,
+		OriginalSize:     originalSize,
+		SummarySize:      len(summary),
+		Model:            resp.Model,
+		TokensUsed:       resp.Usage.TotalTokens,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		EstimatedCostUSD: s.config.estimateCost(resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+		Metadata: map[string]string{
+			"merged_count":    fmt.Sprintf("%d", len(inputs)),
+			"conflicts":       string(encoded),
+			"has_conflicts":   fmt.Sprintf("%t", len(conflicts) > 0),
+			"usage_estimated": fmt.Sprintf("%t", usageEstimated),
+		},
+	}, nil
+}
+
+// BatchResult is the outcome of one request in a batch, tagged with its input index
+type BatchResult struct {
+	Index    int
+	Response *Response
+	Err      error
+}
+
+// SummarizeBatchStream summarizes the requests concurrently and delivers each result
+// on the returned channel as soon as it finishes. Every request produces exactly one
+// result; the channel is closed once all have been delivered.
+func (s *Service) SummarizeBatchStream(ctx context.Context, reqs []Request) <-chan BatchResult {
+	results := make(chan BatchResult, len(reqs))
+
+	concurrency := s.config.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 3
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(index int, r Request) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}: // Acquire
+			case <-ctx.Done():
+				results <- BatchResult{Index: index, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-semaphore }() // Release
+
+			response, err := s.Summarize(ctx, r)
+			results <- BatchResult{Index: index, Response: response, Err: err}
+		}(i, req)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// SummarizeBatch summarizes the requests concurrently and returns the responses in
+// input order. Failed requests leave a nil entry and are reported in the error.
+func (s *Service) SummarizeBatch(ctx context.Context, reqs []Request) ([]*Response, error) {
+	responses := make([]*Response, len(reqs))
+	var errs []error
+	for result := range s.SummarizeBatchStream(ctx, reqs) {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("request %d: %w", result.Index, result.Err))
+			continue
+		}
+		responses[result.Index] = result.Response
+	}
+	return responses, errors.Join(errs...)
+}
+
+// SummarizeBatchPartial summarizes the requests like SummarizeBatch, but treats
+// the end of ctx (e.g. a context.WithTimeout deadline) as a time box rather than
+// a failure: outstanding summaries are cancelled and those that finished are
+// returned with cutShort set. Unfinished requests leave a nil entry; the error
+// reports only requests that failed on their own rather than being cut off.
+func (s *Service) SummarizeBatchPartial(ctx context.Context, reqs []Request) (responses []*Response, cutShort bool, err error) {
+	responses = make([]*Response, len(reqs))
+	var errs []error
+	for result := range s.SummarizeBatchStream(ctx, reqs) {
+		if result.Err == nil {
+			responses[result.Index] = result.Response
+			continue
+		}
+		if cutShortBy(ctx, result.Err) {
+			cutShort = true
+			continue
+		}
+		errs = append(errs, fmt.Errorf("request %d: %w", result.Index, result.Err))
+	}
+	if cutShort {
+		s.logger.Warn().Err(ctx.Err()).Int("requests", len(reqs)).Msg("Batch summarization cut short; returning finished summaries")
+	}
+	return responses, cutShort, errors.Join(errs...)
+}
+
+// cutShortBy reports whether err is the failure of a summary abandoned because
+// ctx ended. A failure of its own, such as a provider error, is not, even when
+// it is read after ctx has ended.
+func cutShortBy(ctx context.Context, err error) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
 
 // ValidateContent checks if content is suitable for summarization
@@ -0,0 +1,1477 @@
+package summarizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/HeidiZHH/skull/internal/audit"
+	"github.com/HeidiZHH/skull/internal/retry"
+	"github.com/rs/zerolog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeLLM is an OpenAI-compatible chat completions endpoint that records each
+// request and answers with respond, called with the zero-based call number.
+// Streaming requests get the reply split into word chunks, or an error with noStream.
+type fakeLLM struct {
+	*httptest.Server
+	respond  func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse
+	noStream bool
+
+	mu       sync.Mutex
+	requests []openai.ChatCompletionRequest
+}
+
+func newFakeLLM(t *testing.T, respond func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse) *fakeLLM {
+	t.Helper()
+	f := &fakeLLM{respond: respond}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		call := len(f.requests)
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+
+		resp := f.respond(call, req)
+		if req.Stream {
+			if f.noStream {
+				http.Error(w, `{"error": {"message": "streaming is not supported"}}`, http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, word := range strings.SplitAfter(resp.Choices[0].Message.Content, " ") {
+				chunk, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+					Model:   resp.Model,
+					Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{Content: word}}},
+				})
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Requests returns the chat completion requests received so far
+func (f *fakeLLM) Requests() []openai.ChatCompletionRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), f.requests...)
+}
+
+// replies answers the calls in order with the given contents, repeating the last
+func replies(contents ...string) func(int, openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+	return func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+		return reply(contents[min(call, len(contents)-1)])
+	}
+}
+
+// reply is a completion of content using 10 prompt and 5 completion tokens
+func reply(content string) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		ID:    "chatcmpl-test",
+		Model: "test-model",
+		Choices: []openai.ChatCompletionChoice{{
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+			FinishReason: openai.FinishReasonStop,
+		}},
+		Usage: openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}
+}
+
+// newTestService creates a summarizer talking to llm; config's Model defaults to test-model
+func newTestService(t *testing.T, llm *fakeLLM, config Config) *Service {
+	t.Helper()
+	config.APIKey = "test-key"
+	config.BaseURL = llm.URL + "/v1"
+	if config.Model == "" {
+		config.Model = "test-model"
+	}
+	service, err := NewService(config, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return service
+}
+
+// userPrompt returns the last user message of a request
+func userPrompt(req openai.ChatCompletionRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == openai.ChatMessageRoleUser {
+			return req.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+const testSource = "The city council approved the new budget on Tuesday. Spending on parks rises by 12 percent, while road repairs receive 4 million dollars."
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   []string // substrings of the error; empty means valid
+	}{
+		{name: "model only", config: Config{Model: "m"}},
+		{name: "missing model", config: Config{}, want: []string{"Model is required"}},
+		{
+			name:   "sampling out of range",
+			config: Config{Model: "m", TopP: 2, FrequencyPenalty: -3, PresencePenalty: 2.5},
+			want:   []string{"TopP must be between 0 and 1", "FrequencyPenalty must be between -2 and 2", "PresencePenalty must be between -2 and 2"},
+		},
+		{
+			name:   "negative limits",
+			config: Config{Model: "m", MaxTokens: -1, BatchConcurrency: -1, EmbeddingBatchSize: -5},
+			want:   []string{"MaxTokens must not be negative", "BatchConcurrency must not be negative", "EmbeddingBatchSize must not be negative"},
+		},
+		{name: "negative price", config: Config{Model: "m", Prices: map[string]ModelPrice{"m": {PromptPerMillion: -1}}}, want: []string{`Prices["m"] must not be negative`}},
+		{name: "bad base URL", config: Config{Model: "m", BaseURL: "https://example.com:0"}, want: []string{"BaseURL", "port must be between 1 and 65535"}},
+		{name: "base URL without scheme", config: Config{Model: "m", BaseURL: " api.example.com/ "}},
+		{name: "bad embeddings URL", config: Config{Model: "m", EmbeddingBaseURL: "not a url"}, want: []string{"EmbeddingBaseURL"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyFaithfulnessFlagsUnsupportedClaims(t *testing.T) {
+	llm := newFakeLLM(t, replies(
+		"The council approved the budget, raising park spending by 12 percent. The mayor resigned in protest.",
+		"```json\n{\"unsupported_claims\": [\"The mayor resigned in protest.\", \" \"]}\n```",
+	))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.Summarize(context.Background(), Request{Content: testSource, VerifyFaithfulness: true})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+
+	requests := llm.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d completions, want the summary and the check", len(requests))
+	}
+	if check := userPrompt(requests[1]); !strings.Contains(check, testSource) || !strings.Contains(check, "The mayor resigned") {
+		t.Errorf("faithfulness prompt does not hold the source and summary:\n%s", check)
+	}
+	if got := resp.Metadata["hallucination_risk"]; got != "high" {
+		t.Errorf("hallucination_risk = %q, want high", got)
+	}
+	if got := resp.Metadata["unsupported_claims"]; got != `["The mayor resigned in protest."]` {
+		t.Errorf("unsupported_claims = %s", got)
+	}
+	if resp.TokensUsed != 30 {
+		t.Errorf("TokensUsed = %d, want both completions' 30", resp.TokensUsed)
+	}
+}
+
+func TestVerifyFaithfulnessFailureKeepsSummary(t *testing.T) {
+	llm := newFakeLLM(t, replies("The council approved the budget.", "I cannot check that."))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.Summarize(context.Background(), Request{Content: testSource, VerifyFaithfulness: true})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if resp.Summary != "The council approved the budget." {
+		t.Errorf("Summary = %q", resp.Summary)
+	}
+	if resp.Metadata["faithfulness_checked"] != "false" || resp.Metadata["faithfulness_error"] == "" {
+		t.Errorf("metadata = %v, want an unchecked verdict with its error", resp.Metadata)
+	}
+}
+
+func TestTwoPassReturnsRevision(t *testing.T) {
+	llm := newFakeLLM(t, replies("Draft: the council met.", "The council approved the budget, raising park spending by 12 percent."))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.Summarize(context.Background(), Request{Content: testSource, TwoPass: true})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+
+	requests := llm.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d completions, want the draft and the revision", len(requests))
+	}
+	if critique := userPrompt(requests[1]); !strings.Contains(critique, "Draft: the council met.") || !strings.Contains(critique, testSource) {
+		t.Errorf("revision prompt does not hold the draft and source:\n%s", critique)
+	}
+	if resp.Summary != "The council approved the budget, raising park spending by 12 percent." {
+		t.Errorf("Summary = %q, want the revision", resp.Summary)
+	}
+	if got := resp.Metadata["passes"]; got != "2" {
+		t.Errorf("passes = %q, want 2", got)
+	}
+	if resp.TokensUsed != 30 {
+		t.Errorf("TokensUsed = %d, want both passes' 30", resp.TokensUsed)
+	}
+}
+
+func TestSinglePassByDefault(t *testing.T) {
+	llm := newFakeLLM(t, replies("The council approved the budget."))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.Summarize(context.Background(), Request{Content: testSource})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if n := len(llm.Requests()); n != 1 {
+		t.Errorf("got %d completions, want 1", n)
+	}
+	if got := resp.Metadata["passes"]; got != "1" {
+		t.Errorf("passes = %q, want 1", got)
+	}
+}
+
+func TestUsageCountsEveryCompletion(t *testing.T) {
+	contents := []string{
+		"A bar chart of park spending by year.",
+		"Draft summary.",
+		"The council approved the budget.",
+		`{"unsupported_claims": []}`,
+	}
+	llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+		resp := reply(contents[min(call, len(contents)-1)])
+		if req.Model == "vision-model" {
+			resp.Model = "vision-model"
+			resp.Usage = openai.Usage{PromptTokens: 200, CompletionTokens: 20, TotalTokens: 220}
+		}
+		return resp
+	})
+	service := newTestService(t, llm, Config{
+		VisionModel: "vision-model",
+		Prices: map[string]ModelPrice{
+			"test-model":   {PromptPerMillion: 1, CompletionPerMillion: 2},
+			"vision-model": {PromptPerMillion: 10, CompletionPerMillion: 20},
+		},
+	})
+
+	resp, err := service.Summarize(context.Background(), Request{
+		Content:            testSource,
+		ImageURL:           "https://example.com/chart.png",
+		TwoPass:            true,
+		VerifyFaithfulness: true,
+	})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if n := len(llm.Requests()); n != 4 {
+		t.Fatalf("got %d completions, want vision, draft, revision, and check", n)
+	}
+
+	if resp.PromptTokens != 230 || resp.CompletionTokens != 35 || resp.TokensUsed != 265 {
+		t.Errorf("tokens = %d prompt, %d completion, %d total; want 230, 35, 265",
+			resp.PromptTokens, resp.CompletionTokens, resp.TokensUsed)
+	}
+	// Three test-model completions of 10+5 tokens and one vision-model completion of 200+20
+	wantCost := (3*10*1.0 + 3*5*2.0 + 200*10.0 + 20*20.0) / 1_000_000
+	if diff := resp.EstimatedCostUSD - wantCost; diff > 1e-12 || diff < -1e-12 {
+		t.Errorf("EstimatedCostUSD = %v, want %v", resp.EstimatedCostUSD, wantCost)
+	}
+	if resp.Metadata["prompt_tokens"] != "230" || resp.Metadata["completion_tokens"] != "35" {
+		t.Errorf("metadata tokens = %s prompt, %s completion; want 230, 35",
+			resp.Metadata["prompt_tokens"], resp.Metadata["completion_tokens"])
+	}
+}
+
+func TestDescribeImage(t *testing.T) {
+	const imageURL = "https://example.com/budget-chart.png"
+	const description = "A bar chart: parks spending rises 12 percent, roads get 4 million dollars."
+
+	for _, visionFails := range []bool{false, true} {
+		t.Run(fmt.Sprintf("vision fails=%v", visionFails), func(t *testing.T) {
+			llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+				if req.Model != "test-vision" {
+					return reply("The council approved the budget.")
+				}
+				if visionFails {
+					return openai.ChatCompletionResponse{Model: "test-vision"}
+				}
+				return reply(description)
+			})
+			service := newTestService(t, llm, Config{VisionModel: "test-vision"})
+
+			resp, err := service.Summarize(context.Background(), Request{Content: testSource, ImageURL: imageURL})
+			if err != nil {
+				t.Fatalf("Summarize: %v", err)
+			}
+			requests := llm.Requests()
+			if len(requests) != 2 {
+				t.Fatalf("got %d completions, want the image description and the summary", len(requests))
+			}
+
+			vision := requests[0]
+			if vision.Model != "test-vision" {
+				t.Errorf("first completion used model %q, want the vision model", vision.Model)
+			}
+			parts := vision.Messages[len(vision.Messages)-1].MultiContent
+			var sent string
+			for _, part := range parts {
+				if part.Type == openai.ChatMessagePartTypeImageURL && part.ImageURL != nil {
+					sent = part.ImageURL.URL
+				}
+			}
+			if sent != imageURL {
+				t.Errorf("vision request image_url part = %q, want %q in %+v", sent, imageURL, parts)
+			}
+
+			prompt := userPrompt(requests[1])
+			if got := strings.Contains(prompt, description); got == visionFails {
+				t.Errorf("summary prompt holds the description = %v, want %v:\n%s", got, !visionFails, prompt)
+			}
+			if !strings.Contains(prompt, testSource) {
+				t.Errorf("summary prompt lost the source:\n%s", prompt)
+			}
+			if visionFails {
+				if _, ok := resp.Metadata["image_described"]; ok {
+					t.Errorf("metadata = %v, want no image_described after a vision failure", resp.Metadata)
+				}
+				return
+			}
+			if resp.Metadata["image_described"] != "true" || resp.Metadata["image_url"] != imageURL {
+				t.Errorf("image_described = %q, image_url = %q; want true and %s",
+					resp.Metadata["image_described"], resp.Metadata["image_url"], imageURL)
+			}
+		})
+	}
+}
+
+func TestSamplingParamsReachRequest(t *testing.T) {
+	llm := newFakeLLM(t, replies("The council approved the budget."))
+	service := newTestService(t, llm, Config{TopP: 0.8, FrequencyPenalty: 0.5, PresencePenalty: -0.3})
+
+	if _, err := service.Summarize(context.Background(), Request{Content: testSource}); err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	req := llm.Requests()[0]
+	if req.TopP != 0.8 || req.FrequencyPenalty != 0.5 || req.PresencePenalty != -0.3 {
+		t.Errorf("sampling = top_p %v, frequency %v, presence %v; want 0.8, 0.5, -0.3",
+			req.TopP, req.FrequencyPenalty, req.PresencePenalty)
+	}
+}
+
+func TestSummarizeBatchStreamDeliversEveryItem(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		prompt := userPrompt(req)
+		item := prompt[strings.Index(prompt, "Item "):][:len("Item 000")]
+		return reply("Summary of " + item + ".")
+	})
+	service := newTestService(t, llm, Config{BatchConcurrency: 2})
+
+	reqs := make([]Request, 5)
+	for i := range reqs {
+		reqs[i] = Request{Content: fmt.Sprintf("%s Item %03d.", testSource, i)}
+	}
+	seen := make(map[int]bool)
+	for result := range service.SummarizeBatchStream(context.Background(), reqs) {
+		if result.Err != nil {
+			t.Errorf("item %d: %v", result.Index, result.Err)
+			continue
+		}
+		if seen[result.Index] {
+			t.Errorf("item %d delivered twice", result.Index)
+		}
+		seen[result.Index] = true
+		if want := fmt.Sprintf("Item %03d.", result.Index); !strings.Contains(result.Response.Summary, want) {
+			t.Errorf("item %d has summary %q, want it to name %q", result.Index, result.Response.Summary, want)
+		}
+	}
+	if len(seen) != len(reqs) {
+		t.Errorf("got %d items, want %d", len(seen), len(reqs))
+	}
+	if maxInFlight > 2 {
+		t.Errorf("%d completions ran at once, want at most BatchConcurrency 2", maxInFlight)
+	}
+}
+
+func TestSummarizeBatchStreamCancelled(t *testing.T) {
+	llm := newFakeLLM(t, replies("unused"))
+	service := newTestService(t, llm, Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	count := 0
+	for result := range service.SummarizeBatchStream(ctx, make([]Request, 4)) {
+		count++
+		if result.Err == nil {
+			t.Errorf("item %d succeeded after cancellation", result.Index)
+		}
+	}
+	if count != 4 {
+		t.Errorf("got %d items, want one per request", count)
+	}
+}
+
+func TestSummarizeBatchPartial(t *testing.T) {
+	release := make(chan struct{})
+	llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+		if strings.Contains(userPrompt(req), "Slow item.") {
+			<-release
+		}
+		return reply("A summary.")
+	})
+	t.Cleanup(func() { close(release) })
+	service := newTestService(t, llm, Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	reqs := []Request{
+		{Content: testSource},
+		{Content: testSource, BilingualTarget: "French", Language: "German"}, // invalid
+		{Content: testSource + " Slow item."},
+	}
+	responses, cutShort, err := service.SummarizeBatchPartial(ctx, reqs)
+
+	if !cutShort {
+		t.Error("cutShort = false, want true after the deadline cut off the slow item")
+	}
+	if responses[0] == nil || responses[0].Summary != "A summary." {
+		t.Errorf("responses[0] = %+v, want the finished summary", responses[0])
+	}
+	if responses[1] != nil || responses[2] != nil {
+		t.Errorf("responses = %v, want nil for the failed and unfinished requests", responses)
+	}
+	if err == nil || !strings.Contains(err.Error(), "request 1") || strings.Contains(err.Error(), "request 2") {
+		t.Errorf("err = %v, want only the request 1 failure", err)
+	}
+}
+
+func TestCutShortBy(t *testing.T) {
+	ended, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{name: "cancelled summary", ctx: ended, err: fmt.Errorf("failed to create chat completion: %w", context.DeadlineExceeded), want: true},
+		{name: "provider error read after the end", ctx: ended, err: &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Message: "bad request"}},
+		{name: "cancellation while running", ctx: context.Background(), err: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cutShortBy(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("cutShortBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLDRPlus(t *testing.T) {
+	llm := newFakeLLM(t, replies("```json\n{\"tldr\": \"Council passes budget.\", \"summary\": \"The council approved the budget on Tuesday, raising park spending by 12 percent.\"}\n```"))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.Summarize(context.Background(), Request{Content: testSource, Style: "tldr_plus", Format: "json"})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if n := len(llm.Requests()); n != 1 {
+		t.Errorf("got %d completions, want both tiers from one", n)
+	}
+	if resp.TLDR != "Council passes budget." {
+		t.Errorf("TLDR = %q", resp.TLDR)
+	}
+	if resp.Summary != "The council approved the budget on Tuesday, raising park spending by 12 percent." {
+		t.Errorf("Summary = %q", resp.Summary)
+	}
+}
+
+func TestTLDRPlusMissingField(t *testing.T) {
+	llm := newFakeLLM(t, replies(`{"tldr": "Council passes budget.", "summary": " "}`))
+	service := newTestService(t, llm, Config{})
+
+	_, err := service.Summarize(context.Background(), Request{Content: testSource, Style: "tldr_plus", Format: "json"})
+	if err == nil || !strings.Contains(err.Error(), "missing the tldr or summary") {
+		t.Errorf("Summarize error = %v, want the missing field reported", err)
+	}
+}
+
+func TestBilingual(t *testing.T) {
+	const spanish = "El consejo aprobó el presupuesto; el gasto en parques sube un 12 por ciento."
+	llm := newFakeLLM(t, replies("```json\n"+`{"source_language": "Spanish", "summary": "`+spanish+`", "translation": "The council approved the budget; park spending rises 12 percent."}`+"\n```"))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.Summarize(context.Background(), Request{Content: "El consejo municipal aprobó el nuevo presupuesto el martes.", BilingualTarget: " English "})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	want := BilingualSummary{
+		SourceLanguage: "Spanish",
+		Summary:        spanish,
+		TargetLanguage: "English",
+		Translation:    "The council approved the budget; park spending rises 12 percent.",
+	}
+	if resp.Bilingual == nil || *resp.Bilingual != want {
+		t.Fatalf("Bilingual = %+v, want %+v", resp.Bilingual, want)
+	}
+	if resp.Summary != spanish {
+		t.Errorf("Summary = %q, want the source-language summary", resp.Summary)
+	}
+	if resp.Metadata["source_language"] != "Spanish" || resp.Metadata["bilingual_target"] != "English" {
+		t.Errorf("metadata = %v, want both languages", resp.Metadata)
+	}
+	requests := llm.Requests()
+	if len(requests) != 1 {
+		t.Errorf("got %d completions, want both summaries from one", len(requests))
+	}
+	if prompt := userPrompt(requests[0]); !strings.Contains(prompt, "translate it into English") {
+		t.Errorf("prompt does not ask for the translation:\n%s", prompt)
+	}
+}
+
+func TestBilingualErrors(t *testing.T) {
+	t.Run("missing translation", func(t *testing.T) {
+		llm := newFakeLLM(t, replies(`{"source_language": "Spanish", "summary": "Resumen."}`))
+		_, err := newTestService(t, llm, Config{}).Summarize(context.Background(), Request{Content: testSource, BilingualTarget: "English"})
+		if err == nil || !strings.Contains(err.Error(), "missing the summary or translation") {
+			t.Errorf("Summarize error = %v, want the missing field reported", err)
+		}
+	})
+
+	for _, req := range []Request{
+		{Content: testSource, BilingualTarget: "English", Language: "French"},
+		{Content: testSource, BilingualTarget: "English", Style: "social"},
+		{Content: testSource, BilingualTarget: "English", Format: "json"},
+	} {
+		llm := newFakeLLM(t, replies("unused"))
+		if _, err := newTestService(t, llm, Config{}).Summarize(context.Background(), req); err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+			t.Errorf("Summarize(%+v) error = %v, want the combination rejected", req, err)
+		}
+		if n := len(llm.Requests()); n != 0 {
+			t.Errorf("got %d completions for a rejected request, want none", n)
+		}
+	}
+}
+
+func TestDiscussionStyle(t *testing.T) {
+	const thread = "Post by mayor_jo: We plan a new central library. Thoughts?\n  - reader1: Yes, the old one is too small.\n    - skeptic: Renovating would be cheaper.\n  - taxpayer: Who pays for it?"
+	llm := newFakeLLM(t, replies("Most commenters back a new library; some prefer renovating."))
+	resp, err := newTestService(t, llm, Config{}).Summarize(context.Background(), Request{Content: thread, Style: "discussion"})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if resp.Summary != "Most commenters back a new library; some prefer renovating." {
+		t.Errorf("Summary = %q", resp.Summary)
+	}
+	prompt := userPrompt(llm.Requests()[0])
+	for _, want := range []string{"discussion thread", "consensus", "disagree", "Renovating would be cheaper."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt lacks %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestRequiredSections(t *testing.T) {
+	names := []string{"Overview", "Key Points", "Risks", "Next Steps"}
+	const complete = "## Overview\nThe council passed the budget.\n\n**Key Points:**\n- Parks up 12 percent\n\n## Risks\nN/A\n\n## Next Steps\nA vote on road repairs."
+	const missingRisks = "## Overview\nThe council passed the budget.\n\n## Key Points\n- Parks up 12 percent\n\n## Next Steps\nA vote on road repairs."
+	const asJSON = `{"sections": {"overview": "The council passed the budget.", "Key Points": "Parks up 12 percent", "Risks": "N/A", "Next Steps": "A vote on road repairs."}}`
+	want := map[string]string{
+		"Overview":   "The council passed the budget.",
+		"Key Points": "- Parks up 12 percent",
+		"Risks":      "N/A",
+		"Next Steps": "A vote on road repairs.",
+	}
+	tests := []struct {
+		name      string
+		format    string
+		replies   []string
+		wantCalls int
+		wantErr   string
+		want      map[string]string
+	}{
+		{name: "markdown headings", replies: []string{complete}, wantCalls: 1, want: want},
+		{name: "missing section retried", replies: []string{missingRisks, complete}, wantCalls: 2, want: want},
+		{name: "still missing after retry", replies: []string{missingRisks}, wantCalls: 2, wantErr: "missing required sections: Risks"},
+		{
+			name:      "json",
+			format:    "json",
+			replies:   []string{asJSON},
+			wantCalls: 1,
+			want:      map[string]string{"Overview": "The council passed the budget.", "Key Points": "Parks up 12 percent", "Risks": "N/A", "Next Steps": "A vote on road repairs."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, replies(tt.replies...))
+			resp, err := newTestService(t, llm, Config{}).Summarize(context.Background(), Request{
+				Content:          testSource,
+				Format:           tt.format,
+				RequiredSections: append([]string{" Overview ", "overview"}, names[1:]...),
+			})
+			if got := len(llm.Requests()); got != tt.wantCalls {
+				t.Errorf("got %d completions, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Summarize error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Summarize: %v", err)
+			}
+			for name, text := range tt.want {
+				if resp.Sections[name] != text {
+					t.Errorf("Sections[%q] = %q, want %q", name, resp.Sections[name], text)
+				}
+			}
+			if len(resp.Sections) != len(names) {
+				t.Errorf("Sections = %v, want exactly %q", resp.Sections, names)
+			}
+			// Every section appears in the summary, in order
+			rest := resp.Summary
+			for _, name := range names {
+				i := strings.Index(rest, name)
+				if i < 0 {
+					t.Fatalf("Summary = %q, want sections %q in order", resp.Summary, names)
+				}
+				rest = rest[i+len(name):]
+			}
+			if prompt := userPrompt(llm.Requests()[0]); !strings.Contains(prompt, "exactly these sections, in order: Overview, Key Points, Risks, Next Steps") {
+				t.Errorf("prompt does not list the sections:\n%s", prompt)
+			}
+		})
+	}
+
+	t.Run("rejected combinations", func(t *testing.T) {
+		for _, req := range []Request{
+			{Content: testSource, RequiredSections: names, Style: "tldr_plus"},
+			{Content: testSource, RequiredSections: names, Style: "bullet_points", Format: "json"},
+			{Content: testSource, RequiredSections: names, BilingualTarget: "English"},
+		} {
+			llm := newFakeLLM(t, replies("unused"))
+			if _, err := newTestService(t, llm, Config{}).Summarize(context.Background(), req); err == nil {
+				t.Errorf("Summarize(%+v) accepted the combination", req)
+			}
+			if n := len(llm.Requests()); n != 0 {
+				t.Errorf("got %d completions for a rejected request, want none", n)
+			}
+		}
+	})
+}
+
+func TestMergeSummaries(t *testing.T) {
+	llm := newFakeLLM(t, replies(`{"summary": "The council approved the budget, raising park spending.", "conflicts": ["One summary says 12 percent, the other 15 percent."]}`))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.MergeSummaries(context.Background(), []string{
+		"The council approved the budget. Park spending rises 12 percent.",
+		"  ",
+		"Park spending rises 15 percent after the council's budget vote.",
+	})
+	if err != nil {
+		t.Fatalf("MergeSummaries: %v", err)
+	}
+
+	prompt := userPrompt(llm.Requests()[0])
+	if !strings.Contains(prompt, "SUMMARY 1:\nThe council approved") || !strings.Contains(prompt, "SUMMARY 2:\nPark spending rises 15") {
+		t.Errorf("merge prompt does not list the non-empty inputs:\n%s", prompt)
+	}
+	if resp.Summary != "The council approved the budget, raising park spending." {
+		t.Errorf("Summary = %q", resp.Summary)
+	}
+	if resp.Metadata["merged_count"] != "2" {
+		t.Errorf("merged_count = %q, want 2", resp.Metadata["merged_count"])
+	}
+	if resp.Metadata["has_conflicts"] != "true" || !strings.Contains(resp.Metadata["conflicts"], "12 percent") {
+		t.Errorf("conflicts = %s (has_conflicts %s), want the contradiction flagged",
+			resp.Metadata["conflicts"], resp.Metadata["has_conflicts"])
+	}
+}
+
+func TestMergeSummariesNothingToMerge(t *testing.T) {
+	llm := newFakeLLM(t, replies("unused"))
+	service := newTestService(t, llm, Config{})
+
+	if _, err := service.MergeSummaries(context.Background(), []string{"", " "}); err == nil {
+		t.Error("MergeSummaries accepted only blank summaries")
+	}
+	if n := len(llm.Requests()); n != 0 {
+		t.Errorf("got %d completions, want none", n)
+	}
+}
+
+func TestSummarizeWithKeywordsRunsConcurrently(t *testing.T) {
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	bothArrived := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(bothArrived)
+	}()
+	var overlapped atomic.Bool
+	llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+		// Hold each call until the other arrives; sequential calls would time out here
+		arrived.Done()
+		select {
+		case <-bothArrived:
+			overlapped.Store(true)
+		case <-time.After(2 * time.Second):
+		}
+		if strings.Contains(req.Messages[0].Content, "extract key terms") {
+			return reply("budget, parks, road repairs")
+		}
+		return reply("The council approved the budget.")
+	})
+	service := newTestService(t, llm, Config{})
+
+	resp, keywords, err := service.SummarizeWithKeywords(context.Background(), Request{Content: testSource})
+	if err != nil {
+		t.Fatalf("SummarizeWithKeywords: %v", err)
+	}
+	if !overlapped.Load() {
+		t.Error("the summary and keyword completions did not overlap")
+	}
+	if resp.Summary != "The council approved the budget." {
+		t.Errorf("Summary = %q", resp.Summary)
+	}
+	if !slices.Equal(keywords, []string{"budget", "parks", "road repairs"}) {
+		t.Errorf("keywords = %q", keywords)
+	}
+}
+
+func TestBulletCountEnforced(t *testing.T) {
+	const two = `{"bullets": ["Budget approved.", "Parks up 12 percent."]}`
+	const three = `{"bullets": ["Budget approved.", "Parks up 12 percent.", "Roads get 4 million dollars."]}`
+
+	t.Run("retry corrects the count", func(t *testing.T) {
+		llm := newFakeLLM(t, replies(two, three))
+		service := newTestService(t, llm, Config{})
+
+		resp, err := service.Summarize(context.Background(), Request{Content: testSource, Style: "bullet_points", Format: "json", BulletCount: 3})
+		if err != nil {
+			t.Fatalf("Summarize: %v", err)
+		}
+		requests := llm.Requests()
+		if len(requests) != 2 {
+			t.Fatalf("got %d completions, want the summary and one retry", len(requests))
+		}
+		if prompt := userPrompt(requests[0]); !strings.Contains(prompt, "exactly 3 bullet points") {
+			t.Errorf("prompt does not ask for 3 bullets:\n%s", prompt)
+		}
+		if retry := userPrompt(requests[1]); !strings.Contains(retry, "expected 3 bullets, got 2") {
+			t.Errorf("retry does not explain the miscount:\n%s", retry)
+		}
+		if len(resp.Bullets) != 3 || resp.Bullets[2] != "Roads get 4 million dollars." {
+			t.Errorf("Bullets = %q, want the retried three", resp.Bullets)
+		}
+	})
+
+	t.Run("wrong count after the retry fails", func(t *testing.T) {
+		llm := newFakeLLM(t, replies(two))
+		service := newTestService(t, llm, Config{})
+
+		_, err := service.Summarize(context.Background(), Request{Content: testSource, Style: "bullet_points", Format: "json", BulletCount: 3})
+		if err == nil || !strings.Contains(err.Error(), "expected 3 bullets, got 2") {
+			t.Errorf("Summarize error = %v, want the miscount reported", err)
+		}
+		if n := len(llm.Requests()); n != 2 {
+			t.Errorf("got %d completions, want exactly one retry", n)
+		}
+	})
+}
+
+const figuresSource = `Quarterly report: revenue rose 14.7% to $3,482,000 while operating costs fell to $1.25 million.
+The company shipped 12,904 units across 38 countries and ended the quarter with 412 employees.`
+
+func TestPreserveNumbers(t *testing.T) {
+	t.Run("figures pinned and kept", func(t *testing.T) {
+		llm := newFakeLLM(t, replies("Revenue rose 14.7% to $3,482,000 on 12,904 units shipped to 38 countries."))
+		service := newTestService(t, llm, Config{})
+
+		resp, err := service.Summarize(context.Background(), Request{Content: figuresSource, PreserveNumbers: true})
+		if err != nil {
+			t.Fatalf("Summarize: %v", err)
+		}
+		prompt := userPrompt(llm.Requests()[0])
+		instructions := prompt[:strings.LastIndex(prompt, figuresSource)]
+		for _, figure := range []string{"14.7%", "$3,482,000", "$1.25", "12,904", "38", "412"} {
+			if !strings.Contains(instructions, figure) {
+				t.Errorf("instructions do not pin %s:\n%s", figure, instructions)
+			}
+		}
+		if resp.Metadata["numbers_checked"] != "true" || resp.Metadata["unsupported_numbers"] != "[]" {
+			t.Errorf("numbers_checked = %s, unsupported_numbers = %s; want true and []",
+				resp.Metadata["numbers_checked"], resp.Metadata["unsupported_numbers"])
+		}
+	})
+
+	t.Run("altered figures flagged", func(t *testing.T) {
+		llm := newFakeLLM(t, replies("Revenue rose about 15% to $3.5 million on 12,904 units."))
+		service := newTestService(t, llm, Config{})
+
+		resp, err := service.Summarize(context.Background(), Request{Content: figuresSource, PreserveNumbers: true})
+		if err != nil {
+			t.Fatalf("Summarize: %v", err)
+		}
+		if got := resp.Metadata["unsupported_numbers"]; got != `["15%","$3.5"]` {
+			t.Errorf("unsupported_numbers = %s, want the rounded figures", got)
+		}
+	})
+}
+
+func TestReadingLevel(t *testing.T) {
+	const summary = "The cat sat on the mat. It was warm."
+	t.Run("target grade", func(t *testing.T) {
+		llm := newFakeLLM(t, replies(summary))
+		service := newTestService(t, llm, Config{})
+
+		resp, err := service.Summarize(context.Background(), Request{Content: testSource, ReadingLevel: 8})
+		if err != nil {
+			t.Fatalf("Summarize: %v", err)
+		}
+		if prompt := userPrompt(llm.Requests()[0]); !strings.Contains(prompt, "US grade 8 reading level") {
+			t.Errorf("prompt does not ask for the reading level:\n%s", prompt)
+		}
+		if resp.Metadata["reading_level_target"] != "8" {
+			t.Errorf("reading_level_target = %q, want 8", resp.Metadata["reading_level_target"])
+		}
+		if want := fmt.Sprintf("%.1f", fleschKincaidGrade(summary)); resp.Metadata["flesch_kincaid_grade"] != want {
+			t.Errorf("flesch_kincaid_grade = %q, want %s", resp.Metadata["flesch_kincaid_grade"], want)
+		}
+	})
+
+	t.Run("no constraint by default", func(t *testing.T) {
+		llm := newFakeLLM(t, replies(summary))
+		service := newTestService(t, llm, Config{})
+
+		resp, err := service.Summarize(context.Background(), Request{Content: testSource})
+		if err != nil {
+			t.Fatalf("Summarize: %v", err)
+		}
+		if prompt := userPrompt(llm.Requests()[0]); strings.Contains(prompt, "reading level") {
+			t.Errorf("prompt asks for a reading level unprompted:\n%s", prompt)
+		}
+		if _, ok := resp.Metadata["flesch_kincaid_grade"]; ok {
+			t.Error("flesch_kincaid_grade computed without a ReadingLevel")
+		}
+	})
+}
+
+func TestFocus(t *testing.T) {
+	tests := []struct {
+		name  string
+		focus string
+		want  bool
+	}{
+		{name: "focused", focus: "  pricing and availability ", want: true},
+		{name: "general by default"},
+		{name: "blank focus", focus: "   "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, replies("It costs $10 and ships in May."))
+			service := newTestService(t, llm, Config{})
+
+			resp, err := service.Summarize(context.Background(), Request{Content: testSource, Focus: tt.focus})
+			if err != nil {
+				t.Fatalf("Summarize: %v", err)
+			}
+			prompt := userPrompt(llm.Requests()[0])
+			if got := strings.Contains(prompt, "Focus the summary on pricing and availability:"); got != tt.want {
+				t.Errorf("prompt carries the focus = %v, want %v:\n%s", got, tt.want, prompt)
+			}
+			if got := resp.Metadata["focused"] == "true"; got != tt.want {
+				t.Errorf("focused = %q, want %v", resp.Metadata["focused"], tt.want)
+			}
+			if tt.want && resp.Metadata["focus"] != "pricing and availability" {
+				t.Errorf("focus = %q, want the trimmed focus", resp.Metadata["focus"])
+			}
+		})
+	}
+}
+
+func TestFleschKincaidGrade(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+	}{
+		// 6 words, 1 sentence, 6 syllables
+		{"The cat sat on the mat.", 0.39*6 + 11.8*1 - 15.59},
+		// 7 words, 2 sentences, 9 syllables ("table" and "little" keep their final "le")
+		{"Put the table here. Make it little.", 0.39*7/2 + 11.8*9/7 - 15.59},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := fleschKincaidGrade(tt.text); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("fleschKincaidGrade(%q) = %g, want %g", tt.text, got, tt.want)
+		}
+	}
+
+	simple := fleschKincaidGrade("We ran. The dog ran too. It was fun.")
+	dense := fleschKincaidGrade("Comprehensive institutional considerations necessitate extraordinarily deliberate organizational restructuring.")
+	if simple >= dense {
+		t.Errorf("simple text scored %g, not below dense text's %g", simple, dense)
+	}
+}
+
+func TestRetryAfterHonored(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "slow down", "type": "rate_limit_exceeded"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(reply("Council passed the budget."))
+	}))
+	t.Cleanup(server.Close)
+	service, err := NewService(Config{
+		APIKey:  "test-key",
+		BaseURL: server.URL + "/v1",
+		Model:   "test-model",
+		// The backoff alone would retry at once
+		Retry: retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := service.Summarize(context.Background(), Request{Content: testSource}); err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the 1s the provider asked for", elapsed)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("made %d requests, want 2", got)
+	}
+}
+
+func TestProviderShapeNormalization(t *testing.T) {
+	tests := []struct {
+		name          string
+		model         string
+		usage         openai.Usage
+		wantModel     string
+		wantEstimated bool
+		wantTokens    int // zero means any positive estimate
+	}{
+		{name: "complete payload", model: "provider-model-v2", usage: openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, wantModel: "provider-model-v2", wantTokens: 15},
+		{name: "missing model", usage: openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, wantModel: "test-model", wantTokens: 15},
+		{name: "missing total", model: "test-model", usage: openai.Usage{PromptTokens: 10, CompletionTokens: 5}, wantModel: "test-model", wantTokens: 15},
+		{name: "missing usage", wantModel: "test-model", wantEstimated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+				resp := reply("The council approved the budget.")
+				resp.Model = tt.model
+				resp.Usage = tt.usage
+				return resp
+			})
+			service := newTestService(t, llm, Config{})
+
+			resp, err := service.Summarize(context.Background(), Request{Content: testSource})
+			if err != nil {
+				t.Fatalf("Summarize: %v", err)
+			}
+			if resp.Model != tt.wantModel {
+				t.Errorf("Model = %q, want %q", resp.Model, tt.wantModel)
+			}
+			if got := resp.Metadata["usage_estimated"]; got != fmt.Sprint(tt.wantEstimated) {
+				t.Errorf("usage_estimated = %s, want %t", got, tt.wantEstimated)
+			}
+			if tt.wantTokens > 0 && resp.TokensUsed != tt.wantTokens {
+				t.Errorf("TokensUsed = %d, want %d", resp.TokensUsed, tt.wantTokens)
+			}
+			if resp.TokensUsed <= 0 || resp.TokensUsed != resp.PromptTokens+resp.CompletionTokens {
+				t.Errorf("tokens = %d total, %d prompt, %d completion; want consistent positive counts",
+					resp.TokensUsed, resp.PromptTokens, resp.CompletionTokens)
+			}
+		})
+	}
+}
+
+func TestExtractEntities(t *testing.T) {
+	content := "Tim Cook said Apple will open a new office in Austin next year to build the iPhone. apple declined further comment."
+	llm := newFakeLLM(t, replies("```json\n"+`{"entities": [
+		{"text": "Tim Cook", "type": "Person"},
+		{"text": "Apple", "type": "organization"},
+		{"text": "Austin", "type": "location"},
+		{"text": "iPhone", "type": "product"},
+		{"text": "apple", "type": "organization"},
+		{"text": "next year", "type": "date"},
+		{"text": " ", "type": "person"}
+	]}`+"\n```"))
+	service := newTestService(t, llm, Config{})
+
+	entities, err := service.ExtractEntities(context.Background(), content)
+	if err != nil {
+		t.Fatalf("ExtractEntities: %v", err)
+	}
+	want := []Entity{
+		{Text: "Tim Cook", Type: "person"},
+		{Text: "Apple", Type: "organization"},
+		{Text: "Austin", Type: "location"},
+		{Text: "iPhone", Type: "product"},
+	}
+	if !slices.Equal(entities, want) {
+		t.Errorf("entities = %+v, want %+v", entities, want)
+	}
+	if prompt := llm.Requests()[0].Messages[1].Content; !strings.Contains(prompt, content) {
+		t.Errorf("prompt does not carry the content: %q", prompt)
+	}
+}
+
+func TestExtractEntitiesInvalidJSON(t *testing.T) {
+	llm := newFakeLLM(t, replies("Tim Cook (person), Apple (organization)"))
+	service := newTestService(t, llm, Config{})
+
+	if _, err := service.ExtractEntities(context.Background(), testSource); err == nil || !strings.Contains(err.Error(), "failed to parse entities") {
+		t.Errorf("ExtractEntities error = %v, want a parse failure", err)
+	}
+}
+
+func TestSummarizeStream(t *testing.T) {
+	summary := "The council approved the budget on Tuesday."
+	tests := []struct {
+		name       string
+		req        Request
+		noStream   bool
+		wantChunks int
+		wantStream bool
+	}{
+		{name: "streams token by token", req: Request{Content: testSource}, wantChunks: len(strings.Fields(summary)), wantStream: true},
+		{name: "provider cannot stream", req: Request{Content: testSource}, noStream: true, wantChunks: 1},
+		{name: "style needs the whole reply", req: Request{Content: testSource, Style: "social"}, wantChunks: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, replies(summary))
+			llm.noStream = tt.noStream
+			service := newTestService(t, llm, Config{})
+
+			var chunks []string
+			resp, err := service.SummarizeStream(context.Background(), tt.req, func(token string) {
+				chunks = append(chunks, token)
+			})
+			if err != nil {
+				t.Fatalf("SummarizeStream: %v", err)
+			}
+			if len(chunks) != tt.wantChunks || strings.Join(chunks, "") != summary {
+				t.Errorf("chunks = %q, want %d adding up to %q", chunks, tt.wantChunks, summary)
+			}
+			if resp.Summary != summary {
+				t.Errorf("Summary = %q, want %q", resp.Summary, summary)
+			}
+			if streamed := resp.Metadata["streamed"] == "true"; streamed != tt.wantStream {
+				t.Errorf("streamed = %v, want %v", streamed, tt.wantStream)
+			}
+			if resp.TokensUsed == 0 {
+				t.Error("TokensUsed = 0, want the completion counted")
+			}
+		})
+	}
+}
+
+func TestLexicalCoverage(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+		min     float64
+		max     float64
+	}{
+		{"faithful summary", "The city council approved the budget on Tuesday, raising park spending by 12 percent.", 0.4, 1},
+		{"off-topic summary", "A recipe for banana bread with walnuts and cinnamon.", 0, 0.05},
+		{"empty summary", "", 0, 0},
+		{"copy of the source", testSource, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := lexicalCoverage(testSource, tt.summary)
+			if score < tt.min || score > tt.max {
+				t.Errorf("lexicalCoverage = %.2f, want between %.2f and %.2f", score, tt.min, tt.max)
+			}
+		})
+	}
+
+	faithful := lexicalCoverage(testSource, tests[0].summary)
+	offTopic := lexicalCoverage(testSource, tests[1].summary)
+	if faithful <= offTopic {
+		t.Errorf("faithful summary scored %.2f, not above the off-topic %.2f", faithful, offTopic)
+	}
+}
+
+func TestCoverageScoreFallsBackToLexical(t *testing.T) {
+	llm := newFakeLLM(t, replies("The city council approved the budget on Tuesday."))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.Summarize(context.Background(), Request{Content: testSource, ScoreCoverage: true})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if resp.Metadata["coverage_method"] != "lexical" {
+		t.Errorf("coverage_method = %q, want lexical without an embeddings model", resp.Metadata["coverage_method"])
+	}
+	want := fmt.Sprintf("%.2f", lexicalCoverage(testSource, resp.Summary))
+	if resp.Metadata["coverage_score"] != want {
+		t.Errorf("coverage_score = %q, want %q", resp.Metadata["coverage_score"], want)
+	}
+}
+
+func TestAuditLogCapturesPrompts(t *testing.T) {
+	llm := newFakeLLM(t, replies("The council approved the budget."))
+	var log bytes.Buffer
+	service := newTestService(t, llm, Config{AuditLog: &log, AuditMaxContent: 40})
+
+	if _, err := service.Summarize(context.Background(), Request{Content: testSource}); err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+
+	var entry audit.Entry
+	if err := json.Unmarshal(log.Bytes(), &entry); err != nil {
+		t.Fatalf("audit log is not one JSON entry: %v\n%s", err, log.String())
+	}
+	if entry.Component != "summarizer" || entry.Response == nil || entry.Response.Choices[0].Message.Content != "The council approved the budget." {
+		t.Errorf("entry = %+v, want the summarizer's request and response", entry)
+	}
+	prompt := entry.Request.Messages[len(entry.Request.Messages)-1].Content
+	if !strings.Contains(prompt, " [truncated ") || utf8.RuneCountInString(prompt) > 80 {
+		t.Errorf("audited prompt = %q, want it captured and truncated to 40 characters", prompt)
+	}
+}
+
+func TestSocialPostLengthCap(t *testing.T) {
+	overlong := strings.Repeat("Council approves budget with more money for parks. ", 20) + "#budget"
+	tests := []struct {
+		platform string
+		limit    int
+	}{
+		{"twitter", 280},
+		{"linkedin", 700},
+		{"short", 160},
+		{"", 160},
+		{"myspace", 160},
+	}
+	for _, tt := range tests {
+		t.Run("platform "+tt.platform, func(t *testing.T) {
+			// The model ignores the limit, even when asked again
+			llm := newFakeLLM(t, replies(overlong))
+			service := newTestService(t, llm, Config{})
+
+			resp, err := service.Summarize(context.Background(), Request{Content: testSource, Style: "social", Platform: tt.platform})
+			if err != nil {
+				t.Fatalf("Summarize: %v", err)
+			}
+			if n := utf8.RuneCountInString(resp.Summary); n > tt.limit {
+				t.Errorf("post has %d characters, over the %d limit: %q", n, tt.limit, resp.Summary)
+			}
+			if !strings.HasSuffix(resp.Summary, "…") {
+				t.Errorf("post %q is not marked as cut", resp.Summary)
+			}
+			if resp.Metadata["char_limit"] != strconv.Itoa(tt.limit) {
+				t.Errorf("char_limit = %q, want %d", resp.Metadata["char_limit"], tt.limit)
+			}
+			requests := llm.Requests()
+			if len(requests) != 2 {
+				t.Fatalf("got %d completions, want the post and one shortening retry", len(requests))
+			}
+			if correction := requests[1].Messages[len(requests[1].Messages)-1].Content; !strings.Contains(correction, fmt.Sprintf("at most %d characters", tt.limit)) {
+				t.Errorf("retry prompt = %q, want the platform's limit", correction)
+			}
+			if resp.TokensUsed != 30 {
+				t.Errorf("TokensUsed = %d, want both completions counted", resp.TokensUsed)
+			}
+		})
+	}
+}
+
+func TestSocialPostShortenedOnRetry(t *testing.T) {
+	shorter := "Council approves budget; parks get 12% more. #budget"
+	llm := newFakeLLM(t, replies(strings.Repeat("Council approves budget. ", 20), shorter))
+	service := newTestService(t, llm, Config{})
+
+	resp, err := service.Summarize(context.Background(), Request{Content: testSource, Style: "social", Platform: "twitter"})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if resp.Summary != shorter {
+		t.Errorf("Summary = %q, want the retried post %q", resp.Summary, shorter)
+	}
+}
+
+func TestSummarizeChunksBoundsMemory(t *testing.T) {
+	const chunkCount = 40
+	llm := newFakeLLM(t, replies("A short partial summary."))
+	service := newTestService(t, llm, Config{})
+
+	// Each chunk is only produced when asked for, so the number of chunks pulled
+	// ahead of the summaries shows how much content is held at once
+	var pulled, maxAhead int
+	chunks := func(yield func(string, error) bool) {
+		for pulled < chunkCount {
+			pulled++
+			summarized := len(llm.Requests())
+			maxAhead = max(maxAhead, pulled-summarized)
+			if !yield(strings.Repeat(fmt.Sprintf("Chunk %d reports one more fact about the budget. ", pulled), 5), nil) {
+				return
+			}
+		}
+	}
+
+	response, err := service.SummarizeChunks(context.Background(), Request{Style: "concise", MaxLength: 100}, chunks)
+	if err != nil {
+		t.Fatalf("SummarizeChunks: %v", err)
+	}
+	if maxAhead > 2 {
+		t.Errorf("up to %d chunks were pulled before being summarized, want at most 2", maxAhead)
+	}
+	// The first 8 partials are condensed, then each 7 more: after chunks 8, 15, 22, 29, and 36
+	wantMeta := map[string]string{"chunks": "40", "reduce_rounds": "5", "max_partials": strconv.Itoa(mapReduceFanIn)}
+	for key, want := range wantMeta {
+		if got := response.Metadata[key]; got != want {
+			t.Errorf("Metadata[%q] = %q, want %q", key, got, want)
+		}
+	}
+	requests := llm.Requests()
+	if len(requests) != chunkCount+5+1 {
+		t.Errorf("made %d completions, want one per chunk, one per reduce round, and the final one", len(requests))
+	}
+	// No prompt grows with the content: each holds a chunk or a few partial summaries
+	for i, req := range requests {
+		if size := len(userPrompt(req)); size > 2000 {
+			t.Errorf("completion %d has a %d byte prompt", i+1, size)
+		}
+	}
+	if response.Summary != "A short partial summary." || response.TokensUsed != 15*len(requests) {
+		t.Errorf("response = %q using %d tokens, want the final summary with every completion's usage", response.Summary, response.TokensUsed)
+	}
+}
+
+func TestSummarizeChunksSingleChunk(t *testing.T) {
+	llm := newFakeLLM(t, replies("Summary."))
+	service := newTestService(t, llm, Config{})
+	chunks := func(yield func(string, error) bool) {
+		yield(testSource, nil)
+	}
+	response, err := service.SummarizeChunks(context.Background(), Request{Style: "concise"}, chunks)
+	if err != nil {
+		t.Fatalf("SummarizeChunks: %v", err)
+	}
+	if requests := llm.Requests(); len(requests) != 1 || !strings.Contains(userPrompt(requests[0]), testSource) {
+		t.Errorf("made %d completions, want the single chunk summarized directly", len(requests))
+	}
+	if response.Metadata["chunks"] != "1" || response.Metadata["reduce_rounds"] != "0" {
+		t.Errorf("Metadata = %v, want one chunk and no reduce rounds", response.Metadata)
+	}
+
+	errFetch := errors.New("connection reset")
+	failing := func(yield func(string, error) bool) {
+		if yield(testSource, nil) {
+			yield("", errFetch)
+		}
+	}
+	if _, err := service.SummarizeChunks(context.Background(), Request{}, failing); !errors.Is(err, errFetch) {
+		t.Errorf("SummarizeChunks error = %v, want the stream's error", err)
+	}
+}
+
+func TestDebugRaw(t *testing.T) {
+	// Vision, summary, revision, and faithfulness completions, in the order Summarize makes them
+	contents := []string{"A chart of rising park spending.", "Draft summary.", "Revised summary.", `{"unsupported_claims": []}`}
+	respond := func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+		resp := reply(contents[min(call, len(contents)-1)])
+		resp.ID = fmt.Sprintf("chatcmpl-%d", call)
+		if call == 1 {
+			resp.Choices[0].FinishReason = openai.FinishReasonLength
+		}
+		return resp
+	}
+	req := Request{Content: testSource, TwoPass: true, VerifyFaithfulness: true, ImageURL: "https://example.com/chart.png"}
+
+	t.Run("off by default", func(t *testing.T) {
+		service := newTestService(t, newFakeLLM(t, respond), Config{VisionModel: "test-vision"})
+		response, err := service.Summarize(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Summarize: %v", err)
+		}
+		if response.Debug != nil {
+			t.Errorf("Debug = %+v without DebugRaw", response.Debug)
+		}
+	})
+
+	t.Run("every completion captured", func(t *testing.T) {
+		service := newTestService(t, newFakeLLM(t, respond), Config{VisionModel: "test-vision", DebugRaw: true})
+		response, err := service.Summarize(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Summarize: %v", err)
+		}
+		want := []RawCompletion{
+			{Purpose: "image_description", ID: "chatcmpl-0", Content: contents[0]},
+			{Purpose: "summary", ID: "chatcmpl-1", Content: contents[1], FinishReason: "length"},
+			{Purpose: "revision", ID: "chatcmpl-2", Content: contents[2]},
+			{Purpose: "faithfulness", ID: "chatcmpl-3", Content: contents[3]},
+		}
+		if len(response.Debug) != len(want) {
+			t.Fatalf("Debug = %+v, want %d completions", response.Debug, len(want))
+		}
+		for i, w := range want {
+			if w.FinishReason == "" {
+				w.FinishReason = "stop"
+			}
+			got := response.Debug[i]
+			if got.Purpose != w.Purpose || got.ID != w.ID || got.Content != w.Content || got.FinishReason != w.FinishReason {
+				t.Errorf("Debug[%d] = %+v, want %+v", i, got, w)
+			}
+			if got.Model != "test-model" || got.PromptTokens != 10 || got.CompletionTokens != 5 || got.TotalTokens != 15 || got.UsageEstimated {
+				t.Errorf("Debug[%d] = %+v, want the reported model and usage", i, got)
+			}
+		}
+	})
+
+	t.Run("reply without choices", func(t *testing.T) {
+		llm := newFakeLLM(t, func(call int, req openai.ChatCompletionRequest) openai.ChatCompletionResponse {
+			return openai.ChatCompletionResponse{ID: "chatcmpl-empty", Model: "test-model", Usage: openai.Usage{PromptTokens: 10, TotalTokens: 10}}
+		})
+		service := newTestService(t, llm, Config{DebugRaw: true})
+
+		_, err := service.Summarize(context.Background(), Request{Content: testSource})
+		var replyErr *ReplyError
+		if !errors.As(err, &replyErr) || !strings.Contains(err.Error(), "no response choices") {
+			t.Fatalf("Summarize error = %v, want a ReplyError", err)
+		}
+		if len(replyErr.Debug) != 1 || replyErr.Debug[0].ID != "chatcmpl-empty" || replyErr.Debug[0].PromptTokens != 10 {
+			t.Errorf("ReplyError.Debug = %+v, want the empty reply", replyErr.Debug)
+		}
+
+		_, err = service.SummarizeMultiple(context.Background(), MultiRequest{Sources: []Source{{Name: "a", Content: testSource}, {Name: "b", Content: testSource}}})
+		if !errors.As(err, &replyErr) || len(replyErr.Debug) != 1 || replyErr.Debug[0].ID != "chatcmpl-empty" {
+			t.Errorf("SummarizeMultiple error = %v, want a ReplyError with the empty reply", err)
+		}
+	})
+
+	t.Run("unusable reply", func(t *testing.T) {
+		service := newTestService(t, newFakeLLM(t, replies("I can't help with that.")), Config{DebugRaw: true})
+		_, err := service.Summarize(context.Background(), Request{Content: testSource, Style: "tldr_plus"})
+		var replyErr *ReplyError
+		if !errors.As(err, &replyErr) || len(replyErr.Debug) != 1 || replyErr.Debug[0].Content != "I can't help with that." {
+			t.Errorf("Summarize error = %v, want a ReplyError with the refusal", err)
+		}
+	})
+}
+
+func TestSummarizeMultipleDiversity(t *testing.T) {
+	sources := []Source{
+		{Name: "Daily Gazette", Content: "The new stadium will bring jobs and tourism to the city, officials said."},
+		{Name: "City Wire", Content: "Officials said the stadium will create jobs and draw tourists."},
+		{Name: "Metro News", Content: "The stadium promises jobs and a tourism boost, according to the mayor."},
+		{Name: "Tenants' Blog", Content: "Residents near the site fear rent increases and displacement once the stadium opens."},
+	}
+	tests := []struct {
+		name        string
+		reply       string
+		wantCounts  map[string]int
+		wantUncited []string
+	}{
+		{
+			name:        "minority view kept",
+			reply:       "Officials expect the stadium to add jobs and tourism [S1, S2, S3]. A minority view, held only by local residents, warns of rising rents and displacement [S4].",
+			wantCounts:  map[string]int{"Daily Gazette": 1, "City Wire": 1, "Metro News": 1, "Tenants' Blog": 1},
+			wantUncited: []string{},
+		},
+		{
+			name:        "minority view dropped",
+			reply:       "Officials expect the stadium to add jobs and tourism [S1, S2, S3] [S1].",
+			wantCounts:  map[string]int{"Daily Gazette": 2, "City Wire": 1, "Metro News": 1, "Tenants' Blog": 0},
+			wantUncited: []string{"Tenants' Blog"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(t, replies(tt.reply))
+			service := newTestService(t, llm, Config{})
+			response, err := service.SummarizeMultiple(context.Background(), MultiRequest{Sources: sources, Diversity: true})
+			if err != nil {
+				t.Fatalf("SummarizeMultiple: %v", err)
+			}
+
+			prompt := userPrompt(llm.Requests()[0])
+			for _, want := range []string{"Explicitly note minority viewpoints", "[S1] or [S2, S4]", "SOURCE S4 (Tenants' Blog):\n" + sources[3].Content} {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt is missing %q:\n%s", want, prompt)
+				}
+			}
+
+			var counts map[string]int
+			var uncited []string
+			if err := json.Unmarshal([]byte(response.Metadata["source_inclusion"]), &counts); err != nil || !reflect.DeepEqual(counts, tt.wantCounts) {
+				t.Errorf("source_inclusion = %s, want %v", response.Metadata["source_inclusion"], tt.wantCounts)
+			}
+			if err := json.Unmarshal([]byte(response.Metadata["uncited_sources"]), &uncited); err != nil || !reflect.DeepEqual(uncited, tt.wantUncited) {
+				t.Errorf("uncited_sources = %s, want %q", response.Metadata["uncited_sources"], tt.wantUncited)
+			}
+			if want := fmt.Sprintf("%d", len(sources)-len(tt.wantUncited)); response.Metadata["sources_cited"] != want {
+				t.Errorf("sources_cited = %q, want %s", response.Metadata["sources_cited"], want)
+			}
+		})
+	}
+
+	t.Run("off", func(t *testing.T) {
+		llm := newFakeLLM(t, replies("The stadium brings jobs, tourism, and rent worries."))
+		response, err := newTestService(t, llm, Config{}).SummarizeMultiple(context.Background(), MultiRequest{Sources: sources})
+		if err != nil {
+			t.Fatalf("SummarizeMultiple: %v", err)
+		}
+		if strings.Contains(userPrompt(llm.Requests()[0]), "minority viewpoints") {
+			t.Error("prompt asks for balanced coverage without Diversity")
+		}
+		if _, ok := response.Metadata["source_inclusion"]; ok || response.Metadata["diversity"] != "false" {
+			t.Errorf("Metadata = %v, want no inclusion counts without Diversity", response.Metadata)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// SummarizeStream is Summarize with the summary delivered to onToken as it is
+// generated. Requests whose reply must be complete before it can be shown (JSON
+// output, the tldr_plus and social styles, required sections, bilingual and
+// two-pass summaries, and pages with an image to describe) run through Summarize
+// and deliver the finished summary in one piece, as does a provider that cannot
+// stream. Streamed usage is estimated from text length.
+func (s *Service) SummarizeStream(ctx context.Context, req Request, onToken func(string)) (*Response, error) {
+	if req.MaxLength == 0 {
+		req.MaxLength = 200
+	}
+	if req.Style == "" {
+		req.Style = "concise"
+	}
+	if !s.streamable(req) {
+		return s.summarizeBuffered(ctx, req, onToken)
+	}
+
+	s.logger.Info().
+		Int("content_length", len(req.Content)).
+		Int("max_length", req.MaxLength).
+		Str("style", req.Style).
+		Msg("Starting streamed summarization")
+
+	chatReq := s.summaryRequest(req)
+	chatReq.Stream = true
+	start := time.Now()
+	stream, err := s.client.CreateChatCompletionStream(ctx, chatReq)
+	if err != nil {
+		s.recordAudit(start, chatReq, openai.ChatCompletionResponse{}, err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to create chat completion: %w", err)
+		}
+		s.logger.Warn().Err(err).Msg("Streaming unavailable; falling back to a buffered summary")
+		return s.summarizeBuffered(ctx, req, onToken)
+	}
+	defer stream.Close()
+
+	var out strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			s.recordAudit(start, chatReq, openai.ChatCompletionResponse{}, err)
+			return nil, fmt.Errorf("summary stream failed: %w", err)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			out.WriteString(delta)
+			onToken(delta)
+		}
+	}
+
+	// Audit and count the streamed reply as if it had arrived in one piece
+	streamed := openai.ChatCompletionResponse{
+		Model: chatReq.Model,
+		Choices: []openai.ChatCompletionChoice{
+			{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: out.String()}},
+		},
+	}
+	s.recordAudit(start, chatReq, streamed, nil)
+	streamed.Usage = estimateUsage(chatReq, streamed)
+
+	summary := strings.TrimSpace(out.String())
+	response := &Response{
+		Summary:      summary,
+		OriginalSize: len(req.Content),
+		SummarySize:  len(summary),
+		Model:        streamed.Model,
+		Metadata: map[string]string{
+			"style":           req.Style,
+			"language":        req.Language,
+			"passes":          "1",
+			"usage_estimated": "true",
+			"streamed":        "true",
+		},
+	}
+	if s.config.DebugRaw {
+		response.Debug = append(response.Debug, rawCompletion("summary", streamed, true))
+	}
+	response.addUsage(s.config, streamed.Model, streamed.Usage)
+	s.annotate(ctx, req, response)
+
+	s.logger.Info().
+		Int("original_size", response.OriginalSize).
+		Int("summary_size", response.SummarySize).
+		Int("tokens_used", response.TokensUsed).
+		Str("model", response.Model).
+		Msg("Streamed summarization completed")
+	return response, nil
+}
+
+// streamable reports whether req's summary can be shown as it is generated,
+// rather than needing the whole reply to be parsed, checked, or revised first
+func (s *Service) streamable(req Request) bool {
+	switch {
+	case req.Format == "json", req.Style == "tldr_plus", req.Style == "social":
+		return false
+	case len(req.RequiredSections) > 0, req.BilingualTarget != "", req.TwoPass:
+		return false
+	case req.ImageURL != "" && s.config.VisionModel != "":
+		return false
+	}
+	return true
+}
+
+// summarizeBuffered runs Summarize and delivers its summary to onToken in one piece
+func (s *Service) summarizeBuffered(ctx context.Context, req Request, onToken func(string)) (*Response, error) {
+	response, err := s.Summarize(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	onToken(response.Summary)
+	return response, nil
+}
@@ -0,0 +1,42 @@
+// Package textutil holds small text helpers shared across the module.
+package textutil
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Ellipsis marks text cut short by TruncateRunes
+const Ellipsis = "..."
+
+// TruncateRunes shortens s to its first n characters (runes, so multibyte text is
+// never split mid-character) followed by Ellipsis. Text of at most n characters is
+// returned unchanged, as is any text when n is zero or less.
+func TruncateRunes(s string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i] + Ellipsis
+		}
+		count++
+	}
+	return s
+}
+
+// TruncateWords shortens s to at most n characters, counting the "…" that marks
+// the cut. The cut falls on the last space in the kept text when that keeps more
+// than half of it, and trailing punctuation before the mark is dropped. Text of at
+// most n characters is returned unchanged, as is any text when n is zero or less.
+func TruncateWords(s string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	cut := string([]rune(s)[:n-1])
+	if i := strings.LastIndexAny(cut, " \n\t"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n\t,;:") + "…"
+}
@@ -0,0 +1,69 @@
+package textutil
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{name: "shorter", s: "hello", n: 10, want: "hello"},
+		{name: "exact length", s: "hello", n: 5, want: "hello"},
+		{name: "cut", s: "hello world", n: 5, want: "hello..."},
+		{name: "no limit", s: "hello", n: 0, want: "hello"},
+		{name: "negative limit", s: "hello", n: -3, want: "hello"},
+		{name: "empty", s: "", n: 3, want: ""},
+		{name: "multibyte exact", s: "héllo", n: 5, want: "héllo"},
+		{name: "multibyte cut after", s: "日本語のテキスト", n: 3, want: "日本語..."},
+		{name: "multibyte cut before", s: "ab日本", n: 3, want: "ab日..."},
+		{name: "emoji", s: "🙂🙂🙂", n: 2, want: "🙂🙂..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateRunes(tt.s, tt.n)
+			if got != tt.want {
+				t.Errorf("TruncateRunes(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateRunes(%q, %d) = %q split a character", tt.s, tt.n, got)
+			}
+		})
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{name: "shorter", s: "Big news today", n: 20, want: "Big news today"},
+		{name: "exact length", s: "Big news today", n: 14, want: "Big news today"},
+		{name: "no limit", s: "Big news today", n: 0, want: "Big news today"},
+		{name: "word boundary", s: "The council approved the budget", n: 20, want: "The council…"},
+		{name: "trailing punctuation", s: "Parks, libraries, roads and more", n: 19, want: "Parks, libraries…"},
+		{name: "no late space", s: "Supercalifragilistic word", n: 10, want: "Supercali…"},
+		{name: "multibyte", s: "Café crème brûlée à la carte", n: 15, want: "Café crème…"},
+		{name: "multibyte without spaces", s: "日本語のテキストです", n: 5, want: "日本語の…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateWords(tt.s, tt.n)
+			if got != tt.want {
+				t.Errorf("TruncateWords(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+			if tt.n > 0 && utf8.RuneCountInString(got) > tt.n {
+				t.Errorf("TruncateWords(%q, %d) = %q is over the limit", tt.s, tt.n, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateWords(%q, %d) = %q split a character", tt.s, tt.n, got)
+			}
+		})
+	}
+}